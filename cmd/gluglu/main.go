@@ -12,6 +12,27 @@ func main() {
 	// Инициализация логгера
 	log.SetOutput(os.Stdout)
 	log.SetPrefix("[GluGlu] ")
+
+	// gluglu reader URL — сохранение статьи в режиме чтения без окна браузера
+	if len(os.Args) > 1 && os.Args[1] == "reader" {
+		// Стандартный вывод занят статьей, поэтому журнал пишется в stderr
+		log.SetOutput(os.Stderr)
+		if err := runReader(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// gluglu render URL — текстовый результат рендеринга страницы для одной
+	// или нескольких областей просмотра
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		log.SetOutput(os.Stderr)
+		if err := runRender(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	log.Println("Запуск браузера GluGlu...")
 
 	// Инициализация UI
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser"
+	"github.com/baneronetwo/gluglu/internal/browser/reader"
+)
+
+// runReader выполняет команду «gluglu reader»: загружает страницу и
+// сохраняет статью в режиме чтения, без оформления и навигации сайта
+func runReader(args []string) error {
+	flags := flag.NewFlagSet("reader", flag.ContinueOnError)
+	text := flags.Bool("text", false, "вывести текст статьи вместо HTML")
+	output := flags.String("o", "", "файл для сохранения статьи (по умолчанию стандартный вывод)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Использование: gluglu reader [-text] [-o файл] URL")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("нужно указать один URL")
+	}
+
+	b := browser.NewBrowser()
+	if err := b.LoadURL(flags.Arg(0)); err != nil {
+		return fmt.Errorf("ошибка загрузки страницы: %w", err)
+	}
+	article, err := b.ReaderView()
+	if err != nil {
+		return fmt.Errorf("ошибка извлечения статьи: %w", err)
+	}
+
+	result := article.HTML()
+	if *text {
+		result = articleText(article)
+	}
+	if *output == "" {
+		_, err = fmt.Fprint(os.Stdout, result)
+		return err
+	}
+	if err := os.WriteFile(*output, []byte(result), 0o644); err != nil {
+		return fmt.Errorf("ошибка записи %s: %w", *output, err)
+	}
+	return nil
+}
+
+// articleText возвращает статью в виде текста с заголовком, автором и датой
+func articleText(article *reader.Article) string {
+	var sb strings.Builder
+	sb.WriteString(article.Title + "\n")
+	if article.Byline != "" {
+		sb.WriteString(article.Byline + "\n")
+	}
+	if !article.Published.IsZero() {
+		sb.WriteString(article.Published.Format("02.01.2006") + "\n")
+	}
+	sb.WriteString("\n" + article.Text + "\n")
+	return sb.String()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser"
+	"github.com/baneronetwo/gluglu/internal/browser/style"
+)
+
+// viewportPresets — размеры области просмотра типичных устройств
+var viewportPresets = map[string][2]float64{
+	"phone":   {375, 667},
+	"tablet":  {768, 1024},
+	"desktop": {1280, 800},
+}
+
+// runRender выполняет команду «gluglu render»: загружает страницу один раз
+// и выводит результат рендеринга для каждой из указанных областей просмотра
+func runRender(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	viewports := flags.String("viewport", "800x600", "области просмотра через запятую: ШИРИНАxВЫСОТА, phone, tablet или desktop")
+	media := flags.String("media", "screen", "тип носителя: screen или print")
+	scheme := flags.String("color-scheme", "light", "предпочитаемая цветовая схема: light или dark")
+	reducedMotion := flags.Bool("reduced-motion", false, "пользователь просит сократить анимацию")
+	dpr := flags.Float64("dpr", 1, "число физических пикселей в пикселе CSS")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Использование: gluglu render [-viewport список] [-media тип] [-color-scheme схема] [-reduced-motion] [-dpr N] URL")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("нужно указать один URL")
+	}
+
+	var devices []style.Device
+	for _, name := range strings.Split(*viewports, ",") {
+		size, err := parseViewport(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		devices = append(devices, style.Device{
+			Width:         size[0],
+			Height:        size[1],
+			Type:          *media,
+			ColorScheme:   *scheme,
+			ReducedMotion: *reducedMotion,
+			Resolution:    *dpr,
+		})
+	}
+
+	// Скрипты страницы выполняются один раз и видят первое устройство
+	b := browser.NewBrowser()
+	b.SetDevice(devices[0])
+	if err := b.LoadURL(flags.Arg(0)); err != nil {
+		return fmt.Errorf("ошибка загрузки страницы: %w", err)
+	}
+	for i, device := range devices {
+		if i > 0 {
+			b.SetDevice(device)
+			fmt.Fprintln(os.Stdout)
+		}
+		fmt.Fprint(os.Stdout, b.GetCurrentPage().RenderedDocument.GetTextRepresentation())
+	}
+	return nil
+}
+
+// parseViewport разбирает размер области просмотра: ШИРИНАxВЫСОТА или имя
+// устройства из viewportPresets
+func parseViewport(s string) ([2]float64, error) {
+	if size, ok := viewportPresets[strings.ToLower(s)]; ok {
+		return size, nil
+	}
+	w, h, ok := strings.Cut(strings.ToLower(s), "x")
+	if ok {
+		width, errW := strconv.ParseFloat(w, 64)
+		height, errH := strconv.ParseFloat(h, 64)
+		if errW == nil && errH == nil && width > 0 && height > 0 {
+			return [2]float64{width, height}, nil
+		}
+	}
+	return [2]float64{}, fmt.Errorf("некорректная область просмотра: %q", s)
+}
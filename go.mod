@@ -7,6 +7,9 @@ toolchain go1.24.2
 require (
 	github.com/AllenDang/giu v0.14.1
 	github.com/robertkrimen/otto v0.5.1
+	github.com/zserge/lorca v0.1.10
+	golang.org/x/net v0.50.0
+	golang.org/x/text v0.34.0
 )
 
 require (
@@ -18,13 +21,10 @@ require (
 	github.com/napsy/go-css v1.0.0 // indirect
 	github.com/pkg/browser v0.0.0-20210911075715-681adbf594b8 // indirect
 	github.com/sahilm/fuzzy v0.1.1 // indirect
-	github.com/zserge/lorca v0.1.10 // indirect
 	golang.design/x/hotkey v0.4.1 // indirect
 	golang.design/x/mainthread v0.3.0 // indirect
 	golang.org/x/image v0.27.0 // indirect
-	golang.org/x/net v0.0.0-20200222125558-5a598a2470a0 // indirect
-	golang.org/x/sys v0.25.0 // indirect
-	golang.org/x/text v0.25.0 // indirect
+	golang.org/x/sys v0.41.0 // indirect
 	gopkg.in/eapache/queue.v1 v1.1.0 // indirect
 	gopkg.in/sourcemap.v1 v1.0.5 // indirect
 )
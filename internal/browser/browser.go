@@ -1,14 +1,23 @@
 package browser
 
 import (
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/baneronetwo/gluglu/internal/browser/html"
 	"github.com/baneronetwo/gluglu/internal/browser/js"
+	"github.com/baneronetwo/gluglu/internal/browser/metadata"
 	"github.com/baneronetwo/gluglu/internal/browser/network"
+	"github.com/baneronetwo/gluglu/internal/browser/reader"
 	"github.com/baneronetwo/gluglu/internal/browser/renderer"
+	"github.com/baneronetwo/gluglu/internal/browser/resources"
+	"github.com/baneronetwo/gluglu/internal/browser/style"
+	"github.com/baneronetwo/gluglu/internal/browser/weburl"
 )
 
 // Browser представляет основной движок браузера
@@ -21,16 +30,38 @@ type Browser struct {
 	currentPage    *Page
 	history        []string
 	historyPos     int
-	mutex          sync.Mutex
+	// onElement — подписчики на элементы загружаемых страниц
+	onElement []func(*html.Node)
+	mutex     sync.Mutex
 }
 
-// Page представляет загруженную страницу
+// maxPageContent — наибольший размер исходного текста, который сохраняется в
+// Page.Content. Документ разбирается потоково, и полная копия крупной
+// страницы держала бы в памяти то, что парсер уже отбросил
+const maxPageContent = 256 << 10
+
+// Page представляет загруженную страницу. Документ разбирается по мере
+// загрузки, поэтому Content хранит не больше maxPageContent байт исходного
+// текста: у крупных страниц это только начало (ContentTruncated равен
+// true), а полный документ доступен через DOM
 type Page struct {
-	URL              string
-	Title            string
+	URL   string
+	Title string
+	// Content — исходный текст документа в UTF-8. У документов больше
+	// maxPageContent сохраняется только начало, а ContentTruncated равен true
 	Content          string
+	ContentTruncated bool
+	// Encoding — кодировка, в которой документ был получен
+	Encoding         string
 	DOM              *html.Document
 	RenderedDocument *renderer.Document
+	// Metadata — метаданные страницы: OpenGraph, Twitter Cards, JSON-LD, микроданные
+	Metadata *metadata.Metadata
+	// Links, Resources и Forms — исходящие ссылки, подресурсы и формы с
+	// адресами, разрешенными относительно базового адреса документа
+	Links     []resources.Link
+	Resources []resources.Resource
+	Forms     []resources.Form
 }
 
 // NewBrowser создает новый экземпляр браузера
@@ -41,7 +72,9 @@ func NewBrowser() *Browser {
 	networkMgr := network.NewManager()
 	htmlParser := html.NewParser()
 	jsEngine := js.NewEngine()
+	jsEngine.SetScriptLoader(networkMgr.Fetch)
 	renderer := renderer.NewRenderer()
+	renderer.SetStylesheetLoader(networkMgr.Fetch)
 	
 	return &Browser{
 		networkManager: networkMgr,
@@ -53,25 +86,43 @@ func NewBrowser() *Browser {
 	}
 }
 
-// LoadURL загружает указанный URL
+// LoadURL загружает указанный URL. Относительный адрес разрешается
+// относительно базового адреса текущей страницы
 func (b *Browser) LoadURL(url string) error {
 	log.Printf("Загрузка URL: %s", url)
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 	
-	// Получение содержимого страницы через сетевой модуль
-	content, err := b.networkManager.Fetch(url)
+	// Разбор адреса по правилам WHATWG URL
+	var base *weburl.URL
+	if b.currentPage != nil {
+		base = b.currentPage.DOM.BaseURL()
+	}
+	target, err := weburl.Parse(url, base)
+	if err != nil {
+		log.Printf("Ошибка разбора URL %s: %v", url, err)
+		return err
+	}
+	
+	// Получение содержимого страницы через сетевой модуль. Фрагмент на
+	// сервер не передается
+	resp, err := b.networkManager.FetchStream(target.StringWithoutFragment())
 	if err != nil {
-		log.Printf("Ошибка загрузки URL %s: %v", url, err)
+		log.Printf("Ошибка загрузки URL %s: %v", target, err)
 		return err
 	}
+	defer resp.Body.Close()
 	
-	// Парсинг HTML
-	doc, err := b.htmlParser.Parse(content)
+	// Парсинг HTML по мере загрузки; начало исходного текста сохраняется
+	// для страницы
+	source := &limitedBuffer{limit: maxPageContent}
+	doc, err := b.htmlParser.ParseReader(io.TeeReader(resp.Body, source), b.onElement...)
 	if err != nil {
 		log.Printf("Ошибка парсинга HTML: %v", err)
 		return err
 	}
+	doc.URL = documentURL(target, resp.URL)
+	url = doc.URL.String()
 	
 	// Выполнение JavaScript
 	b.jsEngine.Execute(doc)
@@ -79,13 +130,30 @@ func (b *Browser) LoadURL(url string) error {
 	// Рендеринг страницы
 	renderedDoc := b.renderer.Render(doc)
 	
+	// Заголовок мог быть изменен скриптами, поэтому читаем его после выполнения
+	title := doc.Title()
+	if title == "" {
+		title = "Без заголовка"
+	}
+	
+	// Метаданные и ссылки тоже извлекаются после скриптов: их часто
+	// добавляют динамически
+	meta := metadata.Extract(doc)
+	found := resources.Extract(doc)
+	
 	// Создание объекта страницы
 	page := &Page{
 		URL:              url,
-		Title:            doc.Title,
-		Content:          content,
+		Title:            title,
+		Content:          source.String(),
+		ContentTruncated: source.truncated,
+		Encoding:         resp.Encoding,
 		DOM:              doc,
 		RenderedDocument: renderedDoc,
+		Metadata:         meta,
+		Links:            found.Links,
+		Resources:        found.Resources,
+		Forms:            found.Forms,
 	}
 	
 	// Обновление текущей страницы и истории
@@ -103,6 +171,79 @@ func (b *Browser) LoadURL(url string) error {
 	return nil
 }
 
+// limitedBuffer накапливает не больше limit байт записанного, а остальное
+// отбрасывает. Обрезанный текст заканчивается целым символом UTF-8
+type limitedBuffer struct {
+	buf       strings.Builder
+	limit     int
+	truncated bool
+}
+
+// Write сохраняет часть p, которая помещается в буфер
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	if l.truncated {
+		return len(p), nil
+	}
+	if room := l.limit - l.buf.Len(); len(p) > room {
+		for room > 0 && !utf8.RuneStart(p[room]) {
+			room--
+		}
+		l.buf.Write(p[:room])
+		l.truncated = true
+		return len(p), nil
+	}
+	l.buf.Write(p)
+	return len(p), nil
+}
+
+// String возвращает накопленный текст
+func (l *limitedBuffer) String() string {
+	return l.buf.String()
+}
+
+// documentURL возвращает адрес загруженного документа: адрес после
+// перенаправлений, если они были, с фрагментом запрошенного адреса
+func documentURL(target *weburl.URL, final string) *weburl.URL {
+	if final == target.StringWithoutFragment() {
+		return target
+	}
+	u, err := weburl.Parse(final, nil)
+	if err != nil {
+		return target
+	}
+	if !u.HasFragment {
+		u.Fragment, u.HasFragment = target.Fragment, target.HasFragment
+	}
+	return u
+}
+
+// SetDevice задает устройство: размер области просмотра и характеристики,
+// по которым вычисляются медиазапросы. Текущая страница рендерится заново
+// без повторной загрузки, поэтому одну страницу можно сравнить на разных
+// устройствах
+func (b *Browser) SetDevice(device style.Device) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	
+	b.jsEngine.SetDevice(device)
+	b.renderer.SetDevice(device)
+	if b.currentPage != nil {
+		b.currentPage.RenderedDocument = b.renderer.Render(b.currentPage.DOM)
+	}
+}
+
+// OnElement подписывает listener на элементы загружаемых страниц. Он
+// вызывается для каждого элемента, как только элемент разобран, пока
+// остальной документ еще загружается, — до выполнения скриптов и
+// рендеринга. Браузер в это время занят загрузкой, поэтому listener не
+// должен вызывать его методы
+func (b *Browser) OnElement(listener func(n *html.Node)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	
+	b.onElement = append(b.onElement, listener)
+}
+
 // GetCurrentPage возвращает текущую страницу
 func (b *Browser) GetCurrentPage() *Page {
 	b.mutex.Lock()
@@ -110,6 +251,18 @@ func (b *Browser) GetCurrentPage() *Page {
 	return b.currentPage
 }
 
+// ReaderView извлекает основное содержимое текущей страницы в режиме чтения:
+// статью без навигации, рекламы и комментариев
+func (b *Browser) ReaderView() (*reader.Article, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	
+	if b.currentPage == nil {
+		return nil, errors.New("страница не загружена")
+	}
+	return reader.Extract(b.currentPage.DOM)
+}
+
 // GoBack переходит на предыдущую страницу в истории
 func (b *Browser) GoBack() error {
 	b.mutex.Lock()
@@ -0,0 +1,42 @@
+package browser
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/baneronetwo/gluglu/internal/browser/style"
+)
+
+func TestMatchMediaUsesDevice(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<!DOCTYPE html><title>-</title><script>
+			var wide = matchMedia('  (min-width: 1000px) and (orientation: landscape) ');
+			var dark = window.matchMedia('(prefers-color-scheme: dark)');
+			document.title = [wide.matches, wide.media, dark.matches, dark.media].join('|');
+		</script>`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		device style.Device
+		want   string
+	}{
+		{style.Device{Width: 1280, Height: 720, Type: "screen", ColorScheme: "light"},
+			"true|(min-width: 1000px) and (orientation: landscape)|false|(prefers-color-scheme: dark)"},
+		{style.Device{Width: 390, Height: 844, Type: "screen", ColorScheme: "dark"},
+			"false|(min-width: 1000px) and (orientation: landscape)|true|(prefers-color-scheme: dark)"},
+	}
+	for _, test := range tests {
+		b := NewBrowser()
+		b.SetDevice(test.device)
+		if err := b.LoadURL(server.URL); err != nil {
+			t.Fatalf("ошибка загрузки: %v", err)
+		}
+		if got := b.GetCurrentPage().Title; got != test.want {
+			t.Errorf("%gx%g: получено %q, ожидалось %q", test.device.Width, test.device.Height, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,620 @@
+package css
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Color — цвет sRGB с прозрачностью. Компоненты R, G, B и A лежат в
+// диапазоне от 0 до 1; цвета из более широких пространств (lab, oklch,
+// display-p3) обрезаются до границ sRGB
+type Color struct {
+	R, G, B, A float64
+}
+
+var (
+	// Transparent — полностью прозрачный черный
+	Transparent = Color{}
+	// Black — непрозрачный черный
+	Black = Color{A: 1}
+	// White — непрозрачный белый
+	White = Color{R: 1, G: 1, B: 1, A: 1}
+)
+
+// RGB8 создает непрозрачный цвет из компонентов от 0 до 255
+func RGB8(r, g, b uint8) Color {
+	return Color{R: float64(r) / 255, G: float64(g) / 255, B: float64(b) / 255, A: 1}
+}
+
+// RGBA возвращает компоненты, умноженные на прозрачность, в диапазоне от
+// 0 до 0xffff. Так Color реализует интерфейс color.Color пакета image/color
+func (c Color) RGBA() (r, g, b, a uint32) {
+	a = uint32(math.Round(clamp01(c.A) * 0xffff))
+	premultiply := func(v float64) uint32 {
+		return uint32(math.Round(clamp01(v) * clamp01(c.A) * 0xffff))
+	}
+	return premultiply(c.R), premultiply(c.G), premultiply(c.B), a
+}
+
+// RGBA8 возвращает компоненты и прозрачность в диапазоне от 0 до 255
+func (c Color) RGBA8() (r, g, b, a uint8) {
+	return to8(c.R), to8(c.G), to8(c.B), to8(c.A)
+}
+
+// IsOpaque проверяет, является ли цвет полностью непрозрачным
+func (c Color) IsOpaque() bool {
+	return c.A >= 1
+}
+
+// IsTransparent проверяет, является ли цвет полностью прозрачным
+func (c Color) IsTransparent() bool {
+	return c.A <= 0
+}
+
+// WithAlpha возвращает тот же цвет с прозрачностью a
+func (c Color) WithAlpha(a float64) Color {
+	c.A = clamp01(a)
+	return c
+}
+
+// Over накладывает цвет на фон backdrop (композиция source-over)
+func (c Color) Over(backdrop Color) Color {
+	a := c.A + backdrop.A*(1-c.A)
+	if a <= 0 {
+		return Transparent
+	}
+	mix := func(src, dst float64) float64 {
+		return (src*c.A + dst*backdrop.A*(1-c.A)) / a
+	}
+	return Color{R: mix(c.R, backdrop.R), G: mix(c.G, backdrop.G), B: mix(c.B, backdrop.B), A: a}
+}
+
+// Mix смешивает цвет с other: доля other равна t от 0 до 1. Компоненты
+// смешиваются с учетом прозрачности, как в color-mix() в пространстве srgb
+func (c Color) Mix(other Color, t float64) Color {
+	t = clamp01(t)
+	a := c.A*(1-t) + other.A*t
+	if a <= 0 {
+		return Transparent
+	}
+	mix := func(x, y float64) float64 {
+		return (x*c.A*(1-t) + y*other.A*t) / a
+	}
+	return Color{R: mix(c.R, other.R), G: mix(c.G, other.G), B: mix(c.B, other.B), A: a}
+}
+
+// Luminance возвращает относительную яркость цвета по WCAG 2 без учета
+// прозрачности
+func (c Color) Luminance() float64 {
+	return 0.2126*toLinear(c.R) + 0.7152*toLinear(c.G) + 0.0722*toLinear(c.B)
+}
+
+// ContrastRatio возвращает контрастность по WCAG 2 (от 1 до 21) цвета
+// текста c на фоне background. Полупрозрачный текст сначала накладывается
+// на фон
+func (c Color) ContrastRatio(background Color) float64 {
+	l1, l2 := c.Over(background).Luminance(), background.Luminance()
+	if l1 < l2 {
+		l1, l2 = l2, l1
+	}
+	return (l1 + 0.05) / (l2 + 0.05)
+}
+
+// Hex возвращает цвет в записи #rrggbb или #rrggbbaa, если он прозрачный
+func (c Color) Hex() string {
+	r, g, b, a := c.RGBA8()
+	digits := []byte{r, g, b}
+	if a != 255 {
+		digits = append(digits, a)
+	}
+	const hex = "0123456789abcdef"
+	var sb strings.Builder
+	sb.WriteByte('#')
+	for _, d := range digits {
+		sb.WriteByte(hex[d>>4])
+		sb.WriteByte(hex[d&15])
+	}
+	return sb.String()
+}
+
+// String возвращает цвет в виде rgb() или rgba(), как его сериализует CSSOM
+func (c Color) String() string {
+	r, g, b, a := c.RGBA8()
+	rgb := strconv.Itoa(int(r)) + ", " + strconv.Itoa(int(g)) + ", " + strconv.Itoa(int(b))
+	if a == 255 {
+		return "rgb(" + rgb + ")"
+	}
+	// Прозрачность записывается самым коротким числом, которое дает то же
+	// 8-битное значение
+	alpha := strconv.FormatFloat(math.Round(float64(a)/255*100)/100, 'f', -1, 64)
+	if to8(math.Round(float64(a)/255*100)/100) != a {
+		alpha = strconv.FormatFloat(math.Round(float64(a)/255*1000)/1000, 'f', -1, 64)
+	}
+	return "rgba(" + rgb + ", " + alpha + ")"
+}
+
+// ParseColor разбирает цвет CSS Color Level 4: имя цвета, #rgb, #rgba,
+// #rrggbb, #rrggbbaa, функции rgb(), rgba(), hsl(), hsla(), hwb(), lab(),
+// lch(), oklab(), oklch() и color(), transparent и системные цвета.
+// currentcolor заменяется цветом current
+func ParseColor(value []ComponentValue, current Color) (Color, bool) {
+	value = trimWhitespace(value)
+	if len(value) != 1 {
+		return Color{}, false
+	}
+	v := value[0]
+	switch v.Type {
+	case HashToken:
+		return parseHexColor(v.Value)
+	case IdentToken:
+		name := strings.ToLower(v.Value)
+		switch name {
+		case "currentcolor":
+			return current, true
+		case "transparent":
+			return Transparent, true
+		}
+		if c, ok := namedColors[name]; ok {
+			return c, true
+		}
+		c, ok := systemColors[name]
+		return c, ok
+	case FunctionToken:
+		switch strings.ToLower(v.Value) {
+		case "rgb", "rgba":
+			return parseRGB(v.Children)
+		case "hsl", "hsla":
+			return parseHSL(v.Children)
+		case "hwb":
+			return parseHWB(v.Children)
+		case "lab":
+			return parseLab(v.Children, 100, 125, labToXYZ)
+		case "lch":
+			return parseLCH(v.Children, 100, 150, labToXYZ)
+		case "oklab":
+			return parseLab(v.Children, 1, 0.4, oklabToXYZ)
+		case "oklch":
+			return parseLCH(v.Children, 1, 0.4, oklabToXYZ)
+		case "color":
+			return parseColorFunction(v.Children)
+		}
+	}
+	return Color{}, false
+}
+
+// ParseColorString разбирает цвет, записанный текстом
+func ParseColorString(s string, current Color) (Color, bool) {
+	return ParseColor(ParseComponentValues(s), current)
+}
+
+// IsColor проверяет, является ли значение цветом. currentcolor тоже цвет
+func IsColor(value []ComponentValue) bool {
+	_, ok := ParseColor(value, Black)
+	return ok
+}
+
+// parseHexColor разбирает шестнадцатеричную запись цвета без «#»
+func parseHexColor(s string) (Color, bool) {
+	switch len(s) {
+	case 3, 4:
+		// Каждая цифра повторяется: #abc — то же, что #aabbcc
+		long := make([]byte, 0, 8)
+		for i := 0; i < len(s); i++ {
+			long = append(long, s[i], s[i])
+		}
+		s = string(long)
+	case 6, 8:
+	default:
+		return Color{}, false
+	}
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return Color{}, false
+	}
+	if len(s) == 6 {
+		n = n<<8 | 0xff
+	}
+	return Color{
+		R: float64(n>>24&0xff) / 255,
+		G: float64(n>>16&0xff) / 255,
+		B: float64(n>>8&0xff) / 255,
+		A: float64(n&0xff) / 255,
+	}, true
+}
+
+// colorArgs делит аргументы цветовой функции на компоненты и
+// прозрачность. legacy — устаревшая запись через запятые, в которой
+// не допускается none
+func colorArgs(values []ComponentValue) (args []ComponentValue, alpha *ComponentValue, legacy bool, ok bool) {
+	var parts []ComponentValue
+	for _, v := range values {
+		if v.Type != WhitespaceToken {
+			parts = append(parts, v)
+		}
+	}
+	for _, v := range parts {
+		if v.Type == CommaToken {
+			legacy = true
+			break
+		}
+	}
+	if legacy {
+		// a, b, c[, alpha]: запятые стоят между всеми значениями
+		for i, v := range parts {
+			if (i%2 == 1) != (v.Type == CommaToken) || v.IsIdent("none") {
+				return nil, nil, false, false
+			}
+			if i%2 == 0 {
+				args = append(args, v)
+			}
+		}
+		if len(parts)%2 == 0 || len(args) < 3 || len(args) > 4 {
+			return nil, nil, false, false
+		}
+		if len(args) == 4 {
+			alpha = &args[3]
+			args = args[:3]
+		}
+		return args, alpha, true, true
+	}
+	// a b c[ / alpha]
+	for i, v := range parts {
+		if v.IsDelim("/") {
+			if i != len(parts)-2 {
+				return nil, nil, false, false
+			}
+			alpha = &parts[i+1]
+			break
+		}
+		args = append(args, v)
+	}
+	return args, alpha, false, len(args) == 3
+}
+
+// isNone проверяет, является ли компонент ключевым словом none
+func isNone(v ComponentValue) bool {
+	return v.IsIdent("none")
+}
+
+// parseAlpha разбирает прозрачность: число или процент. Без значения
+// цвет непрозрачен
+func parseAlpha(v *ComponentValue) (float64, bool) {
+	if v == nil {
+		return 1, true
+	}
+	switch {
+	case v.Type == NumberToken:
+		return clamp01(v.Number), true
+	case v.Type == PercentageToken:
+		return clamp01(v.Number / 100), true
+	case isNone(*v):
+		return 0, true
+	}
+	return 0, false
+}
+
+// parseComponent разбирает число или процент; процент 100% соответствует
+// значению full
+func parseComponent(v ComponentValue, full float64) (float64, bool) {
+	switch {
+	case v.Type == NumberToken:
+		return v.Number, true
+	case v.Type == PercentageToken:
+		return v.Number / 100 * full, true
+	case isNone(v):
+		return 0, true
+	}
+	return 0, false
+}
+
+// parseHue разбирает оттенок: число градусов или угол deg, grad, rad, turn
+func parseHue(v ComponentValue) (float64, bool) {
+	switch {
+	case v.Type == NumberToken:
+		return v.Number, true
+	case isNone(v):
+		return 0, true
+	case v.Type == DimensionToken:
+		switch strings.ToLower(v.Unit) {
+		case "deg":
+			return v.Number, true
+		case "grad":
+			return v.Number * 360 / 400, true
+		case "rad":
+			return v.Number * 180 / math.Pi, true
+		case "turn":
+			return v.Number * 360, true
+		}
+	}
+	return 0, false
+}
+
+// parseRGB разбирает аргументы rgb() и rgba()
+func parseRGB(values []ComponentValue) (Color, bool) {
+	args, alphaArg, legacy, ok := colorArgs(values)
+	if !ok {
+		return Color{}, false
+	}
+	var rgb [3]float64
+	for i, arg := range args {
+		// В устаревшей записи компоненты либо все числа, либо все проценты
+		if legacy && arg.Type != args[0].Type {
+			return Color{}, false
+		}
+		if rgb[i], ok = parseComponent(arg, 255); !ok {
+			return Color{}, false
+		}
+		rgb[i] = clamp01(rgb[i] / 255)
+	}
+	alpha, ok := parseAlpha(alphaArg)
+	if !ok {
+		return Color{}, false
+	}
+	return Color{R: rgb[0], G: rgb[1], B: rgb[2], A: alpha}, true
+}
+
+// parseHSL разбирает аргументы hsl() и hsla()
+func parseHSL(values []ComponentValue) (Color, bool) {
+	args, alphaArg, legacy, ok := colorArgs(values)
+	if !ok {
+		return Color{}, false
+	}
+	h, ok := parseHue(args[0])
+	if !ok {
+		return Color{}, false
+	}
+	var sl [2]float64
+	for i, arg := range args[1:] {
+		if legacy && arg.Type != PercentageToken {
+			return Color{}, false
+		}
+		if sl[i], ok = parseComponent(arg, 100); !ok {
+			return Color{}, false
+		}
+	}
+	alpha, ok := parseAlpha(alphaArg)
+	if !ok {
+		return Color{}, false
+	}
+	r, g, b := hslToRGB(h, clamp01(sl[0]/100), clamp01(sl[1]/100))
+	return Color{R: r, G: g, B: b, A: alpha}, true
+}
+
+// parseHWB разбирает аргументы hwb()
+func parseHWB(values []ComponentValue) (Color, bool) {
+	args, alphaArg, legacy, ok := colorArgs(values)
+	if !ok || legacy {
+		return Color{}, false
+	}
+	h, ok := parseHue(args[0])
+	if !ok {
+		return Color{}, false
+	}
+	var wb [2]float64
+	for i, arg := range args[1:] {
+		if wb[i], ok = parseComponent(arg, 100); !ok {
+			return Color{}, false
+		}
+		wb[i] = clamp01(wb[i] / 100)
+	}
+	alpha, ok := parseAlpha(alphaArg)
+	if !ok {
+		return Color{}, false
+	}
+	white, black := wb[0], wb[1]
+	if white+black >= 1 {
+		gray := white / (white + black)
+		return Color{R: gray, G: gray, B: gray, A: alpha}, true
+	}
+	r, g, b := hslToRGB(h, 1, 0.5)
+	scale := func(v float64) float64 {
+		return v*(1-white-black) + white
+	}
+	return Color{R: scale(r), G: scale(g), B: scale(b), A: alpha}, true
+}
+
+// parseLab разбирает аргументы lab() и oklab(). lightness и chroma —
+// значения, которым соответствуют 100% у светлоты и осей a и b
+func parseLab(values []ComponentValue, lightness, chroma float64, toXYZ func(l, a, b float64) [3]float64) (Color, bool) {
+	args, alphaArg, legacy, ok := colorArgs(values)
+	if !ok || legacy {
+		return Color{}, false
+	}
+	l, ok1 := parseComponent(args[0], lightness)
+	a, ok2 := parseComponent(args[1], chroma)
+	b, ok3 := parseComponent(args[2], chroma)
+	alpha, ok4 := parseAlpha(alphaArg)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return Color{}, false
+	}
+	l = math.Max(0, math.Min(l, lightness))
+	return xyzToSRGB(toXYZ(l, a, b), alpha), true
+}
+
+// parseLCH разбирает аргументы lch() и oklch(): светлоту, насыщенность и
+// оттенок в полярных координатах пространства Lab
+func parseLCH(values []ComponentValue, lightness, chroma float64, toXYZ func(l, a, b float64) [3]float64) (Color, bool) {
+	args, alphaArg, legacy, ok := colorArgs(values)
+	if !ok || legacy {
+		return Color{}, false
+	}
+	l, ok1 := parseComponent(args[0], lightness)
+	c, ok2 := parseComponent(args[1], chroma)
+	h, ok3 := parseHue(args[2])
+	alpha, ok4 := parseAlpha(alphaArg)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return Color{}, false
+	}
+	l = math.Max(0, math.Min(l, lightness))
+	c = math.Max(0, c)
+	rad := h * math.Pi / 180
+	return xyzToSRGB(toXYZ(l, c*math.Cos(rad), c*math.Sin(rad)), alpha), true
+}
+
+// parseColorFunction разбирает color(): пространство и три компонента.
+// Поддерживаются srgb, srgb-linear, display-p3, xyz, xyz-d50 и xyz-d65
+func parseColorFunction(values []ComponentValue) (Color, bool) {
+	values = trimWhitespace(values)
+	if len(values) == 0 || values[0].Type != IdentToken {
+		return Color{}, false
+	}
+	space := strings.ToLower(values[0].Value)
+	args, alphaArg, legacy, ok := colorArgs(values[1:])
+	if !ok || legacy {
+		return Color{}, false
+	}
+	var c [3]float64
+	for i, arg := range args {
+		if c[i], ok = parseComponent(arg, 1); !ok {
+			return Color{}, false
+		}
+	}
+	alpha, ok := parseAlpha(alphaArg)
+	if !ok {
+		return Color{}, false
+	}
+	switch space {
+	case "srgb":
+		return Color{R: clamp01(c[0]), G: clamp01(c[1]), B: clamp01(c[2]), A: alpha}, true
+	case "srgb-linear":
+		return Color{R: clamp01(fromLinear(c[0])), G: clamp01(fromLinear(c[1])), B: clamp01(fromLinear(c[2])), A: alpha}, true
+	case "display-p3":
+		linear := [3]float64{toLinear(c[0]), toLinear(c[1]), toLinear(c[2])}
+		return xyzToSRGB(mulMatrix(p3ToXYZ, linear), alpha), true
+	case "xyz", "xyz-d65":
+		return xyzToSRGB(c, alpha), true
+	case "xyz-d50":
+		return xyzToSRGB(mulMatrix(d50ToD65, c), alpha), true
+	}
+	return Color{}, false
+}
+
+// hslToRGB переводит оттенок в градусах, насыщенность и светлоту от 0 до 1 в sRGB
+func hslToRGB(h, s, l float64) (r, g, b float64) {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	f := func(n float64) float64 {
+		k := math.Mod(n+h/30, 12)
+		a := s * math.Min(l, 1-l)
+		return l - a*math.Max(-1, math.Min(k-3, math.Min(9-k, 1)))
+	}
+	return f(0), f(8), f(4)
+}
+
+// Матрицы перехода между пространствами из приложения CSS Color Level 4
+var (
+	d50ToD65 = [3][3]float64{
+		{0.955473421488075, -0.02309845494876471, 0.06325924320057072},
+		{-0.0283697093338637, 1.0099953980813041, 0.021041441191917323},
+		{0.012314014864481998, -0.020507649298898964, 1.330365926242124},
+	}
+	xyzToLinearSRGB = [3][3]float64{
+		{12831.0 / 3959, -329.0 / 214, -1974.0 / 3959},
+		{-851781.0 / 878810, 1648619.0 / 878810, 36519.0 / 878810},
+		{705.0 / 12673, -2585.0 / 12673, 705.0 / 667},
+	}
+	p3ToXYZ = [3][3]float64{
+		{608311.0 / 1250200, 189793.0 / 714400, 198249.0 / 1000160},
+		{35783.0 / 156275, 247089.0 / 357200, 198249.0 / 2500400},
+		{0, 32229.0 / 714400, 5220557.0 / 5000800},
+	}
+	oklabToLMS = [3][3]float64{
+		{1, 0.3963377773761749, 0.2158037573099136},
+		{1, -0.1055613458156586, -0.0638541728258133},
+		{1, -0.0894841775298119, -1.2914855480194092},
+	}
+	lmsToXYZ = [3][3]float64{
+		{1.2268798758459243, -0.5578149944602171, 0.2813910456659647},
+		{-0.0405757452148008, 1.1122868032803170, -0.0717110580655164},
+		{-0.0763729366746601, -0.4214933324022432, 1.5869240198367816},
+	}
+	// d50White — белая точка D50, относительно которой определен Lab
+	d50White = [3]float64{0.3457 / 0.3585, 1, (1 - 0.3457 - 0.3585) / 0.3585}
+)
+
+// labToXYZ переводит CIE Lab в XYZ с белой точкой D65
+func labToXYZ(l, a, b float64) [3]float64 {
+	const (
+		kappa   = 24389.0 / 27
+		epsilon = 216.0 / 24389
+	)
+	f1 := (l + 16) / 116
+	f0 := a/500 + f1
+	f2 := f1 - b/200
+	inverse := func(f float64) float64 {
+		if f*f*f > epsilon {
+			return f * f * f
+		}
+		return (116*f - 16) / kappa
+	}
+	y := l / kappa
+	if l > kappa*epsilon {
+		y = f1 * f1 * f1
+	}
+	xyz := [3]float64{inverse(f0) * d50White[0], y * d50White[1], inverse(f2) * d50White[2]}
+	return mulMatrix(d50ToD65, xyz)
+}
+
+// oklabToXYZ переводит OKLab в XYZ с белой точкой D65
+func oklabToXYZ(l, a, b float64) [3]float64 {
+	lms := mulMatrix(oklabToLMS, [3]float64{l, a, b})
+	for i := range lms {
+		lms[i] = lms[i] * lms[i] * lms[i]
+	}
+	return mulMatrix(lmsToXYZ, lms)
+}
+
+// xyzToSRGB переводит XYZ с белой точкой D65 в sRGB, обрезая компоненты
+// вне охвата
+func xyzToSRGB(xyz [3]float64, alpha float64) Color {
+	linear := mulMatrix(xyzToLinearSRGB, xyz)
+	return Color{
+		R: clamp01(fromLinear(linear[0])),
+		G: clamp01(fromLinear(linear[1])),
+		B: clamp01(fromLinear(linear[2])),
+		A: alpha,
+	}
+}
+
+func mulMatrix(m [3][3]float64, v [3]float64) [3]float64 {
+	var result [3]float64
+	for i, row := range m {
+		result[i] = row[0]*v[0] + row[1]*v[1] + row[2]*v[2]
+	}
+	return result
+}
+
+// toLinear снимает гамма-коррекцию sRGB
+func toLinear(v float64) float64 {
+	sign := 1.0
+	if v < 0 {
+		sign, v = -1, -v
+	}
+	if v <= 0.04045 {
+		return sign * v / 12.92
+	}
+	return sign * math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// fromLinear применяет гамма-коррекцию sRGB
+func fromLinear(v float64) float64 {
+	sign := 1.0
+	if v < 0 {
+		sign, v = -1, -v
+	}
+	if v <= 0.0031308 {
+		return sign * 12.92 * v
+	}
+	return sign * (1.055*math.Pow(v, 1/2.4) - 0.055)
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(v, 1))
+}
+
+func to8(v float64) uint8 {
+	return uint8(math.Round(clamp01(v) * 255))
+}
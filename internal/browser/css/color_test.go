@@ -0,0 +1,129 @@
+package css
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	current := RGB8(1, 2, 3)
+	tests := []struct {
+		input string
+		// want — цвет в записи String или пустая строка, если цвет
+		// должен быть отвергнут
+		want string
+	}{
+		// Имена, ключевые слова и шестнадцатеричная запись
+		{"red", "rgb(255, 0, 0)"},
+		{"RebeccaPurple", "rgb(102, 51, 153)"},
+		{"transparent", "rgba(0, 0, 0, 0)"},
+		{"currentColor", "rgb(1, 2, 3)"},
+		{"#abc", "rgb(170, 187, 204)"},
+		{"#abcd", "rgba(170, 187, 204, 0.867)"},
+		{"#AABBCC", "rgb(170, 187, 204)"},
+		{"#aabbcc80", "rgba(170, 187, 204, 0.5)"},
+		{"#abcde", ""},
+		{"#ggg", ""},
+		{"notacolor", ""},
+		{"red blue", ""},
+
+		// rgb(): современная запись
+		{"rgb(255 0 0)", "rgb(255, 0, 0)"},
+		{"rgb(100% 50% 0% / 50%)", "rgba(255, 128, 0, 0.5)"},
+		{"rgb(255 50% 0)", "rgb(255, 128, 0)"},
+		{"rgb(none 255 0 / none)", "rgba(0, 255, 0, 0)"},
+		{"rgb(300 -10 0 / 2)", "rgb(255, 0, 0)"},
+		{"rgba(0 0 0 / 0.25)", "rgba(0, 0, 0, 0.25)"},
+		{"rgb(0 0 0 0)", ""},
+		{"rgb(0 0 / 0)", ""},
+		{"rgb(0 0 0 / 1 / 1)", ""},
+
+		// rgb(): устаревшая запись через запятые
+		{"rgb(255, 0, 0)", "rgb(255, 0, 0)"},
+		{"rgba(255, 0, 0, 0.5)", "rgba(255, 0, 0, 0.5)"},
+		{"rgb(100%, 0%, 0%, 50%)", "rgba(255, 0, 0, 0.5)"},
+		{"rgb(255, 0%, 0)", ""},
+		{"rgb(255, 0, 0 / 0.5)", ""},
+		{"rgb(255 0, 0)", ""},
+		{"rgb(255, 0, none)", ""},
+		{"rgb(255, 0, 0,)", ""},
+		{"rgb(255, 0)", ""},
+
+		// hsl() и hwb()
+		{"hsl(120 100% 50%)", "rgb(0, 255, 0)"},
+		{"hsl(120deg 100 50)", "rgb(0, 255, 0)"},
+		{"hsl(0.5turn 100% 25% / 0.5)", "rgba(0, 128, 128, 0.5)"},
+		{"hsl(-120 100% 50%)", "rgb(0, 0, 255)"},
+		{"hsla(240, 100%, 50%, 0.5)", "rgba(0, 0, 255, 0.5)"},
+		{"hsl(240, 100, 50)", ""},
+		{"hsl(none, 100%, 50%)", ""},
+		{"hwb(0 0% 0%)", "rgb(255, 0, 0)"},
+		{"hwb(0 60% 60%)", "rgb(128, 128, 128)"},
+		{"hwb(0, 0%, 0%)", ""},
+
+		// lab(), lch(), oklab(), oklch() и color()
+		{"lab(100 0 0)", "rgb(255, 255, 255)"},
+		{"lab(0% 0 0)", "rgb(0, 0, 0)"},
+		{"oklab(1 0 0)", "rgb(255, 255, 255)"},
+		{"oklch(0.628 0.2577 29.23)", "rgb(255, 0, 0)"},
+		{"lch(50 0 0 / 0.5)", "rgba(119, 119, 119, 0.5)"},
+		{"lab(50, 0, 0)", ""},
+		{"color(srgb 1 0.5 0)", "rgb(255, 128, 0)"},
+		{"color(srgb-linear 1 0 0)", "rgb(255, 0, 0)"},
+		{"color(display-p3 1 0 0)", "rgb(255, 0, 0)"},
+		{"color(xyz 0.9505 1 1.089)", "rgb(255, 255, 255)"},
+		{"color(rec2020 1 0 0)", ""},
+		{"color(srgb 1, 0, 0)", ""},
+	}
+	for _, test := range tests {
+		c, ok := ParseColorString(test.input, current)
+		got := ""
+		if ok {
+			got = c.String()
+		}
+		if got != test.want {
+			t.Errorf("%q: получено %q, ожидалось %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestColorCompositing(t *testing.T) {
+	red, blue := RGB8(255, 0, 0), RGB8(0, 0, 255)
+	tests := []struct {
+		name string
+		got  Color
+		want string
+	}{
+		{"непрозрачный поверх фона", red.Over(blue), "rgb(255, 0, 0)"},
+		{"полупрозрачный поверх непрозрачного", red.WithAlpha(0.5).Over(blue), "rgb(128, 0, 128)"},
+		{"полупрозрачный поверх полупрозрачного", red.WithAlpha(0.5).Over(blue.WithAlpha(0.5)), "rgba(170, 0, 85, 0.75)"},
+		{"прозрачный поверх прозрачного", Transparent.Over(Transparent), "rgba(0, 0, 0, 0)"},
+		{"поверх прозрачного фона", red.WithAlpha(0.5).Over(Transparent), "rgba(255, 0, 0, 0.5)"},
+		{"смешение пополам", red.Mix(blue, 0.5), "rgb(128, 0, 128)"},
+		{"смешение с прозрачным", red.Mix(Transparent, 0.5), "rgba(255, 0, 0, 0.5)"},
+		{"доля вне диапазона", red.Mix(blue, 2), "rgb(0, 0, 255)"},
+		{"прозрачность вне диапазона", red.WithAlpha(-1), "rgba(255, 0, 0, 0)"},
+	}
+	for _, test := range tests {
+		if got := test.got.String(); got != test.want {
+			t.Errorf("%s: получено %s, ожидалось %s", test.name, got, test.want)
+		}
+	}
+
+	if got := RGB8(0x12, 0xab, 0xef).WithAlpha(0.5).Hex(); got != "#12abef80" {
+		t.Errorf("Hex: получено %s", got)
+	}
+	if r, g, b, a := red.WithAlpha(0.5).RGBA(); r != 0x8000 || g != 0 || b != 0 || a != 0x8000 {
+		t.Errorf("RGBA: получено %x %x %x %x", r, g, b, a)
+	}
+	if got := Black.ContrastRatio(White); math.Abs(got-21) > 1e-9 {
+		t.Errorf("контраст черного на белом: получено %g, ожидалось 21", got)
+	}
+	if got := White.ContrastRatio(White); got != 1 {
+		t.Errorf("контраст белого на белом: получено %g, ожидалось 1", got)
+	}
+	// Полупрозрачный черный текст на белом фоне дает серый
+	if got := Black.WithAlpha(0.5).ContrastRatio(White); got < 3.9 || got > 4.1 {
+		t.Errorf("контраст полупрозрачного черного на белом: получено %g", got)
+	}
+}
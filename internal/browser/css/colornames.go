@@ -0,0 +1,200 @@
+package css
+
+// namedColors — именованные цвета CSS Color Level 4
+var namedColors = map[string]Color{
+	"aliceblue":            RGB8(0xf0, 0xf8, 0xff),
+	"antiquewhite":         RGB8(0xfa, 0xeb, 0xd7),
+	"aqua":                 RGB8(0x00, 0xff, 0xff),
+	"aquamarine":           RGB8(0x7f, 0xff, 0xd4),
+	"azure":                RGB8(0xf0, 0xff, 0xff),
+	"beige":                RGB8(0xf5, 0xf5, 0xdc),
+	"bisque":               RGB8(0xff, 0xe4, 0xc4),
+	"black":                RGB8(0x00, 0x00, 0x00),
+	"blanchedalmond":       RGB8(0xff, 0xeb, 0xcd),
+	"blue":                 RGB8(0x00, 0x00, 0xff),
+	"blueviolet":           RGB8(0x8a, 0x2b, 0xe2),
+	"brown":                RGB8(0xa5, 0x2a, 0x2a),
+	"burlywood":            RGB8(0xde, 0xb8, 0x87),
+	"cadetblue":            RGB8(0x5f, 0x9e, 0xa0),
+	"chartreuse":           RGB8(0x7f, 0xff, 0x00),
+	"chocolate":            RGB8(0xd2, 0x69, 0x1e),
+	"coral":                RGB8(0xff, 0x7f, 0x50),
+	"cornflowerblue":       RGB8(0x64, 0x95, 0xed),
+	"cornsilk":             RGB8(0xff, 0xf8, 0xdc),
+	"crimson":              RGB8(0xdc, 0x14, 0x3c),
+	"cyan":                 RGB8(0x00, 0xff, 0xff),
+	"darkblue":             RGB8(0x00, 0x00, 0x8b),
+	"darkcyan":             RGB8(0x00, 0x8b, 0x8b),
+	"darkgoldenrod":        RGB8(0xb8, 0x86, 0x0b),
+	"darkgray":             RGB8(0xa9, 0xa9, 0xa9),
+	"darkgreen":            RGB8(0x00, 0x64, 0x00),
+	"darkgrey":             RGB8(0xa9, 0xa9, 0xa9),
+	"darkkhaki":            RGB8(0xbd, 0xb7, 0x6b),
+	"darkmagenta":          RGB8(0x8b, 0x00, 0x8b),
+	"darkolivegreen":       RGB8(0x55, 0x6b, 0x2f),
+	"darkorange":           RGB8(0xff, 0x8c, 0x00),
+	"darkorchid":           RGB8(0x99, 0x32, 0xcc),
+	"darkred":              RGB8(0x8b, 0x00, 0x00),
+	"darksalmon":           RGB8(0xe9, 0x96, 0x7a),
+	"darkseagreen":         RGB8(0x8f, 0xbc, 0x8f),
+	"darkslateblue":        RGB8(0x48, 0x3d, 0x8b),
+	"darkslategray":        RGB8(0x2f, 0x4f, 0x4f),
+	"darkslategrey":        RGB8(0x2f, 0x4f, 0x4f),
+	"darkturquoise":        RGB8(0x00, 0xce, 0xd1),
+	"darkviolet":           RGB8(0x94, 0x00, 0xd3),
+	"deeppink":             RGB8(0xff, 0x14, 0x93),
+	"deepskyblue":          RGB8(0x00, 0xbf, 0xff),
+	"dimgray":              RGB8(0x69, 0x69, 0x69),
+	"dimgrey":              RGB8(0x69, 0x69, 0x69),
+	"dodgerblue":           RGB8(0x1e, 0x90, 0xff),
+	"firebrick":            RGB8(0xb2, 0x22, 0x22),
+	"floralwhite":          RGB8(0xff, 0xfa, 0xf0),
+	"forestgreen":          RGB8(0x22, 0x8b, 0x22),
+	"fuchsia":              RGB8(0xff, 0x00, 0xff),
+	"gainsboro":            RGB8(0xdc, 0xdc, 0xdc),
+	"ghostwhite":           RGB8(0xf8, 0xf8, 0xff),
+	"gold":                 RGB8(0xff, 0xd7, 0x00),
+	"goldenrod":            RGB8(0xda, 0xa5, 0x20),
+	"gray":                 RGB8(0x80, 0x80, 0x80),
+	"green":                RGB8(0x00, 0x80, 0x00),
+	"greenyellow":          RGB8(0xad, 0xff, 0x2f),
+	"grey":                 RGB8(0x80, 0x80, 0x80),
+	"honeydew":             RGB8(0xf0, 0xff, 0xf0),
+	"hotpink":              RGB8(0xff, 0x69, 0xb4),
+	"indianred":            RGB8(0xcd, 0x5c, 0x5c),
+	"indigo":               RGB8(0x4b, 0x00, 0x82),
+	"ivory":                RGB8(0xff, 0xff, 0xf0),
+	"khaki":                RGB8(0xf0, 0xe6, 0x8c),
+	"lavender":             RGB8(0xe6, 0xe6, 0xfa),
+	"lavenderblush":        RGB8(0xff, 0xf0, 0xf5),
+	"lawngreen":            RGB8(0x7c, 0xfc, 0x00),
+	"lemonchiffon":         RGB8(0xff, 0xfa, 0xcd),
+	"lightblue":            RGB8(0xad, 0xd8, 0xe6),
+	"lightcoral":           RGB8(0xf0, 0x80, 0x80),
+	"lightcyan":            RGB8(0xe0, 0xff, 0xff),
+	"lightgoldenrodyellow": RGB8(0xfa, 0xfa, 0xd2),
+	"lightgray":            RGB8(0xd3, 0xd3, 0xd3),
+	"lightgreen":           RGB8(0x90, 0xee, 0x90),
+	"lightgrey":            RGB8(0xd3, 0xd3, 0xd3),
+	"lightpink":            RGB8(0xff, 0xb6, 0xc1),
+	"lightsalmon":          RGB8(0xff, 0xa0, 0x7a),
+	"lightseagreen":        RGB8(0x20, 0xb2, 0xaa),
+	"lightskyblue":         RGB8(0x87, 0xce, 0xfa),
+	"lightslategray":       RGB8(0x77, 0x88, 0x99),
+	"lightslategrey":       RGB8(0x77, 0x88, 0x99),
+	"lightsteelblue":       RGB8(0xb0, 0xc4, 0xde),
+	"lightyellow":          RGB8(0xff, 0xff, 0xe0),
+	"lime":                 RGB8(0x00, 0xff, 0x00),
+	"limegreen":            RGB8(0x32, 0xcd, 0x32),
+	"linen":                RGB8(0xfa, 0xf0, 0xe6),
+	"magenta":              RGB8(0xff, 0x00, 0xff),
+	"maroon":               RGB8(0x80, 0x00, 0x00),
+	"mediumaquamarine":     RGB8(0x66, 0xcd, 0xaa),
+	"mediumblue":           RGB8(0x00, 0x00, 0xcd),
+	"mediumorchid":         RGB8(0xba, 0x55, 0xd3),
+	"mediumpurple":         RGB8(0x93, 0x70, 0xdb),
+	"mediumseagreen":       RGB8(0x3c, 0xb3, 0x71),
+	"mediumslateblue":      RGB8(0x7b, 0x68, 0xee),
+	"mediumspringgreen":    RGB8(0x00, 0xfa, 0x9a),
+	"mediumturquoise":      RGB8(0x48, 0xd1, 0xcc),
+	"mediumvioletred":      RGB8(0xc7, 0x15, 0x85),
+	"midnightblue":         RGB8(0x19, 0x19, 0x70),
+	"mintcream":            RGB8(0xf5, 0xff, 0xfa),
+	"mistyrose":            RGB8(0xff, 0xe4, 0xe1),
+	"moccasin":             RGB8(0xff, 0xe4, 0xb5),
+	"navajowhite":          RGB8(0xff, 0xde, 0xad),
+	"navy":                 RGB8(0x00, 0x00, 0x80),
+	"oldlace":              RGB8(0xfd, 0xf5, 0xe6),
+	"olive":                RGB8(0x80, 0x80, 0x00),
+	"olivedrab":            RGB8(0x6b, 0x8e, 0x23),
+	"orange":               RGB8(0xff, 0xa5, 0x00),
+	"orangered":            RGB8(0xff, 0x45, 0x00),
+	"orchid":               RGB8(0xda, 0x70, 0xd6),
+	"palegoldenrod":        RGB8(0xee, 0xe8, 0xaa),
+	"palegreen":            RGB8(0x98, 0xfb, 0x98),
+	"paleturquoise":        RGB8(0xaf, 0xee, 0xee),
+	"palevioletred":        RGB8(0xdb, 0x70, 0x93),
+	"papayawhip":           RGB8(0xff, 0xef, 0xd5),
+	"peachpuff":            RGB8(0xff, 0xda, 0xb9),
+	"peru":                 RGB8(0xcd, 0x85, 0x3f),
+	"pink":                 RGB8(0xff, 0xc0, 0xcb),
+	"plum":                 RGB8(0xdd, 0xa0, 0xdd),
+	"powderblue":           RGB8(0xb0, 0xe0, 0xe6),
+	"purple":               RGB8(0x80, 0x00, 0x80),
+	"rebeccapurple":        RGB8(0x66, 0x33, 0x99),
+	"red":                  RGB8(0xff, 0x00, 0x00),
+	"rosybrown":            RGB8(0xbc, 0x8f, 0x8f),
+	"royalblue":            RGB8(0x41, 0x69, 0xe1),
+	"saddlebrown":          RGB8(0x8b, 0x45, 0x13),
+	"salmon":               RGB8(0xfa, 0x80, 0x72),
+	"sandybrown":           RGB8(0xf4, 0xa4, 0x60),
+	"seagreen":             RGB8(0x2e, 0x8b, 0x57),
+	"seashell":             RGB8(0xff, 0xf5, 0xee),
+	"sienna":               RGB8(0xa0, 0x52, 0x2d),
+	"silver":               RGB8(0xc0, 0xc0, 0xc0),
+	"skyblue":              RGB8(0x87, 0xce, 0xeb),
+	"slateblue":            RGB8(0x6a, 0x5a, 0xcd),
+	"slategray":            RGB8(0x70, 0x80, 0x90),
+	"slategrey":            RGB8(0x70, 0x80, 0x90),
+	"snow":                 RGB8(0xff, 0xfa, 0xfa),
+	"springgreen":          RGB8(0x00, 0xff, 0x7f),
+	"steelblue":            RGB8(0x46, 0x82, 0xb4),
+	"tan":                  RGB8(0xd2, 0xb4, 0x8c),
+	"teal":                 RGB8(0x00, 0x80, 0x80),
+	"thistle":              RGB8(0xd8, 0xbf, 0xd8),
+	"tomato":               RGB8(0xff, 0x63, 0x47),
+	"turquoise":            RGB8(0x40, 0xe0, 0xd0),
+	"violet":               RGB8(0xee, 0x82, 0xee),
+	"wheat":                RGB8(0xf5, 0xde, 0xb3),
+	"white":                RGB8(0xff, 0xff, 0xff),
+	"whitesmoke":           RGB8(0xf5, 0xf5, 0xf5),
+	"yellow":               RGB8(0xff, 0xff, 0x00),
+	"yellowgreen":          RGB8(0x9a, 0xcd, 0x32),
+}
+
+// systemColors — системные цвета в светлой схеме. Устаревшие имена
+// (ButtonHighlight, ThreeDFace...) отображаются на ближайшие современные
+var systemColors = map[string]Color{
+	"accentcolor":         RGB8(0x00, 0x75, 0xff),
+	"accentcolortext":     RGB8(0xff, 0xff, 0xff),
+	"activetext":          RGB8(0xff, 0x00, 0x00),
+	"buttonborder":        RGB8(0x76, 0x76, 0x76),
+	"buttonface":          RGB8(0xef, 0xef, 0xef),
+	"buttontext":          RGB8(0x00, 0x00, 0x00),
+	"canvas":              RGB8(0xff, 0xff, 0xff),
+	"canvastext":          RGB8(0x00, 0x00, 0x00),
+	"field":               RGB8(0xff, 0xff, 0xff),
+	"fieldtext":           RGB8(0x00, 0x00, 0x00),
+	"graytext":            RGB8(0x80, 0x80, 0x80),
+	"highlight":           RGB8(0x33, 0x99, 0xff),
+	"highlighttext":       RGB8(0xff, 0xff, 0xff),
+	"linktext":            RGB8(0x00, 0x00, 0xee),
+	"mark":                RGB8(0xff, 0xff, 0x00),
+	"marktext":            RGB8(0x00, 0x00, 0x00),
+	"selecteditem":        RGB8(0x33, 0x99, 0xff),
+	"selecteditemtext":    RGB8(0xff, 0xff, 0xff),
+	"visitedtext":         RGB8(0x55, 0x1a, 0x8b),
+	"activeborder":        RGB8(0x76, 0x76, 0x76),
+	"activecaption":       RGB8(0xff, 0xff, 0xff),
+	"appworkspace":        RGB8(0xff, 0xff, 0xff),
+	"background":          RGB8(0xff, 0xff, 0xff),
+	"buttonhighlight":     RGB8(0xef, 0xef, 0xef),
+	"buttonshadow":        RGB8(0xef, 0xef, 0xef),
+	"captiontext":         RGB8(0x00, 0x00, 0x00),
+	"inactiveborder":      RGB8(0x76, 0x76, 0x76),
+	"inactivecaption":     RGB8(0xff, 0xff, 0xff),
+	"inactivecaptiontext": RGB8(0x80, 0x80, 0x80),
+	"infobackground":      RGB8(0xff, 0xff, 0xff),
+	"infotext":            RGB8(0x00, 0x00, 0x00),
+	"menu":                RGB8(0xff, 0xff, 0xff),
+	"menutext":            RGB8(0x00, 0x00, 0x00),
+	"scrollbar":           RGB8(0xff, 0xff, 0xff),
+	"threeddarkshadow":    RGB8(0x76, 0x76, 0x76),
+	"threedface":          RGB8(0xef, 0xef, 0xef),
+	"threedhighlight":     RGB8(0x76, 0x76, 0x76),
+	"threedlightshadow":   RGB8(0x76, 0x76, 0x76),
+	"threedshadow":        RGB8(0x76, 0x76, 0x76),
+	"window":              RGB8(0xff, 0xff, 0xff),
+	"windowframe":         RGB8(0x76, 0x76, 0x76),
+	"windowtext":          RGB8(0x00, 0x00, 0x00),
+}
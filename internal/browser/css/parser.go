@@ -0,0 +1,378 @@
+// Package css разбирает таблицы стилей по алгоритмам CSS Syntax Level 3:
+// токенизатор, компонентные значения, правила, @-правила и объявления с
+// флагом !important. Разбор не знает свойств CSS и не проверяет значения —
+// это задача системы стилей
+package css
+
+import (
+	"strings"
+)
+
+// ComponentValue — компонентное значение: токен, функция или простой блок.
+// У функции Type == FunctionToken, а Value — ее имя; у блоков Type — токен
+// открывающей скобки ({, [ или (). Children — аргументы функции или
+// содержимое блока
+type ComponentValue struct {
+	Token
+	Children []ComponentValue
+}
+
+// IsBlock проверяет, является ли значение простым блоком
+func (v ComponentValue) IsBlock() bool {
+	return v.Type == OpenCurlyToken || v.Type == OpenSquareToken || v.Type == OpenParenToken
+}
+
+// IsFunction проверяет, является ли значение функцией с именем name (без
+// учета регистра)
+func (v ComponentValue) IsFunction(name string) bool {
+	return v.Type == FunctionToken && strings.EqualFold(v.Value, name)
+}
+
+// IsIdent проверяет, является ли значение идентификатором name (без учета регистра)
+func (v ComponentValue) IsIdent(name string) bool {
+	return v.Type == IdentToken && strings.EqualFold(v.Value, name)
+}
+
+// IsDelim проверяет, является ли значение символом c
+func (v ComponentValue) IsDelim(c string) bool {
+	return v.Type == DelimToken && v.Value == c
+}
+
+// Stylesheet — разобранная таблица стилей
+type Stylesheet struct {
+	Rules []Rule
+}
+
+// Rule — правило таблицы стилей: *StyleRule или *AtRule
+type Rule interface {
+	rule()
+}
+
+// StyleRule — правило со списком селекторов и блоком объявлений
+type StyleRule struct {
+	// Prelude — компонентные значения перед блоком
+	Prelude []ComponentValue
+	// Selector — текст списка селекторов
+	Selector     string
+	Declarations []Declaration
+	// Rules — вложенные правила (CSS Nesting)
+	Rules []Rule
+}
+
+// AtRule — @-правило. Содержимое блока разбирается в зависимости от имени:
+// в правила для @media, @supports и подобных, в объявления для @font-face
+// и @page. Блок неизвестных @-правил остается неразобранным
+type AtRule struct {
+	// Name — имя без «@» в нижнем регистре
+	Name    string
+	Prelude []ComponentValue
+	// Block — содержимое блока; nil у правил без блока (@import, @charset)
+	Block        []ComponentValue
+	Rules        []Rule
+	Declarations []Declaration
+}
+
+func (*StyleRule) rule() {}
+func (*AtRule) rule()    {}
+
+// Declaration — объявление свойства
+type Declaration struct {
+	// Name — имя свойства. Имена обычных свойств приводятся к нижнему
+	// регистру, имена пользовательских (--main-color) сохраняются как есть
+	Name      string
+	Value     []ComponentValue
+	Important bool
+}
+
+// IsCustomProperty проверяет, объявляет ли объявление пользовательское свойство
+func (d Declaration) IsCustomProperty() bool {
+	return strings.HasPrefix(d.Name, "--")
+}
+
+// ValueString возвращает значение объявления в виде текста CSS
+func (d Declaration) ValueString() string {
+	return Serialize(d.Value)
+}
+
+// ruleListAtRules — @-правила, блок которых содержит правила
+var ruleListAtRules = map[string]bool{
+	"media": true, "supports": true, "document": true, "-moz-document": true,
+	"layer": true, "container": true, "scope": true, "starting-style": true,
+	"keyframes": true, "-webkit-keyframes": true, "-moz-keyframes": true,
+}
+
+// declarationListAtRules — @-правила, блок которых содержит объявления
+var declarationListAtRules = map[string]bool{
+	"font-face": true, "page": true, "counter-style": true, "property": true,
+	"font-palette-values": true, "viewport": true, "-ms-viewport": true,
+}
+
+// ParseStylesheet разбирает таблицу стилей. Ошибки разбора, как и в
+// браузерах, не прерывают разбор: некорректные правила и объявления пропускаются
+func ParseStylesheet(input string) *Stylesheet {
+	p := newParser(ParseComponentValues(input))
+	return &Stylesheet{Rules: p.consumeRules(true)}
+}
+
+// ParseDeclarations разбирает список объявлений, например атрибут style
+func ParseDeclarations(input string) []Declaration {
+	declarations, _ := newParser(ParseComponentValues(input)).consumeDeclarations(false)
+	return declarations
+}
+
+// ParseComponentValues разбирает текст в список компонентных значений
+func ParseComponentValues(input string) []ComponentValue {
+	p := &tokenParser{tokens: Tokenize(input)}
+	var values []ComponentValue
+	for p.pos < len(p.tokens) {
+		values = append(values, p.consumeComponentValue())
+	}
+	return values
+}
+
+// tokenParser собирает из токенов функции и простые блоки
+type tokenParser struct {
+	tokens []Token
+	pos    int
+}
+
+// next возвращает очередной токен или EOFToken
+func (p *tokenParser) next() Token {
+	if p.pos >= len(p.tokens) {
+		return Token{Type: EOFToken}
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+// consumeComponentValue читает токен, функцию или простой блок
+func (p *tokenParser) consumeComponentValue() ComponentValue {
+	token := p.next()
+	var ending TokenType
+	switch token.Type {
+	case FunctionToken, OpenParenToken:
+		ending = CloseParenToken
+	case OpenSquareToken:
+		ending = CloseSquareToken
+	case OpenCurlyToken:
+		ending = CloseCurlyToken
+	default:
+		return ComponentValue{Token: token}
+	}
+	// Незакрытые функции и блоки закрываются в конце потока
+	value := ComponentValue{Token: token, Children: []ComponentValue{}}
+	for {
+		next := p.next()
+		if next.Type == ending || next.Type == EOFToken {
+			return value
+		}
+		p.pos--
+		value.Children = append(value.Children, p.consumeComponentValue())
+	}
+}
+
+// parser разбирает правила и объявления из компонентных значений
+type parser struct {
+	values []ComponentValue
+	pos    int
+}
+
+func newParser(values []ComponentValue) *parser {
+	return &parser{values: values}
+}
+
+// done проверяет, закончился ли поток
+func (p *parser) done() bool {
+	return p.pos >= len(p.values)
+}
+
+// peek возвращает текущее значение; за концом потока — EOFToken
+func (p *parser) peek() ComponentValue {
+	if p.done() {
+		return ComponentValue{Token: Token{Type: EOFToken}}
+	}
+	return p.values[p.pos]
+}
+
+// next возвращает текущее значение и переходит к следующему
+func (p *parser) next() ComponentValue {
+	v := p.peek()
+	if !p.done() {
+		p.pos++
+	}
+	return v
+}
+
+// consumeRules читает список правил. На верхнем уровне таблицы стилей
+// токены <!-- и --> игнорируются
+func (p *parser) consumeRules(topLevel bool) []Rule {
+	var rules []Rule
+	for !p.done() {
+		switch v := p.peek(); v.Type {
+		case WhitespaceToken:
+			p.pos++
+		case CDOToken, CDCToken:
+			if topLevel {
+				p.pos++
+				continue
+			}
+			if rule := p.consumeQualifiedRule(false); rule != nil {
+				rules = append(rules, rule)
+			}
+		case AtKeywordToken:
+			rules = append(rules, p.consumeAtRule(false))
+		default:
+			if rule := p.consumeQualifiedRule(false); rule != nil {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return rules
+}
+
+// consumeAtRule читает @-правило до «;» или блока. Условное @-правило,
+// вложенное в правило стиля (nested), может содержать и объявления, которые
+// относятся к родительскому правилу (CSS Nesting)
+func (p *parser) consumeAtRule(nested bool) *AtRule {
+	rule := &AtRule{Name: strings.ToLower(p.next().Value)}
+	for !p.done() {
+		v := p.next()
+		if v.Type == SemicolonToken {
+			break
+		}
+		if v.Type == OpenCurlyToken {
+			rule.Block = v.Children
+			break
+		}
+		rule.Prelude = append(rule.Prelude, v)
+	}
+	rule.Prelude = trimWhitespace(rule.Prelude)
+
+	switch {
+	case rule.Block == nil:
+	case ruleListAtRules[rule.Name] && nested:
+		rule.Declarations, rule.Rules = newParser(rule.Block).consumeDeclarations(true)
+	case ruleListAtRules[rule.Name]:
+		rule.Rules = newParser(rule.Block).consumeRules(false)
+	case declarationListAtRules[rule.Name]:
+		rule.Declarations, _ = newParser(rule.Block).consumeDeclarations(false)
+	}
+	return rule
+}
+
+// consumeQualifiedRule читает правило со списком селекторов. Правило без
+// блока некорректно и отбрасывается. Вложенное правило заканчивается на «;»
+func (p *parser) consumeQualifiedRule(nested bool) *StyleRule {
+	var prelude []ComponentValue
+	for !p.done() {
+		v := p.next()
+		if v.Type == OpenCurlyToken {
+			rule := &StyleRule{Prelude: trimWhitespace(prelude)}
+			rule.Selector = Serialize(rule.Prelude)
+			rule.Declarations, rule.Rules = newParser(v.Children).consumeDeclarations(true)
+			return rule
+		}
+		if nested && v.Type == SemicolonToken {
+			return nil
+		}
+		prelude = append(prelude, v)
+	}
+	return nil
+}
+
+// consumeDeclarations читает содержимое блока объявлений. Если allowRules,
+// в блоке допускаются вложенные правила, иначе они пропускаются
+func (p *parser) consumeDeclarations(allowRules bool) ([]Declaration, []Rule) {
+	var declarations []Declaration
+	var rules []Rule
+	for !p.done() {
+		switch v := p.peek(); v.Type {
+		case WhitespaceToken, SemicolonToken:
+			p.pos++
+		case AtKeywordToken:
+			rule := p.consumeAtRule(allowRules)
+			if allowRules {
+				rules = append(rules, rule)
+			}
+		case IdentToken:
+			start := p.pos
+			values := p.consumeUntilSemicolon()
+			if decl, ok := parseDeclaration(values); ok {
+				declarations = append(declarations, decl)
+				continue
+			}
+			if allowRules {
+				// Не объявление — значит, вложенное правило вида «div { ... }»
+				p.pos = start
+				if rule := p.consumeQualifiedRule(true); rule != nil {
+					rules = append(rules, rule)
+				}
+			}
+		default:
+			if !allowRules {
+				p.consumeUntilSemicolon()
+				continue
+			}
+			if rule := p.consumeQualifiedRule(true); rule != nil {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return declarations, rules
+}
+
+// consumeUntilSemicolon читает значения до «;» или конца потока
+func (p *parser) consumeUntilSemicolon() []ComponentValue {
+	start := p.pos
+	for !p.done() && p.peek().Type != SemicolonToken {
+		p.pos++
+	}
+	return p.values[start:p.pos]
+}
+
+// parseDeclaration разбирает объявление «имя: значение [!important]»
+func parseDeclaration(values []ComponentValue) (Declaration, bool) {
+	if len(values) == 0 || values[0].Type != IdentToken {
+		return Declaration{}, false
+	}
+	decl := Declaration{Name: values[0].Value}
+	if !decl.IsCustomProperty() {
+		decl.Name = strings.ToLower(decl.Name)
+	}
+	rest := trimWhitespace(values[1:])
+	if len(rest) == 0 || rest[0].Type != ColonToken {
+		return Declaration{}, false
+	}
+	value := trimWhitespace(rest[1:])
+	if !decl.IsCustomProperty() {
+		// Блок {} в значении обычного свойства означает, что это не
+		// объявление, а вложенное правило вида «a:hover { ... }»
+		for _, v := range value {
+			if v.Type == OpenCurlyToken {
+				return Declaration{}, false
+			}
+		}
+	}
+
+	// !important — два последних значения без учета пробелов
+	if n := len(value); n >= 2 && value[n-1].IsIdent("important") {
+		bang := trimWhitespace(value[:n-1])
+		if m := len(bang); m > 0 && bang[m-1].IsDelim("!") {
+			decl.Important = true
+			value = trimWhitespace(bang[:m-1])
+		}
+	}
+	decl.Value = append([]ComponentValue(nil), value...)
+	return decl, true
+}
+
+// trimWhitespace удаляет пробельные токены по краям списка
+func trimWhitespace(values []ComponentValue) []ComponentValue {
+	for len(values) > 0 && values[0].Type == WhitespaceToken {
+		values = values[1:]
+	}
+	for len(values) > 0 && values[len(values)-1].Type == WhitespaceToken {
+		values = values[:len(values)-1]
+	}
+	return values
+}
@@ -0,0 +1,173 @@
+package css
+
+import (
+	"strings"
+	"testing"
+)
+
+// describeRules записывает правила в компактном виде: «селектор{объявления
+// вложенные правила}», у @-правил — «@имя прелюдия{...}». Важные объявления
+// помечаются «!»
+func describeRules(rules []Rule) string {
+	list := make([]string, len(rules))
+	for i, rule := range rules {
+		switch r := rule.(type) {
+		case *StyleRule:
+			list[i] = r.Selector + "{" + describeBody(r.Declarations, r.Rules) + "}"
+		case *AtRule:
+			s := "@" + r.Name
+			if len(r.Prelude) > 0 {
+				s += " " + Serialize(r.Prelude)
+			}
+			switch {
+			case r.Block == nil:
+				s += ";"
+			case ruleListAtRules[r.Name] || declarationListAtRules[r.Name]:
+				s += "{" + describeBody(r.Declarations, r.Rules) + "}"
+			default:
+				s += "{raw " + Serialize(r.Block) + "}"
+			}
+			list[i] = s
+		}
+	}
+	return strings.Join(list, " ")
+}
+
+func describeBody(declarations []Declaration, rules []Rule) string {
+	var parts []string
+	for _, d := range declarations {
+		parts = append(parts, describeDeclaration(d))
+	}
+	if len(rules) > 0 {
+		parts = append(parts, describeRules(rules))
+	}
+	return strings.Join(parts, ";")
+}
+
+func describeDeclaration(d Declaration) string {
+	s := d.Name + ":" + d.ValueString()
+	if d.Important {
+		s += "!"
+	}
+	return s
+}
+
+func TestParseStylesheet(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"a{color:red}", "a{color:red}"},
+		{" a , b > c { COLOR : Red ; ; margin:0 }", "a , b > c{color:Red;margin:0}"},
+
+		// !important
+		{"a{color:red !important}", "a{color:red!}"},
+		{"a{color:red ! IMPORTANT ;width:1px}", "a{color:red!;width:1px}"},
+		{"a{color:red!important x}", "a{color:red!important x}"},
+		{"a{color:!important}", "a{color:!}"},
+		{"a{color:red important}", "a{color:red important}"},
+		{"a{--x: 1 !important}", "a{--x:1!}"},
+
+		// Некорректные объявления пропускаются до «;»
+		{"a{color red;width:1px}", "a{width:1px}"},
+		{"a{:red;width:1px}", "a{width:1px}"},
+		{"a{1px:2;width:1px}", "a{width:1px}"},
+		{"a{color:red;;;}", "a{color:red}"},
+
+		// Пользовательские свойства сохраняют регистр и могут содержать блоки
+		{"a{--Main-Color: {x:y} ; --e:;}", "a{--Main-Color:{x:y};--e:}"},
+
+		// Вложенные правила
+		{"a{color:red; b{color:blue} &:hover{x:y} c:d}", "a{color:red;c:d;b{color:blue} &:hover{x:y}}"},
+		{"div{ a:hover{color:red} width:1px }", "div{width:1px;a:hover{color:red}}"},
+		// Объявления во вложенном условном правиле относятся к родителю
+		{"a{ @media print{ color:red; b{x:y} } }", "a{@media print{color:red;b{x:y}}}"},
+		// На верхнем уровне тело @media содержит только правила
+		{"@media print{ color:red; b{x:y} }", "@media print{color:red; b{x:y}}"},
+		{"a{> b{x:y}}", "a{> b{x:y}}"},
+		{"a{.b; c:d}", "a{c:d}"},
+
+		// <!-- и --> на верхнем уровне игнорируются, внутри блоков — нет
+		{"<!-- a{x:y} -->", "a{x:y}"},
+		{"<!--a{x:y}-->b{z:w}", "a{x:y} b{z:w}"},
+		{"@media screen{<!-- a{x:y}}", "@media screen{<!-- a{x:y}}"},
+
+		// Незакрытые блоки в конце потока закрываются
+		{"a{color:red", "a{color:red}"},
+		{"a{color:red;b{c:d", "a{color:red;b{c:d}}"},
+		{"@media screen{a{color:red", "@media screen{a{color:red}}"},
+		{"a{background:url(x.png", "a{background:url(x.png)}"},
+		{"a{content:\"abc", "a{content:\"abc\"}"},
+		{"a{width:calc(1px + (2px", "a{width:calc(1px + (2px))}"},
+		{"a", ""},
+		{"a{x:y} b", "a{x:y}"},
+
+		// Ошибки токенизации не прерывают разбор
+		{"a{content:\"a\n;color:red}", "a{content:\";color:red}"},
+		{"a{background:url(a b);color:red}", "a{background:url();color:red}"},
+		// Неразобранное объявление перечитывается как вложенное правило
+		{"a{color red{x:y} width:1px;height:2px}", "a{width:1px;height:2px;color red{x:y}}"},
+
+		// @-правила
+		{`@import url(x.css) screen;a{x:y}`, "@import url(x.css) screen; a{x:y}"},
+		{"@charset \"utf-8\";", `@charset "utf-8";`},
+		{"@MEDIA (min-width:1px){a{x:y}}", "@media (min-width:1px){a{x:y}}"},
+		{"@font-face{font-family:X;src:url(x.woff)}", "@font-face{font-family:X;src:url(x.woff)}"},
+		{"@font-face{a{x:y} font-weight:bold;font-style:italic}", "@font-face{font-style:italic}"},
+		{"@unknown foo{a{x:y}}", "@unknown foo{raw a{x:y}}"},
+		{"@supports (display:grid){@media print{a{x:y}}}", "@supports (display:grid){@media print{a{x:y}}}"},
+		{"@import 'x.css'", "@import \"x.css\";"},
+	}
+	for _, test := range tests {
+		if got := describeRules(ParseStylesheet(test.input).Rules); got != test.want {
+			t.Errorf("%q:\nполучено  %s\nожидалось %s", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseDeclarations(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"color: red; width: 1px !important", "color:red width:1px!"},
+		{"color: red; a{b:c} width: 1px; height: 2px", "color:red height:2px"},
+		{"color", ""},
+		{"color:red;", "color:red"},
+		{"@media print{x:y}; color:red", "color:red"},
+		{"color: rgb(0, 0, 0", "color:rgb(0, 0, 0)"},
+	}
+	for _, test := range tests {
+		var list []string
+		for _, d := range ParseDeclarations(test.input) {
+			list = append(list, describeDeclaration(d))
+		}
+		if got := strings.Join(list, " "); got != test.want {
+			t.Errorf("%q: получено %s, ожидалось %s", test.input, got, test.want)
+		}
+	}
+}
+
+func TestSerializeRoundTrip(t *testing.T) {
+	// Повторный разбор сериализованного значения дает те же токены
+	for _, input := range []string{
+		`a\:b`, "#\\31 a", "1e3px", "- -x", "a/**/b", "1 -1", `"a\"b\\c"`, "url(a\\)b)",
+		"10\\%", "--x", "a(b)", "x\\ty", "-\\31", "1.5e-7", "#a#b",
+	} {
+		values := ParseComponentValues(input)
+		again := ParseComponentValues(Serialize(values))
+		if got, want := describeTokens(flatten(again)), describeTokens(flatten(values)); got != want {
+			t.Errorf("%q → %q: получено %s, ожидалось %s", input, Serialize(values), got, want)
+		}
+	}
+}
+
+// flatten разворачивает компонентные значения обратно в токены
+func flatten(values []ComponentValue) []Token {
+	var tokens []Token
+	for _, v := range values {
+		tokens = append(tokens, v.Token)
+		tokens = append(tokens, flatten(v.Children)...)
+	}
+	return tokens
+}
@@ -0,0 +1,252 @@
+package css
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Serialize записывает компонентные значения обратно в текст CSS. Между
+// токенами, которые при повторном разборе слились бы в один, вставляется
+// пустой комментарий
+func Serialize(values []ComponentValue) string {
+	s := &serializer{}
+	s.values(values)
+	return s.sb.String()
+}
+
+// String возвращает значение в виде текста CSS
+func (v ComponentValue) String() string {
+	return Serialize([]ComponentValue{v})
+}
+
+// String возвращает токен в виде текста CSS
+func (t Token) String() string {
+	return Serialize([]ComponentValue{{Token: t}})
+}
+
+// serializer накапливает текст и помнит последний записанный токен
+type serializer struct {
+	sb   strings.Builder
+	last Token
+	any  bool
+}
+
+func (s *serializer) values(values []ComponentValue) {
+	for _, v := range values {
+		s.token(v.Token)
+		if v.Type != FunctionToken && !v.IsBlock() {
+			continue
+		}
+		s.values(v.Children)
+		switch v.Type {
+		case FunctionToken, OpenParenToken:
+			s.token(Token{Type: CloseParenToken})
+		case OpenSquareToken:
+			s.token(Token{Type: CloseSquareToken})
+		case OpenCurlyToken:
+			s.token(Token{Type: CloseCurlyToken})
+		}
+	}
+}
+
+func (s *serializer) token(t Token) {
+	if s.any && needsSeparator(s.last, t) {
+		s.sb.WriteString("/**/")
+	}
+	s.last, s.any = t, true
+
+	switch t.Type {
+	case IdentToken:
+		s.sb.WriteString(escapeIdent(t.Value))
+	case FunctionToken:
+		s.sb.WriteString(escapeIdent(t.Value))
+		s.sb.WriteByte('(')
+	case AtKeywordToken:
+		s.sb.WriteByte('@')
+		s.sb.WriteString(escapeIdent(t.Value))
+	case HashToken:
+		s.sb.WriteByte('#')
+		if t.ID {
+			s.sb.WriteString(escapeIdent(t.Value))
+		} else {
+			s.sb.WriteString(escapeName(t.Value))
+		}
+	case StringToken:
+		s.sb.WriteString(QuoteString(t.Value))
+	case BadStringToken:
+		s.sb.WriteString(`"`)
+	case URLToken:
+		s.sb.WriteString("url(")
+		s.sb.WriteString(escapeURL(t.Value))
+		s.sb.WriteByte(')')
+	case BadURLToken:
+		s.sb.WriteString("url()")
+	case DelimToken:
+		if t.Value == `\` {
+			s.sb.WriteString("\\\n")
+		} else {
+			s.sb.WriteString(t.Value)
+		}
+	case NumberToken:
+		s.sb.WriteString(numberRepr(t))
+	case PercentageToken:
+		s.sb.WriteString(numberRepr(t))
+		s.sb.WriteByte('%')
+	case DimensionToken:
+		s.sb.WriteString(numberRepr(t))
+		s.sb.WriteString(escapeUnit(t.Unit))
+	case WhitespaceToken:
+		s.sb.WriteByte(' ')
+	case CDOToken:
+		s.sb.WriteString("<!--")
+	case CDCToken:
+		s.sb.WriteString("-->")
+	case ColonToken:
+		s.sb.WriteByte(':')
+	case SemicolonToken:
+		s.sb.WriteByte(';')
+	case CommaToken:
+		s.sb.WriteByte(',')
+	case OpenSquareToken:
+		s.sb.WriteByte('[')
+	case CloseSquareToken:
+		s.sb.WriteByte(']')
+	case OpenParenToken:
+		s.sb.WriteByte('(')
+	case CloseParenToken:
+		s.sb.WriteByte(')')
+	case OpenCurlyToken:
+		s.sb.WriteByte('{')
+	case CloseCurlyToken:
+		s.sb.WriteByte('}')
+	}
+}
+
+// needsSeparator проверяет по таблице из CSS Syntax, слились бы два токена
+// при повторном разборе
+func needsSeparator(a, b Token) bool {
+	identLike := b.Type == IdentToken || b.Type == FunctionToken || b.Type == URLToken ||
+		b.Type == BadURLToken
+	numeric := b.Type == NumberToken || b.Type == PercentageToken || b.Type == DimensionToken
+	minus := b.Type == DelimToken && b.Value == "-"
+
+	switch {
+	case a.Type == IdentToken:
+		return identLike || minus || numeric || b.Type == CDCToken || b.Type == OpenParenToken
+	case a.Type == AtKeywordToken || a.Type == HashToken || a.Type == DimensionToken:
+		return identLike || minus || numeric || b.Type == CDCToken
+	case a.Type == DelimToken && (a.Value == "#" || a.Value == "-"):
+		return identLike || minus || numeric
+	case a.Type == NumberToken:
+		return identLike || numeric || b.Type == DelimToken && b.Value == "%"
+	case a.Type == DelimToken && a.Value == "@":
+		return identLike || minus
+	case a.Type == DelimToken && (a.Value == "." || a.Value == "+"):
+		return numeric
+	case a.Type == DelimToken && a.Value == "/":
+		return b.Type == DelimToken && b.Value == "*"
+	}
+	return false
+}
+
+// numberRepr возвращает запись числа: исходную, если она есть
+func numberRepr(t Token) string {
+	if t.Repr != "" {
+		return t.Repr
+	}
+	if t.Integer {
+		return strconv.FormatInt(int64(t.Number), 10)
+	}
+	return strconv.FormatFloat(t.Number, 'f', -1, 64)
+}
+
+// escapeUnit экранирует единицу размерности. Единица, начинающаяся с «e» и
+// цифры, иначе была бы прочитана как показатель степени
+func escapeUnit(unit string) string {
+	escaped := escapeIdent(unit)
+	if len(unit) >= 2 && (unit[0] == 'e' || unit[0] == 'E') &&
+		(isDigit(rune(unit[1])) || (unit[1] == '-' || unit[1] == '+') && len(unit) > 2 && isDigit(rune(unit[2]))) {
+		return `\` + strconv.FormatInt(int64(unit[0]), 16) + " " + escaped[1:]
+	}
+	return escaped
+}
+
+// escapeIdent экранирует идентификатор по алгоритму CSSOM
+func escapeIdent(s string) string {
+	var sb strings.Builder
+	runes := []rune(s)
+	for i, c := range runes {
+		switch {
+		case c == 0:
+			sb.WriteRune('�')
+		case c >= 1 && c <= 0x1F || c == 0x7F,
+			i == 0 && isDigit(c),
+			i == 1 && isDigit(c) && runes[0] == '-':
+			sb.WriteString(`\` + strconv.FormatInt(int64(c), 16) + " ")
+		case i == 0 && c == '-' && len(runes) == 1:
+			sb.WriteString(`\-`)
+		case isNameCodePoint(c):
+			sb.WriteRune(c)
+		default:
+			sb.WriteByte('\\')
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+// escapeName экранирует последовательность символов имени (значение хеша,
+// которое может начинаться с цифры)
+func escapeName(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		switch {
+		case c >= 1 && c <= 0x1F || c == 0x7F:
+			sb.WriteString(`\` + strconv.FormatInt(int64(c), 16) + " ")
+		case isNameCodePoint(c):
+			sb.WriteRune(c)
+		default:
+			sb.WriteByte('\\')
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+// escapeURL экранирует адрес url() без кавычек
+func escapeURL(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		switch {
+		case isWhitespace(c) || isNonPrintable(c):
+			sb.WriteString(`\` + strconv.FormatInt(int64(c), 16) + " ")
+		case c == '"' || c == '\'' || c == '(' || c == ')' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(c)
+		default:
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+// QuoteString записывает строку CSS в двойных кавычках с экранированием
+func QuoteString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, c := range s {
+		switch {
+		case c == 0:
+			sb.WriteRune('�')
+		case c >= 1 && c <= 0x1F || c == 0x7F:
+			sb.WriteString(`\` + strconv.FormatInt(int64(c), 16) + " ")
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(c)
+		default:
+			sb.WriteRune(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
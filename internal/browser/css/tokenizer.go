@@ -0,0 +1,506 @@
+package css
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenType определяет тип токена CSS (CSS Syntax Level 3)
+type TokenType int
+
+const (
+	// EOFToken означает конец входного потока
+	EOFToken TokenType = iota
+	IdentToken
+	FunctionToken
+	AtKeywordToken
+	HashToken
+	StringToken
+	BadStringToken
+	URLToken
+	BadURLToken
+	DelimToken
+	NumberToken
+	PercentageToken
+	DimensionToken
+	WhitespaceToken
+	CDOToken
+	CDCToken
+	ColonToken
+	SemicolonToken
+	CommaToken
+	OpenSquareToken
+	CloseSquareToken
+	OpenParenToken
+	CloseParenToken
+	OpenCurlyToken
+	CloseCurlyToken
+)
+
+// String возвращает имя типа токена
+func (t TokenType) String() string {
+	switch t {
+	case EOFToken:
+		return "EOF"
+	case IdentToken:
+		return "ident"
+	case FunctionToken:
+		return "function"
+	case AtKeywordToken:
+		return "at-keyword"
+	case HashToken:
+		return "hash"
+	case StringToken:
+		return "string"
+	case BadStringToken:
+		return "bad-string"
+	case URLToken:
+		return "url"
+	case BadURLToken:
+		return "bad-url"
+	case DelimToken:
+		return "delim"
+	case NumberToken:
+		return "number"
+	case PercentageToken:
+		return "percentage"
+	case DimensionToken:
+		return "dimension"
+	case WhitespaceToken:
+		return "whitespace"
+	case CDOToken:
+		return "CDO"
+	case CDCToken:
+		return "CDC"
+	case ColonToken:
+		return "colon"
+	case SemicolonToken:
+		return "semicolon"
+	case CommaToken:
+		return "comma"
+	case OpenSquareToken:
+		return "["
+	case CloseSquareToken:
+		return "]"
+	case OpenParenToken:
+		return "("
+	case CloseParenToken:
+		return ")"
+	case OpenCurlyToken:
+		return "{"
+	case CloseCurlyToken:
+		return "}"
+	}
+	return "unknown"
+}
+
+// Token представляет токен CSS
+type Token struct {
+	Type TokenType
+	// Value — имя идентификатора, функции, @-правила или хеша (без «#» и «@»),
+	// текст строки, адрес url() или символ Delim
+	Value string
+	// Number — значение числа, процента или размерности. Repr — его запись в
+	// исходном тексте, Integer — записано ли оно как целое
+	Number  float64
+	Repr    string
+	Integer bool
+	// Unit — единица размерности (px, em...) в том виде, в каком она записана
+	Unit string
+	// ID означает, что значение хеша — корректный идентификатор (#main, но не #1a)
+	ID bool
+}
+
+// Tokenize разбивает текст таблицы стилей на токены. Комментарии пропускаются,
+// EOFToken в конец не добавляется
+func Tokenize(input string) []Token {
+	t := newTokenizer(input)
+	var tokens []Token
+	for {
+		token := t.next()
+		if token.Type == EOFToken {
+			return tokens
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// tokenizer — токенизатор CSS
+type tokenizer struct {
+	input []rune
+	pos   int
+}
+
+// newTokenizer создает токенизатор. Переводы строк нормализуются, а NUL
+// заменяется на U+FFFD, как требует предварительная обработка потока
+func newTokenizer(input string) *tokenizer {
+	if !utf8.ValidString(input) {
+		input = strings.ToValidUTF8(input, "�")
+	}
+	input = strings.NewReplacer("\r\n", "\n", "\r", "\n", "\f", "\n", "\x00", "�").Replace(input)
+	return &tokenizer{input: []rune(input)}
+}
+
+// eof — символ, который возвращается за концом входного потока
+const eof = -1
+
+// at возвращает символ со смещением i от текущей позиции или eof
+func (t *tokenizer) at(i int) rune {
+	if t.pos+i >= len(t.input) {
+		return eof
+	}
+	return t.input[t.pos+i]
+}
+
+// consume возвращает текущий символ и переходит к следующему
+func (t *tokenizer) consume() rune {
+	c := t.at(0)
+	if c != eof {
+		t.pos++
+	}
+	return c
+}
+
+// next возвращает очередной токен
+func (t *tokenizer) next() Token {
+	t.consumeComments()
+	c := t.consume()
+	switch {
+	case c == eof:
+		return Token{Type: EOFToken}
+	case isWhitespace(c):
+		for isWhitespace(t.at(0)) {
+			t.pos++
+		}
+		return Token{Type: WhitespaceToken}
+	case c == '"' || c == '\'':
+		return t.consumeString(c)
+	case c == '#':
+		if isNameCodePoint(t.at(0)) || isValidEscape(t.at(0), t.at(1)) {
+			id := startsIdent(t.at(0), t.at(1), t.at(2))
+			return Token{Type: HashToken, Value: t.consumeName(), ID: id}
+		}
+	case c == '(':
+		return Token{Type: OpenParenToken}
+	case c == ')':
+		return Token{Type: CloseParenToken}
+	case c == '[':
+		return Token{Type: OpenSquareToken}
+	case c == ']':
+		return Token{Type: CloseSquareToken}
+	case c == '{':
+		return Token{Type: OpenCurlyToken}
+	case c == '}':
+		return Token{Type: CloseCurlyToken}
+	case c == ',':
+		return Token{Type: CommaToken}
+	case c == ':':
+		return Token{Type: ColonToken}
+	case c == ';':
+		return Token{Type: SemicolonToken}
+	case c == '+' || c == '.':
+		if startsNumber(c, t.at(0), t.at(1)) {
+			t.pos--
+			return t.consumeNumeric()
+		}
+	case c == '-':
+		if startsNumber(c, t.at(0), t.at(1)) {
+			t.pos--
+			return t.consumeNumeric()
+		}
+		if t.at(0) == '-' && t.at(1) == '>' {
+			t.pos += 2
+			return Token{Type: CDCToken}
+		}
+		if startsIdent(c, t.at(0), t.at(1)) {
+			t.pos--
+			return t.consumeIdentLike()
+		}
+	case c == '<':
+		if t.at(0) == '!' && t.at(1) == '-' && t.at(2) == '-' {
+			t.pos += 3
+			return Token{Type: CDOToken}
+		}
+	case c == '@':
+		if startsIdent(t.at(0), t.at(1), t.at(2)) {
+			return Token{Type: AtKeywordToken, Value: t.consumeName()}
+		}
+	case c == '\\':
+		if isValidEscape(c, t.at(0)) {
+			t.pos--
+			return t.consumeIdentLike()
+		}
+	case isDigit(c):
+		t.pos--
+		return t.consumeNumeric()
+	case isNameStartCodePoint(c):
+		t.pos--
+		return t.consumeIdentLike()
+	}
+	return Token{Type: DelimToken, Value: string(c)}
+}
+
+// consumeComments пропускает комментарии /* ... */
+func (t *tokenizer) consumeComments() {
+	for t.at(0) == '/' && t.at(1) == '*' {
+		t.pos += 2
+		for t.at(0) != eof && !(t.at(0) == '*' && t.at(1) == '/') {
+			t.pos++
+		}
+		if t.at(0) != eof {
+			t.pos += 2
+		}
+	}
+}
+
+// consumeNumeric читает число, процент или размерность
+func (t *tokenizer) consumeNumeric() Token {
+	repr, integer := t.consumeNumber()
+	number, _ := strconv.ParseFloat(repr, 64)
+	token := Token{Type: NumberToken, Number: number, Repr: repr, Integer: integer}
+	if startsIdent(t.at(0), t.at(1), t.at(2)) {
+		token.Type = DimensionToken
+		token.Unit = t.consumeName()
+	} else if t.at(0) == '%' {
+		t.pos++
+		token.Type = PercentageToken
+	}
+	return token
+}
+
+// consumeNumber читает запись числа и сообщает, целое ли оно
+func (t *tokenizer) consumeNumber() (string, bool) {
+	var sb strings.Builder
+	integer := true
+	if c := t.at(0); c == '+' || c == '-' {
+		sb.WriteRune(t.consume())
+	}
+	t.consumeDigits(&sb)
+	if t.at(0) == '.' && isDigit(t.at(1)) {
+		sb.WriteRune(t.consume())
+		t.consumeDigits(&sb)
+		integer = false
+	}
+	if c := t.at(0); c == 'e' || c == 'E' {
+		sign := t.at(1) == '+' || t.at(1) == '-'
+		if isDigit(t.at(1)) || sign && isDigit(t.at(2)) {
+			sb.WriteRune(t.consume())
+			if sign {
+				sb.WriteRune(t.consume())
+			}
+			t.consumeDigits(&sb)
+			integer = false
+		}
+	}
+	return sb.String(), integer
+}
+
+func (t *tokenizer) consumeDigits(sb *strings.Builder) {
+	for isDigit(t.at(0)) {
+		sb.WriteRune(t.consume())
+	}
+}
+
+// consumeIdentLike читает идентификатор, функцию или url()
+func (t *tokenizer) consumeIdentLike() Token {
+	name := t.consumeName()
+	if t.at(0) != '(' {
+		return Token{Type: IdentToken, Value: name}
+	}
+	t.pos++
+	if strings.EqualFold(name, "url") {
+		// url("...") с кавычками — обычная функция, без кавычек — токен url
+		for isWhitespace(t.at(0)) && isWhitespace(t.at(1)) {
+			t.pos++
+		}
+		c := t.at(0)
+		if isWhitespace(c) {
+			c = t.at(1)
+		}
+		if c != '"' && c != '\'' {
+			return t.consumeURL()
+		}
+	}
+	return Token{Type: FunctionToken, Value: name}
+}
+
+// consumeString читает строку до закрывающей кавычки. Перевод строки внутри
+// строки делает ее некорректной
+func (t *tokenizer) consumeString(ending rune) Token {
+	var sb strings.Builder
+	for {
+		c := t.consume()
+		switch {
+		case c == ending || c == eof:
+			return Token{Type: StringToken, Value: sb.String()}
+		case c == '\n':
+			t.pos--
+			return Token{Type: BadStringToken}
+		case c == '\\':
+			if t.at(0) == eof {
+				continue
+			}
+			if t.at(0) == '\n' {
+				t.pos++
+				continue
+			}
+			sb.WriteRune(t.consumeEscape())
+		default:
+			sb.WriteRune(c)
+		}
+	}
+}
+
+// consumeURL читает адрес url() без кавычек
+func (t *tokenizer) consumeURL() Token {
+	var sb strings.Builder
+	for isWhitespace(t.at(0)) {
+		t.pos++
+	}
+	for {
+		c := t.consume()
+		switch {
+		case c == ')' || c == eof:
+			return Token{Type: URLToken, Value: sb.String()}
+		case isWhitespace(c):
+			for isWhitespace(t.at(0)) {
+				t.pos++
+			}
+			if t.at(0) == ')' || t.at(0) == eof {
+				t.consume()
+				return Token{Type: URLToken, Value: sb.String()}
+			}
+			t.consumeBadURL()
+			return Token{Type: BadURLToken}
+		case c == '"' || c == '\'' || c == '(' || isNonPrintable(c):
+			t.consumeBadURL()
+			return Token{Type: BadURLToken}
+		case c == '\\':
+			if !isValidEscape(c, t.at(0)) {
+				t.consumeBadURL()
+				return Token{Type: BadURLToken}
+			}
+			sb.WriteRune(t.consumeEscape())
+		default:
+			sb.WriteRune(c)
+		}
+	}
+}
+
+// consumeBadURL пропускает остаток некорректного url() до закрывающей скобки
+func (t *tokenizer) consumeBadURL() {
+	for {
+		c := t.consume()
+		if c == ')' || c == eof {
+			return
+		}
+		if isValidEscape(c, t.at(0)) {
+			t.consumeEscape()
+		}
+	}
+}
+
+// consumeName читает последовательность символов идентификатора с экранированием
+func (t *tokenizer) consumeName() string {
+	var sb strings.Builder
+	for {
+		c := t.at(0)
+		switch {
+		case isNameCodePoint(c):
+			sb.WriteRune(t.consume())
+		case isValidEscape(c, t.at(1)):
+			t.pos++
+			sb.WriteRune(t.consumeEscape())
+		default:
+			return sb.String()
+		}
+	}
+}
+
+// consumeEscape читает экранированный символ после обратной косой черты:
+// до шести шестнадцатеричных цифр с необязательным пробелом или сам символ
+func (t *tokenizer) consumeEscape() rune {
+	c := t.consume()
+	if c == eof {
+		return utf8.RuneError
+	}
+	if !isHexDigit(c) {
+		return c
+	}
+	value := hexValue(c)
+	for i := 0; i < 5 && isHexDigit(t.at(0)); i++ {
+		value = value*16 + hexValue(t.consume())
+	}
+	if isWhitespace(t.at(0)) {
+		t.pos++
+	}
+	if value == 0 || value >= 0xD800 && value <= 0xDFFF || value > utf8.MaxRune {
+		return utf8.RuneError
+	}
+	return value
+}
+
+func isWhitespace(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c rune) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func hexValue(c rune) rune {
+	switch {
+	case isDigit(c):
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+func isNameStartCodePoint(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80 || c == '_'
+}
+
+func isNameCodePoint(c rune) bool {
+	return isNameStartCodePoint(c) || isDigit(c) || c == '-'
+}
+
+func isNonPrintable(c rune) bool {
+	return c >= 0 && c <= 8 || c == 0x0B || c >= 0x0E && c <= 0x1F || c == 0x7F
+}
+
+// isValidEscape проверяет, начинают ли два символа экранирование. Обратная
+// косая черта в конце потока тоже начинает экранирование, которое дает U+FFFD
+func isValidEscape(c1, c2 rune) bool {
+	return c1 == '\\' && c2 != '\n'
+}
+
+// startsIdent проверяет, начинают ли три символа идентификатор
+func startsIdent(c1, c2, c3 rune) bool {
+	switch {
+	case c1 == '-':
+		return isNameStartCodePoint(c2) || c2 == '-' || isValidEscape(c2, c3)
+	case isNameStartCodePoint(c1):
+		return true
+	case c1 == '\\':
+		return isValidEscape(c1, c2)
+	}
+	return false
+}
+
+// startsNumber проверяет, начинают ли три символа число
+func startsNumber(c1, c2, c3 rune) bool {
+	switch {
+	case c1 == '+' || c1 == '-':
+		return isDigit(c2) || c2 == '.' && isDigit(c3)
+	case c1 == '.':
+		return isDigit(c2)
+	}
+	return isDigit(c1)
+}
@@ -0,0 +1,115 @@
+package css
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// describeTokens записывает токены в виде «тип(значение)» через пробел
+func describeTokens(tokens []Token) string {
+	list := make([]string, len(tokens))
+	for i, t := range tokens {
+		switch t.Type {
+		case IdentToken, FunctionToken, AtKeywordToken, StringToken, URLToken, DelimToken:
+			list[i] = fmt.Sprintf("%s(%s)", t.Type, t.Value)
+		case HashToken:
+			kind := "unrestricted"
+			if t.ID {
+				kind = "id"
+			}
+			list[i] = fmt.Sprintf("hash(%s %s)", t.Value, kind)
+		case NumberToken, PercentageToken:
+			list[i] = fmt.Sprintf("%s(%s=%g)", t.Type, t.Repr, t.Number)
+		case DimensionToken:
+			list[i] = fmt.Sprintf("dimension(%s=%g %s)", t.Repr, t.Number, t.Unit)
+		case WhitespaceToken:
+			list[i] = "ws"
+		default:
+			list[i] = t.Type.String()
+		}
+	}
+	return strings.Join(list, " ")
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		// Экранирование
+		{`\41 bc`, "ident(Abc)"},
+		{`\41  bc`, "ident(A) ws ident(bc)"},
+		{`a\:b`, "ident(a:b)"},
+		{`\0 x`, "ident(�x)"},
+		{`\110000`, "ident(�)"},
+		{`\D800`, "ident(�)"},
+		{"a\\", "ident(a�)"},
+		{"\\", "ident(�)"},
+		{"\\\nx", "delim(\\) ws ident(x)"},
+		{`#\31 a`, "hash(1a id)"},
+		{`#1a`, "hash(1a unrestricted)"},
+		{`#-a`, "hash(-a id)"},
+		{`@\6d edia`, "at-keyword(media)"},
+
+		// Строки
+		{`"a\"b"`, `string(a"b)`},
+		{`'a"b'`, `string(a"b)`},
+		{"\"a\\\nb\"", "string(ab)"},
+		{`"a\62 c"`, "string(abc)"},
+		{`"abc`, "string(abc)"},
+		{"\"abc\nd", "bad-string ws ident(d)"},
+		{"'a\r\n'", "bad-string ws string()"},
+
+		// url()
+		{"url(foo.png)", "url(foo.png)"},
+		{"URL(  foo.png \t)", "url(foo.png)"},
+		{`url(a\)b)`, "url(a)b)"},
+		{"url(foo", "url(foo)"},
+		{"url(a\\", "url(a�)"},
+		{"url()", "url()"},
+		{`url("x.png")`, "function(url) string(x.png) )"},
+		{`url( 'x' )`, "function(url) ws string(x) ws )"},
+		{"url(foo bar) x", "bad-url ws ident(x)"},
+		{`url(a"b) x`, "bad-url ws ident(x)"},
+		{"url(a(b) x", "bad-url ws ident(x)"},
+		{"url(a\\\n) x", "bad-url ws ident(x)"},
+		{`url(a b\)c) x`, "bad-url ws ident(x)"},
+
+		// Числа
+		{"10px", "dimension(10=10 px)"},
+		{"+.5e2", "number(+.5e2=50)"},
+		{"-1.5E-1%", "percentage(-1.5E-1=-0.15)"},
+		{"1e", "dimension(1=1 e)"},
+		{"1e+", "dimension(1=1 e) delim(+)"},
+		{"1.", "number(1=1) delim(.)"},
+		{"1--x", "dimension(1=1 --x)"},
+		{`2\70 x`, "dimension(2=2 px)"},
+		{"-x --y -", "ident(-x) ws ident(--y) ws delim(-)"},
+
+		// <!-- и -->, комментарии, прочие токены
+		{"<!-- -->", "CDO ws CDC"},
+		{"<!-x", "delim(<) delim(!) ident(-x)"},
+		{"a-->", "ident(a--) delim(>)"},
+		{"a/* x */b", "ident(a) ident(b)"},
+		{"a /* незакрытый", "ident(a) ws"},
+		{"a!important", "ident(a) delim(!) ident(important)"},
+		{"f(x)[y]{z}", "function(f) ident(x) ) [ ident(y) ] { ident(z) }"},
+		{"a:b;c,d", "ident(a) colon ident(b) semicolon ident(c) comma ident(d)"},
+		{"été \U0001F600", "ident(été) ws ident(\U0001F600)"},
+	}
+	for _, test := range tests {
+		if got := describeTokens(Tokenize(test.input)); got != test.want {
+			t.Errorf("%q: получено %s, ожидалось %s", test.input, got, test.want)
+		}
+	}
+}
+
+func TestTokenIntegerFlag(t *testing.T) {
+	for input, want := range map[string]bool{"1": true, "+1": true, "1.0": false, "1e2": false, "10px": true, "1.5%": false} {
+		tokens := Tokenize(input)
+		if len(tokens) != 1 || tokens[0].Integer != want {
+			t.Errorf("%q: Integer = %v, ожидалось %v", input, len(tokens) == 1 && tokens[0].Integer, want)
+		}
+	}
+}
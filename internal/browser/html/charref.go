@@ -0,0 +1,231 @@
+package html
+
+import (
+	"unicode/utf8"
+)
+
+// c1Replacements — замены для числовых ссылок на управляющие символы C1,
+// которые на практике означают символы Windows-1252
+var c1Replacements = map[rune]rune{
+	0x80: 0x20AC, 0x82: 0x201A, 0x83: 0x0192, 0x84: 0x201E,
+	0x85: 0x2026, 0x86: 0x2020, 0x87: 0x2021, 0x88: 0x02C6,
+	0x89: 0x2030, 0x8A: 0x0160, 0x8B: 0x2039, 0x8C: 0x0152,
+	0x8E: 0x017D, 0x91: 0x2018, 0x92: 0x2019, 0x93: 0x201C,
+	0x94: 0x201D, 0x95: 0x2022, 0x96: 0x2013, 0x97: 0x2014,
+	0x98: 0x02DC, 0x99: 0x2122, 0x9A: 0x0161, 0x9B: 0x203A,
+	0x9C: 0x0153, 0x9E: 0x017E, 0x9F: 0x0178,
+}
+
+// characterReference разбирает ссылку на символ после '&'. Состояния
+// "character reference", "named character reference" и начало числовой
+// ссылки выполняются здесь целиком: им нужно заглянуть вперед не больше чем
+// на streamLookahead символов. Цифры числовой ссылки и буквы после
+// неизвестного имени, длина которых не ограничена, читаются отдельными
+// состояниями, после чего токенизатор возвращается в returnState
+func (t *Tokenizer) characterReference(returnState tokenizerState) {
+	t.returnState = returnState
+	t.state = returnState
+	t.tempBuf = append(t.tempBuf[:0], '&')
+
+	c := t.peek(0)
+	switch {
+	case isASCIIAlphanumeric(c):
+		t.namedCharacterReference()
+	case c == '#':
+		t.pos++
+		t.tempBuf = append(t.tempBuf, '#')
+		t.numericCharacterReference()
+	default:
+		t.flushCharacterReference()
+	}
+}
+
+// namedCharacterReference ищет самое длинное имя из таблицы, совпадающее с входом
+func (t *Tokenizer) namedCharacterReference() {
+	// Кандидат — буквы и цифры подряд и, возможно, завершающая точка с запятой
+	n := 0
+	for n < longestCharacterReference && isASCIIAlphanumeric(t.peek(n)) {
+		n++
+	}
+	if n < longestCharacterReference && t.peek(n) == ';' {
+		n++
+	}
+
+	for ; n > 0; n-- {
+		name := string(t.input[t.pos : t.pos+n])
+		value, ok := namedCharacterReferences[name]
+		if !ok {
+			continue
+		}
+
+		t.pos += n
+		t.tempBuf = append(t.tempBuf, []rune(name)...)
+		last := name[len(name)-1]
+		if last != ';' && t.inAttributeValue() {
+			// По историческим причинам "&amp=" и "&ampx" в атрибутах остаются как есть
+			if next := t.peek(0); next == '=' || isASCIIAlphanumeric(next) {
+				t.flushCharacterReference()
+				return
+			}
+		}
+		if last != ';' {
+			t.parseErrorAt("missing-semicolon-after-character-reference", t.pos)
+		}
+		t.tempBuf = append(t.tempBuf[:0], []rune(value)...)
+		t.flushCharacterReference()
+		return
+	}
+
+	// Имя не найдено: амперсанд остается текстом
+	t.flushCharacterReference()
+	t.state = ambiguousAmpersandState
+}
+
+// ambiguousAmpersand выполняет шаг состояния "ambiguous ampersand": буквы и
+// цифры после амперсанда без известного имени остаются как есть
+func (t *Tokenizer) ambiguousAmpersand(c rune) {
+	switch {
+	case isASCIIAlphanumeric(c):
+		if t.inAttributeValue() {
+			t.appendAttrValue(c)
+		} else {
+			t.emitChar(c)
+		}
+	case c == ';':
+		t.parseError("unknown-named-character-reference")
+		t.reconsume(c, t.returnState)
+	default:
+		t.reconsume(c, t.returnState)
+	}
+}
+
+// numericCharacterReference разбирает десятичную или шестнадцатеричную ссылку
+func (t *Tokenizer) numericCharacterReference() {
+	t.charRefBase = 10
+	if c := t.peek(0); c == 'x' || c == 'X' {
+		t.pos++
+		t.tempBuf = append(t.tempBuf, c)
+		t.charRefBase = 16
+	}
+
+	if !t.isCharRefDigit(t.peek(0)) {
+		t.parseErrorAt("absence-of-digits-in-numeric-character-reference", t.pos)
+		t.flushCharacterReference()
+		return
+	}
+	t.charRefCode = 0
+	t.state = numericCharacterReferenceState
+}
+
+// numericCharacterReferenceDigit выполняет шаг состояний "decimal character
+// reference" и "hexadecimal character reference"
+func (t *Tokenizer) numericCharacterReferenceDigit(c rune) {
+	if t.isCharRefDigit(c) {
+		t.charRefCode = t.charRefCode*t.charRefBase + hexValue(c)
+		// Дальнейшие цифры уже не изменят результат: значение вне диапазона Unicode
+		if t.charRefCode > utf8.MaxRune {
+			t.charRefCode = utf8.MaxRune + 1
+		}
+		return
+	}
+
+	if c != ';' {
+		t.reconsume(c, t.returnState)
+		t.parseErrorAt("missing-semicolon-after-character-reference", t.pos)
+	}
+	t.state = t.returnState
+
+	code := t.charRefCode
+	switch {
+	case code == 0:
+		t.parseErrorAt("null-character-reference", t.pos)
+		code = utf8.RuneError
+	case code > utf8.MaxRune:
+		t.parseErrorAt("character-reference-outside-unicode-range", t.pos)
+		code = utf8.RuneError
+	case code >= 0xD800 && code <= 0xDFFF:
+		t.parseErrorAt("surrogate-character-reference", t.pos)
+		code = utf8.RuneError
+	case isNoncharacter(code):
+		t.parseErrorAt("noncharacter-character-reference", t.pos)
+	case code == 0x0D || isControl(code) && !isHTMLSpace(code):
+		t.parseErrorAt("control-character-reference", t.pos)
+		if r, ok := c1Replacements[code]; ok {
+			code = r
+		}
+	}
+
+	t.tempBuf = append(t.tempBuf[:0], code)
+	t.flushCharacterReference()
+}
+
+// isCharRefDigit проверяет, является ли символ цифрой текущей числовой ссылки
+func (t *Tokenizer) isCharRefDigit(c rune) bool {
+	if t.charRefBase == 16 {
+		return isASCIIHexDigit(c)
+	}
+	return isASCIIDigit(c)
+}
+
+// flushCharacterReference выдает символы из временного буфера: в значение
+// атрибута, если ссылка встретилась в нем, иначе в текст
+func (t *Tokenizer) flushCharacterReference() {
+	for _, c := range t.tempBuf {
+		if t.inAttributeValue() {
+			t.appendAttrValue(c)
+		} else {
+			t.emitChar(c)
+		}
+	}
+	t.tempBuf = t.tempBuf[:0]
+}
+
+// inAttributeValue проверяет, разбирается ли ссылка внутри значения атрибута
+func (t *Tokenizer) inAttributeValue() bool {
+	switch t.returnState {
+	case attributeValueDoubleQuotedState, attributeValueSingleQuotedState, attributeValueUnquotedState:
+		return true
+	}
+	return false
+}
+
+// peek возвращает символ на расстоянии i от текущей позиции, не потребляя его
+func (t *Tokenizer) peek(i int) rune {
+	if t.pos+i >= len(t.input) {
+		return eof
+	}
+	return t.input[t.pos+i]
+}
+
+func isASCIIDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isASCIIHexDigit(c rune) bool {
+	return isASCIIDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isASCIIAlphanumeric(c rune) bool {
+	return isASCIIDigit(c) || isASCIIAlpha(c)
+}
+
+// hexValue возвращает значение цифры (десятичной или шестнадцатеричной)
+func hexValue(c rune) rune {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
+
+// isNoncharacter проверяет, является ли код несимволом Unicode
+func isNoncharacter(c rune) bool {
+	return c >= 0xFDD0 && c <= 0xFDEF || c&0xFFFE == 0xFFFE
+}
+
+// isControl проверяет, является ли код управляющим символом
+func isControl(c rune) bool {
+	return c <= 0x1F || c >= 0x7F && c <= 0x9F
+}
@@ -0,0 +1,246 @@
+package html
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Разбор содержимого SVG и MathML (раздел «foreign content» спецификации)
+
+// svgTagNames восстанавливает регистр имен элементов SVG, которые
+// токенизатор привел к нижнему регистру
+var svgTagNames = caseTable(
+	"altGlyph", "altGlyphDef", "altGlyphItem", "animateColor", "animateMotion",
+	"animateTransform", "clipPath", "feBlend", "feColorMatrix", "feComponentTransfer",
+	"feComposite", "feConvolveMatrix", "feDiffuseLighting", "feDisplacementMap",
+	"feDistantLight", "feDropShadow", "feFlood", "feFuncA", "feFuncB", "feFuncG",
+	"feFuncR", "feGaussianBlur", "feImage", "feMerge", "feMergeNode", "feMorphology",
+	"feOffset", "fePointLight", "feSpecularLighting", "feSpotLight", "feTile",
+	"feTurbulence", "foreignObject", "glyphRef", "linearGradient", "radialGradient",
+	"textPath",
+)
+
+// svgAttributeNames восстанавливает регистр имен атрибутов SVG
+var svgAttributeNames = caseTable(
+	"attributeName", "attributeType", "baseFrequency", "baseProfile", "calcMode",
+	"clipPathUnits", "diffuseConstant", "edgeMode", "filterUnits", "glyphRef",
+	"gradientTransform", "gradientUnits", "kernelMatrix", "kernelUnitLength",
+	"keyPoints", "keySplines", "keyTimes", "lengthAdjust", "limitingConeAngle",
+	"markerHeight", "markerUnits", "markerWidth", "maskContentUnits", "maskUnits",
+	"numOctaves", "pathLength", "patternContentUnits", "patternTransform",
+	"patternUnits", "pointsAtX", "pointsAtY", "pointsAtZ", "preserveAlpha",
+	"preserveAspectRatio", "primitiveUnits", "refX", "refY", "repeatCount",
+	"repeatDur", "requiredExtensions", "requiredFeatures", "specularConstant",
+	"specularExponent", "spreadMethod", "startOffset", "stdDeviation", "stitchTiles",
+	"surfaceScale", "systemLanguage", "tableValues", "targetX", "targetY",
+	"textLength", "viewBox", "viewTarget", "xChannelSelector", "yChannelSelector",
+	"zoomAndPan",
+)
+
+// mathMLAttributeNames восстанавливает регистр имен атрибутов MathML
+var mathMLAttributeNames = caseTable("definitionURL")
+
+// caseTable строит таблицу «имя в нижнем регистре → имя из спецификации»
+func caseTable(names ...string) map[string]string {
+	table := make(map[string]string, len(names))
+	for _, name := range names {
+		table[strings.ToLower(name)] = name
+	}
+	return table
+}
+
+// foreignAttributeNamespaces — атрибуты с префиксами, которые внутри SVG и
+// MathML получают пространство имен
+var foreignAttributeNamespaces = map[string]string{
+	"xlink:actuate": XLinkNamespace,
+	"xlink:arcrole": XLinkNamespace,
+	"xlink:href":    XLinkNamespace,
+	"xlink:role":    XLinkNamespace,
+	"xlink:show":    XLinkNamespace,
+	"xlink:title":   XLinkNamespace,
+	"xlink:type":    XLinkNamespace,
+	"xml:lang":      XMLNamespace,
+	"xml:space":     XMLNamespace,
+	"xmlns":         XMLNSNamespace,
+	"xmlns:xlink":   XMLNSNamespace,
+}
+
+// breakoutElements — HTML теги, которые закрывают открытые элементы SVG и
+// MathML и обрабатываются как обычный HTML
+var breakoutElements = map[string]bool{
+	"b": true, "big": true, "blockquote": true, "body": true, "br": true,
+	"center": true, "code": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"em": true, "embed": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "head": true, "hr": true, "i": true, "img": true,
+	"li": true, "listing": true, "menu": true, "meta": true, "nobr": true, "ol": true,
+	"p": true, "pre": true, "ruby": true, "s": true, "small": true, "span": true,
+	"strong": true, "strike": true, "sub": true, "sup": true, "table": true,
+	"tt": true, "u": true, "ul": true, "var": true,
+}
+
+// adjustForeignAttributes возвращает атрибуты токена, исправленные для
+// элемента из пространства имен namespace: восстанавливает регистр имен и
+// назначает пространства имен атрибутам xlink:, xml: и xmlns
+func adjustForeignAttributes(attrs []Attribute, namespace string) []Attribute {
+	names := svgAttributeNames
+	if namespace == MathMLNamespace {
+		names = mathMLAttributeNames
+	}
+	adjusted := make([]Attribute, len(attrs))
+	for i, a := range attrs {
+		if name, ok := names[a.Name]; ok {
+			a.Name = name
+		}
+		if ns, ok := foreignAttributeNamespaces[a.Name]; ok {
+			a.Namespace = ns
+		}
+		adjusted[i] = a
+	}
+	return adjusted
+}
+
+// isMathMLTextIntegrationPoint проверяет, разбирается ли текст внутри
+// элемента MathML по правилам HTML
+func isMathMLTextIntegrationPoint(n *Node) bool {
+	if n.Namespace != MathMLNamespace {
+		return false
+	}
+	switch n.TagName {
+	case "mi", "mo", "mn", "ms", "mtext":
+		return true
+	}
+	return false
+}
+
+// isHTMLIntegrationPoint проверяет, разбираются ли теги внутри элемента
+// SVG или MathML по правилам HTML
+func isHTMLIntegrationPoint(n *Node) bool {
+	switch n.Namespace {
+	case MathMLNamespace:
+		if n.TagName != "annotation-xml" {
+			return false
+		}
+		encoding := strings.ToLower(n.GetAttribute("encoding"))
+		return encoding == "text/html" || encoding == "application/xhtml+xml"
+	case SVGNamespace:
+		return n.TagName == "foreignObject" || n.TagName == "desc" || n.TagName == "title"
+	}
+	return false
+}
+
+// adjustedCurrent возвращает скорректированный текущий узел: при разборе
+// фрагмента его роль вместо корневого <html> играет элемент-контекст
+func (b *treeBuilder) adjustedCurrent() *Node {
+	if b.context != nil && len(b.oe) == 1 {
+		return b.context
+	}
+	return b.current()
+}
+
+// inForeignContent проверяет, обрабатывается ли токен по правилам
+// содержимого SVG и MathML, а не по правилам режима вставки
+func (b *treeBuilder) inForeignContent(tok *Token) bool {
+	n := b.adjustedCurrent()
+	if n == nil || n.IsHTML() || tok.Type == EOFToken {
+		return false
+	}
+	if isMathMLTextIntegrationPoint(n) {
+		if tok.Type == CharacterToken ||
+			tok.Type == StartTagToken && tok.Data != "mglyph" && tok.Data != "malignmark" {
+			return false
+		}
+	}
+	if n.Namespace == MathMLNamespace && n.TagName == "annotation-xml" &&
+		tok.Type == StartTagToken && tok.Data == "svg" {
+		return false
+	}
+	if isHTMLIntegrationPoint(n) && (tok.Type == StartTagToken || tok.Type == CharacterToken) {
+		return false
+	}
+	return true
+}
+
+// insertForeignElement вставляет элемент SVG или MathML для токена.
+// Самозакрывающийся тег сразу снимается со стека
+func (b *treeBuilder) insertForeignElement(tok *Token, namespace string) {
+	tok.Attr = adjustForeignAttributes(tok.Attr, namespace)
+	b.insertElementNS(tok, namespace)
+	if tok.SelfClosing {
+		b.pop()
+	}
+}
+
+// foreignContent обрабатывает токен внутри SVG или MathML
+func (b *treeBuilder) foreignContent(tok *Token) {
+	switch tok.Type {
+	case CharacterToken:
+		text := tok.Data
+		if strings.IndexByte(text, 0) >= 0 {
+			b.nullCharacterError(tok)
+			text = strings.ReplaceAll(text, "\x00", string(utf8.RuneError))
+		}
+		b.insertText(text)
+		if strings.TrimLeft(text, " \t\n\f\r"+string(utf8.RuneError)) != "" {
+			b.framesetOK = false
+		}
+	case CommentToken:
+		b.insertComment(tok, nil)
+	case DoctypeToken:
+		b.parseError("unexpected-doctype")
+	case StartTagToken:
+		if breakoutElements[tok.Data] || tok.Data == "font" && hasFontAttributes(tok) {
+			b.breakOutOfForeignContent(tok)
+			return
+		}
+		b.insertForeignElement(tok, b.adjustedCurrent().Namespace)
+	case EndTagToken:
+		if tok.Data == "br" || tok.Data == "p" {
+			b.breakOutOfForeignContent(tok)
+			return
+		}
+		b.foreignEndTag(tok)
+	}
+}
+
+// hasFontAttributes проверяет, есть ли у <font> атрибуты оформления HTML
+func hasFontAttributes(tok *Token) bool {
+	for _, a := range tok.Attr {
+		if a.Name == "color" || a.Name == "face" || a.Name == "size" {
+			return true
+		}
+	}
+	return false
+}
+
+// breakOutOfForeignContent закрывает элементы SVG и MathML до ближайшего
+// HTML элемента или точки интеграции и обрабатывает токен как HTML
+func (b *treeBuilder) breakOutOfForeignContent(tok *Token) {
+	b.parseError("unexpected-html-element-in-foreign-content")
+	for {
+		cur := b.current()
+		if cur.IsHTML() || isMathMLTextIntegrationPoint(cur) || isHTMLIntegrationPoint(cur) {
+			break
+		}
+		b.pop()
+	}
+	b.processIn(b.mode, tok)
+}
+
+// foreignEndTag обрабатывает закрывающий тег внутри SVG или MathML
+func (b *treeBuilder) foreignEndTag(tok *Token) {
+	i := len(b.oe) - 1
+	if strings.ToLower(b.oe[i].TagName) != tok.Data {
+		b.parseError("unexpected-end-tag")
+	}
+	for ; i > 0; i-- {
+		n := b.oe[i]
+		if strings.ToLower(n.TagName) == tok.Data {
+			b.popUntilNode(n)
+			return
+		}
+		if b.oe[i-1].IsHTML() {
+			b.processIn(b.mode, tok)
+			return
+		}
+	}
+}
@@ -0,0 +1,633 @@
+package html
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// Тесты прогоняют парсер по набору html5lib-tests, скопированному в
+// testdata/html5lib-tests. Для каждого файла выводится число пройденных
+// тестов, а тест падает, если оно стало меньше зафиксированного минимума.
+// После улучшения парсера минимумы в таблицах ниже нужно поднять
+
+const html5libDir = "testdata/html5lib-tests"
+
+// tokenizerMinimum — минимальное число пройденных тестов токенизатора по файлам
+var tokenizerMinimum = map[string]int{
+	"contentModelFlags.test":       24,
+	"domjs.test":                   59,
+	"entities.test":                80,
+	"escapeFlag.test":              9,
+	"namedEntities.test":           4210,
+	"numericEntities.test":         336,
+	"pendingSpecChanges.test":      1,
+	"test1.test":                   69,
+	"test2.test":                   45,
+	"test3.test":                   1786,
+	"test4.test":                   85,
+	"unicodeChars.test":            323,
+	"unicodeCharsProblematic.test": 5,
+}
+
+// treeConstructionMinimum — минимальное число пройденных тестов построения дерева по файлам
+var treeConstructionMinimum = map[string]int{
+	"adoption01.dat":            18,
+	"adoption02.dat":            2,
+	"blocks.dat":                48,
+	"comments01.dat":            16,
+	"doctype01.dat":             37,
+	"domjs-unsafe.dat":          49,
+	"entities01.dat":            75,
+	"entities02.dat":            26,
+	"foreign-fragment.dat":      66,
+	"html5test-com.dat":         24,
+	"inbody01.dat":              4,
+	"isindex.dat":               4,
+	"main-element.dat":          3,
+	"math.dat":                  8,
+	"menuitem-element.dat":      20,
+	"namespace-sensitivity.dat": 1,
+	"noscript01.dat":            18,
+	"pending-spec-changes-plain-text-unsafe.dat": 1,
+	"pending-spec-changes.dat":                   3,
+	"plain-text-unsafe.dat":                      33,
+	"quirks01.dat":                               4,
+	"ruby.dat":                                   21,
+	"scriptdata01.dat":                           26,
+	"search-element.dat":                         3,
+	"svg.dat":                                    8,
+	"tables01.dat":                               19,
+	"template.dat":                               112,
+	"tests1.dat":                                 112,
+	"tests10.dat":                                54,
+	"tests11.dat":                                13,
+	"tests12.dat":                                2,
+	"tests14.dat":                                7,
+	"tests15.dat":                                14,
+	"tests16.dat":                                197,
+	"tests17.dat":                                13,
+	"tests18.dat":                                36,
+	"tests19.dat":                                103,
+	"tests2.dat":                                 63,
+	"tests20.dat":                                64,
+	"tests21.dat":                                23,
+	"tests22.dat":                                5,
+	"tests23.dat":                                5,
+	"tests24.dat":                                8,
+	"tests25.dat":                                26,
+	"tests26.dat":                                20,
+	"tests3.dat":                                 24,
+	"tests4.dat":                                 9,
+	"tests5.dat":                                 17,
+	"tests6.dat":                                 52,
+	"tests7.dat":                                 34,
+	"tests8.dat":                                 10,
+	"tests9.dat":                                 27,
+	"tests_innerHTML_1.dat":                      80,
+	"tricky01.dat":                               9,
+	"webkit01.dat":                               52,
+	"webkit02.dat":                               45,
+}
+
+// roundTripMinimum — минимальное число документов, дерево которых не меняется
+// после сериализации и повторного разбора. Часть деревьев из тестов (например,
+// вложенные ссылки, созданные алгоритмом усыновления) в HTML непредставима
+const roundTripMinimum = 1505
+
+func TestMain(m *testing.M) {
+	// Парсер пишет в лог при каждом вызове, в тестах это только мешает
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// tokenizerTest — один тест из JSON-файла токенизатора
+type tokenizerTest struct {
+	Description   string        `json:"description"`
+	Input         string        `json:"input"`
+	Output        []interface{} `json:"output"`
+	InitialStates []string      `json:"initialStates"`
+	LastStartTag  string        `json:"lastStartTag"`
+	DoubleEscaped bool          `json:"doubleEscaped"`
+	Errors        []struct {
+		Code   string `json:"code"`
+		Line   int    `json:"line"`
+		Column int    `json:"col"`
+	} `json:"errors"`
+}
+
+// tokenizerInitialStates сопоставляет имена состояний из тестов состояниям токенизатора
+var tokenizerInitialStates = map[string]tokenizerState{
+	"Data state":          dataState,
+	"PLAINTEXT state":     plaintextState,
+	"RCDATA state":        rcdataState,
+	"RAWTEXT state":       rawtextState,
+	"Script data state":   scriptDataState,
+	"CDATA section state": cdataSectionState,
+}
+
+func TestTokenizerConformance(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(html5libDir, "tokenizer", "*.test"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("не найдены тесты токенизатора: %v", err)
+	}
+
+	passed, total := 0, 0
+	for _, file := range files {
+		name := filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var suite struct {
+				Tests []tokenizerTest `json:"tests"`
+			}
+			if err := json.Unmarshal(data, &suite); err != nil {
+				t.Fatalf("ошибка чтения %s: %v", name, err)
+			}
+
+			filePassed, fileTotal := 0, 0
+			for _, test := range suite.Tests {
+				states := test.InitialStates
+				if len(states) == 0 {
+					states = []string{"Data state"}
+				}
+				for _, state := range states {
+					fileTotal++
+					if got, want, ok := runTokenizerTest(test, state); ok {
+						filePassed++
+					} else if testing.Verbose() {
+						t.Logf("%s [%s]\n  вход:     %q\n  ожидание: %s\n  получено: %s", test.Description, state, test.Input, want, got)
+					}
+				}
+			}
+			checkConformance(t, name, filePassed, fileTotal, tokenizerMinimum)
+			passed += filePassed
+			total += fileTotal
+		})
+	}
+	t.Logf("токенизатор: пройдено %d из %d", passed, total)
+}
+
+// runTokenizerTest выполняет тест токенизатора и возвращает полученные и
+// ожидаемые токены в JSON
+func runTokenizerTest(test tokenizerTest, state string) (string, string, bool) {
+	input := test.Input
+	expected := test.Output
+	if test.DoubleEscaped {
+		input = unescapeDouble(input)
+		expected = unescapeDoubleValue(expected).([]interface{})
+	}
+
+	tokenizer := NewTokenizer(input)
+	tokenizer.lastStartTag = test.LastStartTag
+	tokenizer.setState(tokenizerInitialStates[state])
+
+	output := make([]interface{}, 0)
+	for {
+		tok := tokenizer.Next()
+		if tok.Type == EOFToken {
+			break
+		}
+		output = append(output, tokenJSON(tok))
+	}
+
+	// Ошибки сравниваются вместе с токенами
+	var gotErrors, wantErrors []string
+	for _, e := range tokenizer.Errors() {
+		gotErrors = append(gotErrors, e.Error())
+	}
+	for _, e := range test.Errors {
+		// Одиночные суррогаты непредставимы в строках Go: при декодировании
+		// они уже заменены на U+FFFD, поэтому эта ошибка не обнаруживается
+		if e.Code == "surrogate-in-input-stream" {
+			continue
+		}
+		pos := Position{Line: e.Line, Column: runeColumn(input, e.Line, e.Column)}
+		wantErrors = append(wantErrors, ParseError{Code: e.Code, Pos: pos}.Error())
+	}
+
+	want := normalizeTokens(expected) + " " + strings.Join(wantErrors, ", ")
+	got := normalizeTokens(output) + " " + strings.Join(gotErrors, ", ")
+	return got, want, got == want
+}
+
+// runeColumn переводит столбец html5lib-tests, измеряемый в кодовых единицах
+// UTF-16, в столбец в символах Unicode, как считает токенизатор
+func runeColumn(input string, line, column int) int {
+	lines := strings.Split(normalizeNewlines(input), "\n")
+	if line < 1 || line > len(lines) {
+		return column
+	}
+	units, runes := 1, 1
+	for _, c := range lines[line-1] {
+		if units >= column {
+			break
+		}
+		units += utf16.RuneLen(c)
+		runes++
+	}
+	return runes + column - units
+}
+
+// tokenJSON переводит токен в формат html5lib-tests
+func tokenJSON(tok Token) []interface{} {
+	switch tok.Type {
+	case CharacterToken:
+		return []interface{}{"Character", tok.Data}
+	case StartTagToken:
+		attrs := make(map[string]interface{}, len(tok.Attr))
+		for _, attr := range tok.Attr {
+			attrs[attr.Name] = attr.Value
+		}
+		if tok.SelfClosing {
+			return []interface{}{"StartTag", tok.Data, attrs, true}
+		}
+		return []interface{}{"StartTag", tok.Data, attrs}
+	case EndTagToken:
+		return []interface{}{"EndTag", tok.Data}
+	case CommentToken:
+		return []interface{}{"Comment", tok.Data}
+	case DoctypeToken:
+		var name, publicID, systemID interface{}
+		if tok.Data != "" {
+			name = tok.Data
+		}
+		if tok.HasPublicID {
+			publicID = tok.PublicID
+		}
+		if tok.HasSystemID {
+			systemID = tok.SystemID
+		}
+		return []interface{}{"DOCTYPE", name, publicID, systemID, !tok.ForceQuirks}
+	}
+	return nil
+}
+
+// normalizeTokens объединяет соседние символьные токены и сериализует
+// результат в JSON, чтобы сравнение не зависело от типов Go
+func normalizeTokens(tokens []interface{}) string {
+	merged := make([]interface{}, 0, len(tokens))
+	for _, tok := range tokens {
+		list, ok := tok.([]interface{})
+		if ok && len(list) == 2 && list[0] == "Character" && len(merged) > 0 {
+			if prev := merged[len(merged)-1].([]interface{}); prev[0] == "Character" {
+				merged[len(merged)-1] = []interface{}{"Character", prev[1].(string) + list[1].(string)}
+				continue
+			}
+		}
+		merged = append(merged, tok)
+	}
+	data, _ := json.Marshal(merged)
+	var value interface{}
+	json.Unmarshal(data, &value)
+	var sb strings.Builder
+	encoder := json.NewEncoder(&sb)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// unescapeDouble раскрывает последовательности \uXXXX в тестах с doubleEscaped
+func unescapeDouble(s string) string {
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, `\u`)
+		if i < 0 || i+6 > len(s) {
+			sb.WriteString(s)
+			return sb.String()
+		}
+		code, err := strconv.ParseUint(s[i+2:i+6], 16, 32)
+		if err != nil {
+			sb.WriteString(s[:i+2])
+			s = s[i+2:]
+			continue
+		}
+		sb.WriteString(s[:i])
+		sb.WriteRune(rune(code))
+		s = s[i+6:]
+	}
+}
+
+// unescapeDoubleValue применяет unescapeDouble ко всем строкам значения
+func unescapeDoubleValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return unescapeDouble(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = unescapeDoubleValue(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[unescapeDouble(key)] = unescapeDoubleValue(item)
+		}
+		return result
+	}
+	return v
+}
+
+// treeTest — один тест из .dat файла построения дерева
+type treeTest struct {
+	Data     string
+	Fragment string
+	Document string
+	// Scripting: 0 — тест не зависит от флага, 1 — только со скриптами, -1 — без скриптов
+	Scripting int
+}
+
+func TestTreeConstructionConformance(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(html5libDir, "tree-construction", "*.dat"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("не найдены тесты построения дерева: %v", err)
+	}
+
+	passed, total := 0, 0
+	for _, file := range files {
+		name := filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			tests, err := readTreeTests(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			filePassed, fileTotal := 0, 0
+			for _, test := range tests {
+				fileTotal++
+				got := runTreeTest(test)
+				if got == test.Document {
+					filePassed++
+				} else if testing.Verbose() {
+					t.Logf("вход:\n%s\nожидание:\n%s\nполучено:\n%s", test.Data, test.Document, got)
+				}
+			}
+			checkConformance(t, name, filePassed, fileTotal, treeConstructionMinimum)
+			passed += filePassed
+			total += fileTotal
+		})
+	}
+	t.Logf("построение дерева: пройдено %d из %d", passed, total)
+}
+
+// runTreeTest разбирает входные данные теста и возвращает дамп дерева
+func runTreeTest(test treeTest) string {
+	parser := &Parser{scripting: test.Scripting >= 0}
+	var sb strings.Builder
+	if test.Fragment != "" {
+		nodes, err := parser.ParseFragment(test.Data, fragmentContext(test.Fragment))
+		if err != nil {
+			return "ошибка: " + err.Error()
+		}
+		for _, n := range nodes {
+			dumpTree(&sb, n, 0)
+		}
+		return strings.TrimSuffix(sb.String(), "\n")
+	}
+
+	doc, err := parser.Parse(test.Data)
+	if err != nil {
+		return "ошибка: " + err.Error()
+	}
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		dumpTree(&sb, c, 0)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// fragmentContext создает элемент-контекст фрагмента по его описанию в
+// тесте: «div», «svg path» или «math mi»
+func fragmentContext(name string) *Node {
+	if prefix, local, ok := strings.Cut(name, " "); ok {
+		return NewElementNS(testNamespaces[prefix], local)
+	}
+	return NewElement(name)
+}
+
+// testNamespaces сопоставляет префиксы из дампов html5lib пространствам имен
+var testNamespaces = map[string]string{
+	"svg":   SVGNamespace,
+	"math":  MathMLNamespace,
+	"xlink": XLinkNamespace,
+	"xml":   XMLNamespace,
+	"xmlns": XMLNSNamespace,
+}
+
+// testPrefix возвращает префикс пространства имен для дампа html5lib
+func testPrefix(namespace string) string {
+	for prefix, ns := range testNamespaces {
+		if ns == namespace {
+			return prefix + " "
+		}
+	}
+	return ""
+}
+
+// TestSerializerRoundTrip проверяет, что сериализованный документ
+// разбирается в то же дерево
+func TestSerializerRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(html5libDir, "tree-construction", "*.dat"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("не найдены тесты построения дерева: %v", err)
+	}
+
+	passed, total := 0, 0
+	for _, file := range files {
+		tests, err := readTreeTests(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range tests {
+			if test.Fragment != "" {
+				continue
+			}
+			total++
+			doc, err := (&Parser{scripting: test.Scripting >= 0}).Parse(test.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			serialized := treeTest{Data: doc.Serialize(), Scripting: test.Scripting}
+			if got, want := runTreeTest(serialized), runTreeTest(test); got == want {
+				passed++
+			} else if testing.Verbose() {
+				t.Logf("вход:\n%s\nсериализация:\n%s\nожидание:\n%s\nполучено:\n%s", test.Data, serialized.Data, want, got)
+			}
+		}
+	}
+	t.Logf("сериализация: пройдено %d из %d", passed, total)
+	if passed < roundTripMinimum {
+		t.Errorf("сериализация: пройдено %d тестов, ожидалось не меньше %d", passed, roundTripMinimum)
+	}
+}
+
+// TestStreamConformance проверяет, что разбор по одному байту строит то же
+// дерево и находит те же ошибки, что и разбор всей строки
+func TestStreamConformance(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(html5libDir, "tree-construction", "*.dat"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("не найдены тесты построения дерева: %v", err)
+	}
+
+	for _, file := range files {
+		tests, err := readTreeTests(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range tests {
+			if test.Fragment != "" {
+				continue
+			}
+			parser := &Parser{scripting: test.Scripting >= 0}
+			doc, _ := parser.Parse(test.Data)
+
+			stream := parser.NewStream()
+			for _, b := range []byte(test.Data) {
+				stream.Write([]byte{b})
+			}
+			stream.Close()
+
+			var want, got strings.Builder
+			for c := doc.FirstChild; c != nil; c = c.NextSibling {
+				dumpTree(&want, c, 0)
+			}
+			for c := stream.Document().FirstChild; c != nil; c = c.NextSibling {
+				dumpTree(&got, c, 0)
+			}
+			if got.String() != want.String() || !reflect.DeepEqual(stream.Document().Errors, doc.Errors) {
+				t.Errorf("%s: потоковый разбор отличается\nвход:\n%s\nожидание:\n%s%v\nполучено:\n%s%v",
+					filepath.Base(file), test.Data, want.String(), doc.Errors, got.String(), stream.Document().Errors)
+			}
+		}
+	}
+}
+
+// dumpTree выводит узел в формате html5lib-tests
+func dumpTree(sb *strings.Builder, n *Node, depth int) {
+	indent := "| " + strings.Repeat("  ", depth)
+	switch n.Type {
+	case ElementNode:
+		fmt.Fprintf(sb, "%s<%s%s>\n", indent, testPrefix(n.Namespace), n.TagName)
+		attrs := make([][2]string, 0, len(n.Attributes))
+		for _, a := range n.Attributes {
+			attrs = append(attrs, [2]string{testPrefix(a.Namespace) + a.LocalName(), a.Value})
+		}
+		sort.Slice(attrs, func(i, j int) bool {
+			return attrs[i][0] < attrs[j][0]
+		})
+		for _, a := range attrs {
+			fmt.Fprintf(sb, "%s  %s=\"%s\"\n", indent, a[0], a[1])
+		}
+		if n.Content != nil {
+			fmt.Fprintf(sb, "%s  content\n", indent)
+			for c := n.Content.FirstChild; c != nil; c = c.NextSibling {
+				dumpTree(sb, c, depth+2)
+			}
+		}
+	case TextNode:
+		fmt.Fprintf(sb, "%s\"%s\"\n", indent, n.Data)
+	case CommentNode:
+		fmt.Fprintf(sb, "%s<!-- %s -->\n", indent, n.Data)
+	case DocumentTypeNode:
+		if n.PublicID != "" || n.SystemID != "" {
+			fmt.Fprintf(sb, "%s<!DOCTYPE %s \"%s\" \"%s\">\n", indent, n.Data, n.PublicID, n.SystemID)
+		} else {
+			fmt.Fprintf(sb, "%s<!DOCTYPE %s>\n", indent, n.Data)
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		dumpTree(sb, c, depth+1)
+	}
+}
+
+// treeTestSections — заголовки разделов .dat файла
+var treeTestSections = map[string]bool{
+	"#errors":            true,
+	"#new-errors":        true,
+	"#document-fragment": true,
+	"#document":          true,
+	"#script-on":         true,
+	"#script-off":        true,
+}
+
+// readTreeTests читает тесты из .dat файла
+func readTreeTests(file string) ([]treeTest, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tests []treeTest
+	var current *treeTest
+	var section string
+	var lines []string
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		value := strings.Join(lines, "\n")
+		switch section {
+		case "#data":
+			current.Data = value
+		case "#document-fragment":
+			current.Fragment = strings.TrimSpace(value)
+		case "#document":
+			current.Document = strings.TrimRight(value, "\n")
+		}
+		lines = nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "#data" {
+			flush()
+			if current != nil {
+				tests = append(tests, *current)
+			}
+			current = &treeTest{}
+			section = line
+			continue
+		}
+		if current != nil && treeTestSections[line] {
+			flush()
+			section = line
+			switch line {
+			case "#script-on":
+				current.Scripting = 1
+			case "#script-off":
+				current.Scripting = -1
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	if current != nil {
+		tests = append(tests, *current)
+	}
+	return tests, scanner.Err()
+}
+
+// checkConformance сообщает результат по файлу и падает при регрессии
+func checkConformance(t *testing.T, name string, passed, total int, minimum map[string]int) {
+	t.Logf("%s: пройдено %d из %d", name, passed, total)
+	if want := minimum[name]; passed < want {
+		t.Errorf("%s: пройдено %d тестов, ожидалось не меньше %d", name, passed, want)
+	}
+}
@@ -0,0 +1,604 @@
+package html
+
+import (
+	"strings"
+)
+
+// matchContext хранит состояние сопоставления селектора
+type matchContext struct {
+	// scope — элемент, которому соответствует :scope
+	scope *Node
+	// anchor — элемент, для которого проверяется аргумент :has()
+	anchor *Node
+	// quirks — элементы принадлежат документу в режиме совместимости: в нем
+	// классы и id сравниваются без учета регистра
+	quirks bool
+}
+
+// Match проверяет, соответствует ли элемент селектору
+func (s *Selector) Match(n *Node) bool {
+	return s.matchIn(n, &matchContext{quirks: n.inQuirksDocument()})
+}
+
+func (s *Selector) matchIn(n *Node, ctx *matchContext) bool {
+	if n == nil || n.Type != ElementNode {
+		return false
+	}
+	for i := range s.list {
+		// Псевдоэлементы не являются узлами DOM, поэтому в запросах не находятся
+		if s.list[i].pseudoElement == "" && s.list[i].match(n, ctx) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchSpecificity проверяет, соответствует ли элемент селектору, и
+// возвращает наибольшую специфичность среди подошедших селекторов списка —
+// ту, с которой правило участвует в каскаде
+func (s *Selector) MatchSpecificity(n *Node) (Specificity, bool) {
+	var best Specificity
+	matched := false
+	if n == nil || n.Type != ElementNode {
+		return best, false
+	}
+	ctx := &matchContext{quirks: n.inQuirksDocument()}
+	for i := range s.list {
+		sel := &s.list[i]
+		if sel.pseudoElement != "" || matched && !best.Less(sel.specificity) {
+			continue
+		}
+		if sel.match(n, ctx) {
+			best, matched = sel.specificity, true
+		}
+	}
+	return best, matched
+}
+
+// QuerySelector возвращает первого потомка, соответствующего селектору
+func (n *Node) QuerySelector(selector string) (*Node, error) {
+	sel, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	ctx := &matchContext{scope: n, quirks: n.inQuirksDocument()}
+	var found *Node
+	n.walk(func(el *Node) bool {
+		if sel.matchIn(el, ctx) {
+			found = el
+			return false
+		}
+		return true
+	})
+	return found, nil
+}
+
+// QuerySelectorAll возвращает всех потомков, соответствующих селектору, в порядке документа
+func (n *Node) QuerySelectorAll(selector string) ([]*Node, error) {
+	sel, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	ctx := &matchContext{scope: n, quirks: n.inQuirksDocument()}
+	result := make([]*Node, 0)
+	n.walk(func(el *Node) bool {
+		if sel.matchIn(el, ctx) {
+			result = append(result, el)
+		}
+		return true
+	})
+	return result, nil
+}
+
+// Matches проверяет, соответствует ли элемент селектору
+func (n *Node) Matches(selector string) (bool, error) {
+	sel, err := CompileSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return sel.matchIn(n, &matchContext{scope: n, quirks: n.inQuirksDocument()}), nil
+}
+
+// Closest возвращает ближайший элемент среди самого узла и его предков,
+// соответствующий селектору
+func (n *Node) Closest(selector string) (*Node, error) {
+	sel, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	ctx := &matchContext{scope: n, quirks: n.inQuirksDocument()}
+	for el := n; el != nil; el = el.Parent {
+		if sel.matchIn(el, ctx) {
+			return el, nil
+		}
+	}
+	return nil, nil
+}
+
+// inQuirksDocument проверяет, принадлежит ли узел документу, разобранному в
+// режиме совместимости
+func (n *Node) inQuirksDocument() bool {
+	for n != nil && n.Parent != nil {
+		n = n.Parent
+	}
+	return n != nil && n.Type == DocumentNode && n.Quirks
+}
+
+// match проверяет сложный селектор справа налево
+func (sel *complexSelector) match(n *Node, ctx *matchContext) bool {
+	return matchParts(sel.parts, len(sel.parts)-1, n, ctx)
+}
+
+func matchParts(parts []selectorPart, i int, n *Node, ctx *matchContext) bool {
+	part := &parts[i]
+	if !part.compound.match(n, ctx) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+
+	switch part.combinator {
+	case '>':
+		return matchParts(parts, i-1, parentElement(n), ctx)
+	case '+':
+		return matchParts(parts, i-1, previousElementSibling(n), ctx)
+	case '~':
+		for s := previousElementSibling(n); s != nil; s = previousElementSibling(s) {
+			if matchParts(parts, i-1, s, ctx) {
+				return true
+			}
+		}
+	default:
+		for a := parentElement(n); a != nil; a = parentElement(a) {
+			if matchParts(parts, i-1, a, ctx) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *compoundSelector) match(n *Node, ctx *matchContext) bool {
+	if n == nil || n.Type != ElementNode {
+		return false
+	}
+	if c.anchor && n != ctx.anchor {
+		return false
+	}
+	if c.tagName != "" && !matchTagName(n, c.tagName) {
+		return false
+	}
+	for _, simple := range c.simple {
+		if !simple.match(n, ctx) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchTagName сравнивает имя элемента с селектором типа: у HTML элементов
+// без учета регистра, у SVG и MathML — точно
+func matchTagName(n *Node, name string) bool {
+	if n.IsHTML() {
+		return strings.EqualFold(n.TagName, name)
+	}
+	return n.TagName == name
+}
+
+// idSelector — селектор #id
+type idSelector string
+
+func (s idSelector) match(n *Node, ctx *matchContext) bool {
+	if ctx.quirks {
+		return strings.EqualFold(n.ID(), string(s))
+	}
+	return n.ID() == string(s)
+}
+
+func (s idSelector) specificity() Specificity {
+	return Specificity{1, 0, 0}
+}
+
+// classSelector — селектор .class
+type classSelector string
+
+func (s classSelector) match(n *Node, ctx *matchContext) bool {
+	for _, class := range n.ClassNames() {
+		if class == string(s) || ctx.quirks && strings.EqualFold(class, string(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s classSelector) specificity() Specificity {
+	return Specificity{0, 1, 0}
+}
+
+// caseInsensitiveAttributes — атрибуты HTML, значения которых сравниваются
+// селекторами без учета регистра
+var caseInsensitiveAttributes = map[string]bool{
+	"accept": true, "accept-charset": true, "align": true, "alink": true,
+	"axis": true, "bgcolor": true, "charset": true, "checked": true,
+	"clear": true, "codetype": true, "color": true, "compact": true,
+	"declare": true, "defer": true, "dir": true, "direction": true,
+	"disabled": true, "enctype": true, "face": true, "frame": true,
+	"hreflang": true, "http-equiv": true, "lang": true, "language": true,
+	"link": true, "media": true, "method": true, "multiple": true,
+	"nohref": true, "noresize": true, "noshade": true, "nowrap": true,
+	"readonly": true, "rel": true, "rev": true, "rules": true,
+	"scope": true, "scrolling": true, "selected": true, "shape": true,
+	"target": true, "text": true, "type": true, "valign": true,
+	"valuetype": true, "vlink": true,
+}
+
+// attributeSelector — селектор атрибута [name op value]
+type attributeSelector struct {
+	name            string
+	op              rune
+	value           string
+	caseInsensitive bool
+	caseSensitive   bool
+}
+
+func (s *attributeSelector) match(n *Node, ctx *matchContext) bool {
+	if !n.HasAttribute(s.name) {
+		return false
+	}
+	if s.op == 0 {
+		return true
+	}
+
+	value, want := n.GetAttribute(s.name), s.value
+	if s.caseInsensitive || !s.caseSensitive && n.IsHTML() && caseInsensitiveAttributes[strings.ToLower(s.name)] {
+		value, want = strings.ToLower(value), strings.ToLower(want)
+	}
+
+	switch s.op {
+	case '=':
+		return value == want
+	case '~':
+		for _, word := range strings.FieldsFunc(value, isHTMLSpace) {
+			if word == want {
+				return true
+			}
+		}
+		return false
+	case '|':
+		return value == want || strings.HasPrefix(value, want+"-")
+	case '^':
+		return want != "" && strings.HasPrefix(value, want)
+	case '$':
+		return want != "" && strings.HasSuffix(value, want)
+	case '*':
+		return want != "" && strings.Contains(value, want)
+	}
+	return false
+}
+
+func (s *attributeSelector) specificity() Specificity {
+	return Specificity{0, 1, 0}
+}
+
+// pseudoClassSelector — псевдокласс без аргументов
+type pseudoClassSelector struct {
+	name string
+	fn   func(n *Node, ctx *matchContext) bool
+}
+
+func (s pseudoClassSelector) match(n *Node, ctx *matchContext) bool {
+	return s.fn(n, ctx)
+}
+
+func (s pseudoClassSelector) specificity() Specificity {
+	return Specificity{0, 1, 0}
+}
+
+// never — псевдоклассы состояния взаимодействия, которые в статичном
+// документе не выполняются
+func never(n *Node, ctx *matchContext) bool {
+	return false
+}
+
+// pseudoClasses — псевдоклассы без аргументов
+var pseudoClasses map[string]func(n *Node, ctx *matchContext) bool
+
+func init() {
+	pseudoClasses = map[string]func(n *Node, ctx *matchContext) bool{
+		"root": func(n *Node, ctx *matchContext) bool {
+			return n.Parent != nil && n.Parent.Type == DocumentNode
+		},
+		"scope": func(n *Node, ctx *matchContext) bool {
+			if ctx.scope == nil || ctx.scope.Type != ElementNode {
+				return pseudoClasses["root"](n, ctx)
+			}
+			return n == ctx.scope
+		},
+		"empty": func(n *Node, ctx *matchContext) bool {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == ElementNode || c.Type == TextNode && c.Data != "" {
+					return false
+				}
+			}
+			return true
+		},
+		"first-child": func(n *Node, ctx *matchContext) bool {
+			return previousElementSibling(n) == nil
+		},
+		"last-child": func(n *Node, ctx *matchContext) bool {
+			return nextElementSibling(n) == nil
+		},
+		"only-child": func(n *Node, ctx *matchContext) bool {
+			return previousElementSibling(n) == nil && nextElementSibling(n) == nil
+		},
+		"first-of-type": func(n *Node, ctx *matchContext) bool {
+			return siblingPosition(n, false, true, nil, ctx) == 1
+		},
+		"last-of-type": func(n *Node, ctx *matchContext) bool {
+			return siblingPosition(n, true, true, nil, ctx) == 1
+		},
+		"only-of-type": func(n *Node, ctx *matchContext) bool {
+			return siblingPosition(n, false, true, nil, ctx) == 1 && siblingPosition(n, true, true, nil, ctx) == 1
+		},
+		"link":     isLink,
+		"any-link": isLink,
+		"checked": func(n *Node, ctx *matchContext) bool {
+			switch n.TagName {
+			case "input":
+				t := strings.ToLower(n.GetAttribute("type"))
+				return (t == "checkbox" || t == "radio") && n.HasAttribute("checked")
+			case "option":
+				return n.HasAttribute("selected")
+			}
+			return false
+		},
+		"disabled": isDisabled,
+		"enabled": func(n *Node, ctx *matchContext) bool {
+			return isFormControl(n) && !isDisabled(n, ctx)
+		},
+		"required": func(n *Node, ctx *matchContext) bool {
+			return isInputLike(n) && n.HasAttribute("required")
+		},
+		"optional": func(n *Node, ctx *matchContext) bool {
+			return isInputLike(n) && !n.HasAttribute("required")
+		},
+		"read-write": isReadWrite,
+		"read-only": func(n *Node, ctx *matchContext) bool {
+			return !isReadWrite(n, ctx)
+		},
+		"defined": func(n *Node, ctx *matchContext) bool {
+			return true
+		},
+		"hover":         never,
+		"active":        never,
+		"focus":         never,
+		"focus-within":  never,
+		"focus-visible": never,
+		"visited":       never,
+		"target":        never,
+	}
+}
+
+func isLink(n *Node, ctx *matchContext) bool {
+	return (n.TagName == "a" || n.TagName == "area") && n.HasAttribute("href")
+}
+
+func isFormControl(n *Node) bool {
+	switch n.TagName {
+	case "button", "input", "select", "textarea", "optgroup", "option", "fieldset":
+		return true
+	}
+	return false
+}
+
+func isInputLike(n *Node) bool {
+	return n.TagName == "input" || n.TagName == "select" || n.TagName == "textarea"
+}
+
+func isDisabled(n *Node, ctx *matchContext) bool {
+	if !isFormControl(n) {
+		return false
+	}
+	if n.HasAttribute("disabled") {
+		return true
+	}
+	if n.TagName == "option" {
+		if p := parentElement(n); p != nil && p.TagName == "optgroup" && p.HasAttribute("disabled") {
+			return true
+		}
+	}
+	// Элемент внутри отключенного <fieldset> отключен, если не находится в его первой <legend>
+	for child, a := n, parentElement(n); a != nil; child, a = a, parentElement(a) {
+		if a.TagName != "fieldset" || !a.HasAttribute("disabled") {
+			continue
+		}
+		var legend *Node
+		for _, c := range a.Children() {
+			if c.TagName == "legend" {
+				legend = c
+				break
+			}
+		}
+		if legend == nil || legend != child {
+			return true
+		}
+	}
+	return false
+}
+
+func isReadWrite(n *Node, ctx *matchContext) bool {
+	switch n.TagName {
+	case "input":
+		switch strings.ToLower(n.GetAttribute("type")) {
+		case "checkbox", "radio", "file", "hidden", "image", "button", "submit", "reset", "range", "color":
+			return false
+		}
+		return !n.HasAttribute("readonly") && !isDisabled(n, ctx)
+	case "textarea":
+		return !n.HasAttribute("readonly") && !isDisabled(n, ctx)
+	}
+	for a := n; a != nil; a = parentElement(a) {
+		if v, ok := a.LookupAttribute("contenteditable"); ok {
+			v = strings.ToLower(v)
+			return v == "" || v == "true" || v == "plaintext-only"
+		}
+	}
+	return false
+}
+
+// logicalSelector — псевдоклассы :not(), :is() и :where()
+type logicalSelector struct {
+	name string
+	list []complexSelector
+}
+
+func (s *logicalSelector) match(n *Node, ctx *matchContext) bool {
+	matched := false
+	for i := range s.list {
+		if s.list[i].pseudoElement == "" && s.list[i].match(n, ctx) {
+			matched = true
+			break
+		}
+	}
+	if s.name == "not" {
+		return !matched
+	}
+	return matched
+}
+
+func (s *logicalSelector) specificity() Specificity {
+	if s.name == "where" {
+		return Specificity{}
+	}
+	return maxSpecificity(s.list)
+}
+
+// hasSelector — псевдокласс :has() с относительными селекторами
+type hasSelector struct {
+	list []complexSelector
+}
+
+func (s *hasSelector) match(n *Node, ctx *matchContext) bool {
+	inner := &matchContext{scope: ctx.scope, anchor: n, quirks: ctx.quirks}
+	found := false
+	check := func(el *Node) bool {
+		for i := range s.list {
+			if s.list[i].match(el, inner) {
+				found = true
+				return false
+			}
+		}
+		return true
+	}
+
+	// Кандидаты — потомки элемента, а для комбинаторов + и ~ также следующие
+	// братья и их потомки
+	n.walk(check)
+	for sib := nextElementSibling(n); sib != nil && !found; sib = nextElementSibling(sib) {
+		if check(sib) {
+			sib.walk(check)
+		}
+	}
+	return found
+}
+
+func (s *hasSelector) specificity() Specificity {
+	return maxSpecificity(s.list)
+}
+
+// nthSelector — семейство псевдоклассов :nth-child()
+type nthSelector struct {
+	a, b   int
+	last   bool
+	ofType bool
+	of     []complexSelector
+}
+
+func (s *nthSelector) match(n *Node, ctx *matchContext) bool {
+	if s.of != nil && !(&Selector{list: s.of}).matchIn(n, ctx) {
+		return false
+	}
+	pos := siblingPosition(n, s.last, s.ofType, s.of, ctx)
+	// Ищем целое k >= 0, для которого a*k + b == pos
+	if s.a == 0 {
+		return pos == s.b
+	}
+	diff := pos - s.b
+	return diff%s.a == 0 && diff/s.a >= 0
+}
+
+func (s *nthSelector) specificity() Specificity {
+	return Specificity{0, 1, 0}.add(maxSpecificity(s.of))
+}
+
+// siblingPosition возвращает позицию элемента среди братьев (начиная с 1),
+// считая с начала или с конца и учитывая только элементы того же типа или
+// подходящие под селектор of
+func siblingPosition(n *Node, fromEnd, ofType bool, of []complexSelector, ctx *matchContext) int {
+	next := previousElementSibling
+	if fromEnd {
+		next = nextElementSibling
+	}
+	pos := 1
+	for s := next(n); s != nil; s = next(s) {
+		switch {
+		case ofType && s.TagName != n.TagName:
+		case of != nil && !(&Selector{list: of}).matchIn(s, ctx):
+		default:
+			pos++
+		}
+	}
+	return pos
+}
+
+// langSelector — псевдокласс :lang()
+type langSelector []string
+
+func (s langSelector) match(n *Node, ctx *matchContext) bool {
+	lang := ""
+	for a := n; a != nil; a = parentElement(a) {
+		if v, ok := a.LookupAttribute("lang"); ok {
+			lang = strings.ToLower(v)
+			break
+		}
+	}
+	for _, want := range s {
+		if want == "*" && lang != "" || lang == want || strings.HasPrefix(lang, want+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s langSelector) specificity() Specificity {
+	return Specificity{0, 1, 0}
+}
+
+// parentElement возвращает родителя, если он является элементом
+func parentElement(n *Node) *Node {
+	if n == nil || n.Parent == nil || n.Parent.Type != ElementNode {
+		return nil
+	}
+	return n.Parent
+}
+
+// previousElementSibling возвращает предыдущий элемент-брат
+func previousElementSibling(n *Node) *Node {
+	for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+		if s.Type == ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+// nextElementSibling возвращает следующий элемент-брат
+func nextElementSibling(n *Node) *Node {
+	for s := n.NextSibling; s != nil; s = s.NextSibling {
+		if s.Type == ElementNode {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,452 @@
+package html
+
+import (
+	"strings"
+)
+
+// NodeType определяет тип узла DOM-дерева
+type NodeType int
+
+const (
+	// DocumentNode — корневой узел документа
+	DocumentNode NodeType = iota
+	// ElementNode — HTML элемент
+	ElementNode
+	// TextNode — текстовый узел
+	TextNode
+	// CommentNode — комментарий
+	CommentNode
+	// DocumentTypeNode — объявление DOCTYPE
+	DocumentTypeNode
+	// AttributeNode — атрибут элемента. Такие узлы не входят в дерево и
+	// создаются только при вычислении XPath: TagName — имя атрибута, Data —
+	// значение, Parent — элемент-владелец
+	AttributeNode
+	// DocumentFragmentNode — фрагмент документа, например содержимое <template>
+	DocumentFragmentNode
+)
+
+// URI пространств имен элементов и атрибутов
+const (
+	HTMLNamespace   = "http://www.w3.org/1999/xhtml"
+	SVGNamespace    = "http://www.w3.org/2000/svg"
+	MathMLNamespace = "http://www.w3.org/1998/Math/MathML"
+	XLinkNamespace  = "http://www.w3.org/1999/xlink"
+	XMLNamespace    = "http://www.w3.org/XML/1998/namespace"
+	XMLNSNamespace  = "http://www.w3.org/2000/xmlns/"
+)
+
+// Node представляет узел DOM-дерева. Узлы связаны указателями на родителя
+// и соседей, поэтому один и тот же узел, найденный любым способом, — это
+// один и тот же объект, и его изменения видны всем (JavaScript, рендереру
+// и коду на Go)
+type Node struct {
+	Type        NodeType
+	Parent      *Node
+	FirstChild  *Node
+	LastChild   *Node
+	PrevSibling *Node
+	NextSibling *Node
+
+	// TagName — имя тега элемента. У HTML элементов оно в нижнем регистре,
+	// у элементов SVG сохраняет регистр (например, foreignObject)
+	TagName string
+	// Namespace — URI пространства имен элемента; пустая строка означает HTML
+	Namespace string
+	// Data — текст узла, содержимое комментария или имя DOCTYPE
+	Data string
+	// Attributes — атрибуты элемента в порядке появления в исходном документе
+	Attributes []Attribute
+
+	// Идентификаторы DOCTYPE
+	PublicID string
+	SystemID string
+
+	// Content — содержимое элемента <template>. Это отдельный фрагмент
+	// документа: его узлы не являются потомками элемента, поэтому поиск по
+	// дереву их не находит, а скрипты в них не выполняются
+	Content *Node
+
+	// Quirks — документ разобран в режиме совместимости (quirks mode).
+	// Задается только у корневого узла документа
+	Quirks bool
+
+	// Start и End — границы узла в исходном тексте: от начала открывающего
+	// тега до конца закрывающего. Неявно созданный элемент (например, <html>
+	// или <body> без тегов) начинается в позиции токена, который его
+	// потребовал. У узлов, созданных не парсером, они нулевые
+	Start Position
+	End   Position
+}
+
+// NewElement создает элемент, не привязанный к дереву
+func NewElement(tagName string) *Node {
+	n := &Node{
+		Type:    ElementNode,
+		TagName: strings.ToLower(tagName),
+	}
+	if n.TagName == "template" {
+		n.Content = NewDocumentFragment()
+	}
+	return n
+}
+
+// NewElementNS создает элемент в указанном пространстве имен, не привязанный
+// к дереву. Регистр имени сохраняется для всех пространств, кроме HTML
+func NewElementNS(namespace, tagName string) *Node {
+	if namespace == HTMLNamespace || namespace == "" {
+		return NewElement(tagName)
+	}
+	return &Node{Type: ElementNode, TagName: tagName, Namespace: namespace}
+}
+
+// NamespaceURI возвращает URI пространства имен элемента
+func (n *Node) NamespaceURI() string {
+	if n.Type != ElementNode {
+		return ""
+	}
+	if n.Namespace == "" {
+		return HTMLNamespace
+	}
+	return n.Namespace
+}
+
+// LocalName возвращает имя элемента без префикса пространства имен
+func (n *Node) LocalName() string {
+	if i := strings.IndexByte(n.TagName, ':'); i >= 0 {
+		return n.TagName[i+1:]
+	}
+	return n.TagName
+}
+
+// IsHTML проверяет, является ли узел HTML элементом
+func (n *Node) IsHTML() bool {
+	return n.Type == ElementNode && n.Namespace == ""
+}
+
+// isHTMLElement проверяет, является ли узел HTML элементом с одним из тегов
+func (n *Node) isHTMLElement(tagNames ...string) bool {
+	if !n.IsHTML() {
+		return false
+	}
+	for _, name := range tagNames {
+		if n.TagName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDocumentFragment создает пустой фрагмент документа
+func NewDocumentFragment() *Node {
+	return &Node{Type: DocumentFragmentNode}
+}
+
+// NewText создает текстовый узел
+func NewText(data string) *Node {
+	return &Node{Type: TextNode, Data: data}
+}
+
+// NewComment создает узел комментария
+func NewComment(data string) *Node {
+	return &Node{Type: CommentNode, Data: data}
+}
+
+// AppendChild добавляет дочерний узел в конец списка детей.
+// Если узел уже находится в дереве, он сначала удаляется со старого места
+func (n *Node) AppendChild(child *Node) {
+	n.InsertBefore(child, nil)
+}
+
+// InsertBefore вставляет дочерний узел перед ref (или в конец, если ref == nil).
+// Вместо фрагмента документа вставляются его дети
+func (n *Node) InsertBefore(child, ref *Node) {
+	if child.Type == DocumentFragmentNode {
+		for child.FirstChild != nil {
+			n.InsertBefore(child.FirstChild, ref)
+		}
+		return
+	}
+	if child.Parent != nil {
+		child.Parent.RemoveChild(child)
+	}
+	child.Parent = n
+	if ref == nil {
+		child.PrevSibling = n.LastChild
+		if n.LastChild != nil {
+			n.LastChild.NextSibling = child
+		} else {
+			n.FirstChild = child
+		}
+		n.LastChild = child
+		return
+	}
+	child.NextSibling = ref
+	child.PrevSibling = ref.PrevSibling
+	if ref.PrevSibling != nil {
+		ref.PrevSibling.NextSibling = child
+	} else {
+		n.FirstChild = child
+	}
+	ref.PrevSibling = child
+}
+
+// RemoveChild удаляет дочерний узел
+func (n *Node) RemoveChild(child *Node) {
+	if child.Parent != n {
+		return
+	}
+	if child.PrevSibling != nil {
+		child.PrevSibling.NextSibling = child.NextSibling
+	} else {
+		n.FirstChild = child.NextSibling
+	}
+	if child.NextSibling != nil {
+		child.NextSibling.PrevSibling = child.PrevSibling
+	} else {
+		n.LastChild = child.PrevSibling
+	}
+	child.Parent = nil
+	child.PrevSibling = nil
+	child.NextSibling = nil
+}
+
+// ReplaceChild заменяет дочерний узел old на child
+func (n *Node) ReplaceChild(child, old *Node) {
+	if old.Parent != n {
+		return
+	}
+	if child == old {
+		return
+	}
+	ref := old.NextSibling
+	if ref == child {
+		ref = child.NextSibling
+	}
+	n.RemoveChild(old)
+	n.InsertBefore(child, ref)
+}
+
+// moveChildrenTo переносит всех детей узла в конец списка детей dst
+func (n *Node) moveChildrenTo(dst *Node) {
+	for n.FirstChild != nil {
+		dst.AppendChild(n.FirstChild)
+	}
+}
+
+// CloneNode создает копию узла. При deep == true копируются и все потомки,
+// а у <template> — и его содержимое
+func (n *Node) CloneNode(deep bool) *Node {
+	c := &Node{
+		Type:      n.Type,
+		TagName:   n.TagName,
+		Namespace: n.Namespace,
+		Data:      n.Data,
+		PublicID:  n.PublicID,
+		SystemID:  n.SystemID,
+		Quirks:    n.Quirks,
+		Start:     n.Start,
+		End:       n.End,
+	}
+	if n.Attributes != nil {
+		c.Attributes = append([]Attribute(nil), n.Attributes...)
+	}
+	if n.Content != nil {
+		c.Content = NewDocumentFragment()
+		if deep {
+			n.Content.cloneChildrenTo(c.Content)
+		}
+	}
+	if deep {
+		n.cloneChildrenTo(c)
+	}
+	return c
+}
+
+// cloneChildrenTo добавляет в конец списка детей dst копии всех детей узла
+func (n *Node) cloneChildrenTo(dst *Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		dst.AppendChild(child.CloneNode(true))
+	}
+}
+
+// contents возвращает узел, дети которого составляют содержимое n в разметке:
+// для <template> это фрагмент Content, для остальных узлов — сам узел
+func (n *Node) contents() *Node {
+	if n.Content != nil && n.isHTMLElement("template") {
+		return n.Content
+	}
+	return n
+}
+
+// ChildNodes возвращает всех детей узла
+func (n *Node) ChildNodes() []*Node {
+	result := make([]*Node, 0)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		result = append(result, c)
+	}
+	return result
+}
+
+// Children возвращает дочерние элементы узла
+func (n *Node) Children() []*Node {
+	result := make([]*Node, 0)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == ElementNode {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// GetAttribute возвращает значение атрибута или пустую строку
+func (n *Node) GetAttribute(name string) string {
+	value, _ := n.LookupAttribute(name)
+	return value
+}
+
+// LookupAttribute возвращает значение атрибута и признак его наличия
+func (n *Node) LookupAttribute(name string) (string, bool) {
+	if i := n.attributeIndex(name); i >= 0 {
+		return n.Attributes[i].Value, true
+	}
+	return "", false
+}
+
+// HasAttribute проверяет наличие атрибута
+func (n *Node) HasAttribute(name string) bool {
+	return n.attributeIndex(name) >= 0
+}
+
+// SetAttribute устанавливает значение атрибута. Новый атрибут добавляется
+// в конец списка, существующий сохраняет свое место. Имена атрибутов HTML
+// элементов приводятся к нижнему регистру
+func (n *Node) SetAttribute(name, value string) {
+	if n.Namespace == "" {
+		name = strings.ToLower(name)
+	}
+	if i := n.attributeIndex(name); i >= 0 {
+		n.Attributes[i].Value = value
+		return
+	}
+	n.Attributes = append(n.Attributes, Attribute{Name: name, Value: value})
+}
+
+// RemoveAttribute удаляет атрибут
+func (n *Node) RemoveAttribute(name string) {
+	if i := n.attributeIndex(name); i >= 0 {
+		n.Attributes = append(n.Attributes[:i], n.Attributes[i+1:]...)
+	}
+}
+
+// attributeIndex возвращает индекс атрибута в списке или -1. У HTML
+// элементов имя сравнивается без учета регистра, у SVG и MathML — точно
+func (n *Node) attributeIndex(name string) int {
+	if n.Namespace == "" {
+		name = strings.ToLower(name)
+	}
+	for i, a := range n.Attributes {
+		if a.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ID возвращает значение атрибута id
+func (n *Node) ID() string {
+	return n.GetAttribute("id")
+}
+
+// ClassNames возвращает список классов элемента
+func (n *Node) ClassNames() []string {
+	return strings.FieldsFunc(n.GetAttribute("class"), isHTMLSpace)
+}
+
+// TextContent возвращает текст узла и всех его потомков
+func (n *Node) TextContent() string {
+	switch n.Type {
+	case TextNode, CommentNode:
+		return n.Data
+	case DocumentTypeNode:
+		return ""
+	}
+	var sb strings.Builder
+	n.collectText(&sb)
+	return sb.String()
+}
+
+// collectText собирает текст всех потомков
+func (n *Node) collectText(sb *strings.Builder) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case TextNode:
+			sb.WriteString(c.Data)
+		case ElementNode:
+			c.collectText(sb)
+		}
+	}
+}
+
+// SetTextContent заменяет содержимое узла одним текстовым узлом
+func (n *Node) SetTextContent(text string) {
+	switch n.Type {
+	case TextNode, CommentNode:
+		n.Data = text
+		return
+	}
+	for n.FirstChild != nil {
+		n.RemoveChild(n.FirstChild)
+	}
+	if text != "" {
+		n.AppendChild(NewText(text))
+	}
+}
+
+// FindElementsByTagName находит всех потомков с указанным тегом
+func (n *Node) FindElementsByTagName(tagName string) []*Node {
+	result := make([]*Node, 0)
+	n.walk(func(el *Node) bool {
+		if tagName == "*" || strings.EqualFold(el.TagName, tagName) {
+			result = append(result, el)
+		}
+		return true
+	})
+	return result
+}
+
+// FindElementsByID находит первого потомка с указанным ID
+func (n *Node) FindElementsByID(id string) *Node {
+	var found *Node
+	n.walk(func(el *Node) bool {
+		if el.ID() == id {
+			found = el
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// walk обходит элементы-потомки в порядке документа, пока fn возвращает true
+func (n *Node) walk(fn func(el *Node) bool) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != ElementNode {
+			continue
+		}
+		if !fn(c) || !c.walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains проверяет, является ли other самим узлом или его потомком
+func (n *Node) Contains(other *Node) bool {
+	for ; other != nil; other = other.Parent {
+		if other == n {
+			return true
+		}
+	}
+	return false
+}
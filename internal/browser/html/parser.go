@@ -3,35 +3,40 @@ package html
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/weburl"
 )
 
 // Parser представляет HTML парсер
-type Parser struct {}
-
-// Document представляет DOM-дерево HTML документа
-type Document struct {
-	Title    string
-	Body     string
-	Elements []Element
-	Head     *Element
-	BodyElem *Element
+type Parser struct {
+	// scripting определяет, разбирается ли документ с включенным JavaScript
+	// (от этого зависит разбор содержимого <noscript>)
+	scripting bool
 }
 
-// Element представляет HTML элемент
-type Element struct {
-	TagName    string
-	Attributes map[string]string
-	Children   []Element
-	Parent     *Element
-	Text       string
-	ID         string
-	ClassNames []string
+// Document представляет DOM-дерево HTML документа.
+// Сам документ является корневым узлом дерева
+type Document struct {
+	Node
+	
+	// URL — адрес, по которому документ был загружен (nil, если документ
+	// создан не из сети)
+	URL *weburl.URL
+	
+	// Errors — ошибки разбора в порядке их расположения в исходном тексте
+	Errors []ParseError
 }
 
 // NewParser создает новый HTML парсер
 func NewParser() *Parser {
-	return &Parser{}
+	return &Parser{scripting: true}
+}
+
+// NewDocument создает пустой документ
+func NewDocument() *Document {
+	return &Document{Node: Node{Type: DocumentNode}}
 }
 
 // Parse разбирает HTML строку и возвращает DOM-дерево
@@ -39,138 +44,121 @@ func (p *Parser) Parse(htmlContent string) (*Document, error) {
 	log.Println("Парсинг HTML документа...")
 	
 	// Создаем документ
-	doc := &Document{
-		Elements: make([]Element, 0),
-	}
-	
-	// Простой парсинг заголовка
-	titleStart := strings.Index(htmlContent, "<title>")
-	titleEnd := strings.Index(htmlContent, "</title>")
-	if titleStart >= 0 && titleEnd > titleStart {
-		doc.Title = htmlContent[titleStart+7 : titleEnd]
-	} else {
-		doc.Title = "Без заголовка"
-	}
+	doc := NewDocument()
 	
-	// Простой парсинг тела
-	bodyStart := strings.Index(htmlContent, "<body")
-	bodyEnd := strings.Index(htmlContent, "</body>")
-	if bodyStart >= 0 && bodyEnd > bodyStart {
-		// Находим закрывающую скобку открывающего тега body
-		bodyTagEnd := strings.Index(htmlContent[bodyStart:], ">")
-		if bodyTagEnd > 0 {
-			doc.Body = htmlContent[bodyStart+bodyTagEnd+1 : bodyEnd]
-		}
-	} else {
-		// Если нет тегов body, используем весь документ
-		doc.Body = htmlContent
-	}
-	
-	// Создаем корневые элементы
-	html := Element{
-		TagName:    "html",
-		Attributes: make(map[string]string),
-		Children:   make([]Element, 0),
-	}
+	// Строим дерево по алгоритму HTML5
+	tokenizer := NewTokenizer(htmlContent)
+	builder := newTreeBuilder(doc, tokenizer, p.scripting)
+	builder.build()
 	
-	head := Element{
-		TagName:    "head",
-		Attributes: make(map[string]string),
-		Children:   make([]Element, 0),
-		Parent:     &html,
-	}
-	
-	body := Element{
-		TagName:    "body",
-		Attributes: make(map[string]string),
-		Children:   make([]Element, 0),
-		Parent:     &html,
-		Text:       doc.Body,
-	}
-	
-	html.Children = append(html.Children, head, body)
-	doc.Elements = append(doc.Elements, html)
-	doc.Head = &head
-	doc.BodyElem = &body
-	
-	// В реальном браузере здесь должен быть полноценный парсинг DOM-дерева
-	// Для демонстрации используем упрощенную версию
+	doc.Errors = collectErrors(tokenizer, builder)
 	
 	return doc, nil
 }
 
-// FindElementsByTagName находит все элементы с указанным тегом
-func (d *Document) FindElementsByTagName(tagName string) []Element {
-	result := make([]Element, 0)
-	
-	// Рекурсивный поиск по всем элементам
-	for _, element := range d.Elements {
-		findElementsByTagNameRecursive(&element, tagName, &result)
+// collectErrors объединяет ошибки токенизатора и построителя дерева в один
+// список, упорядоченный по позиции
+func collectErrors(tokenizer *Tokenizer, builder *treeBuilder) []ParseError {
+	errors := append(append([]ParseError(nil), tokenizer.Errors()...), builder.errors...)
+	sort.SliceStable(errors, func(i, j int) bool {
+		a, b := errors[i].Pos, errors[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return errors
+}
+
+// ParseFragment разбирает HTML фрагмент в контексте элемента context (как при
+// присваивании innerHTML) и возвращает получившиеся узлы без родителя
+func (p *Parser) ParseFragment(htmlContent string, context *Node) ([]*Node, error) {
+	if context == nil || context.Type != ElementNode {
+		return nil, fmt.Errorf("контекст фрагмента должен быть элементом")
 	}
 	
-	return result
+	b, root := newFragmentTreeBuilder(NewDocument(), NewTokenizer(htmlContent), p.scripting, context)
+	b.build()
+	
+	var nodes []*Node
+	for root.FirstChild != nil {
+		child := root.FirstChild
+		root.RemoveChild(child)
+		nodes = append(nodes, child)
+	}
+	return nodes, nil
 }
 
-// FindElementsByID находит элемент с указанным ID
-func (d *Document) FindElementsByID(id string) *Element {
-	// Рекурсивный поиск по всем элементам
-	for _, element := range d.Elements {
-		if found := findElementByIDRecursive(&element, id); found != nil {
-			return found
+// DocumentElement возвращает корневой элемент <html>
+func (d *Document) DocumentElement() *Node {
+	for c := d.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == ElementNode {
+			return c
 		}
 	}
-	
 	return nil
 }
 
-// Вспомогательная функция для рекурсивного поиска по тегу
-func findElementsByTagNameRecursive(element *Element, tagName string, result *[]Element) {
-	if strings.EqualFold(element.TagName, tagName) {
-		*result = append(*result, *element)
-	}
-	
-	for i := range element.Children {
-		findElementsByTagNameRecursive(&element.Children[i], tagName, result)
+// Head возвращает элемент <head>
+func (d *Document) Head() *Node {
+	return d.rootChild("head")
+}
+
+// Body возвращает элемент <body> (или <frameset>)
+func (d *Document) Body() *Node {
+	if body := d.rootChild("body"); body != nil {
+		return body
 	}
+	return d.rootChild("frameset")
 }
 
-// Вспомогательная функция для рекурсивного поиска по ID
-func findElementByIDRecursive(element *Element, id string) *Element {
-	if element.ID == id {
-		return element
+// rootChild возвращает дочерний элемент <html> с указанным тегом
+func (d *Document) rootChild(tagName string) *Node {
+	html := d.DocumentElement()
+	if html == nil {
+		return nil
 	}
-	
-	for i := range element.Children {
-		if found := findElementByIDRecursive(&element.Children[i], id); found != nil {
-			return found
+	for c := html.FirstChild; c != nil; c = c.NextSibling {
+		if c.isHTMLElement(tagName) {
+			return c
 		}
 	}
-	
 	return nil
 }
 
-// GetInnerHTML возвращает внутреннее HTML содержимое элемента
-func (e *Element) GetInnerHTML() string {
-	var sb strings.Builder
-	
-	// Добавляем текст элемента
-	if e.Text != "" {
-		sb.WriteString(e.Text)
+// Title возвращает заголовок документа из первого элемента <title>
+// с удаленными и схлопнутыми пробелами
+func (d *Document) Title() string {
+	title := d.titleElement()
+	if title == nil {
+		return ""
 	}
-	
-	// Добавляем HTML всех дочерних элементов
-	for _, child := range e.Children {
-		sb.WriteString(fmt.Sprintf("<%s", child.TagName))
-		
-		// Добавляем атрибуты
-		for key, value := range child.Attributes {
-			sb.WriteString(fmt.Sprintf(" %s=\"%s\"", key, value))
-		}
-		
-		sb.WriteString(">")
-		sb.WriteString(child.GetInnerHTML())
-		sb.WriteString(fmt.Sprintf("</%s>", child.TagName))
+	// Схлопываются только пробельные символы ASCII: &nbsp; в заголовке сохраняется
+	return strings.Join(strings.FieldsFunc(title.TextContent(), isHTMLSpace), " ")
+}
+
+// SetTitle изменяет заголовок документа, создавая <title> при необходимости
+func (d *Document) SetTitle(title string) {
+	if element := d.titleElement(); element != nil {
+		element.SetTextContent(title)
+		return
 	}
-	
-	return sb.String()
-}
\ No newline at end of file
+	head := d.Head()
+	if head == nil {
+		return
+	}
+	element := NewElement("title")
+	element.SetTextContent(title)
+	head.AppendChild(element)
+}
+
+// titleElement возвращает первый HTML элемент <title>; <title> внутри SVG
+// заголовком документа не является
+func (d *Document) titleElement() *Node {
+	var found *Node
+	d.walk(func(el *Node) bool {
+		if el.isHTMLElement("title") {
+			found = el
+			return false
+		}
+		return true
+	})
+	return found
+}
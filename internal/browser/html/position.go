@@ -0,0 +1,75 @@
+package html
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Position — позиция в исходном тексте документа. Строки и столбцы
+// нумеруются с единицы, столбцы считаются в символах Unicode
+type Position struct {
+	Line   int
+	Column int
+}
+
+// IsValid сообщает, известна ли позиция (у узлов, созданных не парсером, она нулевая)
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String возвращает позицию в виде «строка:столбец»
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// ParseError — ошибка разбора HTML. Code — имя ошибки из спецификации
+// (например, unexpected-null-character), Pos — место ее обнаружения
+type ParseError struct {
+	Code string
+	Pos  Position
+}
+
+// Error возвращает описание ошибки вместе с позицией
+func (e ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Code)
+}
+
+// lineIndex переводит смещения во входном потоке в позиции «строка:столбец».
+// При потоковом разборе начала давно прочитанных строк забываются
+type lineIndex struct {
+	// starts — смещения начал известных строк, first — номер первой из них
+	starts []int
+	first  int
+}
+
+// newLineIndex создает индекс для входа, который начинается с первой строки
+func newLineIndex() *lineIndex {
+	return &lineIndex{starts: []int{0}, first: 1}
+}
+
+// add запоминает начала строк в символах input, начинающихся со смещения offset
+func (l *lineIndex) add(input []rune, offset int) {
+	for i, c := range input {
+		if c == '\n' {
+			l.starts = append(l.starts, offset+i+1)
+		}
+	}
+}
+
+// forget забывает строки, которые целиком находятся до смещения offset
+func (l *lineIndex) forget(offset int) {
+	n := sort.Search(len(l.starts), func(i int) bool { return l.starts[i] > offset }) - 1
+	if n > 0 {
+		l.starts = append(l.starts[:0], l.starts[n:]...)
+		l.first += n
+	}
+}
+
+// position возвращает позицию символа со смещением offset
+func (l *lineIndex) position(offset int) Position {
+	line := sort.Search(len(l.starts), func(i int) bool { return l.starts[i] > offset }) - 1
+	if line < 0 {
+		line = 0
+	}
+	return Position{Line: l.first + line, Column: offset - l.starts[line] + 1}
+}
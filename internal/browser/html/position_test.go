@@ -0,0 +1,62 @@
+package html
+
+import "testing"
+
+func TestNullCharacterErrorPosition(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		// Ошибку сообщают токенизатор и построитель дерева, и оба указывают
+		// на сам символ
+		{"<p>a\x00b", []string{"2:5: unexpected-null-character", "2:5: unexpected-null-character"}},
+		{"<p>a\n\x00b\x00", []string{"3:1: unexpected-null-character", "3:1: unexpected-null-character", "3:3: unexpected-null-character", "3:3: unexpected-null-character"}},
+		{"<svg>a\x00</svg>", []string{"2:7: unexpected-null-character", "2:7: unexpected-null-character"}},
+	}
+	for _, test := range tests {
+		doc := parseFixture(t, "<!DOCTYPE html>\n"+test.src)
+		var got []string
+		for _, e := range doc.Errors {
+			if e.Code == "unexpected-null-character" {
+				got = append(got, e.Error())
+			}
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%q: ошибки %v, ожидалось %v", test.src, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%q: ошибки %v, ожидалось %v", test.src, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestImpliedElementPositions(t *testing.T) {
+	doc := parseFixture(t, "<!DOCTYPE html>\n<p>x")
+	// Неявные html, head и body начинаются в позиции токена, который
+	// потребовал их создания, и имеют нулевую длину
+	for _, n := range []*Node{doc.DocumentElement(), doc.Head(), doc.Body()} {
+		if n.Start != (Position{Line: 2, Column: 1}) {
+			t.Errorf("<%s>: начало %s, ожидалось 2:1", n.TagName, n.Start)
+		}
+	}
+	p := doc.Body().FirstChild
+	if p.Start != (Position{Line: 2, Column: 1}) || p.End != (Position{Line: 2, Column: 5}) {
+		t.Errorf("<p>: %s–%s, ожидалось 2:1–2:5", p.Start, p.End)
+	}
+
+	// Пустой документ: все неявные элементы начинаются в 1:1
+	empty := parseFixture(t, "")
+	if start := empty.DocumentElement().Start; start != (Position{Line: 1, Column: 1}) {
+		t.Errorf("<html> пустого документа: начало %s, ожидалось 1:1", start)
+	}
+
+	// Явный <html> начинается со своего тега
+	explicit := parseFixture(t, "<!DOCTYPE html><html lang=ru><p>x")
+	if start := explicit.DocumentElement().Start; start != (Position{Line: 1, Column: 16}) {
+		t.Errorf("явный <html>: начало %s, ожидалось 1:16", start)
+	}
+}
@@ -0,0 +1,637 @@
+package html
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Selector — скомпилированный список селекторов CSS (Selectors Level 4)
+type Selector struct {
+	text string
+	list []complexSelector
+}
+
+// complexSelector — составные селекторы, соединенные комбинаторами
+type complexSelector struct {
+	// parts перечислены слева направо
+	parts []selectorPart
+	// pseudoElement — имя псевдоэлемента в конце селектора (например, "before")
+	pseudoElement string
+	specificity   Specificity
+}
+
+// selectorPart — составной селектор и комбинатор, связывающий его с частью слева
+type selectorPart struct {
+	compound compoundSelector
+	// combinator: ' ' — потомок, '>' — ребенок, '+' — соседний, '~' — любой
+	// следующий брат; 0 у самой левой части
+	combinator rune
+}
+
+// compoundSelector — последовательность простых селекторов без комбинаторов
+type compoundSelector struct {
+	// tagName — имя тега в том виде, в каком оно записано в селекторе; пустая
+	// строка означает любой элемент
+	tagName string
+	// anchor означает элемент, относительно которого проверяется :has()
+	anchor bool
+	simple []simpleSelector
+}
+
+// simpleSelector — простой селектор внутри составного
+type simpleSelector interface {
+	match(n *Node, ctx *matchContext) bool
+	specificity() Specificity
+}
+
+// Specificity — специфичность селектора: число идентификаторов, классов
+// (атрибутов, псевдоклассов) и типов
+type Specificity [3]int
+
+// Less сравнивает специфичности
+func (s Specificity) Less(other Specificity) bool {
+	for i := range s {
+		if s[i] != other[i] {
+			return s[i] < other[i]
+		}
+	}
+	return false
+}
+
+func (s Specificity) add(other Specificity) Specificity {
+	return Specificity{s[0] + other[0], s[1] + other[1], s[2] + other[2]}
+}
+
+// selectorCacheLimit ограничивает число скомпилированных селекторов в кэше
+const selectorCacheLimit = 512
+
+var (
+	selectorCacheMu sync.Mutex
+	selectorCache   = make(map[string]*Selector)
+)
+
+// CompileSelector разбирает список селекторов. Результаты кэшируются, поэтому
+// повторная компиляция одного и того же селектора ничего не стоит
+func CompileSelector(text string) (*Selector, error) {
+	selectorCacheMu.Lock()
+	sel, ok := selectorCache[text]
+	selectorCacheMu.Unlock()
+	if ok {
+		return sel, nil
+	}
+
+	p := &selectorParser{input: []rune(text)}
+	list, err := p.parseSelectorList(false)
+	if err == nil && p.pos < len(p.input) {
+		err = p.errorf("неожиданный символ %q", p.input[p.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("неверный селектор %q: %w", text, err)
+	}
+	sel = &Selector{text: text, list: list}
+
+	selectorCacheMu.Lock()
+	if len(selectorCache) >= selectorCacheLimit {
+		selectorCache = make(map[string]*Selector)
+	}
+	selectorCache[text] = sel
+	selectorCacheMu.Unlock()
+
+	return sel, nil
+}
+
+// MustCompileSelector работает как CompileSelector, но паникует при ошибке
+func MustCompileSelector(text string) *Selector {
+	sel, err := CompileSelector(text)
+	if err != nil {
+		panic(err)
+	}
+	return sel
+}
+
+// String возвращает исходный текст селектора
+func (s *Selector) String() string {
+	return s.text
+}
+
+// selectorParser разбирает текст селектора
+type selectorParser struct {
+	input []rune
+	pos   int
+}
+
+func (p *selectorParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("позиция %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *selectorParser) peek(i int) rune {
+	if p.pos+i >= len(p.input) {
+		return eof
+	}
+	return p.input[p.pos+i]
+}
+
+// skipSpace пропускает пробелы и сообщает, были ли они
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for p.pos < len(p.input) && isHTMLSpace(p.input[p.pos]) {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// parseSelectorList разбирает список селекторов через запятую до конца
+// входа или закрывающей скобки
+func (p *selectorParser) parseSelectorList(relative bool) ([]complexSelector, error) {
+	var list []complexSelector
+	for {
+		p.skipSpace()
+		sel, err := p.parseComplex(relative)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, sel)
+		p.skipSpace()
+		if p.peek(0) != ',' {
+			return list, nil
+		}
+		p.pos++
+	}
+}
+
+// parseComplex разбирает селектор с комбинаторами. Относительный селектор
+// (аргумент :has) может начинаться с комбинатора
+func (p *selectorParser) parseComplex(relative bool) (complexSelector, error) {
+	var sel complexSelector
+	combinator := rune(0)
+
+	if relative {
+		sel.parts = append(sel.parts, selectorPart{compound: compoundSelector{anchor: true}})
+		combinator = ' '
+		if c := p.peek(0); c == '>' || c == '+' || c == '~' {
+			combinator = c
+			p.pos++
+			p.skipSpace()
+		}
+	}
+
+	for {
+		compound, pseudoElement, err := p.parseCompound()
+		if err != nil {
+			return sel, err
+		}
+		sel.parts = append(sel.parts, selectorPart{compound: compound, combinator: combinator})
+		sel.specificity = sel.specificity.add(compound.specificity())
+
+		space := p.skipSpace()
+		c := p.peek(0)
+		if pseudoElement != "" {
+			sel.pseudoElement = pseudoElement
+			sel.specificity[2]++
+			if c != ',' && c != ')' && c != eof {
+				return sel, p.errorf("псевдоэлемент должен завершать селектор")
+			}
+		}
+
+		switch {
+		case c == '>' || c == '+' || c == '~':
+			p.pos++
+			p.skipSpace()
+			combinator = c
+		case c == ',' || c == ')' || c == eof:
+			return sel, nil
+		case space:
+			combinator = ' '
+		default:
+			return sel, p.errorf("неожиданный символ %q", c)
+		}
+	}
+}
+
+// parseCompound разбирает составной селектор и псевдоэлемент после него
+func (p *selectorParser) parseCompound() (compoundSelector, string, error) {
+	var compound compoundSelector
+	start := p.pos
+
+	// Селектор типа или универсальный селектор, возможно с пространством имен
+	if p.peek(0) == '*' || p.peek(0) == '|' || p.startsIdent() {
+		name := "*"
+		if p.peek(0) == '*' {
+			p.pos++
+		} else if p.peek(0) != '|' {
+			name = p.parseIdent()
+		}
+		if p.peek(0) == '|' && p.peek(1) != '=' {
+			p.pos++
+			if p.peek(0) == '*' {
+				p.pos++
+				name = "*"
+			} else if p.startsIdent() {
+				name = p.parseIdent()
+			} else {
+				return compound, "", p.errorf("ожидалось имя элемента после '|'")
+			}
+		}
+		if name != "*" {
+			compound.tagName = name
+		}
+	}
+
+	for {
+		switch p.peek(0) {
+		case '#':
+			p.pos++
+			if !p.startsIdent() {
+				return compound, "", p.errorf("ожидался идентификатор после '#'")
+			}
+			compound.simple = append(compound.simple, idSelector(p.parseIdent()))
+		case '.':
+			p.pos++
+			if !p.startsIdent() {
+				return compound, "", p.errorf("ожидалось имя класса после '.'")
+			}
+			compound.simple = append(compound.simple, classSelector(p.parseIdent()))
+		case '[':
+			p.pos++
+			attr, err := p.parseAttribute()
+			if err != nil {
+				return compound, "", err
+			}
+			compound.simple = append(compound.simple, attr)
+		case ':':
+			p.pos++
+			if p.peek(0) == ':' {
+				p.pos++
+				if !p.startsIdent() {
+					return compound, "", p.errorf("ожидалось имя псевдоэлемента")
+				}
+				return compound, strings.ToLower(p.parseIdent()), nil
+			}
+			if !p.startsIdent() {
+				return compound, "", p.errorf("ожидалось имя псевдокласса")
+			}
+			name := strings.ToLower(p.parseIdent())
+			// Псевдоэлементы CSS2 допускают запись с одним двоеточием
+			switch name {
+			case "before", "after", "first-line", "first-letter":
+				return compound, name, nil
+			}
+			simple, err := p.parsePseudoClass(name)
+			if err != nil {
+				return compound, "", err
+			}
+			compound.simple = append(compound.simple, simple)
+		default:
+			if p.pos == start {
+				return compound, "", p.errorf("ожидался селектор")
+			}
+			return compound, "", nil
+		}
+	}
+}
+
+// parseAttribute разбирает селектор атрибута после '['
+func (p *selectorParser) parseAttribute() (simpleSelector, error) {
+	p.skipSpace()
+	if p.peek(0) == '*' && p.peek(1) == '|' {
+		p.pos += 2
+	} else if p.peek(0) == '|' {
+		p.pos++
+	}
+	if !p.startsIdent() {
+		return nil, p.errorf("ожидалось имя атрибута")
+	}
+	attr := &attributeSelector{name: p.parseIdent()}
+	if p.peek(0) == '|' && p.peek(1) != '=' {
+		// Префикс пространства имен: имя атрибута идет после '|'
+		p.pos++
+		if !p.startsIdent() {
+			return nil, p.errorf("ожидалось имя атрибута")
+		}
+		attr.name = p.parseIdent()
+	}
+	p.skipSpace()
+
+	switch c := p.peek(0); c {
+	case ']':
+		p.pos++
+		return attr, nil
+	case '=':
+		attr.op = '='
+		p.pos++
+	case '~', '|', '^', '$', '*':
+		if p.peek(1) != '=' {
+			return nil, p.errorf("неизвестный оператор атрибута")
+		}
+		attr.op = c
+		p.pos += 2
+	default:
+		return nil, p.errorf("неожиданный символ %q в селекторе атрибута", c)
+	}
+
+	p.skipSpace()
+	switch c := p.peek(0); {
+	case c == '"' || c == '\'':
+		value, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		attr.value = value
+	case p.startsIdent():
+		attr.value = p.parseIdent()
+	default:
+		return nil, p.errorf("ожидалось значение атрибута")
+	}
+	p.skipSpace()
+
+	// Флаги регистра: i — без учета регистра, s — с учетом
+	if c := toLowerASCII(p.peek(0)); (c == 'i' || c == 's') && !isNameChar(p.peek(1)) {
+		attr.caseInsensitive = c == 'i'
+		attr.caseSensitive = c == 's'
+		p.pos++
+		p.skipSpace()
+	}
+	if p.peek(0) != ']' {
+		return nil, p.errorf("ожидалась ']'")
+	}
+	p.pos++
+	return attr, nil
+}
+
+// parsePseudoClass разбирает псевдокласс (имя уже прочитано)
+func (p *selectorParser) parsePseudoClass(name string) (simpleSelector, error) {
+	if p.peek(0) != '(' {
+		fn, ok := pseudoClasses[name]
+		if !ok {
+			return nil, p.errorf("неизвестный псевдокласс :%s", name)
+		}
+		return pseudoClassSelector{name: name, fn: fn}, nil
+	}
+	p.pos++
+
+	var (
+		simple simpleSelector
+		err    error
+	)
+	switch name {
+	case "not", "is", "where", "matches", "any", "-webkit-any":
+		var list []complexSelector
+		if list, err = p.parseSelectorList(false); err == nil {
+			simple = &logicalSelector{name: name, list: list}
+		}
+	case "has":
+		var list []complexSelector
+		if list, err = p.parseSelectorList(true); err == nil {
+			simple = &hasSelector{list: list}
+		}
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		simple, err = p.parseNth(name)
+	case "lang":
+		simple, err = p.parseLang()
+	default:
+		return nil, p.errorf("неизвестный псевдокласс :%s()", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.peek(0) != ')' {
+		return nil, p.errorf("ожидалась ')'")
+	}
+	p.pos++
+	return simple, nil
+}
+
+// parseNth разбирает аргумент An+B семейства :nth-child
+func (p *selectorParser) parseNth(name string) (simpleSelector, error) {
+	nth := &nthSelector{
+		last:   strings.HasPrefix(name, "nth-last"),
+		ofType: strings.HasSuffix(name, "of-type"),
+	}
+
+	// Аргумент An+B заканчивается на ')' или на ключевом слове "of"
+	start := p.pos
+	for p.pos < len(p.input) && p.input[p.pos] != ')' {
+		if !nth.ofType && p.pos > start && isHTMLSpace(p.input[p.pos-1]) &&
+			strings.EqualFold(string(p.input[p.pos:min(p.pos+2, len(p.input))]), "of") &&
+			!isNameChar(p.peek(2)) {
+			break
+		}
+		p.pos++
+	}
+
+	var err error
+	nth.a, nth.b, err = parseAnPlusB(string(p.input[start:p.pos]))
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+
+	if !nth.ofType && p.peek(0) != ')' {
+		p.pos += 2
+		p.skipSpace()
+		if nth.of, err = p.parseSelectorList(false); err != nil {
+			return nil, err
+		}
+	}
+	return nth, nil
+}
+
+// parseAnPlusB разбирает запись An+B (а также odd и even)
+func parseAnPlusB(s string) (int, int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	case "":
+		return 0, 0, fmt.Errorf("пустой аргумент An+B")
+	}
+
+	i := strings.IndexByte(s, 'n')
+	if i < 0 {
+		b, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, 0, fmt.Errorf("неверный аргумент An+B %q", s)
+		}
+		return 0, b, nil
+	}
+
+	var a int
+	switch coefficient := s[:i]; coefficient {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		var err error
+		if a, err = strconv.Atoi(coefficient); err != nil {
+			return 0, 0, fmt.Errorf("неверный аргумент An+B %q", s)
+		}
+	}
+
+	rest := strings.TrimSpace(s[i+1:])
+	if rest == "" {
+		return a, 0, nil
+	}
+	sign := rest[0]
+	digits := strings.TrimSpace(rest[1:])
+	if sign != '+' && sign != '-' || digits == "" || digits[0] == '+' || digits[0] == '-' {
+		return 0, 0, fmt.Errorf("неверный аргумент An+B %q", s)
+	}
+	b, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, 0, fmt.Errorf("неверный аргумент An+B %q", s)
+	}
+	if sign == '-' {
+		b = -b
+	}
+	return a, b, nil
+}
+
+// parseLang разбирает список языков :lang()
+func (p *selectorParser) parseLang() (simpleSelector, error) {
+	var langs langSelector
+	for {
+		p.skipSpace()
+		switch c := p.peek(0); {
+		case c == '"' || c == '\'':
+			value, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			langs = append(langs, strings.ToLower(value))
+		case p.startsIdent():
+			langs = append(langs, strings.ToLower(p.parseIdent()))
+		default:
+			return nil, p.errorf("ожидался код языка")
+		}
+		p.skipSpace()
+		if p.peek(0) != ',' {
+			return langs, nil
+		}
+		p.pos++
+	}
+}
+
+// startsIdent проверяет, начинается ли с текущей позиции идентификатор CSS
+func (p *selectorParser) startsIdent() bool {
+	c := p.peek(0)
+	if c == '-' {
+		c = p.peek(1)
+		if c == '-' {
+			return true
+		}
+		if c == '\\' {
+			return p.peek(2) != '\n' && p.peek(2) != eof
+		}
+		return isNameStart(c)
+	}
+	if c == '\\' {
+		return p.peek(1) != '\n' && p.peek(1) != eof
+	}
+	return isNameStart(c)
+}
+
+// parseIdent читает идентификатор CSS с учетом экранирования
+func (p *selectorParser) parseIdent() string {
+	var sb strings.Builder
+	for {
+		c := p.peek(0)
+		switch {
+		case c == '\\' && p.peek(1) != '\n' && p.peek(1) != eof:
+			p.pos++
+			sb.WriteRune(p.parseEscape())
+		case isNameChar(c):
+			sb.WriteRune(c)
+			p.pos++
+		default:
+			return sb.String()
+		}
+	}
+}
+
+// parseEscape читает экранированный символ после '\'
+func (p *selectorParser) parseEscape() rune {
+	if !isASCIIHexDigit(p.peek(0)) {
+		c := p.peek(0)
+		p.pos++
+		return c
+	}
+	var code rune
+	for i := 0; i < 6 && isASCIIHexDigit(p.peek(0)); i++ {
+		code = code*16 + hexValue(p.peek(0))
+		p.pos++
+	}
+	// Один пробел после шестнадцатеричного кода относится к экранированию
+	if isHTMLSpace(p.peek(0)) {
+		p.pos++
+	}
+	if code == 0 || code > 0x10FFFF || code >= 0xD800 && code <= 0xDFFF {
+		return '�'
+	}
+	return code
+}
+
+// parseString читает строку в кавычках
+func (p *selectorParser) parseString() (string, error) {
+	quote := p.peek(0)
+	p.pos++
+	var sb strings.Builder
+	for {
+		c := p.peek(0)
+		switch c {
+		case quote:
+			p.pos++
+			return sb.String(), nil
+		case eof, '\n':
+			return "", p.errorf("незакрытая строка")
+		case '\\':
+			p.pos++
+			switch p.peek(0) {
+			case eof:
+			case '\n':
+				p.pos++
+			default:
+				sb.WriteRune(p.parseEscape())
+			}
+		default:
+			sb.WriteRune(c)
+			p.pos++
+		}
+	}
+}
+
+func isNameStart(c rune) bool {
+	return isASCIIAlpha(c) || c == '_' || c >= 0x80
+}
+
+func isNameChar(c rune) bool {
+	return isNameStart(c) || isASCIIDigit(c) || c == '-'
+}
+
+// specificity возвращает специфичность составного селектора
+func (c compoundSelector) specificity() Specificity {
+	var s Specificity
+	if c.tagName != "" {
+		s[2]++
+	}
+	for _, simple := range c.simple {
+		s = s.add(simple.specificity())
+	}
+	return s
+}
+
+// maxSpecificity возвращает наибольшую специфичность в списке селекторов
+func maxSpecificity(list []complexSelector) Specificity {
+	var max Specificity
+	for _, sel := range list {
+		if max.Less(sel.specificity) {
+			max = sel.specificity
+		}
+	}
+	return max
+}
@@ -0,0 +1,254 @@
+package html
+
+import (
+	"strings"
+	"testing"
+)
+
+const selectorFixture = `<!DOCTYPE html><div id=root>
+<ul id=list><li id=l1 class="a b">1</li><li id=l2>2</li><li id=l3 class=a>3</li><li id=l4>4</li><li id=l5 class=a>5</li><li id=l6>6</li></ul>
+<p id=p1 lang=en-US title="Hello World">x</p><span id=s1 data-x="foo-bar baz">y</span><p id=p2></p>
+<a id=a1 href=#x hreflang=EN>link</a><svg id=svg1 viewBox="0 0 1 1"><foreignObject id=fo1></foreignObject></svg>
+</div>`
+
+// parseFixture разбирает документ для тестов или прерывает тест
+func parseFixture(t *testing.T, src string) *Document {
+	t.Helper()
+	doc, err := NewParser().Parse(src)
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	return doc
+}
+
+// ids возвращает идентификаторы элементов через пробел
+func ids(nodes []*Node) string {
+	list := make([]string, len(nodes))
+	for i, n := range nodes {
+		list[i] = n.GetAttribute("id")
+	}
+	return strings.Join(list, " ")
+}
+
+func TestQuerySelectorAll(t *testing.T) {
+	doc := parseFixture(t, selectorFixture)
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		// Комбинаторы
+		{"#root > ul > li.a", "l1 l3 l5"},
+		{"#root p", "p1 p2"},
+		{"div > li", ""},
+		{"#p1 + span", "s1"},
+		{"#p1 + p", ""},
+		{"#p1 ~ p", "p2"},
+		{"ul  >  li:first-child", "l1"},
+		{"p, #s1, li.b", "l1 p1 s1 p2"},
+		{"*|li:last-child", "l6"},
+
+		// An+B
+		{"li:nth-child(odd)", "l1 l3 l5"},
+		{"li:nth-child(EVEN)", "l2 l4 l6"},
+		{"li:nth-child(2n+1)", "l1 l3 l5"},
+		{"li:nth-child( 3n - 1 )", "l2 l5"},
+		{"li:nth-child(-n+3)", "l1 l2 l3"},
+		{"li:nth-child(n+5)", "l5 l6"},
+		{"li:nth-child(+4)", "l4"},
+		{"li:nth-child(0n+2)", "l2"},
+		{"li:nth-child(-2n+10)", "l2 l4 l6"},
+		{"li:nth-last-child(2)", "l5"},
+		{"li:nth-child(2 of .a)", "l3"},
+		{"li:nth-last-child(1 of .a)", "l5"},
+		{"li:nth-of-type(3n)", "l3 l6"},
+		{"p:nth-last-of-type(1)", "p2"},
+		{"p:only-of-type", ""},
+		{"p:empty", "p2"},
+
+		// Логические псевдоклассы
+		{"li:not(.a)", "l2 l4 l6"},
+		{"li:not(.a, :nth-child(2))", "l4 l6"},
+		{"li:is(#l2, .b)", "l1 l2"},
+		{"li:where(:last-child)", "l6"},
+		{"ul:has(> .b)", "list"},
+		{"li:has(+ #l2)", "l1"},
+
+		// Атрибуты
+		{"[title]", "p1"},
+		{`[title="Hello World"]`, "p1"},
+		{`[title="hello world"]`, ""},
+		{`[title="hello world" i]`, "p1"},
+		{`[title="hello world"I]`, "p1"},
+		{"[data-x~=baz]", "s1"},
+		{"[data-x~=bar]", ""},
+		{"[data-x|=foo]", "s1"},
+		{"[data-x^=foo]", "s1"},
+		{"[data-x$=baz]", "s1"},
+		{`[data-x*="bar b"]`, "s1"},
+		{`[data-x^=""]`, ""},
+		{"[hreflang=en]", "a1"},
+		{"[hreflang=en s]", ""},
+		{"[lang|=en]", "p1"},
+		{":lang(en)", "p1"},
+
+		// Имена тегов: HTML без учета регистра, SVG с учетом
+		{"LI#L1", ""},
+		{"LI#l1", "l1"},
+		{"foreignObject", "fo1"},
+		{"foreignobject", ""},
+		{"svg[viewBox]", "svg1"},
+		{":link", "a1"},
+	}
+	for _, test := range tests {
+		nodes, err := doc.DocumentElement().QuerySelectorAll(test.selector)
+		if err != nil {
+			t.Errorf("%s: ошибка %v", test.selector, err)
+			continue
+		}
+		if got := ids(nodes); got != test.want {
+			t.Errorf("%s: получено %q, ожидалось %q", test.selector, got, test.want)
+		}
+	}
+}
+
+func TestSelectorSpecificity(t *testing.T) {
+	doc := parseFixture(t, selectorFixture)
+	byID := func(id string) *Node {
+		n, _ := doc.DocumentElement().QuerySelector("#" + id)
+		return n
+	}
+	tests := []struct {
+		selector string
+		id       string
+		want     Specificity
+	}{
+		{"li", "l1", Specificity{0, 0, 1}},
+		{"ul > li.a.b", "l1", Specificity{0, 2, 2}},
+		{"#list li:first-child", "l1", Specificity{1, 1, 1}},
+		{"[data-x]", "s1", Specificity{0, 1, 0}},
+		{"li:not(#l1)", "l2", Specificity{1, 0, 1}},
+		{"li:not(.a, #l9)", "l2", Specificity{1, 0, 1}},
+		// :is() берет наибольшую специфичность из списка, даже если
+		// совпал менее специфичный селектор
+		{":is(.a, #l1)", "l3", Specificity{1, 0, 0}},
+		{":where(#l1, .a)", "l3", Specificity{0, 0, 0}},
+		{"li:where(#list *)", "l3", Specificity{0, 0, 1}},
+		{"li:nth-child(2n of #l1, .a)", "l3", Specificity{1, 1, 1}},
+		{":has(> .b)", "list", Specificity{0, 1, 0}},
+		// В списке берется самый специфичный из совпавших селекторов
+		{"li, #l2, .a", "l2", Specificity{1, 0, 0}},
+		{"li, #l2, .a", "l3", Specificity{0, 1, 0}},
+	}
+	for _, test := range tests {
+		sel, err := CompileSelector(test.selector)
+		if err != nil {
+			t.Errorf("%s: ошибка %v", test.selector, err)
+			continue
+		}
+		got, ok := sel.MatchSpecificity(byID(test.id))
+		if !ok || got != test.want {
+			t.Errorf("%s на #%s: получено %v (%v), ожидалось %v", test.selector, test.id, got, ok, test.want)
+		}
+	}
+}
+
+func TestInvalidSelectors(t *testing.T) {
+	for _, selector := range []string{
+		"",
+		"li >",
+		"> li",
+		"a,,b",
+		"a,",
+		"#",
+		".",
+		"li:unknown",
+		"li:nth-child()",
+		"li:nth-child(2n+)",
+		"li:nth-child(2n++1)",
+		"li:nth-child(n-)",
+		"li:nth-child(abc)",
+		"li:nth-child(2",
+		"li:not(",
+		"li:not(>)",
+		"[x",
+		"[x=",
+		"[x~]",
+		"[x=y z]",
+		"[=y]",
+		`[x="unterminated]`,
+		"li:lang()",
+		"li)",
+	} {
+		if _, err := CompileSelector(selector); err == nil {
+			t.Errorf("%q: ожидалась ошибка", selector)
+		}
+	}
+}
+
+func TestMatchesAndClosest(t *testing.T) {
+	doc := parseFixture(t, selectorFixture)
+	l3, _ := doc.DocumentElement().QuerySelector("#l3")
+	if ok, err := l3.Matches("ul > .a:nth-child(3)"); !ok || err != nil {
+		t.Errorf("Matches: %v, %v", ok, err)
+	}
+	if _, err := l3.Matches("li >"); err == nil {
+		t.Errorf("Matches: ожидалась ошибка для неверного селектора")
+	}
+	for _, test := range []struct{ selector, want string }{
+		{"li", "l3"},
+		{"ul", "list"},
+		{"div#root", "root"},
+		{"p", ""},
+	} {
+		n, err := l3.Closest(test.selector)
+		if err != nil {
+			t.Errorf("Closest(%s): ошибка %v", test.selector, err)
+			continue
+		}
+		got := ""
+		if n != nil {
+			got = n.GetAttribute("id")
+		}
+		if got != test.want {
+			t.Errorf("Closest(%s): получено %q, ожидалось %q", test.selector, got, test.want)
+		}
+	}
+}
+
+func TestQuirksModeClassAndID(t *testing.T) {
+	const body = `<p id=Intro class="Lead NOTE">x</p><p id=b class=lead>y</p>`
+	tests := []struct {
+		name, doctype, selector, want string
+	}{
+		// В режиме совместимости классы и id сравниваются без учета регистра
+		{"quirks, класс", "", ".lead", "Intro b"},
+		{"quirks, id", "", "#intro", "Intro"},
+		{"quirks, составной", "", "p.note.LEAD", "Intro"},
+		{"quirks, :has", "", "body:has(.note) > #INTRO", "Intro"},
+		// Атрибутные селекторы от режима не зависят
+		{"quirks, атрибут", "", "[class~=lead]", "b"},
+		{"no-quirks, класс", "<!DOCTYPE html>", ".lead", "b"},
+		{"no-quirks, id", "<!DOCTYPE html>", "#intro", ""},
+		{"limited-quirks, класс", `<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN">`, ".lead", "b"},
+	}
+	for _, test := range tests {
+		doc := parseFixture(t, test.doctype+body)
+		found, err := doc.QuerySelectorAll(test.selector)
+		if err != nil {
+			t.Errorf("%s: ошибка %v", test.name, err)
+			continue
+		}
+		if got := ids(found); got != test.want {
+			t.Errorf("%s: %s нашел %q, ожидалось %q", test.name, test.selector, got, test.want)
+		}
+	}
+
+	doc := parseFixture(t, body)
+	intro, _ := doc.QuerySelector("p")
+	if ok, _ := intro.Matches(".note"); !ok {
+		t.Errorf("Matches(.note) в режиме совместимости: false")
+	}
+	if ok := MustCompileSelector("#INTRO").Match(intro); !ok {
+		t.Errorf("Selector.Match(#INTRO) в режиме совместимости: false")
+	}
+}
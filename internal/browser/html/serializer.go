@@ -0,0 +1,128 @@
+package html
+
+import (
+	"strings"
+)
+
+// voidElements — элементы без содержимого и закрывающего тега
+var voidElements = map[string]bool{
+	"area": true, "base": true, "basefont": true, "bgsound": true, "br": true,
+	"col": true, "embed": true, "frame": true, "hr": true, "img": true,
+	"input": true, "keygen": true, "link": true, "meta": true, "param": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// rawTextElements — элементы, текст которых выводится без экранирования.
+// noscript входит в список, потому что парсер по умолчанию работает с
+// включенными скриптами и разбирает его содержимое как текст
+var rawTextElements = map[string]bool{
+	"style": true, "script": true, "xmp": true, "iframe": true,
+	"noembed": true, "noframes": true, "plaintext": true, "noscript": true,
+}
+
+// InnerHTML возвращает HTML разметку содержимого узла (у <template> — его
+// фрагмента Content)
+func (n *Node) InnerHTML() string {
+	var sb strings.Builder
+	for c := n.contents().FirstChild; c != nil; c = c.NextSibling {
+		serializeNode(&sb, c)
+	}
+	return sb.String()
+}
+
+// OuterHTML возвращает HTML разметку узла вместе с его содержимым
+func (n *Node) OuterHTML() string {
+	var sb strings.Builder
+	serializeNode(&sb, n)
+	return sb.String()
+}
+
+// Serialize возвращает HTML разметку всего документа
+func (d *Document) Serialize() string {
+	return d.InnerHTML()
+}
+
+// serializeNode выводит узел по алгоритму сериализации фрагментов HTML
+func serializeNode(sb *strings.Builder, n *Node) {
+	switch n.Type {
+	case DocumentNode, DocumentFragmentNode:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			serializeNode(sb, c)
+		}
+
+	case ElementNode:
+		sb.WriteByte('<')
+		sb.WriteString(n.TagName)
+		for _, a := range n.Attributes {
+			sb.WriteByte(' ')
+			sb.WriteString(a.Name)
+			sb.WriteString(`="`)
+			escapeHTML(sb, a.Value, true)
+			sb.WriteByte('"')
+		}
+		sb.WriteByte('>')
+		if n.IsHTML() && voidElements[n.TagName] {
+			return
+		}
+
+		// Парсер отбрасывает первый перевод строки в этих элементах, поэтому
+		// для сохранения текста при повторном разборе его нужно удвоить
+		if n.isHTMLElement("pre", "textarea", "listing") {
+			if c := n.FirstChild; c != nil && c.Type == TextNode && strings.HasPrefix(c.Data, "\n") {
+				sb.WriteByte('\n')
+			}
+		}
+
+		for c := n.contents().FirstChild; c != nil; c = c.NextSibling {
+			serializeNode(sb, c)
+		}
+		sb.WriteString("</")
+		sb.WriteString(n.TagName)
+		sb.WriteByte('>')
+
+	case TextNode:
+		// Внутри SVG и MathML текст всегда разбирается с символьными ссылками
+		if n.Parent != nil && n.Parent.IsHTML() && rawTextElements[n.Parent.TagName] {
+			sb.WriteString(n.Data)
+		} else {
+			escapeHTML(sb, n.Data, false)
+		}
+
+	case CommentNode:
+		sb.WriteString("<!--")
+		sb.WriteString(n.Data)
+		sb.WriteString("-->")
+
+	case DocumentTypeNode:
+		sb.WriteString("<!DOCTYPE ")
+		sb.WriteString(n.Data)
+		sb.WriteByte('>')
+
+	case AttributeNode:
+		escapeHTML(sb, n.Data, false)
+	}
+}
+
+// escapeHTML экранирует строку для вывода в тексте или значении атрибута
+func escapeHTML(sb *strings.Builder, s string, attribute bool) {
+	for _, c := range s {
+		switch c {
+		case '&':
+			sb.WriteString("&amp;")
+		case '\u00A0':
+			sb.WriteString("&nbsp;")
+		case '<':
+			sb.WriteString("&lt;")
+		case '>':
+			sb.WriteString("&gt;")
+		case '"':
+			if attribute {
+				sb.WriteString("&quot;")
+			} else {
+				sb.WriteRune(c)
+			}
+		default:
+			sb.WriteRune(c)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package html
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"unicode/utf8"
+)
+
+// errStreamClosed возвращается при записи в уже закрытый поток
+var errStreamClosed = errors.New("запись в завершенный поток разбора HTML")
+
+// Stream разбирает документ по частям по мере их поступления, например из
+// тела HTTP-ответа. Дерево строится постепенно: после каждого Write в
+// документе есть все узлы, для которых уже хватило данных, а прочитанная
+// часть входа не хранится
+type Stream struct {
+	doc       *Document
+	tokenizer *Tokenizer
+	builder   *treeBuilder
+
+	// partial — незавершенный символ UTF-8 в конце последней части
+	partial []byte
+	closed  bool
+}
+
+// NewStream начинает потоковый разбор нового документа
+func (p *Parser) NewStream() *Stream {
+	doc := NewDocument()
+	tokenizer := newStreamTokenizer()
+	return &Stream{
+		doc:       doc,
+		tokenizer: tokenizer,
+		builder:   newTreeBuilder(doc, tokenizer, p.scripting),
+	}
+}
+
+// ParseReader разбирает документ, читая его из r по частям. Подписчики
+// listeners вызываются для элементов по мере их разбора, как при OnElement
+func (p *Parser) ParseReader(r io.Reader, listeners ...func(n *Node)) (*Document, error) {
+	log.Println("Потоковый парсинг HTML документа...")
+
+	s := p.NewStream()
+	for _, listener := range listeners {
+		s.OnElement(listener)
+	}
+	if _, err := io.Copy(s, r); err != nil {
+		return nil, fmt.Errorf("ошибка чтения документа: %w", err)
+	}
+	if err := s.Close(); err != nil {
+		return nil, err
+	}
+	return s.Document(), nil
+}
+
+// Document возвращает документ, построенный к текущему моменту
+func (s *Stream) Document() *Document {
+	return s.doc
+}
+
+// OnElement подписывает listener на завершение разбора элементов. Он
+// вызывается, когда элемент закрыт и все его содержимое уже находится в
+// дереве, — во время Write, не дожидаясь конца документа. Элементы,
+// оставшиеся открытыми, завершаются при Close. Алгоритм построения дерева
+// может позже переместить элемент (например, при исправлении неправильно
+// вложенных тегов)
+func (s *Stream) OnElement(listener func(n *Node)) {
+	s.builder.onElement = append(s.builder.onElement, listener)
+}
+
+// Write добавляет очередную часть документа в UTF-8 и разбирает все, для
+// чего хватает данных. Символ, разрезанный между частями, дожидается
+// следующей части
+func (s *Stream) Write(chunk []byte) (int, error) {
+	if s.closed {
+		return 0, errStreamClosed
+	}
+
+	data := append(s.partial, chunk...)
+	n := len(data)
+	for i := 1; i <= utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				n = len(data) - i
+			}
+			break
+		}
+	}
+	s.tokenizer.write(string(data[:n]))
+	s.partial = append(s.partial[:0], data[n:]...)
+
+	s.builder.run()
+	return len(chunk), nil
+}
+
+// Close сообщает, что документ получен полностью, и завершает разбор
+func (s *Stream) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	// Незавершенный символ в конце документа заменяется на U+FFFD
+	s.tokenizer.write(string(s.partial))
+	s.partial = nil
+	s.tokenizer.closed = true
+
+	s.builder.run()
+	s.doc.Errors = collectErrors(s.tokenizer, s.builder)
+	return nil
+}
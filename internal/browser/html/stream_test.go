@@ -0,0 +1,87 @@
+package html
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// TestStreamOnElement проверяет, что подписчики узнают о разобранных
+// элементах во время Write, а не после Close
+func TestStreamOnElement(t *testing.T) {
+	// Токенизатор заглядывает вперед на streamLookahead символов, поэтому
+	// части дополнены пробелами: без них конец части ждал бы следующей
+	pad := strings.Repeat(" ", streamLookahead)
+	chunks := []struct {
+		data string
+		// done — элементы, завершенные после записи части
+		done string
+	}{
+		{"<!DOCTYPE html><title>Сп", ""},
+		{"исок</title>" + pad, "title"},
+		// Элемент li закрыт неявно следующим li, а head — началом body
+		{"<ul><li>a<li>b" + pad, "title head li"},
+		{"</li></u", "title head li"},
+		{"l>" + pad, "title head li li ul"},
+		{"<p>конец", "title head li li ul"},
+	}
+
+	stream := NewParser().NewStream()
+	var done []string
+	stream.OnElement(func(n *Node) {
+		done = append(done, n.TagName)
+	})
+	for _, chunk := range chunks {
+		if _, err := stream.Write([]byte(chunk.data)); err != nil {
+			t.Fatalf("Write(%q): %v", chunk.data, err)
+		}
+		if got := strings.Join(done, " "); got != chunk.done {
+			t.Errorf("после %q: завершены %q, ожидалось %q", chunk.data, got, chunk.done)
+		}
+	}
+
+	// Открытые в конце документа элементы завершаются при Close,
+	// от внутреннего к внешнему
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(done, " "), "title head li li ul p body html"; got != want {
+		t.Errorf("после Close: завершены %q, ожидалось %q", got, want)
+	}
+
+	// Подписчик видит элемент вместе со всем содержимым
+	stream = NewParser().NewStream()
+	var text []string
+	stream.OnElement(func(n *Node) {
+		if n.TagName == "li" {
+			text = append(text, n.TextContent())
+		}
+	})
+	stream.Write([]byte("<ul><li>од"))
+	stream.Write([]byte("ин<b>два</b><li>три" + pad))
+	if got := strings.Join(text, ","); got != "одиндва" {
+		t.Errorf("содержимое первого li: %q, ожидалось %q", got, "одиндва")
+	}
+	stream.Close()
+}
+
+func TestParseReaderListeners(t *testing.T) {
+	var first, second []string
+	r := iotest.OneByteReader(strings.NewReader("<p>a<p>b<div>c</div>"))
+	doc, err := NewParser().ParseReader(r,
+		func(n *Node) { first = append(first, n.TagName) },
+		func(n *Node) { second = append(second, n.TagName) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "head p p div body html"
+	if got := strings.Join(first, " "); got != want {
+		t.Errorf("первый подписчик: %q, ожидалось %q", got, want)
+	}
+	if got := strings.Join(second, " "); got != want {
+		t.Errorf("второй подписчик: %q, ожидалось %q", got, want)
+	}
+	if doc.Body() == nil || doc.Body().TextContent() != "abc" {
+		t.Errorf("документ разобран неверно")
+	}
+}
@@ -0,0 +1,1526 @@
+package html
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenType определяет тип токена, выдаваемого токенизатором
+type TokenType int
+
+const (
+	// EOFToken означает конец входного потока
+	EOFToken TokenType = iota
+	// CharacterToken содержит последовательность символов
+	CharacterToken
+	// StartTagToken представляет открывающий тег
+	StartTagToken
+	// EndTagToken представляет закрывающий тег
+	EndTagToken
+	// CommentToken представляет комментарий
+	CommentToken
+	// DoctypeToken представляет объявление DOCTYPE
+	DoctypeToken
+)
+
+// String возвращает имя типа токена
+func (t TokenType) String() string {
+	switch t {
+	case EOFToken:
+		return "EOF"
+	case CharacterToken:
+		return "Character"
+	case StartTagToken:
+		return "StartTag"
+	case EndTagToken:
+		return "EndTag"
+	case CommentToken:
+		return "Comment"
+	case DoctypeToken:
+		return "DOCTYPE"
+	}
+	return "Unknown"
+}
+
+// Attribute представляет атрибут тега. Name — полное имя атрибута вместе с
+// префиксом (например, xlink:href), Namespace — URI его пространства имен
+// (пустая строка у обычных атрибутов)
+type Attribute struct {
+	Name      string
+	Value     string
+	Namespace string
+}
+
+// LocalName возвращает имя атрибута без префикса пространства имен
+func (a Attribute) LocalName() string {
+	if i := strings.IndexByte(a.Name, ':'); a.Namespace != "" && i >= 0 {
+		return a.Name[i+1:]
+	}
+	return a.Name
+}
+
+// Token представляет токен HTML
+type Token struct {
+	Type TokenType
+	// Data содержит имя тега, текст, содержимое комментария или имя DOCTYPE
+	Data        string
+	Attr        []Attribute
+	SelfClosing bool
+
+	// Поля DOCTYPE
+	PublicID    string
+	SystemID    string
+	HasPublicID bool
+	HasSystemID bool
+	ForceQuirks bool
+
+	// Start и End — позиции начала токена и символа сразу после его конца
+	Start Position
+	End   Position
+
+	// nulls — позиции символов U+0000 в тексте символьного токена
+	nulls []Position
+}
+
+// tokenizerState — состояние конечного автомата токенизатора
+type tokenizerState int
+
+const (
+	dataState tokenizerState = iota
+	rcdataState
+	rawtextState
+	scriptDataState
+	plaintextState
+	tagOpenState
+	endTagOpenState
+	tagNameState
+	rcdataLessThanSignState
+	rcdataEndTagOpenState
+	rcdataEndTagNameState
+	rawtextLessThanSignState
+	rawtextEndTagOpenState
+	rawtextEndTagNameState
+	scriptDataLessThanSignState
+	scriptDataEndTagOpenState
+	scriptDataEndTagNameState
+	scriptDataEscapeStartState
+	scriptDataEscapeStartDashState
+	scriptDataEscapedState
+	scriptDataEscapedDashState
+	scriptDataEscapedDashDashState
+	scriptDataEscapedLessThanSignState
+	scriptDataEscapedEndTagOpenState
+	scriptDataEscapedEndTagNameState
+	scriptDataDoubleEscapeStartState
+	scriptDataDoubleEscapedState
+	scriptDataDoubleEscapedDashState
+	scriptDataDoubleEscapedDashDashState
+	scriptDataDoubleEscapedLessThanSignState
+	scriptDataDoubleEscapeEndState
+	beforeAttributeNameState
+	attributeNameState
+	afterAttributeNameState
+	beforeAttributeValueState
+	attributeValueDoubleQuotedState
+	attributeValueSingleQuotedState
+	attributeValueUnquotedState
+	afterAttributeValueQuotedState
+	selfClosingStartTagState
+	bogusCommentState
+	markupDeclarationOpenState
+	commentStartState
+	commentStartDashState
+	commentState
+	commentLessThanSignState
+	commentLessThanSignBangState
+	commentLessThanSignBangDashState
+	commentLessThanSignBangDashDashState
+	commentEndDashState
+	commentEndState
+	commentEndBangState
+	doctypeState
+	beforeDoctypeNameState
+	doctypeNameState
+	afterDoctypeNameState
+	afterDoctypePublicKeywordState
+	beforeDoctypePublicIdentifierState
+	doctypePublicIdentifierDoubleQuotedState
+	doctypePublicIdentifierSingleQuotedState
+	afterDoctypePublicIdentifierState
+	betweenDoctypePublicAndSystemIdentifiersState
+	afterDoctypeSystemKeywordState
+	beforeDoctypeSystemIdentifierState
+	doctypeSystemIdentifierDoubleQuotedState
+	doctypeSystemIdentifierSingleQuotedState
+	afterDoctypeSystemIdentifierState
+	bogusDoctypeState
+	cdataSectionState
+	cdataSectionBracketState
+	cdataSectionEndState
+	ambiguousAmpersandState
+	numericCharacterReferenceState
+)
+
+// eof — специальное значение символа, обозначающее конец входного потока
+const eof rune = -1
+
+// Tokenizer реализует токенизацию HTML по спецификации WHATWG
+type Tokenizer struct {
+	input []rune
+	pos   int
+
+	state       tokenizerState
+	returnState tokenizerState
+
+	// lastStartTag — имя последнего выданного открывающего тега
+	lastStartTag string
+	// cdataAllowed разрешает секции CDATA (только внутри SVG и MathML)
+	cdataAllowed bool
+
+	// tok — токен тега, комментария или DOCTYPE, который строится сейчас
+	tok     Token
+	attrDup bool
+	tempBuf []rune
+	// charRefCode и charRefBase — значение и основание разбираемой числовой ссылки
+	charRefCode rune
+	charRefBase rune
+	text        []rune
+	textNulls   []Position
+	pending     []Token
+	done        bool
+
+	// base — смещение первого символа input от начала документа: при
+	// потоковом разборе уже обработанная часть входа отбрасывается.
+	// closed — весь вход получен; lastCR — предыдущая часть входа
+	// закончилась символом CR
+	base   int
+	closed bool
+	lastCR bool
+
+	// lines — начала строк входа; tokenEnd — смещение конца последнего
+	// выданного токена; markup — смещение символа «<», с которого
+	// начинается текущий тег, комментарий или DOCTYPE
+	lines    *lineIndex
+	tokenEnd int
+	markup   int
+	// atEOF — последним прочитанным «символом» был конец входа;
+	// checked — смещение, до которого вход проверен на недопустимые символы
+	atEOF   bool
+	checked int
+
+	errors []ParseError
+}
+
+// streamLookahead — сколько символов должно быть во входе после текущей
+// позиции, чтобы выполнить шаг токенизатора до получения всего входа.
+// Дальше всех заглядывает разбор именованной ссылки на символ
+const streamLookahead = 2 * longestCharacterReference
+
+// streamCompactSize — после скольких обработанных символов потоковый
+// токенизатор отбрасывает прочитанную часть входа
+const streamCompactSize = 4096
+
+// NewTokenizer создает токенизатор для указанной HTML строки
+func NewTokenizer(input string) *Tokenizer {
+	t := newStreamTokenizer()
+	t.write(input)
+	t.closed = true
+	return t
+}
+
+// newStreamTokenizer создает токенизатор, вход которого поступает частями через write
+func newStreamTokenizer() *Tokenizer {
+	return &Tokenizer{
+		state: dataState,
+		lines: newLineIndex(),
+	}
+}
+
+// write добавляет часть входа, приводя переводы строк CR LF и CR к LF.
+// CR в конце части запоминается, чтобы не удвоить перевод строки, если
+// следующая часть начнется с LF
+func (t *Tokenizer) write(s string) {
+	if t.lastCR && strings.HasPrefix(s, "\n") {
+		s = s[1:]
+	}
+	t.lastCR = strings.HasSuffix(s, "\r")
+	runes := []rune(normalizeNewlines(s))
+	t.lines.add(runes, t.base+len(t.input))
+	t.input = append(t.input, runes...)
+}
+
+// normalizeNewlines приводит переводы строк CR LF и CR к LF
+func normalizeNewlines(s string) string {
+	if !strings.Contains(s, "\r") {
+		return s
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "\n")
+}
+
+// Next возвращает следующий токен. После конца входа всегда возвращается EOFToken
+func (t *Tokenizer) Next() Token {
+	tok, _ := t.next()
+	return tok
+}
+
+// next возвращает следующий токен или false, если для него пока не хватает
+// входных данных (только при потоковом разборе)
+func (t *Tokenizer) next() (Token, bool) {
+	for len(t.pending) == 0 {
+		if t.done {
+			return Token{Type: EOFToken}, true
+		}
+		if !t.closed {
+			if len(t.input)-t.pos < streamLookahead {
+				return Token{}, false
+			}
+			t.compact()
+		}
+		t.step()
+	}
+	tok := t.pending[0]
+	t.pending = t.pending[1:]
+	return tok, true
+}
+
+// compact отбрасывает обработанную часть входа, оставляя последний
+// прочитанный символ для reconsume
+func (t *Tokenizer) compact() {
+	shift := t.pos - 1
+	if shift < streamCompactSize {
+		return
+	}
+	t.input = append(t.input[:0], t.input[shift:]...)
+	t.base += shift
+	t.pos -= shift
+	t.markup -= shift
+	t.tokenEnd -= shift
+	t.checked -= shift
+	// Позиции нужны только для еще не выданного текста и последующих токенов
+	t.lines.forget(t.base + t.tokenEnd)
+}
+
+// position возвращает позицию символа со смещением offset во входе
+func (t *Tokenizer) position(offset int) Position {
+	return t.lines.position(t.base + offset)
+}
+
+// Errors возвращает ошибки разбора, обнаруженные токенизатором
+func (t *Tokenizer) Errors() []ParseError {
+	return t.errors
+}
+
+// setState переключает состояние токенизатора (используется построителем дерева)
+func (t *Tokenizer) setState(state tokenizerState) {
+	t.state = state
+}
+
+// parseError регистрирует ошибку разбора с кодом из спецификации. Ошибка
+// относится к последнему прочитанному символу, а в конце входа — к позиции
+// сразу после него
+func (t *Tokenizer) parseError(code string) {
+	offset := t.pos
+	if !t.atEOF {
+		offset--
+	}
+	t.parseErrorAt(code, offset)
+}
+
+// parseErrorAt регистрирует ошибку разбора в символе со смещением offset
+func (t *Tokenizer) parseErrorAt(code string, offset int) {
+	t.errors = append(t.errors, ParseError{Code: code, Pos: t.position(offset)})
+}
+
+// consume возвращает следующий символ входного потока
+func (t *Tokenizer) consume() rune {
+	t.atEOF = t.pos >= len(t.input)
+	if t.atEOF {
+		return eof
+	}
+	c := t.input[t.pos]
+	t.pos++
+
+	// Ошибки входного потока сообщаются один раз, даже если символ
+	// обрабатывается повторно после reconsume
+	if t.pos > t.checked {
+		t.checked = t.pos
+		switch {
+		case c != 0 && isControl(c) && !isHTMLSpace(c):
+			t.parseError("control-character-in-input-stream")
+		case isNoncharacter(c):
+			t.parseError("noncharacter-in-input-stream")
+		}
+	}
+	return c
+}
+
+// reconsume возвращает символ c обратно во входной поток
+func (t *Tokenizer) reconsume(c rune, state tokenizerState) {
+	if c != eof {
+		t.pos--
+	}
+	t.state = state
+}
+
+// lookahead проверяет, начинается ли непрочитанная часть входа со строки s
+func (t *Tokenizer) lookahead(s string, caseInsensitive bool) bool {
+	i := t.pos
+	for _, want := range s {
+		if i >= len(t.input) {
+			return false
+		}
+		got := t.input[i]
+		if caseInsensitive {
+			got = toLowerASCII(got)
+			want = toLowerASCII(want)
+		}
+		if got != want {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// emitChar добавляет символ к текущему символьному токену
+func (t *Tokenizer) emitChar(c rune) {
+	if c == 0 {
+		// U+0000 выдается только что прочитанным символом; его позиция
+		// нужна построителю дерева, который сообщает о нем как об ошибке
+		t.textNulls = append(t.textNulls, t.position(t.pos-1))
+	}
+	t.text = append(t.text, c)
+}
+
+// emitString добавляет строку к текущему символьному токену
+func (t *Tokenizer) emitString(s string) {
+	t.text = append(t.text, []rune(s)...)
+}
+
+// flushText выдает накопленные символы одним символьным токеном
+func (t *Tokenizer) flushText() {
+	if len(t.text) == 0 {
+		return
+	}
+	// Текст заканчивается там, где начинается следующий тег, либо в конце входа
+	end := t.markup
+	if t.done {
+		end = t.pos
+	}
+	t.pending = append(t.pending, Token{
+		Type:  CharacterToken,
+		Data:  string(t.text),
+		Start: t.position(t.tokenEnd),
+		End:   t.position(end),
+		nulls: t.textNulls,
+	})
+	t.tokenEnd = end
+	t.text = t.text[:0]
+	t.textNulls = nil
+}
+
+// emitToken выдает текущий тег, комментарий или DOCTYPE
+func (t *Tokenizer) emitToken() {
+	t.flushText()
+	t.dropDuplicateAttr()
+	tok := t.tok
+	tok.Start = t.position(t.markup)
+	tok.End = t.position(t.pos)
+	t.tokenEnd = t.pos
+	switch tok.Type {
+	case StartTagToken:
+		t.lastStartTag = tok.Data
+	case EndTagToken:
+		if len(tok.Attr) > 0 {
+			t.parseError("end-tag-with-attributes")
+		}
+		if tok.SelfClosing {
+			t.parseError("end-tag-with-trailing-solidus")
+		}
+	}
+	t.pending = append(t.pending, tok)
+	t.tok = Token{}
+}
+
+// emitEOF завершает токенизацию
+func (t *Tokenizer) emitEOF() {
+	t.done = true
+	t.flushText()
+	end := t.position(len(t.input))
+	t.pending = append(t.pending, Token{Type: EOFToken, Start: end, End: end})
+}
+
+// newTag начинает новый токен тега
+func (t *Tokenizer) newTag(typ TokenType) {
+	t.tok = Token{Type: typ}
+	t.attrDup = false
+}
+
+// newComment начинает новый токен комментария
+func (t *Tokenizer) newComment(data string) {
+	t.tok = Token{Type: CommentToken, Data: data}
+}
+
+// newDoctype начинает новый токен DOCTYPE
+func (t *Tokenizer) newDoctype() {
+	t.tok = Token{Type: DoctypeToken}
+}
+
+// appendData добавляет символ к имени тега, комментарию или имени DOCTYPE
+func (t *Tokenizer) appendData(c rune) {
+	t.tok.Data += string(c)
+}
+
+// newAttr начинает новый атрибут текущего тега
+func (t *Tokenizer) newAttr(name string) {
+	t.dropDuplicateAttr()
+	t.tok.Attr = append(t.tok.Attr, Attribute{Name: name})
+}
+
+// appendAttrName добавляет символ к имени текущего атрибута
+func (t *Tokenizer) appendAttrName(c rune) {
+	t.tok.Attr[len(t.tok.Attr)-1].Name += string(c)
+}
+
+// appendAttrValue добавляет символ к значению текущего атрибута
+func (t *Tokenizer) appendAttrValue(c rune) {
+	t.tok.Attr[len(t.tok.Attr)-1].Value += string(c)
+}
+
+// finishAttrName проверяет, не повторяет ли имя текущего атрибута уже существующее
+func (t *Tokenizer) finishAttrName() {
+	n := len(t.tok.Attr)
+	if n == 0 {
+		return
+	}
+	name := t.tok.Attr[n-1].Name
+	for _, a := range t.tok.Attr[:n-1] {
+		if a.Name == name {
+			t.parseError("duplicate-attribute")
+			t.attrDup = true
+			return
+		}
+	}
+}
+
+// dropDuplicateAttr удаляет повторный атрибут после того, как прочитано его значение
+func (t *Tokenizer) dropDuplicateAttr() {
+	if t.attrDup {
+		t.tok.Attr = t.tok.Attr[:len(t.tok.Attr)-1]
+		t.attrDup = false
+	}
+}
+
+// isAppropriateEndTag проверяет, соответствует ли текущий закрывающий тег последнему открывающему
+func (t *Tokenizer) isAppropriateEndTag() bool {
+	return t.lastStartTag != "" && t.tok.Data == t.lastStartTag
+}
+
+// step выполняет один шаг конечного автомата
+func (t *Tokenizer) step() {
+	c := t.consume()
+
+	// В тексте символ «<» может начинать тег, комментарий или DOCTYPE
+	if c == '<' {
+		switch t.state {
+		case dataState, rcdataState, rawtextState, scriptDataState,
+			scriptDataEscapedState, scriptDataEscapedDashState, scriptDataEscapedDashDashState:
+			t.markup = t.pos - 1
+		}
+	}
+
+	switch t.state {
+	case dataState:
+		switch c {
+		case '&':
+			t.characterReference(dataState)
+		case '<':
+			t.state = tagOpenState
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.emitChar(c)
+		case eof:
+			t.emitEOF()
+		default:
+			t.emitChar(c)
+		}
+
+	case rcdataState:
+		switch c {
+		case '&':
+			t.characterReference(rcdataState)
+		case '<':
+			t.state = rcdataLessThanSignState
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.emitChar(utf8.RuneError)
+		case eof:
+			t.emitEOF()
+		default:
+			t.emitChar(c)
+		}
+
+	case rawtextState:
+		switch c {
+		case '<':
+			t.state = rawtextLessThanSignState
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.emitChar(utf8.RuneError)
+		case eof:
+			t.emitEOF()
+		default:
+			t.emitChar(c)
+		}
+
+	case scriptDataState:
+		switch c {
+		case '<':
+			t.state = scriptDataLessThanSignState
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.emitChar(utf8.RuneError)
+		case eof:
+			t.emitEOF()
+		default:
+			t.emitChar(c)
+		}
+
+	case plaintextState:
+		switch c {
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.emitChar(utf8.RuneError)
+		case eof:
+			t.emitEOF()
+		default:
+			t.emitChar(c)
+		}
+
+	case tagOpenState:
+		switch {
+		case c == '!':
+			t.state = markupDeclarationOpenState
+		case c == '/':
+			t.state = endTagOpenState
+		case isASCIIAlpha(c):
+			t.newTag(StartTagToken)
+			t.reconsume(c, tagNameState)
+		case c == '?':
+			t.parseError("unexpected-question-mark-instead-of-tag-name")
+			t.newComment("")
+			t.reconsume(c, bogusCommentState)
+		case c == eof:
+			t.parseError("eof-before-tag-name")
+			t.emitChar('<')
+			t.emitEOF()
+		default:
+			t.parseError("invalid-first-character-of-tag-name")
+			t.emitChar('<')
+			t.reconsume(c, dataState)
+		}
+
+	case endTagOpenState:
+		switch {
+		case isASCIIAlpha(c):
+			t.newTag(EndTagToken)
+			t.reconsume(c, tagNameState)
+		case c == '>':
+			t.parseError("missing-end-tag-name")
+			t.state = dataState
+		case c == eof:
+			t.parseError("eof-before-tag-name")
+			t.emitString("</")
+			t.emitEOF()
+		default:
+			t.parseError("invalid-first-character-of-tag-name")
+			t.newComment("")
+			t.reconsume(c, bogusCommentState)
+		}
+
+	case tagNameState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			t.state = beforeAttributeNameState
+		case '/':
+			t.state = selfClosingStartTagState
+		case '>':
+			t.state = dataState
+			t.emitToken()
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.appendData(utf8.RuneError)
+		case eof:
+			t.parseError("eof-in-tag")
+			t.emitEOF()
+		default:
+			t.appendData(toLowerASCII(c))
+		}
+
+	case rcdataLessThanSignState:
+		t.lessThanSign(c, rcdataState, rcdataEndTagOpenState)
+	case rcdataEndTagOpenState:
+		t.endTagOpen(c, rcdataState, rcdataEndTagNameState)
+	case rcdataEndTagNameState:
+		t.endTagName(c, rcdataState)
+
+	case rawtextLessThanSignState:
+		t.lessThanSign(c, rawtextState, rawtextEndTagOpenState)
+	case rawtextEndTagOpenState:
+		t.endTagOpen(c, rawtextState, rawtextEndTagNameState)
+	case rawtextEndTagNameState:
+		t.endTagName(c, rawtextState)
+
+	case scriptDataLessThanSignState:
+		if c == '!' {
+			t.state = scriptDataEscapeStartState
+			t.emitString("<!")
+			break
+		}
+		t.lessThanSign(c, scriptDataState, scriptDataEndTagOpenState)
+	case scriptDataEndTagOpenState:
+		t.endTagOpen(c, scriptDataState, scriptDataEndTagNameState)
+	case scriptDataEndTagNameState:
+		t.endTagName(c, scriptDataState)
+
+	case scriptDataEscapeStartState:
+		if c == '-' {
+			t.state = scriptDataEscapeStartDashState
+			t.emitChar('-')
+		} else {
+			t.reconsume(c, scriptDataState)
+		}
+
+	case scriptDataEscapeStartDashState:
+		if c == '-' {
+			t.state = scriptDataEscapedDashDashState
+			t.emitChar('-')
+		} else {
+			t.reconsume(c, scriptDataState)
+		}
+
+	case scriptDataEscapedState:
+		switch c {
+		case '-':
+			t.state = scriptDataEscapedDashState
+			t.emitChar('-')
+		case '<':
+			t.state = scriptDataEscapedLessThanSignState
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.emitChar(utf8.RuneError)
+		case eof:
+			t.parseError("eof-in-script-html-comment-like-text")
+			t.emitEOF()
+		default:
+			t.emitChar(c)
+		}
+
+	case scriptDataEscapedDashState:
+		switch c {
+		case '-':
+			t.state = scriptDataEscapedDashDashState
+			t.emitChar('-')
+		case '<':
+			t.state = scriptDataEscapedLessThanSignState
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.state = scriptDataEscapedState
+			t.emitChar(utf8.RuneError)
+		case eof:
+			t.parseError("eof-in-script-html-comment-like-text")
+			t.emitEOF()
+		default:
+			t.state = scriptDataEscapedState
+			t.emitChar(c)
+		}
+
+	case scriptDataEscapedDashDashState:
+		switch c {
+		case '-':
+			t.emitChar('-')
+		case '<':
+			t.state = scriptDataEscapedLessThanSignState
+		case '>':
+			t.state = scriptDataState
+			t.emitChar('>')
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.state = scriptDataEscapedState
+			t.emitChar(utf8.RuneError)
+		case eof:
+			t.parseError("eof-in-script-html-comment-like-text")
+			t.emitEOF()
+		default:
+			t.state = scriptDataEscapedState
+			t.emitChar(c)
+		}
+
+	case scriptDataEscapedLessThanSignState:
+		switch {
+		case c == '/':
+			t.tempBuf = t.tempBuf[:0]
+			t.state = scriptDataEscapedEndTagOpenState
+		case isASCIIAlpha(c):
+			t.tempBuf = t.tempBuf[:0]
+			t.emitChar('<')
+			t.reconsume(c, scriptDataDoubleEscapeStartState)
+		default:
+			t.emitChar('<')
+			t.reconsume(c, scriptDataEscapedState)
+		}
+
+	case scriptDataEscapedEndTagOpenState:
+		t.endTagOpen(c, scriptDataEscapedState, scriptDataEscapedEndTagNameState)
+	case scriptDataEscapedEndTagNameState:
+		t.endTagName(c, scriptDataEscapedState)
+
+	case scriptDataDoubleEscapeStartState:
+		t.doubleEscapeBoundary(c, scriptDataDoubleEscapedState, scriptDataEscapedState)
+
+	case scriptDataDoubleEscapedState:
+		switch c {
+		case '-':
+			t.state = scriptDataDoubleEscapedDashState
+			t.emitChar('-')
+		case '<':
+			t.state = scriptDataDoubleEscapedLessThanSignState
+			t.emitChar('<')
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.emitChar(utf8.RuneError)
+		case eof:
+			t.parseError("eof-in-script-html-comment-like-text")
+			t.emitEOF()
+		default:
+			t.emitChar(c)
+		}
+
+	case scriptDataDoubleEscapedDashState:
+		switch c {
+		case '-':
+			t.state = scriptDataDoubleEscapedDashDashState
+			t.emitChar('-')
+		case '<':
+			t.state = scriptDataDoubleEscapedLessThanSignState
+			t.emitChar('<')
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.state = scriptDataDoubleEscapedState
+			t.emitChar(utf8.RuneError)
+		case eof:
+			t.parseError("eof-in-script-html-comment-like-text")
+			t.emitEOF()
+		default:
+			t.state = scriptDataDoubleEscapedState
+			t.emitChar(c)
+		}
+
+	case scriptDataDoubleEscapedDashDashState:
+		switch c {
+		case '-':
+			t.emitChar('-')
+		case '<':
+			t.state = scriptDataDoubleEscapedLessThanSignState
+			t.emitChar('<')
+		case '>':
+			t.state = scriptDataState
+			t.emitChar('>')
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.state = scriptDataDoubleEscapedState
+			t.emitChar(utf8.RuneError)
+		case eof:
+			t.parseError("eof-in-script-html-comment-like-text")
+			t.emitEOF()
+		default:
+			t.state = scriptDataDoubleEscapedState
+			t.emitChar(c)
+		}
+
+	case scriptDataDoubleEscapedLessThanSignState:
+		if c == '/' {
+			t.tempBuf = t.tempBuf[:0]
+			t.state = scriptDataDoubleEscapeEndState
+			t.emitChar('/')
+		} else {
+			t.reconsume(c, scriptDataDoubleEscapedState)
+		}
+
+	case scriptDataDoubleEscapeEndState:
+		t.doubleEscapeBoundary(c, scriptDataEscapedState, scriptDataDoubleEscapedState)
+
+	case beforeAttributeNameState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			// Пропускаем пробельные символы
+		case '/', '>', eof:
+			t.reconsume(c, afterAttributeNameState)
+		case '=':
+			t.parseError("unexpected-equals-sign-before-attribute-name")
+			t.newAttr("=")
+			t.state = attributeNameState
+		default:
+			t.newAttr("")
+			t.reconsume(c, attributeNameState)
+		}
+
+	case attributeNameState:
+		switch c {
+		case '\t', '\n', '\f', ' ', '/', '>', eof:
+			t.finishAttrName()
+			t.reconsume(c, afterAttributeNameState)
+		case '=':
+			t.finishAttrName()
+			t.state = beforeAttributeValueState
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.appendAttrName(utf8.RuneError)
+		case '"', '\'', '<':
+			t.parseError("unexpected-character-in-attribute-name")
+			t.appendAttrName(c)
+		default:
+			t.appendAttrName(toLowerASCII(c))
+		}
+
+	case afterAttributeNameState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			// Пропускаем пробельные символы
+		case '/':
+			t.state = selfClosingStartTagState
+		case '=':
+			t.state = beforeAttributeValueState
+		case '>':
+			t.state = dataState
+			t.emitToken()
+		case eof:
+			t.parseError("eof-in-tag")
+			t.emitEOF()
+		default:
+			t.newAttr("")
+			t.reconsume(c, attributeNameState)
+		}
+
+	case beforeAttributeValueState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			// Пропускаем пробельные символы
+		case '"':
+			t.state = attributeValueDoubleQuotedState
+		case '\'':
+			t.state = attributeValueSingleQuotedState
+		case '>':
+			t.parseError("missing-attribute-value")
+			t.state = dataState
+			t.emitToken()
+		default:
+			t.reconsume(c, attributeValueUnquotedState)
+		}
+
+	case attributeValueDoubleQuotedState, attributeValueSingleQuotedState:
+		quote := '"'
+		if t.state == attributeValueSingleQuotedState {
+			quote = '\''
+		}
+		switch c {
+		case quote:
+			t.state = afterAttributeValueQuotedState
+		case '&':
+			t.characterReference(t.state)
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.appendAttrValue(utf8.RuneError)
+		case eof:
+			t.parseError("eof-in-tag")
+			t.emitEOF()
+		default:
+			t.appendAttrValue(c)
+		}
+
+	case attributeValueUnquotedState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			t.state = beforeAttributeNameState
+		case '&':
+			t.characterReference(attributeValueUnquotedState)
+		case '>':
+			t.state = dataState
+			t.emitToken()
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.appendAttrValue(utf8.RuneError)
+		case '"', '\'', '<', '=', '`':
+			t.parseError("unexpected-character-in-unquoted-attribute-value")
+			t.appendAttrValue(c)
+		case eof:
+			t.parseError("eof-in-tag")
+			t.emitEOF()
+		default:
+			t.appendAttrValue(c)
+		}
+
+	case afterAttributeValueQuotedState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			t.state = beforeAttributeNameState
+		case '/':
+			t.state = selfClosingStartTagState
+		case '>':
+			t.state = dataState
+			t.emitToken()
+		case eof:
+			t.parseError("eof-in-tag")
+			t.emitEOF()
+		default:
+			t.parseError("missing-whitespace-between-attributes")
+			t.reconsume(c, beforeAttributeNameState)
+		}
+
+	case selfClosingStartTagState:
+		switch c {
+		case '>':
+			t.tok.SelfClosing = true
+			t.state = dataState
+			t.emitToken()
+		case eof:
+			t.parseError("eof-in-tag")
+			t.emitEOF()
+		default:
+			t.parseError("unexpected-solidus-in-tag")
+			t.reconsume(c, beforeAttributeNameState)
+		}
+
+	case bogusCommentState:
+		switch c {
+		case '>':
+			t.state = dataState
+			t.emitToken()
+		case eof:
+			t.emitToken()
+			t.emitEOF()
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.appendData(utf8.RuneError)
+		default:
+			t.appendData(c)
+		}
+
+	case markupDeclarationOpenState:
+		t.reconsume(c, markupDeclarationOpenState)
+		switch {
+		case t.lookahead("--", false):
+			t.pos += 2
+			t.newComment("")
+			t.state = commentStartState
+		case t.lookahead("DOCTYPE", true):
+			t.pos += 7
+			t.state = doctypeState
+		case t.lookahead("[CDATA[", false):
+			t.pos += 7
+			if t.cdataAllowed {
+				t.state = cdataSectionState
+			} else {
+				t.parseError("cdata-in-html-content")
+				t.newComment("[CDATA[")
+				t.state = bogusCommentState
+			}
+		default:
+			t.parseErrorAt("incorrectly-opened-comment", t.pos)
+			t.newComment("")
+			t.state = bogusCommentState
+		}
+
+	case commentStartState:
+		switch c {
+		case '-':
+			t.state = commentStartDashState
+		case '>':
+			t.parseError("abrupt-closing-of-empty-comment")
+			t.state = dataState
+			t.emitToken()
+		default:
+			t.reconsume(c, commentState)
+		}
+
+	case commentStartDashState:
+		switch c {
+		case '-':
+			t.state = commentEndState
+		case '>':
+			t.parseError("abrupt-closing-of-empty-comment")
+			t.state = dataState
+			t.emitToken()
+		case eof:
+			t.parseError("eof-in-comment")
+			t.emitToken()
+			t.emitEOF()
+		default:
+			t.appendData('-')
+			t.reconsume(c, commentState)
+		}
+
+	case commentState:
+		switch c {
+		case '<':
+			t.appendData(c)
+			t.state = commentLessThanSignState
+		case '-':
+			t.state = commentEndDashState
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.appendData(utf8.RuneError)
+		case eof:
+			t.parseError("eof-in-comment")
+			t.emitToken()
+			t.emitEOF()
+		default:
+			t.appendData(c)
+		}
+
+	case commentLessThanSignState:
+		switch c {
+		case '!':
+			t.appendData(c)
+			t.state = commentLessThanSignBangState
+		case '<':
+			t.appendData(c)
+		default:
+			t.reconsume(c, commentState)
+		}
+
+	case commentLessThanSignBangState:
+		if c == '-' {
+			t.state = commentLessThanSignBangDashState
+		} else {
+			t.reconsume(c, commentState)
+		}
+
+	case commentLessThanSignBangDashState:
+		if c == '-' {
+			t.state = commentLessThanSignBangDashDashState
+		} else {
+			t.reconsume(c, commentEndDashState)
+		}
+
+	case commentLessThanSignBangDashDashState:
+		if c != '>' && c != eof {
+			t.parseError("nested-comment")
+		}
+		t.reconsume(c, commentEndState)
+
+	case commentEndDashState:
+		switch c {
+		case '-':
+			t.state = commentEndState
+		case eof:
+			t.parseError("eof-in-comment")
+			t.emitToken()
+			t.emitEOF()
+		default:
+			t.appendData('-')
+			t.reconsume(c, commentState)
+		}
+
+	case commentEndState:
+		switch c {
+		case '>':
+			t.state = dataState
+			t.emitToken()
+		case '!':
+			t.state = commentEndBangState
+		case '-':
+			t.appendData('-')
+		case eof:
+			t.parseError("eof-in-comment")
+			t.emitToken()
+			t.emitEOF()
+		default:
+			t.tok.Data += "--"
+			t.reconsume(c, commentState)
+		}
+
+	case commentEndBangState:
+		switch c {
+		case '-':
+			t.tok.Data += "--!"
+			t.state = commentEndDashState
+		case '>':
+			t.parseError("incorrectly-closed-comment")
+			t.state = dataState
+			t.emitToken()
+		case eof:
+			t.parseError("eof-in-comment")
+			t.emitToken()
+			t.emitEOF()
+		default:
+			t.tok.Data += "--!"
+			t.reconsume(c, commentState)
+		}
+
+	case doctypeState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			t.state = beforeDoctypeNameState
+		case '>':
+			t.reconsume(c, beforeDoctypeNameState)
+		case eof:
+			t.parseError("eof-in-doctype")
+			t.newDoctype()
+			t.tok.ForceQuirks = true
+			t.emitToken()
+			t.emitEOF()
+		default:
+			t.parseError("missing-whitespace-before-doctype-name")
+			t.reconsume(c, beforeDoctypeNameState)
+		}
+
+	case beforeDoctypeNameState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			// Пропускаем пробельные символы
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.newDoctype()
+			t.appendData(utf8.RuneError)
+			t.state = doctypeNameState
+		case '>':
+			t.parseError("missing-doctype-name")
+			t.newDoctype()
+			t.tok.ForceQuirks = true
+			t.state = dataState
+			t.emitToken()
+		case eof:
+			t.parseError("eof-in-doctype")
+			t.newDoctype()
+			t.tok.ForceQuirks = true
+			t.emitToken()
+			t.emitEOF()
+		default:
+			t.newDoctype()
+			t.appendData(toLowerASCII(c))
+			t.state = doctypeNameState
+		}
+
+	case doctypeNameState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			t.state = afterDoctypeNameState
+		case '>':
+			t.state = dataState
+			t.emitToken()
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.appendData(utf8.RuneError)
+		case eof:
+			t.eofInDoctype()
+		default:
+			t.appendData(toLowerASCII(c))
+		}
+
+	case afterDoctypeNameState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			// Пропускаем пробельные символы
+		case '>':
+			t.state = dataState
+			t.emitToken()
+		case eof:
+			t.eofInDoctype()
+		default:
+			t.reconsume(c, afterDoctypeNameState)
+			switch {
+			case t.lookahead("PUBLIC", true):
+				t.pos += 6
+				t.state = afterDoctypePublicKeywordState
+			case t.lookahead("SYSTEM", true):
+				t.pos += 6
+				t.state = afterDoctypeSystemKeywordState
+			default:
+				t.parseErrorAt("invalid-character-sequence-after-doctype-name", t.pos)
+				t.tok.ForceQuirks = true
+				t.state = bogusDoctypeState
+			}
+		}
+
+	case afterDoctypePublicKeywordState, beforeDoctypePublicIdentifierState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			t.state = beforeDoctypePublicIdentifierState
+		case '"', '\'':
+			if t.state == afterDoctypePublicKeywordState {
+				t.parseError("missing-whitespace-after-doctype-public-keyword")
+			}
+			t.tok.HasPublicID = true
+			t.tok.PublicID = ""
+			if c == '"' {
+				t.state = doctypePublicIdentifierDoubleQuotedState
+			} else {
+				t.state = doctypePublicIdentifierSingleQuotedState
+			}
+		case '>':
+			t.parseError("missing-doctype-public-identifier")
+			t.tok.ForceQuirks = true
+			t.state = dataState
+			t.emitToken()
+		case eof:
+			t.eofInDoctype()
+		default:
+			t.parseError("missing-quote-before-doctype-public-identifier")
+			t.tok.ForceQuirks = true
+			t.reconsume(c, bogusDoctypeState)
+		}
+
+	case doctypePublicIdentifierDoubleQuotedState, doctypePublicIdentifierSingleQuotedState:
+		quote := '"'
+		if t.state == doctypePublicIdentifierSingleQuotedState {
+			quote = '\''
+		}
+		switch c {
+		case quote:
+			t.state = afterDoctypePublicIdentifierState
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.tok.PublicID += string(utf8.RuneError)
+		case '>':
+			t.parseError("abrupt-doctype-public-identifier")
+			t.tok.ForceQuirks = true
+			t.state = dataState
+			t.emitToken()
+		case eof:
+			t.eofInDoctype()
+		default:
+			t.tok.PublicID += string(c)
+		}
+
+	case afterDoctypePublicIdentifierState, betweenDoctypePublicAndSystemIdentifiersState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			t.state = betweenDoctypePublicAndSystemIdentifiersState
+		case '>':
+			t.state = dataState
+			t.emitToken()
+		case '"', '\'':
+			if t.state == afterDoctypePublicIdentifierState {
+				t.parseError("missing-whitespace-between-doctype-public-and-system-identifiers")
+			}
+			t.tok.HasSystemID = true
+			t.tok.SystemID = ""
+			if c == '"' {
+				t.state = doctypeSystemIdentifierDoubleQuotedState
+			} else {
+				t.state = doctypeSystemIdentifierSingleQuotedState
+			}
+		case eof:
+			t.eofInDoctype()
+		default:
+			t.parseError("missing-quote-before-doctype-system-identifier")
+			t.tok.ForceQuirks = true
+			t.reconsume(c, bogusDoctypeState)
+		}
+
+	case afterDoctypeSystemKeywordState, beforeDoctypeSystemIdentifierState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			t.state = beforeDoctypeSystemIdentifierState
+		case '"', '\'':
+			if t.state == afterDoctypeSystemKeywordState {
+				t.parseError("missing-whitespace-after-doctype-system-keyword")
+			}
+			t.tok.HasSystemID = true
+			t.tok.SystemID = ""
+			if c == '"' {
+				t.state = doctypeSystemIdentifierDoubleQuotedState
+			} else {
+				t.state = doctypeSystemIdentifierSingleQuotedState
+			}
+		case '>':
+			t.parseError("missing-doctype-system-identifier")
+			t.tok.ForceQuirks = true
+			t.state = dataState
+			t.emitToken()
+		case eof:
+			t.eofInDoctype()
+		default:
+			t.parseError("missing-quote-before-doctype-system-identifier")
+			t.tok.ForceQuirks = true
+			t.reconsume(c, bogusDoctypeState)
+		}
+
+	case doctypeSystemIdentifierDoubleQuotedState, doctypeSystemIdentifierSingleQuotedState:
+		quote := '"'
+		if t.state == doctypeSystemIdentifierSingleQuotedState {
+			quote = '\''
+		}
+		switch c {
+		case quote:
+			t.state = afterDoctypeSystemIdentifierState
+		case 0:
+			t.parseError("unexpected-null-character")
+			t.tok.SystemID += string(utf8.RuneError)
+		case '>':
+			t.parseError("abrupt-doctype-system-identifier")
+			t.tok.ForceQuirks = true
+			t.state = dataState
+			t.emitToken()
+		case eof:
+			t.eofInDoctype()
+		default:
+			t.tok.SystemID += string(c)
+		}
+
+	case afterDoctypeSystemIdentifierState:
+		switch c {
+		case '\t', '\n', '\f', ' ':
+			// Пропускаем пробельные символы
+		case '>':
+			t.state = dataState
+			t.emitToken()
+		case eof:
+			t.eofInDoctype()
+		default:
+			t.parseError("unexpected-character-after-doctype-system-identifier")
+			t.reconsume(c, bogusDoctypeState)
+		}
+
+	case bogusDoctypeState:
+		switch c {
+		case '>':
+			t.state = dataState
+			t.emitToken()
+		case 0:
+			t.parseError("unexpected-null-character")
+		case eof:
+			t.emitToken()
+			t.emitEOF()
+		}
+
+	case cdataSectionState:
+		switch c {
+		case ']':
+			t.state = cdataSectionBracketState
+		case eof:
+			t.parseError("eof-in-cdata")
+			t.emitEOF()
+		default:
+			t.emitChar(c)
+		}
+
+	case cdataSectionBracketState:
+		if c == ']' {
+			t.state = cdataSectionEndState
+		} else {
+			t.emitChar(']')
+			t.reconsume(c, cdataSectionState)
+		}
+
+	case cdataSectionEndState:
+		switch c {
+		case ']':
+			t.emitChar(']')
+		case '>':
+			t.state = dataState
+		default:
+			t.emitString("]]")
+			t.reconsume(c, cdataSectionState)
+		}
+
+	case ambiguousAmpersandState:
+		t.ambiguousAmpersand(c)
+
+	case numericCharacterReferenceState:
+		t.numericCharacterReferenceDigit(c)
+	}
+}
+
+// eofInDoctype обрабатывает конец входа внутри DOCTYPE
+func (t *Tokenizer) eofInDoctype() {
+	t.parseError("eof-in-doctype")
+	t.tok.ForceQuirks = true
+	t.emitToken()
+	t.emitEOF()
+}
+
+// lessThanSign обрабатывает символ после '<' в RCDATA, RAWTEXT и script data
+func (t *Tokenizer) lessThanSign(c rune, textState, endTagOpen tokenizerState) {
+	if c == '/' {
+		t.tempBuf = t.tempBuf[:0]
+		t.state = endTagOpen
+		return
+	}
+	t.emitChar('<')
+	t.reconsume(c, textState)
+}
+
+// endTagOpen обрабатывает символ после "</" в RCDATA, RAWTEXT и script data
+func (t *Tokenizer) endTagOpen(c rune, textState, endTagName tokenizerState) {
+	if isASCIIAlpha(c) {
+		t.newTag(EndTagToken)
+		t.reconsume(c, endTagName)
+		return
+	}
+	t.emitString("</")
+	t.reconsume(c, textState)
+}
+
+// endTagName обрабатывает имя закрывающего тега в RCDATA, RAWTEXT и script data.
+// Тег считается закрывающим, только если совпадает с последним открывающим
+func (t *Tokenizer) endTagName(c rune, textState tokenizerState) {
+	switch {
+	case isHTMLSpace(c) && t.isAppropriateEndTag():
+		t.state = beforeAttributeNameState
+		return
+	case c == '/' && t.isAppropriateEndTag():
+		t.state = selfClosingStartTagState
+		return
+	case c == '>' && t.isAppropriateEndTag():
+		t.state = dataState
+		t.emitToken()
+		return
+	case isASCIIAlpha(c):
+		t.appendData(toLowerASCII(c))
+		t.tempBuf = append(t.tempBuf, c)
+		return
+	}
+	t.emitString("</")
+	t.emitString(string(t.tempBuf))
+	t.reconsume(c, textState)
+}
+
+// doubleEscapeBoundary обрабатывает границу двойного экранирования в script data:
+// слово "script" переключает между экранированным и дважды экранированным состояниями
+func (t *Tokenizer) doubleEscapeBoundary(c rune, scriptState, otherState tokenizerState) {
+	switch {
+	case isHTMLSpace(c) || c == '/' || c == '>':
+		if string(t.tempBuf) == "script" {
+			t.state = scriptState
+		} else {
+			t.state = otherState
+		}
+		t.emitChar(c)
+	case isASCIIAlpha(c):
+		t.tempBuf = append(t.tempBuf, toLowerASCII(c))
+		t.emitChar(c)
+	default:
+		t.reconsume(c, otherState)
+	}
+}
+
+// isHTMLSpace проверяет, является ли символ пробельным в смысле HTML
+func isHTMLSpace(c rune) bool {
+	return c == '\t' || c == '\n' || c == '\f' || c == ' ' || c == '\r'
+}
+
+// isASCIIAlpha проверяет, является ли символ латинской буквой
+func isASCIIAlpha(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// toLowerASCII переводит латинскую букву в нижний регистр
+func toLowerASCII(c rune) rune {
+	if c >= 'A' && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
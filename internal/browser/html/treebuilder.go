@@ -0,0 +1,2400 @@
+package html
+
+import (
+	"strings"
+)
+
+// insertionMode — режим вставки алгоритма построения дерева
+type insertionMode int
+
+const (
+	initialMode insertionMode = iota
+	beforeHTMLMode
+	beforeHeadMode
+	inHeadMode
+	inHeadNoscriptMode
+	afterHeadMode
+	inBodyMode
+	textMode
+	inTableMode
+	inTableTextMode
+	inCaptionMode
+	inColumnGroupMode
+	inTableBodyMode
+	inRowMode
+	inCellMode
+	inTemplateMode
+	afterBodyMode
+	inFramesetMode
+	afterFramesetMode
+	afterAfterBodyMode
+	afterAfterFramesetMode
+)
+
+// quirksMode — режим совместимости документа, определяемый по DOCTYPE
+type quirksMode int
+
+const (
+	noQuirks quirksMode = iota
+	quirks
+	limitedQuirks
+)
+
+// treeBuilder реализует алгоритм построения дерева HTML5
+type treeBuilder struct {
+	tokenizer *Tokenizer
+	doc       *Node
+
+	mode         insertionMode
+	originalMode insertionMode
+	// templateModes — стек режимов вставки для открытых элементов <template>
+	templateModes []insertionMode
+
+	// oe — стек открытых элементов, afe — список активных элементов
+	// форматирования (nil в списке обозначает маркер)
+	oe  []*Node
+	afe []*Node
+
+	head *Node
+	form *Node
+
+	// context — элемент, в контексте которого разбирается фрагмент
+	// (nil при разборе целого документа)
+	context *Node
+
+	quirks          quirksMode
+	framesetOK      bool
+	scripting       bool
+	fosterParenting bool
+	skipNewline     bool
+
+	pendingTableText []rune
+	// pendingTableSpan — позиции начала и конца накопленного текста таблицы
+	pendingTableSpan [2]Position
+
+	// tok — обрабатываемый токен, по которому определяются позиции новых
+	// узлов и ошибок
+	tok    *Token
+	errors []ParseError
+
+	// onElement вызывается для каждого элемента, снятого со стека открытых
+	// элементов; finished — обработан конец входа
+	onElement []func(*Node)
+	finished  bool
+}
+
+// newTreeBuilder создает построитель дерева, который заполняет документ doc
+func newTreeBuilder(doc *Document, tokenizer *Tokenizer, scripting bool) *treeBuilder {
+	return &treeBuilder{
+		tokenizer:  tokenizer,
+		doc:        &doc.Node,
+		mode:       initialMode,
+		framesetOK: true,
+		scripting:  scripting,
+	}
+}
+
+// newFragmentTreeBuilder создает построитель дерева для разбора фрагмента в
+// контексте элемента context. Узлы фрагмента вставляются в корневой <html>
+func newFragmentTreeBuilder(doc *Document, tokenizer *Tokenizer, scripting bool, context *Node) (*treeBuilder, *Node) {
+	b := newTreeBuilder(doc, tokenizer, scripting)
+	b.context = context
+
+	// Токенизатор начинает в состоянии, соответствующем содержимому контекста
+	tagName := context.TagName
+	if !context.IsHTML() {
+		tagName = ""
+	}
+	switch tagName {
+	case "title", "textarea":
+		tokenizer.setState(rcdataState)
+	case "style", "xmp", "iframe", "noembed", "noframes":
+		tokenizer.setState(rawtextState)
+	case "script":
+		tokenizer.setState(scriptDataState)
+	case "noscript":
+		if scripting {
+			tokenizer.setState(rawtextState)
+		}
+	case "plaintext":
+		tokenizer.setState(plaintextState)
+	}
+
+	root := NewElement("html")
+	b.doc.AppendChild(root)
+	b.oe = append(b.oe, root)
+	if context.isHTMLElement("template") {
+		b.templateModes = append(b.templateModes, inTemplateMode)
+	}
+	b.resetInsertionMode()
+
+	for n := context; n != nil; n = n.Parent {
+		if n.isHTMLElement("form") {
+			b.form = n
+			break
+		}
+	}
+	return b, root
+}
+
+// build читает все токены и достраивает документ
+func (b *treeBuilder) build() {
+	b.run()
+}
+
+// run обрабатывает токены, для которых токенизатору хватает входных данных.
+// Возвращает true, когда обработан конец входа
+func (b *treeBuilder) run() bool {
+	for !b.finished {
+		// Секции CDATA допустимы только внутри SVG и MathML
+		if n := b.adjustedCurrent(); n != nil {
+			b.tokenizer.cdataAllowed = !n.IsHTML()
+		}
+		tok, ok := b.tokenizer.next()
+		if !ok {
+			return false
+		}
+		b.processToken(&tok)
+		if tok.Type == EOFToken {
+			// Незакрытые элементы заканчиваются в конце документа
+			for i := len(b.oe) - 1; i >= 0; i-- {
+				b.closeElement(b.oe[i])
+			}
+			b.finished = true
+		}
+	}
+	return true
+}
+
+// processToken передает токен обработчику текущего режима вставки
+func (b *treeBuilder) processToken(tok *Token) {
+	b.tok = tok
+	if b.skipNewline {
+		b.skipNewline = false
+		if tok.Type == CharacterToken && strings.HasPrefix(tok.Data, "\n") {
+			tok.Data = tok.Data[1:]
+			if tok.Data == "" {
+				return
+			}
+		}
+	}
+	b.process(tok)
+}
+
+// process обрабатывает токен по правилам текущего режима вставки или, внутри
+// SVG и MathML, по правилам их содержимого
+func (b *treeBuilder) process(tok *Token) {
+	if b.inForeignContent(tok) {
+		b.foreignContent(tok)
+		return
+	}
+	b.processIn(b.mode, tok)
+}
+
+// processIn обрабатывает токен по правилам указанного режима вставки
+func (b *treeBuilder) processIn(mode insertionMode, tok *Token) {
+	switch mode {
+	case initialMode:
+		b.initialMode(tok)
+	case beforeHTMLMode:
+		b.beforeHTMLMode(tok)
+	case beforeHeadMode:
+		b.beforeHeadMode(tok)
+	case inHeadMode:
+		b.inHeadMode(tok)
+	case inHeadNoscriptMode:
+		b.inHeadNoscriptMode(tok)
+	case afterHeadMode:
+		b.afterHeadMode(tok)
+	case inBodyMode:
+		b.inBodyMode(tok)
+	case textMode:
+		b.textMode(tok)
+	case inTableMode:
+		b.inTableMode(tok)
+	case inTableTextMode:
+		b.inTableTextMode(tok)
+	case inCaptionMode:
+		b.inCaptionMode(tok)
+	case inColumnGroupMode:
+		b.inColumnGroupMode(tok)
+	case inTableBodyMode:
+		b.inTableBodyMode(tok)
+	case inRowMode:
+		b.inRowMode(tok)
+	case inCellMode:
+		b.inCellMode(tok)
+	case inTemplateMode:
+		b.inTemplateMode(tok)
+	case afterBodyMode:
+		b.afterBodyMode(tok)
+	case inFramesetMode:
+		b.inFramesetMode(tok)
+	case afterFramesetMode:
+		b.afterFramesetMode(tok)
+	case afterAfterBodyMode:
+		b.afterAfterBodyMode(tok)
+	case afterAfterFramesetMode:
+		b.afterAfterFramesetMode(tok)
+	}
+}
+
+// parseError регистрирует ошибку построения дерева
+func (b *treeBuilder) parseError(code string) {
+	b.errors = append(b.errors, ParseError{Code: code, Pos: b.tok.Start})
+}
+
+// nullCharacterError регистрирует unexpected-null-character для каждого
+// символа U+0000 в тексте токена в позиции самого символа
+func (b *treeBuilder) nullCharacterError(tok *Token) {
+	if len(tok.nulls) == 0 {
+		b.parseError("unexpected-null-character")
+		return
+	}
+	for _, pos := range tok.nulls {
+		b.errors = append(b.errors, ParseError{Code: "unexpected-null-character", Pos: pos})
+	}
+}
+
+// Наборы элементов, используемые алгоритмом
+
+var specialElements = map[string]bool{
+	"address": true, "applet": true, "area": true, "article": true, "aside": true,
+	"base": true, "basefont": true, "bgsound": true, "blockquote": true, "body": true,
+	"br": true, "button": true, "caption": true, "center": true, "col": true,
+	"colgroup": true, "dd": true, "details": true, "dir": true, "div": true, "dl": true,
+	"dt": true, "embed": true, "fieldset": true, "figcaption": true, "figure": true,
+	"footer": true, "form": true, "frame": true, "frameset": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "head": true, "header": true,
+	"hgroup": true, "hr": true, "html": true, "iframe": true, "img": true, "input": true,
+	"keygen": true, "li": true, "link": true, "listing": true, "main": true,
+	"marquee": true, "menu": true, "meta": true, "nav": true, "noembed": true,
+	"noframes": true, "noscript": true, "object": true, "ol": true, "p": true,
+	"param": true, "plaintext": true, "pre": true, "script": true, "search": true,
+	"section": true, "select": true, "source": true, "style": true, "summary": true,
+	"table": true, "tbody": true, "td": true, "template": true, "textarea": true,
+	"tfoot": true, "th": true, "thead": true, "title": true, "tr": true, "track": true,
+	"ul": true, "wbr": true, "xmp": true,
+}
+
+var formattingElements = map[string]bool{
+	"a": true, "b": true, "big": true, "code": true, "em": true, "font": true, "i": true,
+	"nobr": true, "s": true, "small": true, "strike": true, "strong": true, "tt": true,
+	"u": true,
+}
+
+var impliedEndTags = map[string]bool{
+	"dd": true, "dt": true, "li": true, "optgroup": true, "option": true, "p": true,
+	"rb": true, "rp": true, "rt": true, "rtc": true,
+}
+
+var impliedEndTagsThoroughly = map[string]bool{
+	"caption": true, "colgroup": true, "dd": true, "dt": true, "li": true,
+	"optgroup": true, "option": true, "p": true, "rb": true, "rp": true, "rt": true,
+	"rtc": true, "tbody": true, "td": true, "tfoot": true, "th": true, "thead": true,
+	"tr": true,
+}
+
+// isSpecial проверяет, относится ли элемент к категории special
+func isSpecial(n *Node) bool {
+	switch n.Namespace {
+	case "":
+		return specialElements[n.TagName]
+	case MathMLNamespace:
+		return isMathMLTextIntegrationPoint(n) || n.TagName == "annotation-xml"
+	case SVGNamespace:
+		return n.TagName == "foreignObject" || n.TagName == "desc" || n.TagName == "title"
+	}
+	return false
+}
+
+var defaultScopeElements = map[string]bool{
+	"applet": true, "caption": true, "html": true, "table": true, "td": true, "th": true,
+	"marquee": true, "object": true, "select": true, "template": true,
+}
+
+var headingElements = map[string]bool{
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// Виды областей видимости элементов
+type scope int
+
+const (
+	defaultScope scope = iota
+	listItemScope
+	buttonScope
+	tableScope
+	selectScope
+)
+
+// isScopeBoundary проверяет, ограничивает ли элемент указанную область видимости
+func isScopeBoundary(n *Node, s scope) bool {
+	if !n.IsHTML() {
+		// Специальные элементы SVG и MathML ограничивают все области, кроме
+		// табличной, а область видимости select ограничивают любые из них
+		switch s {
+		case tableScope:
+			return false
+		case selectScope:
+			return true
+		}
+		return isSpecial(n)
+	}
+	switch s {
+	case listItemScope:
+		if n.TagName == "ol" || n.TagName == "ul" {
+			return true
+		}
+	case buttonScope:
+		if n.TagName == "button" {
+			return true
+		}
+	case tableScope:
+		return n.TagName == "html" || n.TagName == "table" || n.TagName == "template"
+	case selectScope:
+		return n.TagName != "optgroup" && n.TagName != "option"
+	}
+	return defaultScopeElements[n.TagName]
+}
+
+// inScope проверяет, есть ли элемент с одним из указанных тегов в области видимости
+func (b *treeBuilder) inScope(s scope, tagNames ...string) bool {
+	for i := len(b.oe) - 1; i >= 0; i-- {
+		n := b.oe[i]
+		if n.isHTMLElement(tagNames...) {
+			return true
+		}
+		if isScopeBoundary(n, s) {
+			return false
+		}
+	}
+	return false
+}
+
+// nodeInScope проверяет, находится ли конкретный узел в области видимости
+func (b *treeBuilder) nodeInScope(target *Node, s scope) bool {
+	for i := len(b.oe) - 1; i >= 0; i-- {
+		n := b.oe[i]
+		if n == target {
+			return true
+		}
+		if isScopeBoundary(n, s) {
+			return false
+		}
+	}
+	return false
+}
+
+// Работа со стеком открытых элементов
+
+// current возвращает текущий узел
+func (b *treeBuilder) current() *Node {
+	if len(b.oe) == 0 {
+		return nil
+	}
+	return b.oe[len(b.oe)-1]
+}
+
+// currentIs проверяет тег текущего узла
+func (b *treeBuilder) currentIs(tagNames ...string) bool {
+	cur := b.current()
+	return cur != nil && cur.isHTMLElement(tagNames...)
+}
+
+// pop снимает текущий узел со стека
+func (b *treeBuilder) pop() *Node {
+	n := b.oe[len(b.oe)-1]
+	b.oe = b.oe[:len(b.oe)-1]
+	b.closeElement(n)
+	return n
+}
+
+// closeElement вызывается для элемента, снятого со стека: запоминает его
+// конец и сообщает подписчикам, что элемент разобран. Если элемент закрыт
+// своим закрывающим тегом, конец приходится на конец тега, иначе элемент
+// заканчивается перед токеном, который его неявно закрыл
+func (b *treeBuilder) closeElement(n *Node) {
+	if b.tok != nil {
+		if b.tok.Type == EndTagToken && strings.EqualFold(b.tok.Data, n.TagName) {
+			n.End = b.tok.End
+		} else {
+			n.End = b.tok.Start
+		}
+	}
+	for _, listener := range b.onElement {
+		listener(n)
+	}
+}
+
+// popUntil снимает элементы со стека, пока не будет снят элемент с одним из тегов
+func (b *treeBuilder) popUntil(tagNames ...string) {
+	for len(b.oe) > 0 {
+		if b.pop().isHTMLElement(tagNames...) {
+			return
+		}
+	}
+}
+
+// popUntilNode снимает элементы со стека, пока не будет снят указанный узел
+func (b *treeBuilder) popUntilNode(target *Node) {
+	for len(b.oe) > 0 {
+		if b.pop() == target {
+			return
+		}
+	}
+}
+
+// indexOf возвращает позицию узла в стеке открытых элементов или -1
+func (b *treeBuilder) indexOf(n *Node) int {
+	for i := len(b.oe) - 1; i >= 0; i-- {
+		if b.oe[i] == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeFromStack удаляет узел из стека открытых элементов
+func (b *treeBuilder) removeFromStack(n *Node) {
+	if i := b.indexOf(n); i >= 0 {
+		b.oe = append(b.oe[:i], b.oe[i+1:]...)
+		b.closeElement(n)
+	}
+}
+
+// hasOpen проверяет, есть ли в стеке элемент с указанным тегом
+func (b *treeBuilder) hasOpen(tagName string) bool {
+	for _, n := range b.oe {
+		if n.isHTMLElement(tagName) {
+			return true
+		}
+	}
+	return false
+}
+
+// generateImpliedEndTags закрывает элементы с неявными закрывающими тегами
+func (b *treeBuilder) generateImpliedEndTags(except string) {
+	for {
+		cur := b.current()
+		if cur == nil || !cur.IsHTML() || !impliedEndTags[cur.TagName] || cur.TagName == except {
+			return
+		}
+		b.pop()
+	}
+}
+
+// generateImpliedEndTagsThoroughly закрывает элементы с неявными закрывающими тегами, включая табличные
+func (b *treeBuilder) generateImpliedEndTagsThoroughly() {
+	for {
+		cur := b.current()
+		if cur == nil || !cur.IsHTML() || !impliedEndTagsThoroughly[cur.TagName] {
+			return
+		}
+		b.pop()
+	}
+}
+
+// closePElement закрывает элемент <p>
+func (b *treeBuilder) closePElement() {
+	b.generateImpliedEndTags("p")
+	if !b.currentIs("p") {
+		b.parseError("unexpected-end-tag")
+	}
+	b.popUntil("p")
+}
+
+// closePIfInButtonScope закрывает <p>, если он открыт в области видимости кнопки
+func (b *treeBuilder) closePIfInButtonScope() {
+	if b.inScope(buttonScope, "p") {
+		b.closePElement()
+	}
+}
+
+// Вставка узлов
+
+// appropriatePlace возвращает родителя и узел, перед которым нужно вставлять новый узел
+func (b *treeBuilder) appropriatePlace(override *Node) (parent, before *Node) {
+	target := override
+	if target == nil {
+		target = b.current()
+	}
+	parent = target
+	if b.fosterParenting && target.isHTMLElement("table", "tbody", "tfoot", "thead", "tr") {
+		parent, before = b.fosterPlace()
+	}
+	// Узлы внутри <template> попадают в его содержимое
+	if parent.isHTMLElement("template") {
+		return parent.Content, nil
+	}
+	return parent, before
+}
+
+// fosterPlace возвращает место вставки для содержимого, вынесенного из таблицы
+func (b *treeBuilder) fosterPlace() (parent, before *Node) {
+	lastTemplate, lastTable := -1, -1
+	for i := len(b.oe) - 1; i >= 0; i-- {
+		if b.oe[i].isHTMLElement("template") && lastTemplate < 0 {
+			lastTemplate = i
+		}
+		if b.oe[i].isHTMLElement("table") && lastTable < 0 {
+			lastTable = i
+		}
+	}
+	if lastTemplate >= 0 && (lastTable < 0 || lastTemplate > lastTable) {
+		return b.oe[lastTemplate], nil
+	}
+	if lastTable < 0 {
+		return b.oe[0], nil
+	}
+	table := b.oe[lastTable]
+	if table.Parent != nil {
+		return table.Parent, table
+	}
+	return b.oe[lastTable-1], nil
+}
+
+// createElement создает элемент для токена в пространстве имен namespace
+func (b *treeBuilder) createElement(tok *Token, namespace string) *Node {
+	n := NewElementNS(namespace, tok.Data)
+	if namespace == SVGNamespace {
+		if name, ok := svgTagNames[n.TagName]; ok {
+			n.TagName = name
+		}
+	}
+	n.Attributes = append([]Attribute(nil), tok.Attr...)
+	// Неявно созданные элементы начинаются там, где их потребовал текущий токен
+	n.Start, n.End = tok.Start, tok.End
+	if !tok.Start.IsValid() && b.tok != nil {
+		n.Start, n.End = b.tok.Start, b.tok.Start
+	}
+	return n
+}
+
+// insertElement создает HTML элемент для токена, вставляет его и помещает в стек
+func (b *treeBuilder) insertElement(tok *Token) *Node {
+	return b.insertElementNS(tok, "")
+}
+
+// insertElementNS создает элемент из пространства имен namespace для токена,
+// вставляет его и помещает в стек
+func (b *treeBuilder) insertElementNS(tok *Token, namespace string) *Node {
+	n := b.createElement(tok, namespace)
+	parent, before := b.appropriatePlace(nil)
+	parent.InsertBefore(n, before)
+	b.oe = append(b.oe, n)
+	return n
+}
+
+// insertElementNamed вставляет элемент с указанным тегом без атрибутов
+func (b *treeBuilder) insertElementNamed(tagName string) *Node {
+	return b.insertElement(&Token{Type: StartTagToken, Data: tagName})
+}
+
+// insertText вставляет текст, объединяя его с соседним текстовым узлом
+func (b *treeBuilder) insertText(text string) {
+	if text == "" {
+		return
+	}
+	parent, before := b.appropriatePlace(nil)
+	if parent.Type == DocumentNode {
+		return
+	}
+	prev := parent.LastChild
+	if before != nil {
+		prev = before.PrevSibling
+	}
+	if prev != nil && prev.Type == TextNode {
+		prev.Data += text
+		prev.End = b.tok.End
+		return
+	}
+	n := NewText(text)
+	n.Start, n.End = b.tok.Start, b.tok.End
+	parent.InsertBefore(n, before)
+}
+
+// insertComment вставляет комментарий
+func (b *treeBuilder) insertComment(tok *Token, parent *Node) {
+	var before *Node
+	if parent == nil {
+		parent, before = b.appropriatePlace(nil)
+	}
+	n := NewComment(tok.Data)
+	n.Start, n.End = tok.Start, tok.End
+	parent.InsertBefore(n, before)
+}
+
+// mergeAttributes добавляет элементу атрибуты токена, которых у него еще нет
+func (b *treeBuilder) mergeAttributes(n *Node, tok *Token) {
+	for _, a := range tok.Attr {
+		if !n.HasAttribute(a.Name) {
+			n.SetAttribute(a.Name, a.Value)
+		}
+	}
+}
+
+// parseRawText обрабатывает элемент, содержимое которого разбирается как текст
+func (b *treeBuilder) parseRawText(tok *Token, state tokenizerState) {
+	b.insertElement(tok)
+	b.tokenizer.setState(state)
+	b.originalMode = b.mode
+	b.mode = textMode
+}
+
+// Список активных элементов форматирования
+
+// pushFormatting добавляет элемент в список активных элементов форматирования
+// с учетом ограничения на три одинаковых элемента после последнего маркера
+func (b *treeBuilder) pushFormatting(n *Node) {
+	count, earliest := 0, -1
+	for i := len(b.afe) - 1; i >= 0; i-- {
+		e := b.afe[i]
+		if e == nil {
+			break
+		}
+		if e.TagName == n.TagName && sameAttributes(e.Attributes, n.Attributes) {
+			count++
+			earliest = i
+		}
+	}
+	if count >= 3 {
+		b.afe = append(b.afe[:earliest], b.afe[earliest+1:]...)
+	}
+	b.afe = append(b.afe, n)
+}
+
+// sameAttributes сравнивает наборы атрибутов без учета порядка
+func sameAttributes(a, b []Attribute) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, attr := range a {
+		found := false
+		for _, other := range b {
+			if other.Name == attr.Name {
+				found = other.Value == attr.Value
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// insertMarker добавляет маркер в список активных элементов форматирования
+func (b *treeBuilder) insertMarker() {
+	b.afe = append(b.afe, nil)
+}
+
+// clearFormattingToMarker удаляет элементы форматирования до последнего маркера
+func (b *treeBuilder) clearFormattingToMarker() {
+	for len(b.afe) > 0 {
+		e := b.afe[len(b.afe)-1]
+		b.afe = b.afe[:len(b.afe)-1]
+		if e == nil {
+			return
+		}
+	}
+}
+
+// formattingIndex возвращает позицию элемента в списке форматирования или -1
+func (b *treeBuilder) formattingIndex(n *Node) int {
+	for i := len(b.afe) - 1; i >= 0; i-- {
+		if b.afe[i] == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeFormatting удаляет элемент из списка активных элементов форматирования
+func (b *treeBuilder) removeFormatting(n *Node) {
+	if i := b.formattingIndex(n); i >= 0 {
+		b.afe = append(b.afe[:i], b.afe[i+1:]...)
+	}
+}
+
+// reconstructFormatting восстанавливает активные элементы форматирования,
+// которые были закрыты неявно
+func (b *treeBuilder) reconstructFormatting() {
+	if len(b.afe) == 0 {
+		return
+	}
+	i := len(b.afe) - 1
+	if e := b.afe[i]; e == nil || b.indexOf(e) >= 0 {
+		return
+	}
+	for i > 0 {
+		e := b.afe[i-1]
+		if e == nil || b.indexOf(e) >= 0 {
+			break
+		}
+		i--
+	}
+	for ; i < len(b.afe); i++ {
+		n := b.afe[i].CloneNode(false)
+		parent, before := b.appropriatePlace(nil)
+		parent.InsertBefore(n, before)
+		b.oe = append(b.oe, n)
+		b.afe[i] = n
+	}
+}
+
+// adoptionAgency реализует алгоритм adoption agency для неправильно вложенных
+// элементов форматирования. Возвращает false, если тег нужно обработать
+// как «любой другой закрывающий тег»
+func (b *treeBuilder) adoptionAgency(subject string) bool {
+	if cur := b.current(); cur.isHTMLElement(subject) && b.formattingIndex(cur) < 0 {
+		b.pop()
+		return true
+	}
+
+	for outer := 0; outer < 8; outer++ {
+		// Ищем элемент форматирования после последнего маркера
+		var formatting *Node
+		for i := len(b.afe) - 1; i >= 0; i-- {
+			e := b.afe[i]
+			if e == nil {
+				break
+			}
+			if e.TagName == subject {
+				formatting = e
+				break
+			}
+		}
+		if formatting == nil {
+			return false
+		}
+
+		feIndex := b.indexOf(formatting)
+		if feIndex < 0 {
+			b.parseError("adoption-agency-1.2")
+			b.removeFormatting(formatting)
+			return true
+		}
+		if !b.nodeInScope(formatting, defaultScope) {
+			b.parseError("adoption-agency-4.4")
+			return true
+		}
+		if formatting != b.current() {
+			b.parseError("adoption-agency-1.3")
+		}
+
+		// Ищем самый верхний специальный элемент ниже элемента форматирования
+		var furthestBlock *Node
+		for i := feIndex + 1; i < len(b.oe); i++ {
+			if isSpecial(b.oe[i]) {
+				furthestBlock = b.oe[i]
+				break
+			}
+		}
+		if furthestBlock == nil {
+			b.popUntilNode(formatting)
+			b.removeFormatting(formatting)
+			return true
+		}
+
+		commonAncestor := b.oe[feIndex-1]
+		bookmark := b.formattingIndex(formatting)
+
+		n, lastNode := furthestBlock, furthestBlock
+		nIndex := b.indexOf(n)
+		for inner := 1; ; inner++ {
+			nIndex--
+			n = b.oe[nIndex]
+			if n == formatting {
+				break
+			}
+			if inner > 3 && b.formattingIndex(n) >= 0 {
+				if b.formattingIndex(n) < bookmark {
+					bookmark--
+				}
+				b.removeFormatting(n)
+			}
+			fi := b.formattingIndex(n)
+			if fi < 0 {
+				b.oe = append(b.oe[:nIndex], b.oe[nIndex+1:]...)
+				continue
+			}
+			clone := n.CloneNode(false)
+			b.afe[fi] = clone
+			b.oe[nIndex] = clone
+			n = clone
+			if lastNode == furthestBlock {
+				bookmark = fi + 1
+			}
+			n.AppendChild(lastNode)
+			lastNode = n
+		}
+
+		parent, before := b.appropriatePlace(commonAncestor)
+		parent.InsertBefore(lastNode, before)
+
+		clone := formatting.CloneNode(false)
+		furthestBlock.moveChildrenTo(clone)
+		furthestBlock.AppendChild(clone)
+
+		if fi := b.formattingIndex(formatting); fi >= 0 {
+			if fi < bookmark {
+				bookmark--
+			}
+			b.afe = append(b.afe[:fi], b.afe[fi+1:]...)
+		}
+		b.afe = append(b.afe[:bookmark], append([]*Node{clone}, b.afe[bookmark:]...)...)
+
+		b.removeFromStack(formatting)
+		fbIndex := b.indexOf(furthestBlock)
+		b.oe = append(b.oe[:fbIndex+1], append([]*Node{clone}, b.oe[fbIndex+1:]...)...)
+	}
+	return true
+}
+
+// resetInsertionMode выбирает режим вставки по содержимому стека открытых элементов
+func (b *treeBuilder) resetInsertionMode() {
+	for i := len(b.oe) - 1; i >= 0; i-- {
+		n := b.oe[i]
+		last := i == 0
+		if last && b.context != nil {
+			n = b.context
+		}
+		tagName := n.TagName
+		if !n.IsHTML() {
+			tagName = ""
+		}
+		switch tagName {
+		case "template":
+			b.mode = b.templateModes[len(b.templateModes)-1]
+			return
+		case "td", "th":
+			if !last {
+				b.mode = inCellMode
+				return
+			}
+		case "tr":
+			b.mode = inRowMode
+			return
+		case "tbody", "thead", "tfoot":
+			b.mode = inTableBodyMode
+			return
+		case "caption":
+			b.mode = inCaptionMode
+			return
+		case "colgroup":
+			b.mode = inColumnGroupMode
+			return
+		case "table":
+			b.mode = inTableMode
+			return
+		case "head":
+			if !last {
+				b.mode = inHeadMode
+				return
+			}
+		case "body":
+			b.mode = inBodyMode
+			return
+		case "frameset":
+			b.mode = inFramesetMode
+			return
+		case "html":
+			if b.head == nil {
+				b.mode = beforeHeadMode
+			} else {
+				b.mode = afterHeadMode
+			}
+			return
+		}
+		if last {
+			b.mode = inBodyMode
+			return
+		}
+	}
+}
+
+// splitLeadingSpace разделяет строку на ведущие пробельные символы и остаток
+func splitLeadingSpace(s string) (space, rest string) {
+	i := 0
+	for i < len(s) && isHTMLSpace(rune(s[i])) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// isAllSpace проверяет, состоит ли строка только из пробельных символов
+func isAllSpace(s string) bool {
+	space, _ := splitLeadingSpace(s)
+	return len(space) == len(s)
+}
+
+// processLeadingSpace обрабатывает ведущие пробелы символьного токена функцией handle
+// и возвращает false, если в токене больше ничего не осталось
+func processLeadingSpace(tok *Token, handle func(space string)) bool {
+	space, rest := splitLeadingSpace(tok.Data)
+	if space != "" {
+		handle(space)
+	}
+	tok.Data = rest
+	return rest != ""
+}
+
+// Режимы вставки
+
+func (b *treeBuilder) initialMode(tok *Token) {
+	switch tok.Type {
+	case CharacterToken:
+		if !processLeadingSpace(tok, func(string) {}) {
+			return
+		}
+	case CommentToken:
+		b.insertComment(tok, b.doc)
+		return
+	case DoctypeToken:
+		if tok.Data != "html" || tok.HasPublicID || (tok.HasSystemID && tok.SystemID != "about:legacy-compat") {
+			b.parseError("bad-doctype")
+		}
+		b.doc.AppendChild(&Node{
+			Type:     DocumentTypeNode,
+			Data:     tok.Data,
+			PublicID: tok.PublicID,
+			SystemID: tok.SystemID,
+			Start:    tok.Start,
+			End:      tok.End,
+		})
+		b.setQuirksMode(doctypeQuirksMode(tok))
+		b.mode = beforeHTMLMode
+		return
+	}
+	b.parseError("expected-doctype")
+	b.setQuirksMode(quirks)
+	b.mode = beforeHTMLMode
+	b.process(tok)
+}
+
+func (b *treeBuilder) beforeHTMLMode(tok *Token) {
+	switch tok.Type {
+	case DoctypeToken:
+		b.parseError("unexpected-doctype")
+		return
+	case CommentToken:
+		b.insertComment(tok, b.doc)
+		return
+	case CharacterToken:
+		if !processLeadingSpace(tok, func(string) {}) {
+			return
+		}
+	case StartTagToken:
+		if tok.Data == "html" {
+			n := b.createElement(tok, "")
+			b.doc.AppendChild(n)
+			b.oe = append(b.oe, n)
+			b.mode = beforeHeadMode
+			return
+		}
+	case EndTagToken:
+		switch tok.Data {
+		case "head", "body", "html", "br":
+		default:
+			b.parseError("unexpected-end-tag")
+			return
+		}
+	}
+	// Неявный <html> начинается там же, где токен, который его потребовал
+	n := b.createElement(&Token{Type: StartTagToken, Data: "html"}, "")
+	b.doc.AppendChild(n)
+	b.oe = append(b.oe, n)
+	b.mode = beforeHeadMode
+	b.process(tok)
+}
+
+func (b *treeBuilder) beforeHeadMode(tok *Token) {
+	switch tok.Type {
+	case CharacterToken:
+		if !processLeadingSpace(tok, func(string) {}) {
+			return
+		}
+	case CommentToken:
+		b.insertComment(tok, nil)
+		return
+	case DoctypeToken:
+		b.parseError("unexpected-doctype")
+		return
+	case StartTagToken:
+		switch tok.Data {
+		case "html":
+			b.inBodyMode(tok)
+			return
+		case "head":
+			b.head = b.insertElement(tok)
+			b.mode = inHeadMode
+			return
+		}
+	case EndTagToken:
+		switch tok.Data {
+		case "head", "body", "html", "br":
+		default:
+			b.parseError("unexpected-end-tag")
+			return
+		}
+	}
+	b.head = b.insertElementNamed("head")
+	b.mode = inHeadMode
+	b.process(tok)
+}
+
+func (b *treeBuilder) inHeadMode(tok *Token) {
+	switch tok.Type {
+	case CharacterToken:
+		if !processLeadingSpace(tok, b.insertText) {
+			return
+		}
+	case CommentToken:
+		b.insertComment(tok, nil)
+		return
+	case DoctypeToken:
+		b.parseError("unexpected-doctype")
+		return
+	case StartTagToken:
+		switch tok.Data {
+		case "html":
+			b.inBodyMode(tok)
+			return
+		case "base", "basefont", "bgsound", "link", "meta":
+			b.insertElement(tok)
+			b.pop()
+			return
+		case "title":
+			b.parseRawText(tok, rcdataState)
+			return
+		case "noscript":
+			if !b.scripting {
+				b.insertElement(tok)
+				b.mode = inHeadNoscriptMode
+				return
+			}
+			b.parseRawText(tok, rawtextState)
+			return
+		case "noframes", "style":
+			b.parseRawText(tok, rawtextState)
+			return
+		case "script":
+			b.parseRawText(tok, scriptDataState)
+			return
+		case "template":
+			b.insertElement(tok)
+			b.insertMarker()
+			b.framesetOK = false
+			b.mode = inTemplateMode
+			b.templateModes = append(b.templateModes, inTemplateMode)
+			return
+		case "head":
+			b.parseError("unexpected-start-tag")
+			return
+		}
+	case EndTagToken:
+		switch tok.Data {
+		case "head":
+			b.pop()
+			b.mode = afterHeadMode
+			return
+		case "template":
+			if !b.hasOpen("template") {
+				b.parseError("unexpected-end-tag")
+				return
+			}
+			b.generateImpliedEndTagsThoroughly()
+			if !b.currentIs("template") {
+				b.parseError("end-tag-too-early")
+			}
+			b.popUntil("template")
+			b.clearFormattingToMarker()
+			b.popTemplateMode()
+			b.resetInsertionMode()
+			return
+		case "body", "html", "br":
+		default:
+			b.parseError("unexpected-end-tag")
+			return
+		}
+	}
+	b.pop()
+	b.mode = afterHeadMode
+	b.process(tok)
+}
+
+func (b *treeBuilder) inHeadNoscriptMode(tok *Token) {
+	switch tok.Type {
+	case DoctypeToken:
+		b.parseError("unexpected-doctype")
+		return
+	case CharacterToken:
+		if !processLeadingSpace(tok, b.insertText) {
+			return
+		}
+	case CommentToken:
+		b.inHeadMode(tok)
+		return
+	case StartTagToken:
+		switch tok.Data {
+		case "html":
+			b.inBodyMode(tok)
+			return
+		case "basefont", "bgsound", "link", "meta", "noframes", "style":
+			b.inHeadMode(tok)
+			return
+		case "head", "noscript":
+			b.parseError("unexpected-start-tag")
+			return
+		}
+	case EndTagToken:
+		switch tok.Data {
+		case "noscript":
+			b.pop()
+			b.mode = inHeadMode
+			return
+		case "br":
+		default:
+			b.parseError("unexpected-end-tag")
+			return
+		}
+	}
+	b.parseError("unexpected-token-in-head-noscript")
+	b.pop()
+	b.mode = inHeadMode
+	b.process(tok)
+}
+
+func (b *treeBuilder) afterHeadMode(tok *Token) {
+	switch tok.Type {
+	case CharacterToken:
+		if !processLeadingSpace(tok, b.insertText) {
+			return
+		}
+	case CommentToken:
+		b.insertComment(tok, nil)
+		return
+	case DoctypeToken:
+		b.parseError("unexpected-doctype")
+		return
+	case StartTagToken:
+		switch tok.Data {
+		case "html":
+			b.inBodyMode(tok)
+			return
+		case "body":
+			b.insertElement(tok)
+			b.framesetOK = false
+			b.mode = inBodyMode
+			return
+		case "frameset":
+			b.insertElement(tok)
+			b.mode = inFramesetMode
+			return
+		case "base", "basefont", "bgsound", "link", "meta", "noframes", "script", "style", "template", "title":
+			b.parseError("unexpected-start-tag")
+			b.oe = append(b.oe, b.head)
+			b.inHeadMode(tok)
+			b.removeFromStack(b.head)
+			return
+		case "head":
+			b.parseError("unexpected-start-tag")
+			return
+		}
+	case EndTagToken:
+		switch tok.Data {
+		case "body", "html", "br":
+		default:
+			b.parseError("unexpected-end-tag")
+			return
+		}
+	}
+	b.insertElementNamed("body")
+	b.mode = inBodyMode
+	b.process(tok)
+}
+
+func (b *treeBuilder) inBodyMode(tok *Token) {
+	switch tok.Type {
+	case CharacterToken:
+		text := tok.Data
+		if strings.IndexByte(text, 0) >= 0 {
+			b.nullCharacterError(tok)
+			text = strings.ReplaceAll(text, "\x00", "")
+		}
+		if text == "" {
+			return
+		}
+		b.reconstructFormatting()
+		b.insertText(text)
+		if !isAllSpace(text) {
+			b.framesetOK = false
+		}
+	case CommentToken:
+		b.insertComment(tok, nil)
+	case DoctypeToken:
+		b.parseError("unexpected-doctype")
+	case StartTagToken:
+		b.inBodyStartTag(tok)
+	case EndTagToken:
+		b.inBodyEndTag(tok)
+	case EOFToken:
+		if len(b.templateModes) > 0 {
+			b.inTemplateMode(tok)
+			return
+		}
+		for _, n := range b.oe {
+			switch n.TagName {
+			case "dd", "dt", "li", "optgroup", "option", "p", "rb", "rp", "rt", "rtc",
+				"tbody", "td", "tfoot", "th", "thead", "tr", "body", "html":
+			default:
+				b.parseError("eof-with-open-elements")
+				return
+			}
+		}
+	}
+}
+
+func (b *treeBuilder) inBodyStartTag(tok *Token) {
+	switch tok.Data {
+	case "html":
+		b.parseError("unexpected-start-tag")
+		if b.hasOpen("template") {
+			return
+		}
+		b.mergeAttributes(b.oe[0], tok)
+	case "base", "basefont", "bgsound", "link", "meta", "noframes", "script", "style", "template", "title":
+		b.inHeadMode(tok)
+	case "body":
+		b.parseError("unexpected-start-tag")
+		if len(b.oe) < 2 || b.oe[1].TagName != "body" || b.hasOpen("template") {
+			return
+		}
+		b.framesetOK = false
+		b.mergeAttributes(b.oe[1], tok)
+	case "frameset":
+		b.parseError("unexpected-start-tag")
+		if len(b.oe) < 2 || b.oe[1].TagName != "body" || !b.framesetOK {
+			return
+		}
+		body := b.oe[1]
+		if body.Parent != nil {
+			body.Parent.RemoveChild(body)
+		}
+		b.oe = b.oe[:1]
+		b.insertElement(tok)
+		b.mode = inFramesetMode
+	case "address", "article", "aside", "blockquote", "center", "details", "dialog", "dir",
+		"div", "dl", "fieldset", "figcaption", "figure", "footer", "header", "hgroup", "main",
+		"menu", "nav", "ol", "p", "search", "section", "summary", "ul":
+		b.closePIfInButtonScope()
+		b.insertElement(tok)
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		b.closePIfInButtonScope()
+		if cur := b.current(); cur.IsHTML() && headingElements[cur.TagName] {
+			b.parseError("unexpected-start-tag")
+			b.pop()
+		}
+		b.insertElement(tok)
+	case "pre", "listing":
+		b.closePIfInButtonScope()
+		b.insertElement(tok)
+		b.skipNewline = true
+		b.framesetOK = false
+	case "form":
+		templateOpen := b.hasOpen("template")
+		if b.form != nil && !templateOpen {
+			b.parseError("unexpected-start-tag")
+			return
+		}
+		b.closePIfInButtonScope()
+		n := b.insertElement(tok)
+		if !templateOpen {
+			b.form = n
+		}
+	case "li":
+		b.framesetOK = false
+		for i := len(b.oe) - 1; i >= 0; i-- {
+			n := b.oe[i]
+			if n.isHTMLElement("li") {
+				b.generateImpliedEndTags("li")
+				if !b.currentIs("li") {
+					b.parseError("unexpected-start-tag")
+				}
+				b.popUntil("li")
+				break
+			}
+			if isSpecial(n) && !n.isHTMLElement("address", "div", "p") {
+				break
+			}
+		}
+		b.closePIfInButtonScope()
+		b.insertElement(tok)
+	case "dd", "dt":
+		b.framesetOK = false
+		for i := len(b.oe) - 1; i >= 0; i-- {
+			n := b.oe[i]
+			if n.isHTMLElement("dd", "dt") {
+				b.generateImpliedEndTags(n.TagName)
+				if !b.currentIs(n.TagName) {
+					b.parseError("unexpected-start-tag")
+				}
+				b.popUntil(n.TagName)
+				break
+			}
+			if isSpecial(n) && !n.isHTMLElement("address", "div", "p") {
+				break
+			}
+		}
+		b.closePIfInButtonScope()
+		b.insertElement(tok)
+	case "plaintext":
+		b.closePIfInButtonScope()
+		b.insertElement(tok)
+		b.tokenizer.setState(plaintextState)
+	case "button":
+		if b.inScope(defaultScope, "button") {
+			b.parseError("unexpected-start-tag")
+			b.generateImpliedEndTags("")
+			b.popUntil("button")
+		}
+		b.reconstructFormatting()
+		b.insertElement(tok)
+		b.framesetOK = false
+	case "a":
+		for i := len(b.afe) - 1; i >= 0 && b.afe[i] != nil; i-- {
+			if a := b.afe[i]; a.isHTMLElement("a") {
+				b.parseError("unexpected-start-tag")
+				b.adoptionAgency("a")
+				b.removeFormatting(a)
+				b.removeFromStack(a)
+				break
+			}
+		}
+		b.reconstructFormatting()
+		b.pushFormatting(b.insertElement(tok))
+	case "b", "big", "code", "em", "font", "i", "s", "small", "strike", "strong", "tt", "u":
+		b.reconstructFormatting()
+		b.pushFormatting(b.insertElement(tok))
+	case "nobr":
+		b.reconstructFormatting()
+		if b.inScope(defaultScope, "nobr") {
+			b.parseError("unexpected-start-tag")
+			b.adoptionAgency("nobr")
+			b.reconstructFormatting()
+		}
+		b.pushFormatting(b.insertElement(tok))
+	case "applet", "marquee", "object":
+		b.reconstructFormatting()
+		b.insertElement(tok)
+		b.insertMarker()
+		b.framesetOK = false
+	case "table":
+		if b.quirks != quirks {
+			b.closePIfInButtonScope()
+		}
+		b.insertElement(tok)
+		b.framesetOK = false
+		b.mode = inTableMode
+	case "area", "br", "embed", "img", "keygen", "wbr":
+		b.reconstructFormatting()
+		b.insertElement(tok)
+		b.pop()
+		b.framesetOK = false
+	case "input":
+		if b.inScope(defaultScope, "select") {
+			b.parseError("unexpected-start-tag")
+			b.popUntil("select")
+		}
+		b.reconstructFormatting()
+		b.insertElement(tok)
+		b.pop()
+		if !isHiddenInput(tok) {
+			b.framesetOK = false
+		}
+	case "param", "source", "track":
+		b.insertElement(tok)
+		b.pop()
+	case "hr":
+		b.closePIfInButtonScope()
+		if b.inScope(defaultScope, "select") {
+			b.generateImpliedEndTags("")
+		}
+		b.insertElement(tok)
+		b.pop()
+		b.framesetOK = false
+	case "image":
+		b.parseError("unexpected-start-tag")
+		tok.Data = "img"
+		b.process(tok)
+	case "textarea":
+		b.insertElement(tok)
+		b.skipNewline = true
+		b.tokenizer.setState(rcdataState)
+		b.originalMode = b.mode
+		b.framesetOK = false
+		b.mode = textMode
+	case "xmp":
+		b.closePIfInButtonScope()
+		b.reconstructFormatting()
+		b.framesetOK = false
+		b.parseRawText(tok, rawtextState)
+	case "iframe":
+		b.framesetOK = false
+		b.parseRawText(tok, rawtextState)
+	case "noembed":
+		b.parseRawText(tok, rawtextState)
+	case "noscript":
+		if b.scripting {
+			b.parseRawText(tok, rawtextState)
+			return
+		}
+		b.reconstructFormatting()
+		b.insertElement(tok)
+	case "select":
+		// Вложенный <select> закрывает внешний
+		if b.inScope(defaultScope, "select") {
+			b.parseError("unexpected-start-tag")
+			b.popUntil("select")
+			return
+		}
+		b.reconstructFormatting()
+		b.insertElement(tok)
+		b.framesetOK = false
+	case "option":
+		if b.inScope(defaultScope, "select") {
+			b.generateImpliedEndTags("optgroup")
+		} else if b.currentIs("option") {
+			b.pop()
+		}
+		b.reconstructFormatting()
+		b.insertElement(tok)
+	case "optgroup":
+		if b.inScope(defaultScope, "select") {
+			b.generateImpliedEndTags("")
+		} else if b.currentIs("option") {
+			b.pop()
+		}
+		b.reconstructFormatting()
+		b.insertElement(tok)
+	case "rb", "rtc":
+		if b.inScope(defaultScope, "ruby") {
+			b.generateImpliedEndTags("")
+			if !b.currentIs("ruby") {
+				b.parseError("unexpected-start-tag")
+			}
+		}
+		b.insertElement(tok)
+	case "rp", "rt":
+		if b.inScope(defaultScope, "ruby") {
+			b.generateImpliedEndTags("rtc")
+			if !b.currentIs("ruby", "rtc") {
+				b.parseError("unexpected-start-tag")
+			}
+		}
+		b.insertElement(tok)
+	case "math":
+		b.reconstructFormatting()
+		b.insertForeignElement(tok, MathMLNamespace)
+	case "svg":
+		b.reconstructFormatting()
+		b.insertForeignElement(tok, SVGNamespace)
+	case "caption", "col", "colgroup", "frame", "head", "tbody", "td", "tfoot", "th", "thead", "tr":
+		b.parseError("unexpected-start-tag")
+	default:
+		b.reconstructFormatting()
+		b.insertElement(tok)
+	}
+}
+
+// isHiddenInput проверяет, является ли <input> скрытым полем
+func isHiddenInput(tok *Token) bool {
+	for _, a := range tok.Attr {
+		if a.Name == "type" {
+			return strings.EqualFold(a.Value, "hidden")
+		}
+	}
+	return false
+}
+
+func (b *treeBuilder) inBodyEndTag(tok *Token) {
+	switch tok.Data {
+	case "body", "html":
+		if !b.inScope(defaultScope, "body") {
+			b.parseError("unexpected-end-tag")
+			return
+		}
+		b.mode = afterBodyMode
+		if tok.Data == "html" {
+			b.process(tok)
+		}
+	case "address", "article", "aside", "blockquote", "button", "center", "details", "dialog",
+		"dir", "div", "dl", "fieldset", "figcaption", "figure", "footer", "header", "hgroup",
+		"listing", "main", "menu", "nav", "ol", "pre", "search", "section", "select", "summary", "ul":
+		if !b.inScope(defaultScope, tok.Data) {
+			b.parseError("unexpected-end-tag")
+			return
+		}
+		b.generateImpliedEndTags("")
+		if !b.currentIs(tok.Data) {
+			b.parseError("end-tag-too-early")
+		}
+		b.popUntil(tok.Data)
+	case "form":
+		if !b.hasOpen("template") {
+			n := b.form
+			b.form = nil
+			if n == nil || !b.nodeInScope(n, defaultScope) {
+				b.parseError("unexpected-end-tag")
+				return
+			}
+			b.generateImpliedEndTags("")
+			if b.current() != n {
+				b.parseError("end-tag-too-early")
+			}
+			b.removeFromStack(n)
+			return
+		}
+		if !b.inScope(defaultScope, "form") {
+			b.parseError("unexpected-end-tag")
+			return
+		}
+		b.generateImpliedEndTags("")
+		if !b.currentIs("form") {
+			b.parseError("end-tag-too-early")
+		}
+		b.popUntil("form")
+	case "p":
+		if !b.inScope(buttonScope, "p") {
+			b.parseError("unexpected-end-tag")
+			b.insertElementNamed("p")
+		}
+		b.closePElement()
+	case "li":
+		if !b.inScope(listItemScope, "li") {
+			b.parseError("unexpected-end-tag")
+			return
+		}
+		b.generateImpliedEndTags("li")
+		if !b.currentIs("li") {
+			b.parseError("end-tag-too-early")
+		}
+		b.popUntil("li")
+	case "dd", "dt":
+		if !b.inScope(defaultScope, tok.Data) {
+			b.parseError("unexpected-end-tag")
+			return
+		}
+		b.generateImpliedEndTags(tok.Data)
+		if !b.currentIs(tok.Data) {
+			b.parseError("end-tag-too-early")
+		}
+		b.popUntil(tok.Data)
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		if !b.inScope(defaultScope, "h1", "h2", "h3", "h4", "h5", "h6") {
+			b.parseError("unexpected-end-tag")
+			return
+		}
+		b.generateImpliedEndTags("")
+		if !b.currentIs(tok.Data) {
+			b.parseError("end-tag-too-early")
+		}
+		b.popUntil("h1", "h2", "h3", "h4", "h5", "h6")
+	case "a", "b", "big", "code", "em", "font", "i", "nobr", "s", "small", "strike", "strong", "tt", "u":
+		if !b.adoptionAgency(tok.Data) {
+			b.anyOtherEndTag(tok)
+		}
+	case "applet", "marquee", "object":
+		if !b.inScope(defaultScope, tok.Data) {
+			b.parseError("unexpected-end-tag")
+			return
+		}
+		b.generateImpliedEndTags("")
+		if !b.currentIs(tok.Data) {
+			b.parseError("end-tag-too-early")
+		}
+		b.popUntil(tok.Data)
+		b.clearFormattingToMarker()
+	case "br":
+		b.parseError("unexpected-end-tag")
+		b.inBodyStartTag(&Token{Type: StartTagToken, Data: "br"})
+	case "template":
+		b.inHeadMode(tok)
+	default:
+		b.anyOtherEndTag(tok)
+	}
+}
+
+// anyOtherEndTag обрабатывает закрывающий тег без специальных правил в режиме «in body»
+func (b *treeBuilder) anyOtherEndTag(tok *Token) {
+	for i := len(b.oe) - 1; i >= 0; i-- {
+		n := b.oe[i]
+		if n.isHTMLElement(tok.Data) {
+			b.generateImpliedEndTags(tok.Data)
+			if b.current() != n {
+				b.parseError("end-tag-too-early")
+			}
+			b.popUntilNode(n)
+			return
+		}
+		if isSpecial(n) {
+			b.parseError("unexpected-end-tag")
+			return
+		}
+	}
+}
+
+func (b *treeBuilder) textMode(tok *Token) {
+	switch tok.Type {
+	case CharacterToken:
+		b.insertText(tok.Data)
+	case EOFToken:
+		b.parseError("eof-in-text")
+		b.pop()
+		b.mode = b.originalMode
+		b.process(tok)
+	case EndTagToken:
+		b.pop()
+		b.mode = b.originalMode
+	}
+}
+
+func (b *treeBuilder) inTableMode(tok *Token) {
+	switch tok.Type {
+	case CharacterToken:
+		if b.currentIs("table", "tbody", "template", "tfoot", "thead", "tr") {
+			b.pendingTableText = b.pendingTableText[:0]
+			b.originalMode = b.mode
+			b.mode = inTableTextMode
+			b.process(tok)
+			return
+		}
+	case CommentToken:
+		b.insertComment(tok, nil)
+		return
+	case DoctypeToken:
+		b.parseError("unexpected-doctype")
+		return
+	case StartTagToken:
+		switch tok.Data {
+		case "caption":
+			b.clearStackToContext("table", "template", "html")
+			b.insertMarker()
+			b.insertElement(tok)
+			b.mode = inCaptionMode
+			return
+		case "colgroup":
+			b.clearStackToContext("table", "template", "html")
+			b.insertElement(tok)
+			b.mode = inColumnGroupMode
+			return
+		case "col":
+			b.clearStackToContext("table", "template", "html")
+			b.insertElementNamed("colgroup")
+			b.mode = inColumnGroupMode
+			b.process(tok)
+			return
+		case "tbody", "tfoot", "thead":
+			b.clearStackToContext("table", "template", "html")
+			b.insertElement(tok)
+			b.mode = inTableBodyMode
+			return
+		case "td", "th", "tr":
+			b.clearStackToContext("table", "template", "html")
+			b.insertElementNamed("tbody")
+			b.mode = inTableBodyMode
+			b.process(tok)
+			return
+		case "table":
+			b.parseError("unexpected-start-tag")
+			if !b.inScope(tableScope, "table") {
+				return
+			}
+			b.popUntil("table")
+			b.resetInsertionMode()
+			b.process(tok)
+			return
+		case "style", "script", "template":
+			b.inHeadMode(tok)
+			return
+		case "input":
+			if isHiddenInput(tok) {
+				b.parseError("unexpected-start-tag")
+				b.insertElement(tok)
+				b.pop()
+				return
+			}
+		case "form":
+			b.parseError("unexpected-start-tag")
+			if b.hasOpen("template") || b.form != nil {
+				return
+			}
+			b.form = b.insertElement(tok)
+			b.pop()
+			return
+		}
+	case EndTagToken:
+		switch tok.Data {
+		case "table":
+			if !b.inScope(tableScope, "table") {
+				b.parseError("unexpected-end-tag")
+				return
+			}
+			b.popUntil("table")
+			b.resetInsertionMode()
+			return
+		case "body", "caption", "col", "colgroup", "html", "tbody", "td", "tfoot", "th", "thead", "tr":
+			b.parseError("unexpected-end-tag")
+			return
+		case "template":
+			b.inHeadMode(tok)
+			return
+		}
+	case EOFToken:
+		b.inBodyMode(tok)
+		return
+	}
+	b.parseError("unexpected-token-in-table")
+	b.fosterParenting = true
+	b.inBodyMode(tok)
+	b.fosterParenting = false
+}
+
+// clearStackToContext снимает элементы со стека до одного из указанных
+func (b *treeBuilder) clearStackToContext(tagNames ...string) {
+	for !b.currentIs(tagNames...) {
+		b.pop()
+	}
+}
+
+func (b *treeBuilder) inTableTextMode(tok *Token) {
+	if tok.Type == CharacterToken {
+		for _, c := range tok.Data {
+			if c == 0 {
+				b.parseError("unexpected-null-character")
+				continue
+			}
+			b.pendingTableText = append(b.pendingTableText, c)
+		}
+		if !b.pendingTableSpan[0].IsValid() {
+			b.pendingTableSpan[0] = tok.Start
+		}
+		b.pendingTableSpan[1] = tok.End
+		return
+	}
+
+	// Накопленный текст вставляется с позициями исходных символьных токенов
+	pending := &Token{Type: CharacterToken, Data: string(b.pendingTableText), Start: b.pendingTableSpan[0], End: b.pendingTableSpan[1]}
+	b.pendingTableText = b.pendingTableText[:0]
+	b.pendingTableSpan = [2]Position{}
+	b.tok = pending
+	if !isAllSpace(pending.Data) {
+		b.parseError("unexpected-character-in-table")
+		b.fosterParenting = true
+		b.inBodyMode(pending)
+		b.fosterParenting = false
+	} else {
+		b.insertText(pending.Data)
+	}
+	b.tok = tok
+	b.mode = b.originalMode
+	b.process(tok)
+}
+
+func (b *treeBuilder) inCaptionMode(tok *Token) {
+	switch tok.Type {
+	case StartTagToken:
+		switch tok.Data {
+		case "caption", "col", "colgroup", "tbody", "td", "tfoot", "th", "thead", "tr":
+			if b.closeCaption() {
+				b.process(tok)
+			}
+			return
+		}
+	case EndTagToken:
+		switch tok.Data {
+		case "caption":
+			b.closeCaption()
+			return
+		case "table":
+			if b.closeCaption() {
+				b.process(tok)
+			}
+			return
+		case "body", "col", "colgroup", "html", "tbody", "td", "tfoot", "th", "thead", "tr":
+			b.parseError("unexpected-end-tag")
+			return
+		}
+	}
+	b.inBodyMode(tok)
+}
+
+// closeCaption закрывает элемент <caption> и возвращается в режим таблицы
+func (b *treeBuilder) closeCaption() bool {
+	if !b.inScope(tableScope, "caption") {
+		b.parseError("unexpected-end-tag")
+		return false
+	}
+	b.generateImpliedEndTags("")
+	if !b.currentIs("caption") {
+		b.parseError("end-tag-too-early")
+	}
+	b.popUntil("caption")
+	b.clearFormattingToMarker()
+	b.mode = inTableMode
+	return true
+}
+
+func (b *treeBuilder) inColumnGroupMode(tok *Token) {
+	switch tok.Type {
+	case CharacterToken:
+		if !processLeadingSpace(tok, b.insertText) {
+			return
+		}
+	case CommentToken:
+		b.insertComment(tok, nil)
+		return
+	case DoctypeToken:
+		b.parseError("unexpected-doctype")
+		return
+	case StartTagToken:
+		switch tok.Data {
+		case "html":
+			b.inBodyMode(tok)
+			return
+		case "col":
+			b.insertElement(tok)
+			b.pop()
+			return
+		case "template":
+			b.inHeadMode(tok)
+			return
+		}
+	case EndTagToken:
+		switch tok.Data {
+		case "colgroup":
+			if !b.currentIs("colgroup") {
+				b.parseError("unexpected-end-tag")
+				return
+			}
+			b.pop()
+			b.mode = inTableMode
+			return
+		case "col":
+			b.parseError("unexpected-end-tag")
+			return
+		case "template":
+			b.inHeadMode(tok)
+			return
+		}
+	case EOFToken:
+		b.inBodyMode(tok)
+		return
+	}
+	if !b.currentIs("colgroup") {
+		b.parseError("unexpected-token-in-column-group")
+		return
+	}
+	b.pop()
+	b.mode = inTableMode
+	b.process(tok)
+}
+
+func (b *treeBuilder) inTableBodyMode(tok *Token) {
+	switch tok.Type {
+	case StartTagToken:
+		switch tok.Data {
+		case "tr":
+			b.clearStackToContext("tbody", "tfoot", "thead", "template", "html")
+			b.insertElement(tok)
+			b.mode = inRowMode
+			return
+		case "th", "td":
+			b.parseError("unexpected-start-tag")
+			b.clearStackToContext("tbody", "tfoot", "thead", "template", "html")
+			b.insertElementNamed("tr")
+			b.mode = inRowMode
+			b.process(tok)
+			return
+		case "caption", "col", "colgroup", "tbody", "tfoot", "thead":
+			if b.closeTableBody() {
+				b.process(tok)
+			}
+			return
+		}
+	case EndTagToken:
+		switch tok.Data {
+		case "tbody", "tfoot", "thead":
+			if !b.inScope(tableScope, tok.Data) {
+				b.parseError("unexpected-end-tag")
+				return
+			}
+			b.clearStackToContext("tbody", "tfoot", "thead", "template", "html")
+			b.pop()
+			b.mode = inTableMode
+			return
+		case "table":
+			if b.closeTableBody() {
+				b.process(tok)
+			}
+			return
+		case "body", "caption", "col", "colgroup", "html", "td", "th", "tr":
+			b.parseError("unexpected-end-tag")
+			return
+		}
+	}
+	b.inTableMode(tok)
+}
+
+// closeTableBody закрывает секцию таблицы и возвращается в режим таблицы
+func (b *treeBuilder) closeTableBody() bool {
+	if !b.inScope(tableScope, "tbody", "thead", "tfoot") {
+		b.parseError("unexpected-token-in-table-body")
+		return false
+	}
+	b.clearStackToContext("tbody", "tfoot", "thead", "template", "html")
+	b.pop()
+	b.mode = inTableMode
+	return true
+}
+
+func (b *treeBuilder) inRowMode(tok *Token) {
+	switch tok.Type {
+	case StartTagToken:
+		switch tok.Data {
+		case "th", "td":
+			b.clearStackToContext("tr", "template", "html")
+			b.insertElement(tok)
+			b.mode = inCellMode
+			b.insertMarker()
+			return
+		case "caption", "col", "colgroup", "tbody", "tfoot", "thead", "tr":
+			if b.closeRow() {
+				b.process(tok)
+			}
+			return
+		}
+	case EndTagToken:
+		switch tok.Data {
+		case "tr":
+			b.closeRow()
+			return
+		case "table":
+			if b.closeRow() {
+				b.process(tok)
+			}
+			return
+		case "tbody", "tfoot", "thead":
+			if !b.inScope(tableScope, tok.Data) {
+				b.parseError("unexpected-end-tag")
+				return
+			}
+			if b.closeRow() {
+				b.process(tok)
+			}
+			return
+		case "body", "caption", "col", "colgroup", "html", "td", "th":
+			b.parseError("unexpected-end-tag")
+			return
+		}
+	}
+	b.inTableMode(tok)
+}
+
+// closeRow закрывает строку таблицы
+func (b *treeBuilder) closeRow() bool {
+	if !b.inScope(tableScope, "tr") {
+		b.parseError("unexpected-end-tag")
+		return false
+	}
+	b.clearStackToContext("tr", "template", "html")
+	b.pop()
+	b.mode = inTableBodyMode
+	return true
+}
+
+func (b *treeBuilder) inCellMode(tok *Token) {
+	switch tok.Type {
+	case StartTagToken:
+		switch tok.Data {
+		case "caption", "col", "colgroup", "tbody", "td", "tfoot", "th", "thead", "tr":
+			if !b.inScope(tableScope, "td", "th") {
+				b.parseError("unexpected-start-tag")
+				return
+			}
+			b.closeCell()
+			b.process(tok)
+			return
+		}
+	case EndTagToken:
+		switch tok.Data {
+		case "td", "th":
+			if !b.inScope(tableScope, tok.Data) {
+				b.parseError("unexpected-end-tag")
+				return
+			}
+			b.generateImpliedEndTags("")
+			if !b.currentIs(tok.Data) {
+				b.parseError("end-tag-too-early")
+			}
+			b.popUntil(tok.Data)
+			b.clearFormattingToMarker()
+			b.mode = inRowMode
+			return
+		case "body", "caption", "col", "colgroup", "html":
+			b.parseError("unexpected-end-tag")
+			return
+		case "table", "tbody", "tfoot", "thead", "tr":
+			if !b.inScope(tableScope, tok.Data) {
+				b.parseError("unexpected-end-tag")
+				return
+			}
+			b.closeCell()
+			b.process(tok)
+			return
+		}
+	}
+	b.inBodyMode(tok)
+}
+
+// closeCell закрывает ячейку таблицы
+func (b *treeBuilder) closeCell() {
+	b.generateImpliedEndTags("")
+	if !b.currentIs("td", "th") {
+		b.parseError("end-tag-too-early")
+	}
+	b.popUntil("td", "th")
+	b.clearFormattingToMarker()
+	b.mode = inRowMode
+}
+
+func (b *treeBuilder) inTemplateMode(tok *Token) {
+	switch tok.Type {
+	case CharacterToken, CommentToken, DoctypeToken:
+		b.inBodyMode(tok)
+	case StartTagToken:
+		switch tok.Data {
+		case "base", "basefont", "bgsound", "link", "meta", "noframes", "script", "style", "template", "title":
+			b.inHeadMode(tok)
+		case "caption", "colgroup", "tbody", "tfoot", "thead":
+			b.switchTemplateMode(inTableMode, tok)
+		case "col":
+			b.switchTemplateMode(inColumnGroupMode, tok)
+		case "tr":
+			b.switchTemplateMode(inTableBodyMode, tok)
+		case "td", "th":
+			b.switchTemplateMode(inRowMode, tok)
+		default:
+			b.switchTemplateMode(inBodyMode, tok)
+		}
+	case EndTagToken:
+		if tok.Data == "template" {
+			b.inHeadMode(tok)
+			return
+		}
+		b.parseError("unexpected-end-tag")
+	case EOFToken:
+		if !b.hasOpen("template") {
+			return
+		}
+		b.parseError("eof-in-template")
+		b.popUntil("template")
+		b.clearFormattingToMarker()
+		b.popTemplateMode()
+		b.resetInsertionMode()
+		b.process(tok)
+	}
+}
+
+// switchTemplateMode заменяет текущий режим вставки шаблона на mode и
+// обрабатывает токен в нем
+func (b *treeBuilder) switchTemplateMode(mode insertionMode, tok *Token) {
+	b.popTemplateMode()
+	b.templateModes = append(b.templateModes, mode)
+	b.mode = mode
+	b.process(tok)
+}
+
+// popTemplateMode снимает режим вставки с вершины стека режимов шаблонов
+func (b *treeBuilder) popTemplateMode() {
+	if len(b.templateModes) > 0 {
+		b.templateModes = b.templateModes[:len(b.templateModes)-1]
+	}
+}
+
+func (b *treeBuilder) afterBodyMode(tok *Token) {
+	switch tok.Type {
+	case CharacterToken:
+		if !processLeadingSpace(tok, func(space string) {
+			b.inBodyMode(&Token{Type: CharacterToken, Data: space})
+		}) {
+			return
+		}
+	case CommentToken:
+		b.insertComment(tok, b.oe[0])
+		return
+	case DoctypeToken:
+		b.parseError("unexpected-doctype")
+		return
+	case StartTagToken:
+		if tok.Data == "html" {
+			b.inBodyMode(tok)
+			return
+		}
+	case EndTagToken:
+		if tok.Data == "html" {
+			if b.context != nil {
+				b.parseError("unexpected-end-tag")
+				return
+			}
+			b.mode = afterAfterBodyMode
+			return
+		}
+	case EOFToken:
+		return
+	}
+	b.parseError("unexpected-token-after-body")
+	b.mode = inBodyMode
+	b.process(tok)
+}
+
+func (b *treeBuilder) inFramesetMode(tok *Token) {
+	switch tok.Type {
+	case CharacterToken:
+		b.insertFramesetSpace(tok.Data)
+	case CommentToken:
+		b.insertComment(tok, nil)
+	case DoctypeToken:
+		b.parseError("unexpected-doctype")
+	case StartTagToken:
+		switch tok.Data {
+		case "html":
+			b.inBodyMode(tok)
+		case "frameset":
+			b.insertElement(tok)
+		case "frame":
+			b.insertElement(tok)
+			b.pop()
+		case "noframes":
+			b.inHeadMode(tok)
+		default:
+			b.parseError("unexpected-start-tag")
+		}
+	case EndTagToken:
+		if tok.Data != "frameset" {
+			b.parseError("unexpected-end-tag")
+			return
+		}
+		if b.currentIs("html") {
+			b.parseError("unexpected-end-tag")
+			return
+		}
+		b.pop()
+		if b.context == nil && !b.currentIs("frameset") {
+			b.mode = afterFramesetMode
+		}
+	case EOFToken:
+		if !b.currentIs("html") {
+			b.parseError("eof-in-frameset")
+		}
+	}
+}
+
+// insertFramesetSpace вставляет только пробельные символы, остальные игнорирует
+func (b *treeBuilder) insertFramesetSpace(text string) {
+	var sb strings.Builder
+	for _, c := range text {
+		if isHTMLSpace(c) {
+			sb.WriteRune(c)
+		} else {
+			b.parseError("unexpected-character-in-frameset")
+		}
+	}
+	b.insertText(sb.String())
+}
+
+func (b *treeBuilder) afterFramesetMode(tok *Token) {
+	switch tok.Type {
+	case CharacterToken:
+		b.insertFramesetSpace(tok.Data)
+	case CommentToken:
+		b.insertComment(tok, nil)
+	case DoctypeToken:
+		b.parseError("unexpected-doctype")
+	case StartTagToken:
+		switch tok.Data {
+		case "html":
+			b.inBodyMode(tok)
+		case "noframes":
+			b.inHeadMode(tok)
+		default:
+			b.parseError("unexpected-start-tag")
+		}
+	case EndTagToken:
+		if tok.Data == "html" {
+			b.mode = afterAfterFramesetMode
+			return
+		}
+		b.parseError("unexpected-end-tag")
+	}
+}
+
+func (b *treeBuilder) afterAfterBodyMode(tok *Token) {
+	switch tok.Type {
+	case CommentToken:
+		b.insertComment(tok, b.doc)
+		return
+	case DoctypeToken:
+		b.inBodyMode(tok)
+		return
+	case CharacterToken:
+		if !processLeadingSpace(tok, func(space string) {
+			b.inBodyMode(&Token{Type: CharacterToken, Data: space})
+		}) {
+			return
+		}
+	case StartTagToken:
+		if tok.Data == "html" {
+			b.inBodyMode(tok)
+			return
+		}
+	case EOFToken:
+		return
+	}
+	b.parseError("unexpected-token-after-body")
+	b.mode = inBodyMode
+	b.process(tok)
+}
+
+func (b *treeBuilder) afterAfterFramesetMode(tok *Token) {
+	switch tok.Type {
+	case CommentToken:
+		b.insertComment(tok, b.doc)
+	case DoctypeToken:
+		b.inBodyMode(tok)
+	case CharacterToken:
+		var sb strings.Builder
+		for _, c := range tok.Data {
+			if isHTMLSpace(c) {
+				sb.WriteRune(c)
+			} else {
+				b.parseError("unexpected-character-after-frameset")
+			}
+		}
+		if sb.Len() > 0 {
+			b.inBodyMode(&Token{Type: CharacterToken, Data: sb.String()})
+		}
+	case StartTagToken:
+		switch tok.Data {
+		case "html":
+			b.inBodyMode(tok)
+		case "noframes":
+			b.inHeadMode(tok)
+		default:
+			b.parseError("unexpected-start-tag")
+		}
+	case EndTagToken:
+		b.parseError("unexpected-end-tag")
+	}
+}
+
+// Определение режима совместимости по DOCTYPE
+
+var quirkyPublicIDPrefixes = []string{
+	"+//silmaril//dtd html pro v0r11 19970101//",
+	"-//as//dtd html 3.0 aswedit + extensions//",
+	"-//advasoft ltd//dtd html 3.0 aswedit + extensions//",
+	"-//ietf//dtd html 2.0 level 1//",
+	"-//ietf//dtd html 2.0 level 2//",
+	"-//ietf//dtd html 2.0 strict level 1//",
+	"-//ietf//dtd html 2.0 strict level 2//",
+	"-//ietf//dtd html 2.0 strict//",
+	"-//ietf//dtd html 2.0//",
+	"-//ietf//dtd html 2.1e//",
+	"-//ietf//dtd html 3.0//",
+	"-//ietf//dtd html 3.2 final//",
+	"-//ietf//dtd html 3.2//",
+	"-//ietf//dtd html 3//",
+	"-//ietf//dtd html level 0//",
+	"-//ietf//dtd html level 1//",
+	"-//ietf//dtd html level 2//",
+	"-//ietf//dtd html level 3//",
+	"-//ietf//dtd html strict level 0//",
+	"-//ietf//dtd html strict level 1//",
+	"-//ietf//dtd html strict level 2//",
+	"-//ietf//dtd html strict level 3//",
+	"-//ietf//dtd html strict//",
+	"-//ietf//dtd html//",
+	"-//metrius//dtd metrius presentational//",
+	"-//microsoft//dtd internet explorer 2.0 html strict//",
+	"-//microsoft//dtd internet explorer 2.0 html//",
+	"-//microsoft//dtd internet explorer 2.0 tables//",
+	"-//microsoft//dtd internet explorer 3.0 html strict//",
+	"-//microsoft//dtd internet explorer 3.0 html//",
+	"-//microsoft//dtd internet explorer 3.0 tables//",
+	"-//netscape comm. corp.//dtd html//",
+	"-//netscape comm. corp.//dtd strict html//",
+	"-//o'reilly and associates//dtd html 2.0//",
+	"-//o'reilly and associates//dtd html extended 1.0//",
+	"-//o'reilly and associates//dtd html extended relaxed 1.0//",
+	"-//sq//dtd html 2.0 hotmetal + extensions//",
+	"-//softquad software//dtd hotmetal pro 6.0::19990601::extensions to html 4.0//",
+	"-//softquad//dtd hotmetal pro 4.0::19971010::extensions to html 4.0//",
+	"-//spyglass//dtd html 2.0 extended//",
+	"-//sun microsystems corp.//dtd hotjava html//",
+	"-//sun microsystems corp.//dtd hotjava strict html//",
+	"-//w3c//dtd html 3 1995-03-24//",
+	"-//w3c//dtd html 3.2 draft//",
+	"-//w3c//dtd html 3.2 final//",
+	"-//w3c//dtd html 3.2//",
+	"-//w3c//dtd html 3.2s draft//",
+	"-//w3c//dtd html 4.0 frameset//",
+	"-//w3c//dtd html 4.0 transitional//",
+	"-//w3c//dtd html experimental 19960712//",
+	"-//w3c//dtd html experimental 970421//",
+	"-//w3c//dtd w3 html//",
+	"-//w3o//dtd w3 html 3.0//",
+	"-//webtechs//dtd mozilla html 2.0//",
+	"-//webtechs//dtd mozilla html//",
+}
+
+// setQuirksMode задает режим совместимости и отмечает его на документе
+func (b *treeBuilder) setQuirksMode(mode quirksMode) {
+	b.quirks = mode
+	b.doc.Quirks = mode == quirks
+}
+
+// doctypeQuirksMode определяет режим совместимости по токену DOCTYPE
+func doctypeQuirksMode(tok *Token) quirksMode {
+	publicID := strings.ToLower(tok.PublicID)
+	systemID := strings.ToLower(tok.SystemID)
+	if tok.ForceQuirks || tok.Data != "html" {
+		return quirks
+	}
+	switch publicID {
+	case "-//w3o//dtd w3 html strict 3.0//en//", "-/w3c/dtd html 4.0 transitional/en", "html":
+		return quirks
+	}
+	if systemID == "http://www.ibm.com/data/dtd/v11/ibmxhtml1-transitional.dtd" {
+		return quirks
+	}
+	for _, prefix := range quirkyPublicIDPrefixes {
+		if strings.HasPrefix(publicID, prefix) {
+			return quirks
+		}
+	}
+	html401 := strings.HasPrefix(publicID, "-//w3c//dtd html 4.01 frameset//") ||
+		strings.HasPrefix(publicID, "-//w3c//dtd html 4.01 transitional//")
+	if html401 && !tok.HasSystemID {
+		return quirks
+	}
+	if strings.HasPrefix(publicID, "-//w3c//dtd xhtml 1.0 frameset//") ||
+		strings.HasPrefix(publicID, "-//w3c//dtd xhtml 1.0 transitional//") {
+		return limitedQuirks
+	}
+	if html401 && tok.HasSystemID {
+		return limitedQuirks
+	}
+	return noQuirks
+}
@@ -0,0 +1,42 @@
+package html
+
+import (
+	"github.com/baneronetwo/gluglu/internal/browser/weburl"
+)
+
+// BaseURL возвращает базовый адрес документа: href первого элемента <base>
+// с этим атрибутом, разрешенный относительно адреса документа. Если такого
+// элемента нет или его адрес некорректен, базовым считается адрес документа
+func (d *Document) BaseURL() *weburl.URL {
+	for _, base := range d.FindElementsByTagName("base") {
+		href, ok := base.LookupAttribute("href")
+		if !ok || !base.IsHTML() {
+			continue
+		}
+		if u, err := weburl.Parse(href, d.URL); err == nil {
+			return u
+		}
+		break
+	}
+	return d.URL
+}
+
+// ResolveURL разрешает адрес ref относительно базового адреса документа
+func (d *Document) ResolveURL(ref string) (*weburl.URL, error) {
+	return weburl.Parse(ref, d.BaseURL())
+}
+
+// ResolveAttribute возвращает значение атрибута-адреса элемента, разрешенное
+// относительно базового адреса документа. Если атрибута нет, возвращается
+// пустая строка, а если адрес не разбирается — значение атрибута как есть
+func (d *Document) ResolveAttribute(n *Node, attr string) string {
+	value, ok := n.LookupAttribute(attr)
+	if !ok {
+		return ""
+	}
+	u, err := d.ResolveURL(value)
+	if err != nil {
+		return value
+	}
+	return u.String()
+}
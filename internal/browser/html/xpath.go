@@ -0,0 +1,612 @@
+package html
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// XPathExpr — скомпилированное выражение XPath 1.0
+type XPathExpr struct {
+	text string
+	root xpathExpr
+}
+
+// xpathCacheLimit ограничивает число скомпилированных выражений в кэше
+const xpathCacheLimit = 512
+
+var (
+	xpathCacheMu sync.Mutex
+	xpathCache   = make(map[string]*XPathExpr)
+)
+
+// CompileXPath разбирает выражение XPath 1.0. Результаты кэшируются
+func CompileXPath(text string) (*XPathExpr, error) {
+	xpathCacheMu.Lock()
+	expr, ok := xpathCache[text]
+	xpathCacheMu.Unlock()
+	if ok {
+		return expr, nil
+	}
+
+	tokens, err := lexXPath(text)
+	if err != nil {
+		return nil, fmt.Errorf("неверное выражение XPath %q: %w", text, err)
+	}
+	p := &xpathParser{tokens: tokens}
+	root, err := p.parseExpr()
+	if err == nil && p.peek().kind != xpathEOF {
+		err = p.errorf("неожиданная лексема %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("неверное выражение XPath %q: %w", text, err)
+	}
+	expr = &XPathExpr{text: text, root: root}
+
+	xpathCacheMu.Lock()
+	if len(xpathCache) >= xpathCacheLimit {
+		xpathCache = make(map[string]*XPathExpr)
+	}
+	xpathCache[text] = expr
+	xpathCacheMu.Unlock()
+
+	return expr, nil
+}
+
+// String возвращает исходный текст выражения
+func (e *XPathExpr) String() string {
+	return e.text
+}
+
+// xpathTokenKind — вид лексемы XPath
+type xpathTokenKind int
+
+const (
+	xpathEOF xpathTokenKind = iota
+	xpathName
+	xpathNumber
+	xpathLiteral
+	xpathVariable
+	xpathOperator
+)
+
+// xpathToken — лексема выражения XPath
+type xpathToken struct {
+	kind xpathTokenKind
+	text string
+	pos  int
+}
+
+// lexXPath разбивает выражение на лексемы
+func lexXPath(text string) ([]xpathToken, error) {
+	input := []rune(text)
+	var tokens []xpathToken
+	for i := 0; i < len(input); {
+		c := input[i]
+		start := i
+		switch {
+		case isHTMLSpace(c):
+			i++
+			continue
+
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(input) && input[end] != c {
+				end++
+			}
+			if end >= len(input) {
+				return nil, fmt.Errorf("позиция %d: незакрытая строка", start)
+			}
+			tokens = append(tokens, xpathToken{xpathLiteral, string(input[i+1 : end]), start})
+			i = end + 1
+
+		case isASCIIDigit(c) || c == '.' && i+1 < len(input) && isASCIIDigit(input[i+1]):
+			for i < len(input) && isASCIIDigit(input[i]) {
+				i++
+			}
+			if i < len(input) && input[i] == '.' {
+				i++
+				for i < len(input) && isASCIIDigit(input[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, xpathToken{xpathNumber, string(input[start:i]), start})
+
+		case c == '$':
+			i++
+			name := scanXPathQName(input, &i)
+			if name == "" {
+				return nil, fmt.Errorf("позиция %d: ожидалось имя переменной", start)
+			}
+			tokens = append(tokens, xpathToken{xpathVariable, name, start})
+
+		case isXPathNameStart(c):
+			name := scanXPathQName(input, &i)
+			// Проверка шаблона prefix:*
+			if i+1 < len(input) && input[i] == ':' && input[i+1] == '*' && !strings.Contains(name, ":") {
+				name += ":*"
+				i += 2
+			}
+			tokens = append(tokens, xpathToken{xpathName, name, start})
+
+		default:
+			op := string(c)
+			if i+1 < len(input) {
+				switch two := string(input[i : i+2]); two {
+				case "//", "..", "::", "!=", "<=", ">=":
+					op = two
+				}
+			}
+			if !strings.Contains("/()[].@,:|+-=!<>*", op[:1]) || op == "!" || op == ":" {
+				return nil, fmt.Errorf("позиция %d: неожиданный символ %q", start, c)
+			}
+			tokens = append(tokens, xpathToken{xpathOperator, op, start})
+			i += len([]rune(op))
+		}
+	}
+	tokens = append(tokens, xpathToken{kind: xpathEOF, pos: len(input)})
+	return tokens, nil
+}
+
+// scanXPathQName читает имя вида NCName или prefix:NCName
+func scanXPathQName(input []rune, i *int) string {
+	start := *i
+	for *i < len(input) && isXPathNameChar(input[*i]) {
+		*i++
+	}
+	if *i+1 < len(input) && input[*i] == ':' && isXPathNameStart(input[*i+1]) && *i > start {
+		*i++
+		for *i < len(input) && isXPathNameChar(input[*i]) {
+			*i++
+		}
+	}
+	return string(input[start:*i])
+}
+
+func isXPathNameStart(c rune) bool {
+	return isASCIIAlpha(c) || c == '_' || c >= 0xC0
+}
+
+func isXPathNameChar(c rune) bool {
+	return isXPathNameStart(c) || isASCIIDigit(c) || c == '-' || c == '.' || c == 0xB7
+}
+
+// xpathExpr — узел синтаксического дерева выражения
+type xpathExpr interface{}
+
+// xpathBinary — бинарная операция (or, and, =, !=, <, <=, >, >=, +, -, *, div, mod, |)
+type xpathBinary struct {
+	op          string
+	left, right xpathExpr
+}
+
+// xpathNegate — унарный минус
+type xpathNegate struct {
+	expr xpathExpr
+}
+
+// xpathLiteralExpr — строковая константа
+type xpathLiteralExpr string
+
+// xpathNumberExpr — числовая константа
+type xpathNumberExpr float64
+
+// xpathVariableExpr — ссылка на переменную
+type xpathVariableExpr string
+
+// xpathCall — вызов функции
+type xpathCall struct {
+	name string
+	args []xpathExpr
+}
+
+// xpathFilter — первичное выражение с предикатами
+type xpathFilter struct {
+	primary    xpathExpr
+	predicates []xpathExpr
+}
+
+// xpathPath — путь: начальная точка (корень, выражение-фильтр или
+// контекстный узел) и шаги
+type xpathPath struct {
+	absolute bool
+	filter   xpathExpr
+	steps    []xpathStep
+}
+
+// xpathStep — шаг пути: ось, проверка узла и предикаты
+type xpathStep struct {
+	axis       string
+	test       xpathNodeTest
+	predicates []xpathExpr
+}
+
+// xpathNodeTest — проверка узла: по имени ("*", "prefix:*", имя) или по типу
+type xpathNodeTest struct {
+	// kind: "name", "node", "text", "comment", "processing-instruction"
+	kind string
+	name string
+}
+
+var xpathAxes = map[string]bool{
+	"ancestor": true, "ancestor-or-self": true, "attribute": true,
+	"child": true, "descendant": true, "descendant-or-self": true,
+	"following": true, "following-sibling": true, "namespace": true,
+	"parent": true, "preceding": true, "preceding-sibling": true, "self": true,
+}
+
+var xpathNodeTypes = map[string]bool{
+	"node": true, "text": true, "comment": true, "processing-instruction": true,
+}
+
+// xpathParser — парсер выражений XPath методом рекурсивного спуска
+type xpathParser struct {
+	tokens []xpathToken
+	pos    int
+}
+
+func (p *xpathParser) peek() xpathToken {
+	return p.tokens[p.pos]
+}
+
+func (p *xpathParser) peekAt(i int) xpathToken {
+	if p.pos+i >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+i]
+}
+
+func (p *xpathParser) next() xpathToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != xpathEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *xpathParser) isOp(op string) bool {
+	tok := p.peek()
+	return tok.kind == xpathOperator && tok.text == op
+}
+
+// isOperatorName проверяет, является ли текущая лексема оператором-словом
+// (and, or, div, mod). В позиции оператора такие имена не могут быть шагом пути
+func (p *xpathParser) isOperatorName(name string) bool {
+	tok := p.peek()
+	return tok.kind == xpathName && tok.text == name
+}
+
+func (p *xpathParser) expect(op string) error {
+	if !p.isOp(op) {
+		return p.errorf("ожидалось %q", op)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *xpathParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("позиция %d: %s", p.peek().pos, fmt.Sprintf(format, args...))
+}
+
+func (p *xpathParser) parseExpr() (xpathExpr, error) {
+	return p.parseOr()
+}
+
+func (p *xpathParser) parseOr() (xpathExpr, error) {
+	return p.parseBinary(p.parseAnd, func() string {
+		if p.isOperatorName("or") {
+			return "or"
+		}
+		return ""
+	})
+}
+
+func (p *xpathParser) parseAnd() (xpathExpr, error) {
+	return p.parseBinary(p.parseEquality, func() string {
+		if p.isOperatorName("and") {
+			return "and"
+		}
+		return ""
+	})
+}
+
+func (p *xpathParser) parseEquality() (xpathExpr, error) {
+	return p.parseBinary(p.parseRelational, func() string {
+		if p.isOp("=") || p.isOp("!=") {
+			return p.peek().text
+		}
+		return ""
+	})
+}
+
+func (p *xpathParser) parseRelational() (xpathExpr, error) {
+	return p.parseBinary(p.parseAdditive, func() string {
+		if p.isOp("<") || p.isOp("<=") || p.isOp(">") || p.isOp(">=") {
+			return p.peek().text
+		}
+		return ""
+	})
+}
+
+func (p *xpathParser) parseAdditive() (xpathExpr, error) {
+	return p.parseBinary(p.parseMultiplicative, func() string {
+		if p.isOp("+") || p.isOp("-") {
+			return p.peek().text
+		}
+		return ""
+	})
+}
+
+func (p *xpathParser) parseMultiplicative() (xpathExpr, error) {
+	return p.parseBinary(p.parseUnary, func() string {
+		switch {
+		case p.isOp("*"):
+			return "*"
+		case p.isOperatorName("div"):
+			return "div"
+		case p.isOperatorName("mod"):
+			return "mod"
+		}
+		return ""
+	})
+}
+
+// parseBinary разбирает левоассоциативную цепочку операций одного приоритета
+func (p *xpathParser) parseBinary(operand func() (xpathExpr, error), operator func() string) (xpathExpr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := operator()
+		if op == "" {
+			return left, nil
+		}
+		p.pos++
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &xpathBinary{op: op, left: left, right: right}
+	}
+}
+
+func (p *xpathParser) parseUnary() (xpathExpr, error) {
+	if p.isOp("-") {
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &xpathNegate{expr: expr}, nil
+	}
+	return p.parseUnion()
+}
+
+func (p *xpathParser) parseUnion() (xpathExpr, error) {
+	return p.parseBinary(p.parsePath, func() string {
+		if p.isOp("|") {
+			return "|"
+		}
+		return ""
+	})
+}
+
+// parsePath разбирает путь или выражение-фильтр
+func (p *xpathParser) parsePath() (xpathExpr, error) {
+	if p.startsFilter() {
+		primary, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		predicates, err := p.parsePredicates()
+		if err != nil {
+			return nil, err
+		}
+		var filter xpathExpr = primary
+		if len(predicates) > 0 {
+			filter = &xpathFilter{primary: primary, predicates: predicates}
+		}
+		if !p.isOp("/") && !p.isOp("//") {
+			return filter, nil
+		}
+		path := &xpathPath{filter: filter}
+		return path, p.parseRelativePath(path)
+	}
+
+	path := &xpathPath{}
+	switch {
+	case p.isOp("/"):
+		p.pos++
+		path.absolute = true
+		// Одиночный "/" — корень документа
+		if !p.startsStep() {
+			return path, nil
+		}
+		return path, p.parseSteps(path)
+	case p.isOp("//"):
+		path.absolute = true
+		return path, p.parseRelativePath(path)
+	}
+	return path, p.parseSteps(path)
+}
+
+// startsFilter проверяет, начинается ли с текущей лексемы первичное выражение
+func (p *xpathParser) startsFilter() bool {
+	tok := p.peek()
+	switch tok.kind {
+	case xpathLiteral, xpathNumber, xpathVariable:
+		return true
+	case xpathOperator:
+		return tok.text == "("
+	case xpathName:
+		next := p.peekAt(1)
+		return next.kind == xpathOperator && next.text == "(" && !xpathNodeTypes[tok.text]
+	}
+	return false
+}
+
+// startsStep проверяет, начинается ли с текущей лексемы шаг пути
+func (p *xpathParser) startsStep() bool {
+	tok := p.peek()
+	switch tok.kind {
+	case xpathName:
+		return true
+	case xpathOperator:
+		return tok.text == "*" || tok.text == "@" || tok.text == "." || tok.text == ".."
+	}
+	return false
+}
+
+// parseRelativePath разбирает "/" или "//" и следующие за ними шаги
+func (p *xpathParser) parseRelativePath(path *xpathPath) error {
+	if p.isOp("//") {
+		p.pos++
+		path.steps = append(path.steps, xpathStep{axis: "descendant-or-self", test: xpathNodeTest{kind: "node"}})
+	} else if err := p.expect("/"); err != nil {
+		return err
+	}
+	return p.parseSteps(path)
+}
+
+// parseSteps разбирает шаги, разделенные "/" и "//"
+func (p *xpathParser) parseSteps(path *xpathPath) error {
+	for {
+		step, err := p.parseStep()
+		if err != nil {
+			return err
+		}
+		path.steps = append(path.steps, step)
+
+		switch {
+		case p.isOp("/"):
+			p.pos++
+		case p.isOp("//"):
+			p.pos++
+			path.steps = append(path.steps, xpathStep{axis: "descendant-or-self", test: xpathNodeTest{kind: "node"}})
+		default:
+			return nil
+		}
+	}
+}
+
+// parseStep разбирает один шаг пути
+func (p *xpathParser) parseStep() (xpathStep, error) {
+	switch {
+	case p.isOp("."):
+		p.pos++
+		return xpathStep{axis: "self", test: xpathNodeTest{kind: "node"}}, nil
+	case p.isOp(".."):
+		p.pos++
+		return xpathStep{axis: "parent", test: xpathNodeTest{kind: "node"}}, nil
+	}
+
+	step := xpathStep{axis: "child"}
+	if p.isOp("@") {
+		p.pos++
+		step.axis = "attribute"
+	} else if tok := p.peek(); tok.kind == xpathName && p.peekAt(1).kind == xpathOperator && p.peekAt(1).text == "::" {
+		if !xpathAxes[tok.text] {
+			return step, p.errorf("неизвестная ось %q", tok.text)
+		}
+		step.axis = tok.text
+		p.pos += 2
+	}
+
+	start := p.pos
+	tok := p.next()
+	switch {
+	case tok.kind == xpathOperator && tok.text == "*":
+		step.test = xpathNodeTest{kind: "name", name: "*"}
+	case tok.kind == xpathName && xpathNodeTypes[tok.text] && p.isOp("("):
+		p.pos++
+		step.test = xpathNodeTest{kind: tok.text}
+		if tok.text == "processing-instruction" && p.peek().kind == xpathLiteral {
+			step.test.name = p.next().text
+		}
+		if err := p.expect(")"); err != nil {
+			return step, err
+		}
+	case tok.kind == xpathName:
+		step.test = xpathNodeTest{kind: "name", name: tok.text}
+	default:
+		// На конце выражения next не сдвигает позицию, поэтому возвращаемся
+		// к сохраненной, а не на одну лексему назад
+		p.pos = start
+		return step, p.errorf("ожидался шаг пути")
+	}
+
+	var err error
+	step.predicates, err = p.parsePredicates()
+	return step, err
+}
+
+// parsePredicates разбирает предикаты в квадратных скобках
+func (p *xpathParser) parsePredicates() ([]xpathExpr, error) {
+	var predicates []xpathExpr
+	for p.isOp("[") {
+		p.pos++
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, expr)
+	}
+	return predicates, nil
+}
+
+// parsePrimary разбирает первичное выражение
+func (p *xpathParser) parsePrimary() (xpathExpr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case xpathLiteral:
+		return xpathLiteralExpr(tok.text), nil
+	case xpathNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("неверное число %q", tok.text)
+		}
+		return xpathNumberExpr(f), nil
+	case xpathVariable:
+		return xpathVariableExpr(tok.text), nil
+	case xpathOperator:
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(")")
+	}
+
+	// Вызов функции
+	fn, ok := xpathFunctions[tok.text]
+	if !ok {
+		p.pos--
+		return nil, p.errorf("неизвестная функция %s()", tok.text)
+	}
+	p.pos++
+	call := &xpathCall{name: tok.text}
+	if !p.isOp(")") {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if !p.isOp(",") {
+				break
+			}
+			p.pos++
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if len(call.args) < fn.minArgs || fn.maxArgs >= 0 && len(call.args) > fn.maxArgs {
+		return nil, fmt.Errorf("неверное число аргументов функции %s()", tok.text)
+	}
+	return call, nil
+}
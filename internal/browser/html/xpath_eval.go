@@ -0,0 +1,639 @@
+package html
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// XPathResultType — тип результата выражения XPath
+type XPathResultType int
+
+const (
+	// XPathNodeSet — набор узлов в порядке документа
+	XPathNodeSet XPathResultType = iota
+	// XPathString — строка
+	XPathString
+	// XPathNumber — число
+	XPathNumber
+	// XPathBoolean — логическое значение
+	XPathBoolean
+)
+
+// XPathResult — результат вычисления выражения XPath
+type XPathResult struct {
+	Type    XPathResultType
+	Nodes   []*Node
+	String  string
+	Number  float64
+	Boolean bool
+}
+
+// StringValue приводит результат к строке по правилам функции string()
+func (r *XPathResult) StringValue() string {
+	return xpathToString(r.value())
+}
+
+// NumberValue приводит результат к числу по правилам функции number()
+func (r *XPathResult) NumberValue() float64 {
+	return xpathToNumber(r.value())
+}
+
+// BooleanValue приводит результат к логическому значению по правилам функции boolean()
+func (r *XPathResult) BooleanValue() bool {
+	return xpathToBoolean(r.value())
+}
+
+func (r *XPathResult) value() interface{} {
+	switch r.Type {
+	case XPathString:
+		return r.String
+	case XPathNumber:
+		return r.Number
+	case XPathBoolean:
+		return r.Boolean
+	}
+	return r.Nodes
+}
+
+// Evaluate вычисляет выражение XPath относительно узла
+func (n *Node) Evaluate(expr string) (*XPathResult, error) {
+	compiled, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return compiled.Evaluate(n)
+}
+
+// SelectNodes вычисляет выражение XPath и возвращает набор узлов
+func (n *Node) SelectNodes(expr string) ([]*Node, error) {
+	result, err := n.Evaluate(expr)
+	if err != nil {
+		return nil, err
+	}
+	if result.Type != XPathNodeSet {
+		return nil, fmt.Errorf("выражение XPath %q возвращает не набор узлов", expr)
+	}
+	return result.Nodes, nil
+}
+
+// xpathError — ошибка вычисления, прерывающая обход дерева выражения
+type xpathError struct {
+	err error
+}
+
+// Evaluate вычисляет выражение относительно узла context
+func (e *XPathExpr) Evaluate(context *Node) (result *XPathResult, err error) {
+	// Ошибки типов обнаруживаются глубоко в рекурсии, поэтому передаются
+	// паникой и превращаются в обычную ошибку здесь
+	defer func() {
+		if r := recover(); r != nil {
+			xerr, ok := r.(xpathError)
+			if !ok {
+				panic(r)
+			}
+			result, err = nil, fmt.Errorf("ошибка вычисления XPath %q: %w", e.text, xerr.err)
+		}
+	}()
+
+	ev := &xpathEvaluator{attributes: make(map[*Node][]*Node)}
+	value := ev.eval(e.root, xpathContext{node: context, position: 1, size: 1})
+
+	switch v := value.(type) {
+	case []*Node:
+		return &XPathResult{Type: XPathNodeSet, Nodes: v}, nil
+	case string:
+		return &XPathResult{Type: XPathString, String: v}, nil
+	case float64:
+		return &XPathResult{Type: XPathNumber, Number: v}, nil
+	case bool:
+		return &XPathResult{Type: XPathBoolean, Boolean: v}, nil
+	}
+	return nil, fmt.Errorf("неизвестный тип результата XPath")
+}
+
+func xpathFail(format string, args ...interface{}) {
+	panic(xpathError{fmt.Errorf(format, args...)})
+}
+
+// xpathContext — контекст вычисления: узел, позиция и размер
+type xpathContext struct {
+	node     *Node
+	position int
+	size     int
+}
+
+// xpathEvaluator хранит состояние одного вычисления
+type xpathEvaluator struct {
+	// attributes — узлы атрибутов элементов, создаваемые по требованию, чтобы
+	// один атрибут всегда был представлен одним узлом
+	attributes map[*Node][]*Node
+	// order — позиции узлов в порядке документа
+	order map[*Node]int
+}
+
+func (ev *xpathEvaluator) eval(expr xpathExpr, ctx xpathContext) interface{} {
+	switch e := expr.(type) {
+	case xpathLiteralExpr:
+		return string(e)
+	case xpathNumberExpr:
+		return float64(e)
+	case xpathVariableExpr:
+		xpathFail("переменная $%s не определена", string(e))
+	case *xpathNegate:
+		return -xpathToNumber(ev.eval(e.expr, ctx))
+	case *xpathBinary:
+		return ev.evalBinary(e, ctx)
+	case *xpathCall:
+		return ev.call(e, ctx)
+	case *xpathFilter:
+		nodes := ev.nodeSet(ev.eval(e.primary, ctx), "фильтр")
+		for _, predicate := range e.predicates {
+			nodes = ev.filter(nodes, predicate, false)
+		}
+		return nodes
+	case *xpathPath:
+		return ev.evalPath(e, ctx)
+	}
+	xpathFail("неизвестное выражение")
+	return nil
+}
+
+// nodeSet проверяет, что значение является набором узлов
+func (ev *xpathEvaluator) nodeSet(value interface{}, where string) []*Node {
+	nodes, ok := value.([]*Node)
+	if !ok {
+		xpathFail("%s: ожидался набор узлов", where)
+	}
+	return nodes
+}
+
+func (ev *xpathEvaluator) evalBinary(e *xpathBinary, ctx xpathContext) interface{} {
+	switch e.op {
+	case "or":
+		return xpathToBoolean(ev.eval(e.left, ctx)) || xpathToBoolean(ev.eval(e.right, ctx))
+	case "and":
+		return xpathToBoolean(ev.eval(e.left, ctx)) && xpathToBoolean(ev.eval(e.right, ctx))
+	case "|":
+		left := ev.nodeSet(ev.eval(e.left, ctx), "|")
+		right := ev.nodeSet(ev.eval(e.right, ctx), "|")
+		return ev.sortNodes(append(append([]*Node{}, left...), right...))
+	case "=", "!=", "<", "<=", ">", ">=":
+		return xpathCompare(e.op, ev.eval(e.left, ctx), ev.eval(e.right, ctx))
+	}
+
+	left := xpathToNumber(ev.eval(e.left, ctx))
+	right := xpathToNumber(ev.eval(e.right, ctx))
+	switch e.op {
+	case "+":
+		return left + right
+	case "-":
+		return left - right
+	case "*":
+		return left * right
+	case "div":
+		return left / right
+	case "mod":
+		return math.Mod(left, right)
+	}
+	xpathFail("неизвестная операция %s", e.op)
+	return nil
+}
+
+// xpathCompare сравнивает значения по правилам XPath 1.0
+func xpathCompare(op string, left, right interface{}) bool {
+	leftNodes, leftIsSet := left.([]*Node)
+	rightNodes, rightIsSet := right.([]*Node)
+
+	switch {
+	case leftIsSet && rightIsSet:
+		for _, l := range leftNodes {
+			for _, r := range rightNodes {
+				if xpathCompareAtoms(op, xpathStringValue(l), xpathStringValue(r)) {
+					return true
+				}
+			}
+		}
+		return false
+	case leftIsSet || rightIsSet:
+		nodes, other, swapped := leftNodes, right, false
+		if rightIsSet {
+			nodes, other, swapped = rightNodes, left, true
+		}
+		if b, ok := other.(bool); ok {
+			set := len(nodes) > 0
+			if swapped {
+				return xpathCompareAtoms(op, b, set)
+			}
+			return xpathCompareAtoms(op, set, b)
+		}
+		for _, n := range nodes {
+			var atom interface{} = xpathStringValue(n)
+			if _, ok := other.(float64); ok {
+				atom = xpathToNumber(atom)
+			}
+			if swapped && xpathCompareAtoms(op, other, atom) || !swapped && xpathCompareAtoms(op, atom, other) {
+				return true
+			}
+		}
+		return false
+	}
+	return xpathCompareAtoms(op, left, right)
+}
+
+// xpathCompareAtoms сравнивает строки, числа и логические значения
+func xpathCompareAtoms(op string, left, right interface{}) bool {
+	if op == "=" || op == "!=" {
+		var equal bool
+		_, leftBool := left.(bool)
+		_, rightBool := right.(bool)
+		_, leftNum := left.(float64)
+		_, rightNum := right.(float64)
+		switch {
+		case leftBool || rightBool:
+			equal = xpathToBoolean(left) == xpathToBoolean(right)
+		case leftNum || rightNum:
+			equal = xpathToNumber(left) == xpathToNumber(right)
+		default:
+			equal = xpathToString(left) == xpathToString(right)
+		}
+		return equal == (op == "=")
+	}
+
+	l, r := xpathToNumber(left), xpathToNumber(right)
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	}
+	return l >= r
+}
+
+func (ev *xpathEvaluator) evalPath(e *xpathPath, ctx xpathContext) interface{} {
+	var nodes []*Node
+	switch {
+	case e.filter != nil:
+		nodes = ev.nodeSet(ev.eval(e.filter, ctx), "путь")
+	case e.absolute:
+		root := ctx.node
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		nodes = []*Node{root}
+	default:
+		nodes = []*Node{ctx.node}
+	}
+
+	for _, step := range e.steps {
+		var next []*Node
+		seen := make(map[*Node]bool)
+		for _, n := range nodes {
+			for _, m := range ev.evalStep(step, n) {
+				if !seen[m] {
+					seen[m] = true
+					next = append(next, m)
+				}
+			}
+		}
+		nodes = ev.sortNodes(next)
+	}
+	if nodes == nil {
+		nodes = make([]*Node, 0)
+	}
+	return nodes
+}
+
+// evalStep выполняет шаг пути от одного узла. Узлы возвращаются в порядке оси
+func (ev *xpathEvaluator) evalStep(step xpathStep, n *Node) []*Node {
+	var nodes []*Node
+	for _, m := range ev.axis(step.axis, n) {
+		if xpathTest(step, m) {
+			nodes = append(nodes, m)
+		}
+	}
+	reverse := false
+	switch step.axis {
+	case "ancestor", "ancestor-or-self", "preceding", "preceding-sibling":
+		reverse = true
+	}
+	for _, predicate := range step.predicates {
+		nodes = ev.filter(nodes, predicate, reverse)
+	}
+	return nodes
+}
+
+// filter оставляет узлы, для которых выполняется предикат. Узлы передаются
+// в порядке оси, поэтому позиция считается от начала списка
+func (ev *xpathEvaluator) filter(nodes []*Node, predicate xpathExpr, reverse bool) []*Node {
+	var result []*Node
+	for i, n := range nodes {
+		value := ev.eval(predicate, xpathContext{node: n, position: i + 1, size: len(nodes)})
+		if num, ok := value.(float64); ok {
+			if num == float64(i+1) {
+				result = append(result, n)
+			}
+		} else if xpathToBoolean(value) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// axis возвращает узлы оси в порядке оси (обратном для обратных осей)
+func (ev *xpathEvaluator) axis(name string, n *Node) []*Node {
+	var nodes []*Node
+	switch name {
+	case "self":
+		nodes = append(nodes, n)
+	case "child":
+		if n.Type != AttributeNode {
+			nodes = xpathChildren(n)
+		}
+	case "parent":
+		if n.Parent != nil {
+			nodes = append(nodes, n.Parent)
+		}
+	case "attribute":
+		nodes = ev.attributeNodes(n)
+	case "descendant", "descendant-or-self":
+		if name == "descendant-or-self" {
+			nodes = append(nodes, n)
+		}
+		if n.Type != AttributeNode {
+			nodes = xpathDescendants(n, nodes)
+		}
+	case "ancestor", "ancestor-or-self":
+		if name == "ancestor-or-self" {
+			nodes = append(nodes, n)
+		}
+		for p := n.Parent; p != nil; p = p.Parent {
+			nodes = append(nodes, p)
+		}
+	case "following-sibling":
+		if n.Type != AttributeNode {
+			for s := n.NextSibling; s != nil; s = s.NextSibling {
+				if isXPathNode(s) {
+					nodes = append(nodes, s)
+				}
+			}
+		}
+	case "preceding-sibling":
+		if n.Type != AttributeNode {
+			for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+				if isXPathNode(s) {
+					nodes = append(nodes, s)
+				}
+			}
+		}
+	case "following":
+		// Узлы после n в порядке документа, кроме его потомков
+		start := n
+		if n.Type == AttributeNode {
+			// У атрибута following включает потомков его элемента
+			nodes = xpathDescendants(n.Parent, nodes)
+			start = n.Parent
+		}
+		for a := start; a != nil; a = a.Parent {
+			for s := a.NextSibling; s != nil; s = s.NextSibling {
+				if isXPathNode(s) {
+					nodes = append(nodes, s)
+					nodes = xpathDescendants(s, nodes)
+				}
+			}
+		}
+	case "preceding":
+		// Узлы до n в порядке документа, кроме его предков, в обратном порядке
+		start := n
+		if n.Type == AttributeNode {
+			start = n.Parent
+		}
+		for a := start; a != nil; a = a.Parent {
+			for s := a.PrevSibling; s != nil; s = s.PrevSibling {
+				if !isXPathNode(s) {
+					continue
+				}
+				subtree := xpathDescendants(s, []*Node{s})
+				for i := len(subtree) - 1; i >= 0; i-- {
+					nodes = append(nodes, subtree[i])
+				}
+			}
+		}
+	}
+	return nodes
+}
+
+// isXPathNode проверяет, входит ли узел в модель данных XPath (DOCTYPE не входит)
+func isXPathNode(n *Node) bool {
+	return n.Type != DocumentTypeNode
+}
+
+func xpathChildren(n *Node) []*Node {
+	var nodes []*Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isXPathNode(c) {
+			nodes = append(nodes, c)
+		}
+	}
+	return nodes
+}
+
+// xpathDescendants добавляет к nodes потомков узла в порядке документа
+func xpathDescendants(n *Node, nodes []*Node) []*Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isXPathNode(c) {
+			nodes = append(nodes, c)
+			nodes = xpathDescendants(c, nodes)
+		}
+	}
+	return nodes
+}
+
+// attributeNodes возвращает узлы атрибутов элемента
+func (ev *xpathEvaluator) attributeNodes(n *Node) []*Node {
+	if n.Type != ElementNode {
+		return nil
+	}
+	if attrs, ok := ev.attributes[n]; ok {
+		return attrs
+	}
+	attrs := make([]*Node, 0, len(n.Attributes))
+	for _, a := range n.Attributes {
+		attrs = append(attrs, &Node{Type: AttributeNode, Parent: n, TagName: a.Name, Data: a.Value})
+	}
+	ev.attributes[n] = attrs
+	return attrs
+}
+
+// xpathTest проверяет узел на соответствие проверке шага
+func xpathTest(step xpathStep, n *Node) bool {
+	switch step.test.kind {
+	case "node":
+		return true
+	case "text":
+		return n.Type == TextNode
+	case "comment":
+		return n.Type == CommentNode
+	case "processing-instruction":
+		return false
+	}
+
+	// Проверка имени выполняется только для основного типа узлов оси
+	principal := ElementNode
+	if step.axis == "attribute" {
+		principal = AttributeNode
+	}
+	if n.Type != principal {
+		return false
+	}
+	name := step.test.name
+	if name == "*" || strings.HasSuffix(name, ":*") {
+		return true
+	}
+	// Префиксы пространств имен пока не различаются
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	// Имена элементов и атрибутов HTML сравниваются без учета регистра
+	return strings.EqualFold(n.TagName, name)
+}
+
+// sortNodes сортирует набор узлов в порядке документа
+func (ev *xpathEvaluator) sortNodes(nodes []*Node) []*Node {
+	if len(nodes) < 2 {
+		return nodes
+	}
+	if ev.order == nil {
+		ev.order = make(map[*Node]int)
+	}
+	for _, n := range nodes {
+		if _, ok := ev.order[n]; !ok {
+			root := n
+			for root.Parent != nil {
+				root = root.Parent
+			}
+			ev.numberTree(root)
+		}
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return ev.order[nodes[i]] < ev.order[nodes[j]]
+	})
+
+	// Удаляем повторы
+	result := nodes[:1]
+	for _, n := range nodes[1:] {
+		if n != result[len(result)-1] {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// numberTree нумерует узлы дерева в порядке документа. Атрибуты идут сразу
+// после своего элемента, перед его детьми
+func (ev *xpathEvaluator) numberTree(root *Node) {
+	var number func(n *Node)
+	number = func(n *Node) {
+		ev.order[n] = len(ev.order)
+		for _, attr := range ev.attributeNodes(n) {
+			ev.order[attr] = len(ev.order)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			number(c)
+		}
+	}
+	number(root)
+}
+
+// xpathStringValue возвращает строковое значение узла
+func xpathStringValue(n *Node) string {
+	switch n.Type {
+	case AttributeNode, TextNode, CommentNode:
+		return n.Data
+	}
+	return n.TextContent()
+}
+
+// xpathToString приводит значение к строке
+func xpathToString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "Infinity"
+		case math.IsInf(v, -1):
+			return "-Infinity"
+		case v == 0:
+			return "0"
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []*Node:
+		if len(v) == 0 {
+			return ""
+		}
+		return xpathStringValue(v[0])
+	}
+	return ""
+}
+
+// xpathToNumber приводит значение к числу
+func xpathToNumber(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case string:
+		return xpathParseNumber(v)
+	case []*Node:
+		return xpathParseNumber(xpathToString(v))
+	}
+	return math.NaN()
+}
+
+// xpathParseNumber разбирает строку как число XPath: необязательный минус,
+// цифры и точка, окруженные пробелами
+func xpathParseNumber(s string) float64 {
+	s = strings.TrimFunc(s, isHTMLSpace)
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || digits == "." || strings.Trim(digits, "0123456789.") != "" || strings.Count(digits, ".") > 1 {
+		return math.NaN()
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+// xpathToBoolean приводит значение к логическому
+func xpathToBoolean(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	case []*Node:
+		return len(v) > 0
+	}
+	return false
+}
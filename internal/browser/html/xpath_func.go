@@ -0,0 +1,268 @@
+package html
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// xpathFunction — функция из базовой библиотеки XPath 1.0
+type xpathFunction struct {
+	minArgs int
+	// maxArgs < 0 означает произвольное число аргументов
+	maxArgs int
+	fn      func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{}
+}
+
+// xpathFunctions — базовая библиотека функций XPath 1.0
+var xpathFunctions map[string]xpathFunction
+
+func init() {
+	xpathFunctions = map[string]xpathFunction{
+		// Функции наборов узлов
+		"last": {0, 0, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return float64(ctx.size)
+		}},
+		"position": {0, 0, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return float64(ctx.position)
+		}},
+		"count": {1, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return float64(len(ev.nodeSet(ev.eval(args[0], ctx), "count()")))
+		}},
+		"id": {1, 1, xpathID},
+		"local-name": {0, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			n := ev.optionalNode(args, ctx, "local-name()")
+			if n != nil && n.Type == ElementNode {
+				return n.LocalName()
+			}
+			return xpathNodeName(n)
+		}},
+		"name": {0, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return xpathNodeName(ev.optionalNode(args, ctx, "name()"))
+		}},
+		"namespace-uri": {0, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			n := ev.optionalNode(args, ctx, "namespace-uri()")
+			if n == nil {
+				return ""
+			}
+			return n.NamespaceURI()
+		}},
+
+		// Строковые функции
+		"string": {0, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return ev.stringArg(args, 0, ctx)
+		}},
+		"concat": {2, -1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			var sb strings.Builder
+			for i := range args {
+				sb.WriteString(ev.stringArg(args, i, ctx))
+			}
+			return sb.String()
+		}},
+		"starts-with": {2, 2, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return strings.HasPrefix(ev.stringArg(args, 0, ctx), ev.stringArg(args, 1, ctx))
+		}},
+		"contains": {2, 2, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return strings.Contains(ev.stringArg(args, 0, ctx), ev.stringArg(args, 1, ctx))
+		}},
+		"substring-before": {2, 2, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			s, sep := ev.stringArg(args, 0, ctx), ev.stringArg(args, 1, ctx)
+			if i := strings.Index(s, sep); i >= 0 {
+				return s[:i]
+			}
+			return ""
+		}},
+		"substring-after": {2, 2, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			s, sep := ev.stringArg(args, 0, ctx), ev.stringArg(args, 1, ctx)
+			if i := strings.Index(s, sep); i >= 0 {
+				return s[i+len(sep):]
+			}
+			return ""
+		}},
+		"substring": {2, 3, xpathSubstring},
+		"string-length": {0, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return float64(utf8.RuneCountInString(ev.stringArg(args, 0, ctx)))
+		}},
+		"normalize-space": {0, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return strings.Join(strings.FieldsFunc(ev.stringArg(args, 0, ctx), isHTMLSpace), " ")
+		}},
+		"translate": {3, 3, xpathTranslate},
+
+		// Логические функции
+		"boolean": {1, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return xpathToBoolean(ev.eval(args[0], ctx))
+		}},
+		"not": {1, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return !xpathToBoolean(ev.eval(args[0], ctx))
+		}},
+		"true": {0, 0, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return true
+		}},
+		"false": {0, 0, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return false
+		}},
+		"lang": {1, 1, xpathLang},
+
+		// Числовые функции
+		"number": {0, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			if len(args) == 0 {
+				return xpathToNumber([]*Node{ctx.node})
+			}
+			return xpathToNumber(ev.eval(args[0], ctx))
+		}},
+		"sum": {1, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			sum := 0.0
+			for _, n := range ev.nodeSet(ev.eval(args[0], ctx), "sum()") {
+				sum += xpathParseNumber(xpathStringValue(n))
+			}
+			return sum
+		}},
+		"floor": {1, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return math.Floor(xpathToNumber(ev.eval(args[0], ctx)))
+		}},
+		"ceiling": {1, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return math.Ceil(xpathToNumber(ev.eval(args[0], ctx)))
+		}},
+		"round": {1, 1, func(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+			return xpathRound(xpathToNumber(ev.eval(args[0], ctx)))
+		}},
+	}
+}
+
+func (ev *xpathEvaluator) call(e *xpathCall, ctx xpathContext) interface{} {
+	return xpathFunctions[e.name].fn(ev, ctx, e.args)
+}
+
+// stringArg возвращает i-й аргумент как строку; при его отсутствии —
+// строковое значение контекстного узла
+func (ev *xpathEvaluator) stringArg(args []xpathExpr, i int, ctx xpathContext) string {
+	if i >= len(args) {
+		return xpathStringValue(ctx.node)
+	}
+	return xpathToString(ev.eval(args[i], ctx))
+}
+
+// optionalNode возвращает первый узел аргумента-набора или контекстный узел
+func (ev *xpathEvaluator) optionalNode(args []xpathExpr, ctx xpathContext, where string) *Node {
+	if len(args) == 0 {
+		return ctx.node
+	}
+	nodes := ev.nodeSet(ev.eval(args[0], ctx), where)
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
+
+// xpathNodeName возвращает имя узла для name() и local-name()
+func xpathNodeName(n *Node) string {
+	if n == nil {
+		return ""
+	}
+	switch n.Type {
+	case ElementNode, AttributeNode:
+		return n.TagName
+	}
+	return ""
+}
+
+// xpathID реализует id(): элементы с идентификаторами из строкового значения аргумента
+func xpathID(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+	var ids []string
+	value := ev.eval(args[0], ctx)
+	if nodes, ok := value.([]*Node); ok {
+		for _, n := range nodes {
+			ids = append(ids, strings.FieldsFunc(xpathStringValue(n), isHTMLSpace)...)
+		}
+	} else {
+		ids = strings.FieldsFunc(xpathToString(value), isHTMLSpace)
+	}
+
+	root := ctx.node
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	var result []*Node
+	for _, id := range ids {
+		if el := root.FindElementsByID(id); el != nil {
+			result = append(result, el)
+		}
+	}
+	return ev.sortNodes(result)
+}
+
+// xpathSubstring реализует substring() с округлением позиций по правилам XPath
+func xpathSubstring(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+	s := []rune(ev.stringArg(args, 0, ctx))
+	start := xpathRound(xpathToNumber(ev.eval(args[1], ctx)))
+	end := math.Inf(1)
+	if len(args) == 3 {
+		end = start + xpathRound(xpathToNumber(ev.eval(args[2], ctx)))
+	}
+
+	var sb strings.Builder
+	for i, c := range s {
+		pos := float64(i + 1)
+		if pos >= start && pos < end {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+// xpathTranslate реализует translate()
+func xpathTranslate(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+	s := ev.stringArg(args, 0, ctx)
+	from := []rune(ev.stringArg(args, 1, ctx))
+	to := []rune(ev.stringArg(args, 2, ctx))
+
+	mapping := make(map[rune]rune, len(from))
+	for i, c := range from {
+		if _, ok := mapping[c]; ok {
+			continue
+		}
+		if i < len(to) {
+			mapping[c] = to[i]
+		} else {
+			mapping[c] = -1
+		}
+	}
+
+	var sb strings.Builder
+	for _, c := range s {
+		if r, ok := mapping[c]; ok {
+			if r >= 0 {
+				sb.WriteRune(r)
+			}
+			continue
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// xpathLang реализует lang(): язык из ближайшего атрибута lang
+func xpathLang(ev *xpathEvaluator, ctx xpathContext, args []xpathExpr) interface{} {
+	want := strings.ToLower(ev.stringArg(args, 0, ctx))
+	for n := ctx.node; n != nil; n = n.Parent {
+		if n.Type != ElementNode {
+			continue
+		}
+		if lang, ok := n.LookupAttribute("lang"); ok {
+			lang = strings.ToLower(lang)
+			return lang == want || strings.HasPrefix(lang, want+"-")
+		}
+	}
+	return false
+}
+
+// xpathRound округляет число к ближайшему целому, половины — в сторону +∞
+func xpathRound(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) || f == 0 {
+		return f
+	}
+	if f < 0 && f >= -0.5 {
+		return math.Copysign(0, -1)
+	}
+	return math.Floor(f + 0.5)
+}
@@ -0,0 +1,220 @@
+package html
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const xpathFixture = `<!DOCTYPE html><html><body>` +
+	`<div id=d1 class=x><p id=p1>one</p><p id=p2 lang=en>two <b id=b1>bold</b></p><p id=p3>three</p></div>` +
+	`<div id=d2><span id=s1>4</span><span id=s2>5.5</span></div>` +
+	`</body></html>`
+
+// xpathLabels возвращает узлы через пробел: элементы по id (или имени
+// тега), атрибуты как @имя
+func xpathLabels(nodes []*Node) string {
+	list := make([]string, len(nodes))
+	for i, n := range nodes {
+		switch {
+		case n.Type == AttributeNode:
+			list[i] = "@" + n.TagName
+		case n.GetAttribute("id") != "":
+			list[i] = n.GetAttribute("id")
+		default:
+			list[i] = n.TagName
+		}
+	}
+	return strings.Join(list, " ")
+}
+
+func TestXPathNodeSets(t *testing.T) {
+	doc := parseFixture(t, xpathFixture)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		// Оси
+		{"//p", "p1 p2 p3"},
+		{"/html/body/div/p", "p1 p2 p3"},
+		{"//b/ancestor::*", "html body d1 p2"},
+		{"//b/ancestor-or-self::p", "p2"},
+		{"//b/parent::p", "p2"},
+		{"//b/..", "p2"},
+		{"//b/self::b", "b1"},
+		{"//b/self::p", ""},
+		{"//p[1]/following-sibling::*", "p2 p3"},
+		{"//p[3]/preceding-sibling::*", "p1 p2"},
+		{"//p[1]/following::span", "s1 s2"},
+		// preceding не включает предков; head вставлен парсером неявно
+		{"//b/preceding::*", "head p1"},
+		{"id('d1')/descendant::*", "p1 p2 b1 p3"},
+		{"id('d1')/descendant-or-self::*[@id]", "d1 p1 p2 b1 p3"},
+		{"//p[@lang]/attribute::lang", "@lang"},
+		{"//p/@*", "@id @id @lang @id"},
+		{"id('d2')/child::node()", "s1 s2"},
+
+		// Предикаты с позицией
+		{"//p[2]", "p2"},
+		{"//p[last()]", "p3"},
+		{"//p[position() < 3]", "p1 p2"},
+		{"//p[position() = last() - 1]", "p2"},
+		{"(//p)[1]", "p1"},
+		{"//div/*[1]", "p1 s1"},
+		{"(//div/*)[last()]", "s2"},
+		// На обратной оси позиция отсчитывается от узла контекста
+		{"//b/ancestor::*[1]", "p2"},
+		{"//p[3]/preceding-sibling::p[1]", "p2"},
+		{"//p[3]/preceding-sibling::p[last()]", "p1"},
+		{"//p[@id != 'p2'][2]", "p3"},
+		{"//p[b]", "p2"},
+		{"//p[not(@lang)]", "p1 p3"},
+		{"//p[contains(., 'bold')]", "p2"},
+		{"//span[. > 5]", "s2"},
+
+		// Объединение в порядке документа без повторов
+		{"//span | //p[1] | //div", "d1 p1 d2 s1 s2"},
+		{"//p[1] | //p[1]", "p1"},
+		{"//b | //b/ancestor::div", "d1 b1"},
+		{"id('p3 s1')", "p3 s1"},
+		{"id('nope')", ""},
+	}
+	for _, test := range tests {
+		nodes, err := doc.Node.SelectNodes(test.expr)
+		if err != nil {
+			t.Errorf("%s: ошибка %v", test.expr, err)
+			continue
+		}
+		if got := xpathLabels(nodes); got != test.want {
+			t.Errorf("%s: получено %q, ожидалось %q", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestXPathValues(t *testing.T) {
+	doc := parseFixture(t, xpathFixture)
+	b1, _ := doc.DocumentElement().QuerySelector("#b1")
+	tests := []struct {
+		expr    string
+		context *Node
+		want    string
+	}{
+		// Строковые функции
+		{"string(//p[2])", nil, "two bold"},
+		{"concat('a', 'b', 1)", nil, "ab1"},
+		{"starts-with('abc', 'ab')", nil, "true"},
+		{"contains('abc', 'd')", nil, "false"},
+		{"substring('12345', 2, 3)", nil, "234"},
+		{"substring('12345', 1.5, 2.6)", nil, "234"},
+		{"substring('12345', 0, 3)", nil, "12"},
+		{"substring('12345', 0 div 0, 3)", nil, ""},
+		{"substring('12345', -42, 1 div 0)", nil, "12345"},
+		{"substring-before('1999/04/01', '/')", nil, "1999"},
+		{"substring-after('1999/04/01', '/')", nil, "04/01"},
+		{"substring-after('abc', 'x')", nil, ""},
+		{"string-length('абв')", nil, "3"},
+		{"normalize-space('  a \t  b  ')", nil, "a b"},
+		{"translate('bar', 'abc', 'ABC')", nil, "BAr"},
+		{"translate('--aaa--', 'abc-', 'ABC')", nil, "AAA"},
+		{"local-name(//b)", nil, "b"},
+		{"name(//p/@lang)", nil, "lang"},
+		{"string(//nothing)", nil, ""},
+		{"string(.)", b1, "bold"},
+		{"lang('EN')", b1, "true"},
+		{"lang('en-us')", b1, "false"},
+
+		// Числа
+		{"count(//p)", nil, "3"},
+		{"count(//p/@*)", nil, "4"},
+		{"sum(//span)", nil, "9.5"},
+		{"number('  12 ')", nil, "12"},
+		{"number('1e3')", nil, "NaN"},
+		{"number(true())", nil, "1"},
+		{"floor(-1.5)", nil, "-2"},
+		{"ceiling(1.2)", nil, "2"},
+		{"round(2.5)", nil, "3"},
+		{"round(-2.5)", nil, "-2"},
+		{"7 mod -3", nil, "1"},
+		{"-7 mod 3", nil, "-1"},
+		{"1 div 0", nil, "Infinity"},
+		{"-1 div 0", nil, "-Infinity"},
+		{"0 div 0", nil, "NaN"},
+		{"1.50", nil, "1.5"},
+		{"2 + 3 * 4 - -1", nil, "15"},
+
+		// Логические выражения и сравнения наборов узлов
+		{"boolean('')", nil, "false"},
+		{"boolean(//p)", nil, "true"},
+		{"//p = 'two bold'", nil, "true"},
+		{"//p != 'one'", nil, "true"},
+		{"//span > 5", nil, "true"},
+		{"//span > 6", nil, "false"},
+		{"//span = //p", nil, "false"},
+		{"1 < 2 and 2 < 1", nil, "false"},
+		{"1 < 2 or 2 < 1", nil, "true"},
+		{"'1' = 1.0", nil, "true"},
+		{"true() = 'x'", nil, "true"},
+	}
+	for _, test := range tests {
+		context := test.context
+		if context == nil {
+			context = &doc.Node
+		}
+		result, err := context.Evaluate(test.expr)
+		if err != nil {
+			t.Errorf("%s: ошибка %v", test.expr, err)
+			continue
+		}
+		if got := result.StringValue(); got != test.want {
+			t.Errorf("%s: получено %q, ожидалось %q", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestXPathResultTypes(t *testing.T) {
+	doc := parseFixture(t, xpathFixture)
+	result, err := doc.Node.Evaluate("count(//p) div 2")
+	if err != nil || result.Type != XPathNumber || result.Number != 1.5 {
+		t.Errorf("число: %+v, %v", result, err)
+	}
+	result, err = doc.Node.Evaluate("0 div 0")
+	if err != nil || !math.IsNaN(result.NumberValue()) || result.BooleanValue() {
+		t.Errorf("NaN: %+v, %v", result, err)
+	}
+	if _, err := doc.Node.SelectNodes("count(//p)"); err == nil {
+		t.Errorf("SelectNodes: ожидалась ошибка для числового результата")
+	}
+}
+
+func TestXPathErrors(t *testing.T) {
+	doc := parseFixture(t, xpathFixture)
+	for _, expr := range []string{
+		"",
+		"//p[",
+		"//p[1]]",
+		"//p[]",
+		"count(",
+		"unknown()",
+		"count()",
+		"concat('a')",
+		"1 +",
+		"//",
+		"///p",
+		"p/",
+		"@",
+		"bogus::p",
+		"child::",
+		"'unterminated",
+		"//p | ",
+		"$var",
+		"1 2",
+		// Ошибки типов обнаруживаются при вычислении
+		"count('a')",
+		"'a' | //p",
+		"sum(1)",
+	} {
+		if _, err := doc.Node.Evaluate(expr); err == nil {
+			t.Errorf("%q: ожидалась ошибка", expr)
+		}
+	}
+}
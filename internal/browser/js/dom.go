@@ -0,0 +1,537 @@
+package js
+
+import (
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/robertkrimen/otto"
+)
+
+// Значения nodeType из спецификации DOM
+const (
+	elementNodeType   = 1
+	attributeNodeType = 2
+	textNodeType      = 3
+	commentNodeType   = 8
+	documentNodeType  = 9
+	doctypeNodeType   = 10
+	fragmentNodeType  = 11
+)
+
+// setupDocumentObject настраивает объект document для доступа из JavaScript
+func (e *Engine) setupDocumentObject(doc *html.Document) {
+	e.doc = doc
+	e.wrappers = make(map[*html.Node]*otto.Object)
+	e.nodes = make(map[int64]*html.Node)
+
+	// Объект document — это обертка корневого узла документа
+	documentObj := e.wrapNode(&doc.Node).Object()
+
+	e.defineAccessor(documentObj, "title", func() interface{} {
+		return doc.Title()
+	}, func(value otto.Value) {
+		title, _ := value.ToString()
+		doc.SetTitle(title)
+	})
+	e.defineAccessor(documentObj, "documentElement", func() interface{} {
+		return e.wrapNode(doc.DocumentElement())
+	}, nil)
+	e.defineAccessor(documentObj, "head", func() interface{} {
+		return e.wrapNode(doc.Head())
+	}, nil)
+	e.defineAccessor(documentObj, "body", func() interface{} {
+		return e.wrapNode(doc.Body())
+	}, nil)
+
+	// Добавляем методы
+	documentObj.Set("getElementById", func(call otto.FunctionCall) otto.Value {
+		// Получаем ID из аргументов
+		id, _ := call.Argument(0).ToString()
+
+		// Находим элемент по ID
+		return e.wrapNode(doc.FindElementsByID(id))
+	})
+
+	// Добавляем метод createElement
+	documentObj.Set("createElement", func(call otto.FunctionCall) otto.Value {
+		tagName, _ := call.Argument(0).ToString()
+		return e.wrapNode(html.NewElement(tagName))
+	})
+
+	documentObj.Set("createElementNS", func(call otto.FunctionCall) otto.Value {
+		namespace := ""
+		if arg := call.Argument(0); !arg.IsNull() && !arg.IsUndefined() {
+			namespace, _ = arg.ToString()
+		}
+		tagName, _ := call.Argument(1).ToString()
+		return e.wrapNode(html.NewElementNS(namespace, tagName))
+	})
+
+	documentObj.Set("createDocumentFragment", func(call otto.FunctionCall) otto.Value {
+		return e.wrapNode(html.NewDocumentFragment())
+	})
+
+	// Узлы не принадлежат конкретному документу, поэтому импорт — это копирование
+	documentObj.Set("importNode", func(call otto.FunctionCall) otto.Value {
+		n := e.argumentNode(call, 0)
+		if n.Type == html.DocumentNode {
+			e.throwError("NotSupportedError", "Документ нельзя импортировать")
+		}
+		deep, _ := call.Argument(1).ToBoolean()
+		return e.wrapNode(n.CloneNode(deep))
+	})
+
+	documentObj.Set("createTextNode", func(call otto.FunctionCall) otto.Value {
+		data, _ := call.Argument(0).ToString()
+		return e.wrapNode(html.NewText(data))
+	})
+
+	documentObj.Set("createComment", func(call otto.FunctionCall) otto.Value {
+		data, _ := call.Argument(0).ToString()
+		return e.wrapNode(html.NewComment(data))
+	})
+
+	e.setupXPath(documentObj)
+	e.setupDocumentURL(documentObj)
+
+	e.vm.Set("document", documentObj)
+}
+
+// wrapNode возвращает JS-объект для узла DOM. Для одного и того же узла
+// всегда возвращается один и тот же объект
+func (e *Engine) wrapNode(n *html.Node) otto.Value {
+	if n == nil {
+		return otto.NullValue()
+	}
+	if obj, ok := e.wrappers[n]; ok {
+		return obj.Value()
+	}
+
+	obj, _ := e.vm.Object("({})")
+	e.wrappers[n] = obj
+	obj.Set("__node", e.nextNode)
+	e.nodes[e.nextNode] = n
+	e.nextNode++
+
+	e.setupNodeObject(obj, n)
+	switch n.Type {
+	case html.ElementNode:
+		e.setupElementObject(obj, n)
+	case html.AttributeNode:
+		e.setupAttributeObject(obj, n)
+	}
+
+	return obj.Value()
+}
+
+// unwrapNode возвращает узел DOM, которому соответствует JS-объект
+func (e *Engine) unwrapNode(value otto.Value) *html.Node {
+	if !value.IsObject() {
+		return nil
+	}
+	index, err := value.Object().Get("__node")
+	if err != nil || !index.IsNumber() {
+		return nil
+	}
+	i, _ := index.ToInteger()
+	return e.nodes[i]
+}
+
+// defineAccessor определяет свойство объекта с геттером и (необязательно) сеттером
+func (e *Engine) defineAccessor(obj *otto.Object, name string, get func() interface{}, set func(value otto.Value)) {
+	getter := func(call otto.FunctionCall) otto.Value {
+		value, _ := e.vm.ToValue(get())
+		return value
+	}
+	var setter interface{} = otto.UndefinedValue()
+	if set != nil {
+		setter = func(call otto.FunctionCall) otto.Value {
+			set(call.Argument(0))
+			return otto.UndefinedValue()
+		}
+	}
+	e.vm.Call("__defineAccessor", nil, obj, name, getter, setter)
+}
+
+// newArray создает JS-массив из узлов DOM
+func (e *Engine) newArray(nodes []*html.Node) otto.Value {
+	array, _ := e.vm.Object("([])")
+	for _, n := range nodes {
+		array.Call("push", e.wrapNode(n))
+	}
+	return array.Value()
+}
+
+// throwError прерывает выполнение Go-функции исключением DOMException
+func (e *Engine) throwError(name, message string) {
+	panic(e.vm.MakeCustomError(name, message))
+}
+
+// setupNodeObject добавляет свойства и методы интерфейса Node
+func (e *Engine) setupNodeObject(obj *otto.Object, n *html.Node) {
+	obj.Set("nodeType", nodeType(n))
+	obj.Set("nodeName", nodeName(n))
+	e.defineAccessor(obj, "baseURI", func() interface{} {
+		return e.baseURL()
+	}, nil)
+
+	e.defineAccessor(obj, "parentNode", func() interface{} {
+		// Родитель узла атрибута — его элемент-владелец, но в DOM у Attr нет parentNode
+		if n.Type == html.AttributeNode {
+			return otto.NullValue()
+		}
+		return e.wrapNode(n.Parent)
+	}, nil)
+	e.defineAccessor(obj, "parentElement", func() interface{} {
+		if n.Parent == nil || n.Parent.Type != html.ElementNode {
+			return otto.NullValue()
+		}
+		return e.wrapNode(n.Parent)
+	}, nil)
+	e.defineAccessor(obj, "firstChild", func() interface{} {
+		return e.wrapNode(n.FirstChild)
+	}, nil)
+	e.defineAccessor(obj, "lastChild", func() interface{} {
+		return e.wrapNode(n.LastChild)
+	}, nil)
+	e.defineAccessor(obj, "nextSibling", func() interface{} {
+		return e.wrapNode(n.NextSibling)
+	}, nil)
+	e.defineAccessor(obj, "previousSibling", func() interface{} {
+		return e.wrapNode(n.PrevSibling)
+	}, nil)
+	e.defineAccessor(obj, "childNodes", func() interface{} {
+		return e.newArray(n.ChildNodes())
+	}, nil)
+	e.defineAccessor(obj, "children", func() interface{} {
+		return e.newArray(n.Children())
+	}, nil)
+
+	e.defineAccessor(obj, "textContent", func() interface{} {
+		if n.Type == html.DocumentNode || n.Type == html.DocumentTypeNode {
+			return otto.NullValue()
+		}
+		return n.TextContent()
+	}, func(value otto.Value) {
+		if n.Type == html.DocumentNode || n.Type == html.DocumentTypeNode {
+			return
+		}
+		text, _ := value.ToString()
+		n.SetTextContent(text)
+	})
+
+	if n.Type == html.TextNode || n.Type == html.CommentNode {
+		dataGetter := func() interface{} {
+			return n.Data
+		}
+		dataSetter := func(value otto.Value) {
+			n.Data, _ = value.ToString()
+		}
+		e.defineAccessor(obj, "data", dataGetter, dataSetter)
+		e.defineAccessor(obj, "nodeValue", dataGetter, dataSetter)
+	}
+
+	obj.Set("hasChildNodes", func(call otto.FunctionCall) otto.Value {
+		value, _ := e.vm.ToValue(n.FirstChild != nil)
+		return value
+	})
+
+	obj.Set("appendChild", func(call otto.FunctionCall) otto.Value {
+		child := e.argumentNode(call, 0)
+		e.checkInsertion(n, child)
+		n.AppendChild(child)
+		return call.Argument(0)
+	})
+
+	obj.Set("insertBefore", func(call otto.FunctionCall) otto.Value {
+		child := e.argumentNode(call, 0)
+		ref := e.unwrapNode(call.Argument(1))
+		if ref != nil && ref.Parent != n {
+			e.throwError("NotFoundError", "Узел, перед которым выполняется вставка, не является дочерним")
+		}
+		e.checkInsertion(n, child)
+		if ref == child {
+			ref = child.NextSibling
+		}
+		n.InsertBefore(child, ref)
+		return call.Argument(0)
+	})
+
+	obj.Set("removeChild", func(call otto.FunctionCall) otto.Value {
+		child := e.argumentNode(call, 0)
+		if child.Parent != n {
+			e.throwError("NotFoundError", "Удаляемый узел не является дочерним")
+		}
+		n.RemoveChild(child)
+		return call.Argument(0)
+	})
+
+	obj.Set("replaceChild", func(call otto.FunctionCall) otto.Value {
+		child := e.argumentNode(call, 0)
+		old := e.argumentNode(call, 1)
+		if old.Parent != n {
+			e.throwError("NotFoundError", "Заменяемый узел не является дочерним")
+		}
+		e.checkInsertion(n, child)
+		n.ReplaceChild(child, old)
+		return call.Argument(1)
+	})
+
+	obj.Set("cloneNode", func(call otto.FunctionCall) otto.Value {
+		deep, _ := call.Argument(0).ToBoolean()
+		return e.wrapNode(n.CloneNode(deep))
+	})
+
+	obj.Set("contains", func(call otto.FunctionCall) otto.Value {
+		value, _ := e.vm.ToValue(n.Contains(e.unwrapNode(call.Argument(0))))
+		return value
+	})
+
+	// Добавляем метод getElementsByTagName
+	obj.Set("getElementsByTagName", func(call otto.FunctionCall) otto.Value {
+		tagName, _ := call.Argument(0).ToString()
+		return e.newArray(n.FindElementsByTagName(tagName))
+	})
+	obj.Set("querySelector", func(call otto.FunctionCall) otto.Value {
+		selector, _ := call.Argument(0).ToString()
+		found, err := n.QuerySelector(selector)
+		if err != nil {
+			e.throwError("SyntaxError", err.Error())
+		}
+		return e.wrapNode(found)
+	})
+
+	obj.Set("querySelectorAll", func(call otto.FunctionCall) otto.Value {
+		selector, _ := call.Argument(0).ToString()
+		found, err := n.QuerySelectorAll(selector)
+		if err != nil {
+			e.throwError("SyntaxError", err.Error())
+		}
+		return e.newArray(found)
+	})
+}
+
+// setupElementObject добавляет свойства и методы интерфейса Element
+func (e *Engine) setupElementObject(obj *otto.Object, element *html.Node) {
+	obj.Set("tagName", nodeName(element))
+	obj.Set("localName", element.LocalName())
+	obj.Set("namespaceURI", element.NamespaceURI())
+
+	e.defineAttributeAccessor(obj, element, "id", "id")
+	e.defineAttributeAccessor(obj, element, "className", "class")
+	e.setupURLAttributes(obj, element)
+
+	if element.Content != nil {
+		obj.Set("content", e.wrapNode(element.Content))
+	}
+
+	e.defineAccessor(obj, "innerHTML", func() interface{} {
+		return element.InnerHTML()
+	}, func(value otto.Value) {
+		markup, _ := value.ToString()
+		nodes := e.parseFragment(markup, element)
+		// Разметка <template> заменяет его содержимое, а не детей
+		parent := element
+		if element.Content != nil {
+			parent = element.Content
+		}
+		for parent.FirstChild != nil {
+			parent.RemoveChild(parent.FirstChild)
+		}
+		for _, n := range nodes {
+			parent.AppendChild(n)
+		}
+	})
+	e.defineAccessor(obj, "outerHTML", func() interface{} {
+		return element.OuterHTML()
+	}, func(value otto.Value) {
+		parent := element.Parent
+		if parent == nil {
+			return
+		}
+		if parent.Type == html.DocumentNode {
+			e.throwError("NoModificationAllowedError", "Нельзя заменить корневой элемент документа")
+		}
+		markup, _ := value.ToString()
+		for _, n := range e.parseFragment(markup, parent) {
+			parent.InsertBefore(n, element)
+		}
+		parent.RemoveChild(element)
+	})
+
+	obj.Set("insertAdjacentHTML", func(call otto.FunctionCall) otto.Value {
+		position, _ := call.Argument(0).ToString()
+		markup, _ := call.Argument(1).ToString()
+
+		var parent, before *html.Node
+		switch strings.ToLower(position) {
+		case "beforebegin":
+			parent, before = element.Parent, element
+		case "afterbegin":
+			parent, before = element, element.FirstChild
+		case "beforeend":
+			parent = element
+		case "afterend":
+			parent, before = element.Parent, element.NextSibling
+		default:
+			e.throwError("SyntaxError", "Неизвестная позиция вставки: "+position)
+		}
+		if parent == nil || parent.Type == html.DocumentNode {
+			e.throwError("NoModificationAllowedError", "У элемента нет родительского элемента")
+		}
+
+		for _, n := range e.parseFragment(markup, parent) {
+			parent.InsertBefore(n, before)
+		}
+		return otto.UndefinedValue()
+	})
+
+	obj.Set("getAttribute", func(call otto.FunctionCall) otto.Value {
+		name, _ := call.Argument(0).ToString()
+		if !element.HasAttribute(name) {
+			return otto.NullValue()
+		}
+		value, _ := e.vm.ToValue(element.GetAttribute(name))
+		return value
+	})
+
+	// Добавляем метод setAttribute
+	obj.Set("setAttribute", func(call otto.FunctionCall) otto.Value {
+		name, _ := call.Argument(0).ToString()
+		value, _ := call.Argument(1).ToString()
+		element.SetAttribute(name, value)
+		return otto.UndefinedValue()
+	})
+
+	obj.Set("removeAttribute", func(call otto.FunctionCall) otto.Value {
+		name, _ := call.Argument(0).ToString()
+		element.RemoveAttribute(name)
+		return otto.UndefinedValue()
+	})
+
+	obj.Set("hasAttribute", func(call otto.FunctionCall) otto.Value {
+		name, _ := call.Argument(0).ToString()
+		value, _ := e.vm.ToValue(element.HasAttribute(name))
+		return value
+	})
+
+	obj.Set("matches", func(call otto.FunctionCall) otto.Value {
+		selector, _ := call.Argument(0).ToString()
+		matched, err := element.Matches(selector)
+		if err != nil {
+			e.throwError("SyntaxError", err.Error())
+		}
+		value, _ := e.vm.ToValue(matched)
+		return value
+	})
+
+	obj.Set("closest", func(call otto.FunctionCall) otto.Value {
+		selector, _ := call.Argument(0).ToString()
+		found, err := element.Closest(selector)
+		if err != nil {
+			e.throwError("SyntaxError", err.Error())
+		}
+		return e.wrapNode(found)
+	})
+}
+
+// parseFragment разбирает HTML разметку в контексте элемента context. Как и в
+// браузерах, корневой элемент <html> в качестве контекста заменяется на <body>
+func (e *Engine) parseFragment(markup string, context *html.Node) []*html.Node {
+	if context.Type != html.ElementNode || context.TagName == "html" {
+		context = html.NewElement("body")
+	}
+	nodes, err := html.NewParser().ParseFragment(markup, context)
+	if err != nil {
+		e.throwError("SyntaxError", err.Error())
+	}
+	return nodes
+}
+
+// setupAttributeObject добавляет свойства интерфейса Attr
+func (e *Engine) setupAttributeObject(obj *otto.Object, attr *html.Node) {
+	obj.Set("name", attr.TagName)
+	obj.Set("localName", attr.TagName)
+	obj.Set("ownerElement", e.wrapNode(attr.Parent))
+
+	valueGetter := func() interface{} {
+		return attr.Data
+	}
+	valueSetter := func(value otto.Value) {
+		attr.Data, _ = value.ToString()
+		attr.Parent.SetAttribute(attr.TagName, attr.Data)
+	}
+	e.defineAccessor(obj, "value", valueGetter, valueSetter)
+	e.defineAccessor(obj, "nodeValue", valueGetter, valueSetter)
+}
+
+// defineAttributeAccessor определяет свойство, отражающее атрибут элемента
+func (e *Engine) defineAttributeAccessor(obj *otto.Object, element *html.Node, property, attribute string) {
+	e.defineAccessor(obj, property, func() interface{} {
+		return element.GetAttribute(attribute)
+	}, func(value otto.Value) {
+		v, _ := value.ToString()
+		element.SetAttribute(attribute, v)
+	})
+}
+
+// argumentNode возвращает узел DOM из аргумента вызова или выбрасывает TypeError
+func (e *Engine) argumentNode(call otto.FunctionCall, i int) *html.Node {
+	n := e.unwrapNode(call.Argument(i))
+	if n == nil {
+		panic(e.vm.MakeTypeError("Аргумент не является узлом DOM"))
+	}
+	return n
+}
+
+// checkInsertion проверяет, можно ли вставить child в parent
+func (e *Engine) checkInsertion(parent, child *html.Node) {
+	if child.Contains(parent) {
+		e.throwError("HierarchyRequestError", "Узел нельзя вставить внутрь самого себя")
+	}
+	if child.Type == html.DocumentNode {
+		e.throwError("HierarchyRequestError", "Документ нельзя вставить в дерево")
+	}
+}
+
+// nodeType возвращает значение nodeType узла
+func nodeType(n *html.Node) int {
+	switch n.Type {
+	case html.ElementNode:
+		return elementNodeType
+	case html.TextNode:
+		return textNodeType
+	case html.CommentNode:
+		return commentNodeType
+	case html.DocumentTypeNode:
+		return doctypeNodeType
+	case html.AttributeNode:
+		return attributeNodeType
+	case html.DocumentFragmentNode:
+		return fragmentNodeType
+	}
+	return documentNodeType
+}
+
+// nodeName возвращает значение nodeName узла
+func nodeName(n *html.Node) string {
+	switch n.Type {
+	case html.ElementNode:
+		// В верхний регистр переводятся только имена HTML элементов
+		if !n.IsHTML() {
+			return n.TagName
+		}
+		return strings.ToUpper(n.TagName)
+	case html.TextNode:
+		return "#text"
+	case html.CommentNode:
+		return "#comment"
+	case html.DocumentTypeNode:
+		return n.Data
+	case html.AttributeNode:
+		return n.TagName
+	case html.DocumentFragmentNode:
+		return "#document-fragment"
+	}
+	return "#document"
+}
@@ -0,0 +1,100 @@
+package js
+
+import (
+	"testing"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+)
+
+// runScript разбирает документ, выполняет его скрипты и затем script.
+// Возвращает результат последнего выражения script в виде строки
+func runScript(t *testing.T, src, script string) (string, *html.Document) {
+	t.Helper()
+	doc, err := html.NewParser().Parse(src)
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	e := NewEngine()
+	e.Execute(doc)
+	result, err := e.EvaluateScript(script)
+	if err != nil {
+		t.Fatalf("ошибка выполнения %q: %v", script, err)
+	}
+	return result, doc
+}
+
+const selectorFixture = `<!DOCTYPE html><div id=root>
+<ul id=list><li id=l1 class=a>1</li><li id=l2>2</li><li id=l3 class=a><b id=b1>3</b></li></ul>
+<p id=p1>x</p></div>`
+
+func TestSelectorBindings(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{`document.querySelector('li.a').id`, "l1"},
+		{`document.querySelector('li.missing')`, "null"},
+		{`document.querySelectorAll('li.a').map(function (n) { return n.id }).join(' ')`, "l1 l3"},
+		{`document.querySelectorAll('table').length`, "0"},
+		// Поиск от элемента не выходит за его пределы
+		{`document.getElementById('p1').querySelectorAll('li').length`, "0"},
+		{`document.getElementById('list').querySelector(':scope > li:last-child').id`, "l3"},
+		{`document.querySelector('li') === document.getElementById('l1')`, "true"},
+		{`document.getElementById('l3').matches('#list > .a')`, "true"},
+		{`document.getElementById('l2').matches('.a')`, "false"},
+		{`document.getElementById('b1').closest('li').id`, "l3"},
+		{`document.getElementById('b1').closest('b').id`, "b1"},
+		{`document.getElementById('b1').closest('p')`, "null"},
+	}
+	for _, test := range tests {
+		if got, _ := runScript(t, selectorFixture, test.script); got != test.want {
+			t.Errorf("%s = %q, ожидалось %q", test.script, got, test.want)
+		}
+	}
+}
+
+func TestInvalidSelectorThrows(t *testing.T) {
+	scripts := []string{
+		`document.querySelector('li[')`,
+		`document.querySelectorAll('li:unknown')`,
+		`document.getElementById('l1').matches('')`,
+		`document.getElementById('b1').closest('li >')`,
+	}
+	for _, script := range scripts {
+		got, _ := runScript(t, selectorFixture, `try { `+script+`; 'не выброшено' } catch (e) { e.name }`)
+		if got != "SyntaxError" {
+			t.Errorf("%s: получено %q, ожидалось исключение SyntaxError", script, got)
+		}
+	}
+}
+
+func TestWrappersFromPreviousDocument(t *testing.T) {
+	e := NewEngine()
+	for i, src := range []string{
+		`<!DOCTYPE html><p id=old>старый</p>`,
+		`<!DOCTYPE html><div id=a></div><div id=b></div>`,
+	} {
+		doc, err := html.NewParser().Parse(src)
+		if err != nil {
+			t.Fatalf("ошибка разбора: %v", err)
+		}
+		e.Execute(doc)
+		if i == 0 {
+			// Объект узла прошлой страницы переживает загрузку новой
+			if _, err := e.EvaluateScript(`var kept = document.getElementById('old')`); err != nil {
+				t.Fatalf("ошибка: %v", err)
+			}
+		}
+	}
+
+	// Узлы новой страницы получают новые номера, и старый объект не
+	// превращается в один из них
+	got, err := e.EvaluateScript(`document.getElementById('a'); document.getElementById('b');
+		try { document.body.appendChild(kept); 'вставлен' } catch (e) { e.name }`)
+	if err != nil || got != "TypeError" {
+		t.Errorf("получено %q, %v, ожидалось TypeError", got, err)
+	}
+	if got, _ := e.EvaluateScript(`document.body.childNodes.length`); got != "2" {
+		t.Errorf("в body %s узлов, ожидалось 2", got)
+	}
+}
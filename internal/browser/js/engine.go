@@ -2,14 +2,32 @@ package js
 
 import (
 	"log"
+	"strings"
 
 	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/style"
 	"github.com/robertkrimen/otto"
 )
 
 // Engine представляет JavaScript движок
 type Engine struct {
 	vm *otto.Otto
+	
+	// doc — документ, в контексте которого выполняются скрипты
+	doc *html.Document
+	// wrappers хранит JS-объекты узлов DOM, чтобы один узел всегда
+	// был представлен одним и тем же объектом. nodes находит узел по номеру
+	// из JS-объекта; номера не повторяются между документами, поэтому
+	// объект, сохраненный скриптом с прошлой страницы, ни с чем не связан
+	wrappers map[*html.Node]*otto.Object
+	nodes    map[int64]*html.Node
+	nextNode int64
+	
+	// loadScript загружает внешний скрипт по абсолютному адресу
+	loadScript func(url string) (string, error)
+	
+	// device — устройство, на котором отображается документ
+	device style.Device
 }
 
 // NewEngine создает новый JavaScript движок
@@ -19,12 +37,24 @@ func NewEngine() *Engine {
 	// Создаем новый экземпляр Otto VM
 	vm := otto.New()
 	
+	// Вспомогательная функция для определения свойств с геттером и сеттером
+	vm.Run(`function __defineAccessor(obj, name, get, set) {
+		Object.defineProperty(obj, name, {get: get, set: set, enumerable: true, configurable: true});
+	}`)
+	
 	// Возвращаем новый движок
 	return &Engine{
-		vm: vm,
+		vm:     vm,
+		device: style.DefaultDevice(),
 	}
 }
 
+// SetScriptLoader задает функцию загрузки внешних скриптов. Без нее скрипты
+// с атрибутом src пропускаются
+func (e *Engine) SetScriptLoader(load func(url string) (string, error)) {
+	e.loadScript = load
+}
+
 // Execute выполняет JavaScript код в контексте документа
 func (e *Engine) Execute(doc *html.Document) {
 	log.Println("Выполнение JavaScript...")
@@ -32,21 +62,26 @@ func (e *Engine) Execute(doc *html.Document) {
 	// Находим все скрипты в документе
 	scripts := doc.FindElementsByTagName("script")
 	
-	// Создаем объект document для доступа из JavaScript
+	// Создаем объекты document и window для доступа из JavaScript
 	e.setupDocumentObject(doc)
+	e.setupWindow()
 	
 	// Выполняем каждый скрипт
 	for _, script := range scripts {
+		// Блоки данных (например, JSON-LD) и модули не выполняются
+		if !isClassicScript(script) {
+			continue
+		}
+		
 		// Проверяем, является ли скрипт внешним (имеет атрибут src)
-		if src, ok := script.Attributes["src"]; ok && src != "" {
-			// В реальном браузере здесь должна быть загрузка внешнего скрипта
-			log.Printf("Внешний скрипт не загружен: %s", src)
+		if src, ok := script.LookupAttribute("src"); ok {
+			e.executeExternal(doc, src)
 			continue
 		}
 		
 		// Выполняем встроенный скрипт
-		if script.Text != "" {
-			_, err := e.vm.Run(script.Text)
+		if text := script.TextContent(); text != "" {
+			_, err := e.vm.Run(text)
 			if err != nil {
 				log.Printf("Ошибка выполнения JavaScript: %v", err)
 			}
@@ -54,110 +89,69 @@ func (e *Engine) Execute(doc *html.Document) {
 	}
 }
 
-// setupDocumentObject настраивает объект document для доступа из JavaScript
-func (e *Engine) setupDocumentObject(doc *html.Document) {
-	// Создаем объект document
-	documentObj, _ := e.vm.Object("document = {}")
-	
-	// Добавляем свойства
-	documentObj.Set("title", doc.Title)
-	
-	// Добавляем методы
-	documentObj.Set("getElementById", func(call otto.FunctionCall) otto.Value {
-		// Получаем ID из аргументов
-		id, _ := call.Argument(0).ToString()
-		
-		// Находим элемент по ID
-		element := doc.FindElementsByID(id)
-		
-		if element == nil {
-			// Если элемент не найден, возвращаем null
-			nullValue, _ := otto.NullValue().ToValue()
-			return nullValue
-		}
-		
-		// Создаем объект элемента
-		elementObj, _ := e.vm.Object("({})")
-		
-		// Добавляем свойства элемента
-		elementObj.Set("tagName", element.TagName)
-		elementObj.Set("id", element.ID)
-		elementObj.Set("innerHTML", element.GetInnerHTML())
-		
-		// Добавляем метод setAttribute
-		elementObj.Set("setAttribute", func(call otto.FunctionCall) otto.Value {
-			name, _ := call.Argument(0).ToString()
-			value, _ := call.Argument(1).ToString()
-			
-			element.Attributes[name] = value
-			
-			// Специальная обработка для id
-			if name == "id" {
-				element.ID = value
-			}
-			
-			// Возвращаем undefined
-			undefinedValue, _ := otto.UndefinedValue().ToValue()
-			return undefinedValue
-		})
-		
-		// Добавляем свойство textContent с геттером и сеттером
-		elementObj.Set("textContent", element.Text)
-		
-		// Возвращаем объект элемента
-		return elementObj.Value()
-	})
-	
-	// Добавляем метод getElementsByTagName
-	documentObj.Set("getElementsByTagName", func(call otto.FunctionCall) otto.Value {
-		// Получаем имя тега из аргументов
-		tagName, _ := call.Argument(0).ToString()
-		
-		// Находим элементы по имени тега
-		elements := doc.FindElementsByTagName(tagName)
-		
-		// Создаем массив для результатов
-		resultArray, _ := e.vm.Object("([])")
-		
-		// Добавляем каждый элемент в массив
-		for i, element := range elements {
-			// Создаем объект элемента
-			elementObj, _ := e.vm.Object("({})")
-			
-			// Добавляем свойства элемента
-			elementObj.Set("tagName", element.TagName)
-			elementObj.Set("id", element.ID)
-			elementObj.Set("innerHTML", element.GetInnerHTML())
-			
-			// Добавляем элемент в массив
-			resultArray.Set(i, elementObj)
-		}
-		
-		// Возвращаем массив элементов
-		return resultArray.Value()
-	})
-	
-	// Добавляем метод createElement
-	documentObj.Set("createElement", func(call otto.FunctionCall) otto.Value {
-		// Получаем имя тега из аргументов
-		tagName, _ := call.Argument(0).ToString()
-		
-		// Создаем новый элемент
-		element := html.Element{
-			TagName:    tagName,
-			Attributes: make(map[string]string),
-			Children:   make([]html.Element, 0),
+// javaScriptTypes — значения атрибута type, при которых <script> содержит
+// JavaScript (JavaScript MIME type essence match из спецификации HTML)
+var javaScriptTypes = map[string]bool{
+	"application/ecmascript":   true,
+	"application/javascript":   true,
+	"application/x-ecmascript": true,
+	"application/x-javascript": true,
+	"text/ecmascript":          true,
+	"text/javascript":          true,
+	"text/javascript1.0":       true,
+	"text/javascript1.1":       true,
+	"text/javascript1.2":       true,
+	"text/javascript1.3":       true,
+	"text/javascript1.4":       true,
+	"text/javascript1.5":       true,
+	"text/jscript":             true,
+	"text/livescript":          true,
+	"text/x-ecmascript":        true,
+	"text/x-javascript":        true,
+}
+
+// isClassicScript определяет по атрибутам type и language, содержит ли
+// элемент <script> классический скрипт. Без type и language, а также с
+// пустым type скрипт считается JavaScript
+func isClassicScript(script *html.Node) bool {
+	typ, ok := script.LookupAttribute("type")
+	if !ok {
+		language := script.GetAttribute("language")
+		if language == "" {
+			return true
 		}
-		
-		// Создаем объект элемента
-		elementObj, _ := e.vm.Object("({})")
-		
-		// Добавляем свойства элемента
-		elementObj.Set("tagName", element.TagName)
-		
-		// Возвращаем объект элемента
-		return elementObj.Value()
-	})
+		typ = "text/" + language
+	}
+	if typ == "" {
+		return true
+	}
+	return javaScriptTypes[strings.ToLower(strings.Trim(typ, " \t\n\f\r"))]
+}
+
+// executeExternal загружает и выполняет внешний скрипт. Адрес разрешается
+// относительно базового адреса документа
+func (e *Engine) executeExternal(doc *html.Document, src string) {
+	// Пустой src — ошибка, а не ссылка на сам документ
+	if strings.TrimSpace(src) == "" {
+		return
+	}
+	u, err := doc.ResolveURL(src)
+	if err != nil {
+		log.Printf("Некорректный адрес скрипта: %v", err)
+		return
+	}
+	if e.loadScript == nil {
+		log.Printf("Внешний скрипт не загружен: %s", u)
+		return
+	}
+	text, err := e.loadScript(u.StringWithoutFragment())
+	if err != nil {
+		log.Printf("Ошибка загрузки скрипта %s: %v", u, err)
+		return
+	}
+	if _, err := e.vm.Run(text); err != nil {
+		log.Printf("Ошибка выполнения JavaScript %s: %v", u, err)
+	}
 }
 
 // EvaluateScript выполняет JavaScript код и возвращает результат
@@ -169,4 +163,4 @@ func (e *Engine) EvaluateScript(script string) (string, error) {
 	
 	result, _ := value.ToString()
 	return result, nil
-}
\ No newline at end of file
+}
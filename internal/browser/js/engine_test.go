@@ -0,0 +1,34 @@
+package js
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExecuteSkipsDataBlocks(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	got, _ := runScript(t, `<!DOCTYPE html><head>
+<script type="application/ld+json">{"@context": "https://schema.org", "@type": "Article", "headline": "x"}</script>
+<script type="text/template"><p>{{name}}</p></script>
+<script type="module">window.order.push('module')</script>
+<script>window.order = ['plain']</script>
+<script type="">order.push('empty')</script>
+<script type=" Text/JavaScript ">order.push('text/javascript')</script>
+<script language="javascript1.5">order.push('language')</script>
+<script language="vbscript">order.push('vbscript')</script>
+<script type="text/javascript; charset=utf-8">order.push('parameters')</script>
+</head>`, `order.join(' ')`)
+
+	if want := "plain empty text/javascript language"; got != want {
+		t.Errorf("выполнены скрипты %q, ожидалось %q", got, want)
+	}
+	if strings.Contains(logs.String(), "Ошибка выполнения JavaScript") {
+		t.Errorf("блок данных выполнен как скрипт:\n%s", logs.String())
+	}
+}
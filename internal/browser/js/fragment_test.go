@@ -0,0 +1,71 @@
+package js
+
+import "testing"
+
+const fragmentFixture = `<!DOCTYPE html><body><div id=t><span>old</span></div><p id=after></p>`
+
+func TestMarkupSetters(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		// body — разметка body после выполнения скрипта
+		body string
+	}{
+		{"innerHTML", `document.getElementById('t').innerHTML = '<b>1</b>2'`,
+			`<div id="t"><b>1</b>2</div><p id="after"></p>`},
+		{"innerHTML с пустой строкой", `document.getElementById('t').innerHTML = ''`,
+			`<div id="t"></div><p id="after"></p>`},
+		// Разметка разбирается в контексте элемента: <tr> внутри <table>
+		{"innerHTML в контексте", `var t = document.createElement('table'); document.body.appendChild(t); t.innerHTML = '<tr><td>x</td></tr>'`,
+			`<div id="t"><span>old</span></div><p id="after"></p><table><tbody><tr><td>x</td></tr></tbody></table>`},
+		{"outerHTML", `document.getElementById('t').outerHTML = '<i>a</i><i>b</i>'`,
+			`<i>a</i><i>b</i><p id="after"></p>`},
+		{"beforebegin", `document.getElementById('t').insertAdjacentHTML('beforebegin', '<hr>')`,
+			`<hr><div id="t"><span>old</span></div><p id="after"></p>`},
+		{"afterbegin", `document.getElementById('t').insertAdjacentHTML('afterbegin', '<i>1</i>')`,
+			`<div id="t"><i>1</i><span>old</span></div><p id="after"></p>`},
+		{"beforeend", `document.getElementById('t').insertAdjacentHTML('beforeend', '<i>1</i>')`,
+			`<div id="t"><span>old</span><i>1</i></div><p id="after"></p>`},
+		{"afterend", `document.getElementById('t').insertAdjacentHTML('afterend', 'text<i>1</i>')`,
+			`<div id="t"><span>old</span></div>text<i>1</i><p id="after"></p>`},
+		{"позиция без учета регистра", `document.getElementById('t').insertAdjacentHTML('AfterEnd', '<hr>')`,
+			`<div id="t"><span>old</span></div><hr><p id="after"></p>`},
+	}
+	for _, test := range tests {
+		_, doc := runScript(t, fragmentFixture, test.script)
+		if got := doc.Body().InnerHTML(); got != test.body {
+			t.Errorf("%s: body = %q, ожидалось %q", test.name, got, test.body)
+		}
+	}
+}
+
+func TestOuterHTMLWithoutParent(t *testing.T) {
+	// У элемента без родителя outerHTML заменять нечем: присваивание
+	// ничего не делает и не выбрасывает исключение
+	got, _ := runScript(t, fragmentFixture, `var d = document.createElement('div');
+		d.innerHTML = '<b>x</b>';
+		d.outerHTML = '<p>y</p>';
+		d.outerHTML + ':' + (d.parentNode === null)`)
+	if want := "<div><b>x</b></div>:true"; got != want {
+		t.Errorf("получено %q, ожидалось %q", got, want)
+	}
+}
+
+func TestMarkupSetterErrors(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{`document.documentElement.outerHTML = '<html></html>'`, "NoModificationAllowedError"},
+		{`document.createElement('div').insertAdjacentHTML('beforebegin', '<p>')`, "NoModificationAllowedError"},
+		{`document.createElement('div').insertAdjacentHTML('afterend', '<p>')`, "NoModificationAllowedError"},
+		{`document.documentElement.insertAdjacentHTML('afterend', '<p>')`, "NoModificationAllowedError"},
+		{`document.body.insertAdjacentHTML('middle', '<p>')`, "SyntaxError"},
+	}
+	for _, test := range tests {
+		got, _ := runScript(t, fragmentFixture, `try { `+test.script+`; 'не выброшено' } catch (e) { e.name }`)
+		if got != test.want {
+			t.Errorf("%s: получено %q, ожидалось %s", test.script, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package js
+
+import "testing"
+
+const templateFixture = `<!DOCTYPE html><body><template id=tpl><p class=row><b>x</b></p><script>document.title = 'выполнен'</script></template><div id=out></div>`
+
+func TestTemplateContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		// Содержимое шаблона инертно: поиск по документу его не находит,
+		// а скрипты в нем не выполняются
+		{"querySelector не видит содержимое", `document.querySelector('p.row')`, "null"},
+		{"querySelectorAll не видит содержимое", `document.querySelectorAll('b').length`, "0"},
+		{"скрипт не выполнен", `document.title`, ""},
+		{"у шаблона нет детей", `document.getElementById('tpl').childNodes.length`, "0"},
+		{"content — фрагмент", `var c = document.getElementById('tpl').content; c.nodeType + ' ' + c.nodeName`, "11 #document-fragment"},
+		{"поиск внутри content", `document.getElementById('tpl').content.querySelector('p.row b').textContent`, "x"},
+		{"content — один и тот же объект", `document.getElementById('tpl').content === document.getElementById('tpl').content`, "true"},
+	}
+	for _, test := range tests {
+		if got, _ := runScript(t, templateFixture, test.script); got != test.want {
+			t.Errorf("%s: получено %q, ожидалось %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestImportTemplateContent(t *testing.T) {
+	got, doc := runScript(t, templateFixture, `var tpl = document.getElementById('tpl');
+		var copy = document.importNode(tpl.content, true);
+		var out = document.getElementById('out');
+		out.appendChild(copy);
+		out.querySelector('b').textContent = 'изменен';
+		[copy === tpl.content, copy.nodeType, tpl.content.querySelector('b').textContent, document.querySelectorAll('p.row').length].join(' ')`)
+	// Глубокая копия независима от шаблона: изменение вставленной копии не
+	// затрагивает content
+	if want := "false 11 x 1"; got != want {
+		t.Errorf("получено %q, ожидалось %q", got, want)
+	}
+	if html := doc.FindElementsByID("out").InnerHTML(); html != `<p class="row"><b>изменен</b></p><script>document.title = 'выполнен'</script>` {
+		t.Errorf("#out = %q", html)
+	}
+
+	// Поверхностный импорт и cloneNode(false) не копируют детей
+	got, _ = runScript(t, templateFixture, `var c = document.getElementById('tpl').content;
+		[document.importNode(c, false).childNodes.length, c.cloneNode(false).childNodes.length, c.cloneNode(true).childNodes.length].join(' ')`)
+	if want := "0 0 2"; got != want {
+		t.Errorf("поверхностные копии: получено %q, ожидалось %q", got, want)
+	}
+
+	// cloneNode(true) шаблона копирует и его content
+	got, _ = runScript(t, templateFixture, `var clone = document.getElementById('tpl').cloneNode(true);
+		clone.content !== document.getElementById('tpl').content && clone.content.querySelector('b').textContent`)
+	if got != "x" {
+		t.Errorf("cloneNode(true) шаблона: получено %q", got)
+	}
+}
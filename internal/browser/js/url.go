@@ -0,0 +1,85 @@
+package js
+
+import (
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/robertkrimen/otto"
+)
+
+// urlAttributes — атрибуты-адреса элементов HTML, которые отражаются в
+// одноименных свойствах с адресом, разрешенным относительно базового адреса
+var urlAttributes = map[string][]string{
+	"a":          {"href"},
+	"area":       {"href"},
+	"link":       {"href"},
+	"base":       {"href"},
+	"img":        {"src"},
+	"script":     {"src"},
+	"iframe":     {"src"},
+	"frame":      {"src"},
+	"embed":      {"src"},
+	"audio":      {"src"},
+	"video":      {"src", "poster"},
+	"source":     {"src"},
+	"track":      {"src"},
+	"input":      {"src"},
+	"object":     {"data"},
+	"blockquote": {"cite"},
+	"q":          {"cite"},
+	"del":        {"cite"},
+	"ins":        {"cite"},
+}
+
+// documentURL возвращает адрес документа или about:blank, если его нет
+func (e *Engine) documentURL() string {
+	if e.doc.URL == nil {
+		return "about:blank"
+	}
+	return e.doc.URL.String()
+}
+
+// baseURL возвращает базовый адрес документа
+func (e *Engine) baseURL() string {
+	if base := e.doc.BaseURL(); base != nil {
+		return base.String()
+	}
+	return e.documentURL()
+}
+
+// setupDocumentURL добавляет свойства document.URL, documentURI и baseURI
+func (e *Engine) setupDocumentURL(documentObj *otto.Object) {
+	e.defineAccessor(documentObj, "URL", func() interface{} {
+		return e.documentURL()
+	}, nil)
+	e.defineAccessor(documentObj, "documentURI", func() interface{} {
+		return e.documentURL()
+	}, nil)
+}
+
+// setupURLAttributes добавляет элементу свойства, отражающие атрибуты-адреса
+func (e *Engine) setupURLAttributes(obj *otto.Object, element *html.Node) {
+	if !element.IsHTML() {
+		return
+	}
+	for _, attr := range urlAttributes[element.TagName] {
+		attr := attr
+		e.defineAccessor(obj, attr, func() interface{} {
+			return e.doc.ResolveAttribute(element, attr)
+		}, func(value otto.Value) {
+			v, _ := value.ToString()
+			element.SetAttribute(attr, v)
+		})
+	}
+
+	// Пустое или отсутствующее действие формы — это адрес самого документа
+	if element.TagName == "form" {
+		e.defineAccessor(obj, "action", func() interface{} {
+			if element.GetAttribute("action") == "" {
+				return e.documentURL()
+			}
+			return e.doc.ResolveAttribute(element, "action")
+		}, func(value otto.Value) {
+			v, _ := value.ToString()
+			element.SetAttribute("action", v)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package js
+
+import (
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/style"
+	"github.com/robertkrimen/otto"
+)
+
+// SetDevice задает устройство, размеры и характеристики которого видны
+// скриптам через window.innerWidth, devicePixelRatio и matchMedia
+func (e *Engine) SetDevice(device style.Device) {
+	e.device = device
+}
+
+// setupWindow определяет глобальный объект window: размеры области
+// просмотра и matchMedia
+func (e *Engine) setupWindow() {
+	window, _ := e.vm.Object("this")
+	e.vm.Set("window", window)
+	e.vm.Set("self", window)
+
+	e.defineAccessor(window, "innerWidth", func() interface{} {
+		return int(e.device.Width)
+	}, nil)
+	e.defineAccessor(window, "innerHeight", func() interface{} {
+		return int(e.device.Height)
+	}, nil)
+	e.defineAccessor(window, "devicePixelRatio", func() interface{} {
+		if e.device.Resolution <= 0 {
+			return 1
+		}
+		return e.device.Resolution
+	}, nil)
+
+	window.Set("matchMedia", func(call otto.FunctionCall) otto.Value {
+		if len(call.ArgumentList) == 0 {
+			panic(e.vm.MakeTypeError("matchMedia: нужен аргумент"))
+		}
+		query, _ := call.Argument(0).ToString()
+		return e.newMediaQueryList(strings.TrimSpace(query))
+	})
+}
+
+// newMediaQueryList создает объект MediaQueryList. Устройство не меняется
+// во время выполнения скриптов, поэтому обработчики изменений не вызываются
+func (e *Engine) newMediaQueryList(query string) otto.Value {
+	list, _ := e.vm.Object("({})")
+	list.Set("media", query)
+	e.defineAccessor(list, "matches", func() interface{} {
+		return style.MatchMedia(query, e.device)
+	}, nil)
+	list.Set("onchange", otto.NullValue())
+	noop := func(call otto.FunctionCall) otto.Value {
+		return otto.UndefinedValue()
+	}
+	for _, name := range []string{"addListener", "removeListener", "addEventListener", "removeEventListener"} {
+		list.Set(name, noop)
+	}
+	return list.Value()
+}
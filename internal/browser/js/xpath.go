@@ -0,0 +1,137 @@
+package js
+
+import (
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/robertkrimen/otto"
+)
+
+// Типы результата XPathResult из спецификации DOM
+const (
+	anyType                   = 0
+	numberType                = 1
+	stringType                = 2
+	booleanType               = 3
+	unorderedNodeIteratorType = 4
+	orderedNodeIteratorType   = 5
+	unorderedNodeSnapshotType = 6
+	orderedNodeSnapshotType   = 7
+	anyUnorderedNodeType      = 8
+	firstOrderedNodeType      = 9
+)
+
+// xpathResultConstants — имена констант XPathResult
+var xpathResultConstants = map[string]int{
+	"ANY_TYPE":                     anyType,
+	"NUMBER_TYPE":                  numberType,
+	"STRING_TYPE":                  stringType,
+	"BOOLEAN_TYPE":                 booleanType,
+	"UNORDERED_NODE_ITERATOR_TYPE": unorderedNodeIteratorType,
+	"ORDERED_NODE_ITERATOR_TYPE":   orderedNodeIteratorType,
+	"UNORDERED_NODE_SNAPSHOT_TYPE": unorderedNodeSnapshotType,
+	"ORDERED_NODE_SNAPSHOT_TYPE":   orderedNodeSnapshotType,
+	"ANY_UNORDERED_NODE_TYPE":      anyUnorderedNodeType,
+	"FIRST_ORDERED_NODE_TYPE":      firstOrderedNodeType,
+}
+
+// setupXPath добавляет document.evaluate и глобальный объект XPathResult
+func (e *Engine) setupXPath(documentObj *otto.Object) {
+	constants, _ := e.vm.Object("({})")
+	for name, value := range xpathResultConstants {
+		constants.Set(name, value)
+	}
+	e.vm.Set("XPathResult", constants)
+
+	documentObj.Set("evaluate", func(call otto.FunctionCall) otto.Value {
+		expr, _ := call.Argument(0).ToString()
+
+		context := e.unwrapNode(call.Argument(1))
+		if context == nil {
+			context = &e.doc.Node
+		}
+
+		resultType := int64(anyType)
+		if arg := call.Argument(3); !arg.IsUndefined() {
+			resultType, _ = arg.ToInteger()
+		}
+
+		compiled, err := html.CompileXPath(expr)
+		if err != nil {
+			e.throwError("SyntaxError", err.Error())
+		}
+		result, err := compiled.Evaluate(context)
+		if err != nil {
+			panic(e.vm.MakeTypeError(err.Error()))
+		}
+
+		return e.newXPathResult(result, int(resultType))
+	})
+}
+
+// newXPathResult создает JS-объект XPathResult нужного типа
+func (e *Engine) newXPathResult(result *html.XPathResult, resultType int) otto.Value {
+	if resultType == anyType {
+		switch result.Type {
+		case html.XPathNumber:
+			resultType = numberType
+		case html.XPathString:
+			resultType = stringType
+		case html.XPathBoolean:
+			resultType = booleanType
+		default:
+			resultType = unorderedNodeIteratorType
+		}
+	}
+
+	obj, _ := e.vm.Object("({})")
+	obj.Set("resultType", resultType)
+
+	// Запрошенный тип приводится из фактического результата
+	switch resultType {
+	case numberType:
+		obj.Set("numberValue", result.NumberValue())
+		return obj.Value()
+	case stringType:
+		obj.Set("stringValue", result.StringValue())
+		return obj.Value()
+	case booleanType:
+		obj.Set("booleanValue", result.BooleanValue())
+		return obj.Value()
+	}
+
+	if result.Type != html.XPathNodeSet {
+		panic(e.vm.MakeTypeError("Результат выражения XPath не является набором узлов"))
+	}
+	nodes := result.Nodes
+
+	switch resultType {
+	case unorderedNodeIteratorType, orderedNodeIteratorType:
+		next := 0
+		obj.Set("invalidIteratorState", false)
+		obj.Set("iterateNext", func(call otto.FunctionCall) otto.Value {
+			if next >= len(nodes) {
+				return otto.NullValue()
+			}
+			next++
+			return e.wrapNode(nodes[next-1])
+		})
+	case unorderedNodeSnapshotType, orderedNodeSnapshotType:
+		obj.Set("snapshotLength", len(nodes))
+		obj.Set("snapshotItem", func(call otto.FunctionCall) otto.Value {
+			i, _ := call.Argument(0).ToInteger()
+			if i < 0 || int(i) >= len(nodes) {
+				return otto.NullValue()
+			}
+			return e.wrapNode(nodes[i])
+		})
+	case anyUnorderedNodeType, firstOrderedNodeType:
+		var first *html.Node
+		if len(nodes) > 0 {
+			first = nodes[0]
+		}
+		obj.Set("singleNodeValue", e.wrapNode(first))
+	default:
+		panic(e.vm.MakeTypeError("Неизвестный тип результата XPath"))
+	}
+
+	return obj.Value()
+}
@@ -0,0 +1,64 @@
+package js
+
+import "testing"
+
+const xpathFixture = `<!DOCTYPE html><ul id=list><li id=l1>один</li><li id=l2 class=a>два</li><li id=l3 class=a>три</li></ul>`
+
+func TestDocumentEvaluate(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"snapshot", `var r = document.evaluate('//li[@class="a"]', document, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+			r.resultType + ':' + r.snapshotLength + ':' + r.snapshotItem(0).id + ':' + r.snapshotItem(1).id + ':' + r.snapshotItem(2)`,
+			"7:2:l2:l3:null"},
+		{"iterator", `var r = document.evaluate('//li', document, null, XPathResult.ORDERED_NODE_ITERATOR_TYPE, null);
+			var ids = [], n;
+			while ((n = r.iterateNext())) ids.push(n.id);
+			r.resultType + ':' + ids.join(' ')`,
+			"5:l1 l2 l3"},
+		{"number", `var r = document.evaluate('count(//li)', document, null, XPathResult.NUMBER_TYPE, null);
+			r.resultType + ':' + r.numberValue`,
+			"1:3"},
+		{"string", `var r = document.evaluate('//li[2]', document, null, XPathResult.STRING_TYPE, null);
+			r.resultType + ':' + r.stringValue`,
+			"2:два"},
+		{"boolean", `var r = document.evaluate('//li[@id="l9"]', document, null, XPathResult.BOOLEAN_TYPE, null);
+			r.resultType + ':' + r.booleanValue`,
+			"3:false"},
+		{"first ordered node", `var r = document.evaluate('//li[@class="a"]', document, null, XPathResult.FIRST_ORDERED_NODE_TYPE, null);
+			r.resultType + ':' + r.singleNodeValue.id`,
+			"9:l2"},
+		{"first ordered node без результата", `document.evaluate('//table', document, null, XPathResult.FIRST_ORDERED_NODE_TYPE, null).singleNodeValue`,
+			"null"},
+		{"ANY_TYPE по типу выражения", `document.evaluate('string(//li)', document, null, XPathResult.ANY_TYPE, null).resultType`,
+			"2"},
+		{"контекстный узел", `document.evaluate('count(li)', document.getElementById('list'), null, XPathResult.NUMBER_TYPE, null).numberValue`,
+			"3"},
+		{"узел из результата — тот же объект", `document.evaluate('//li', document, null, XPathResult.FIRST_ORDERED_NODE_TYPE, null).singleNodeValue === document.getElementById('l1')`,
+			"true"},
+	}
+	for _, test := range tests {
+		if got, _ := runScript(t, xpathFixture, test.script); got != test.want {
+			t.Errorf("%s: получено %q, ожидалось %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDocumentEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{`document.evaluate('//li[', document, null, XPathResult.ANY_TYPE, null)`, "SyntaxError"},
+		// Число нельзя привести к набору узлов
+		{`document.evaluate('count(//li)', document, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null)`, "TypeError"},
+	}
+	for _, test := range tests {
+		got, _ := runScript(t, xpathFixture, `try { `+test.script+`; 'не выброшено' } catch (e) { e.name }`)
+		if got != test.want {
+			t.Errorf("%s: получено %q, ожидалось %s", test.script, got, test.want)
+		}
+	}
+}
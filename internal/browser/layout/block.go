@@ -0,0 +1,426 @@
+package layout
+
+import (
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/style"
+)
+
+// Layout строит дерево блоков документа и раскладывает его в области
+// просмотра размером width×height. Плавающие и абсолютно позиционированные
+// блоки пока остаются в обычном потоке, а таблицы, flex и grid
+// раскладываются как обычные блоки
+func Layout(doc *html.Document, styles map[*html.Node]*style.ComputedStyle, width, height float64) *Box {
+	root := BuildTree(doc, styles)
+	if root == nil {
+		return nil
+	}
+	layoutBlock(root, width, height)
+	// Отступы корневого элемента ни с чем не схлопываются
+	place(root, root.Margin.Top)
+	translate(root, 0, 0)
+	return root
+}
+
+// marginSet — набор схлопнувшихся вертикальных отступов. Итоговый отступ
+// равен сумме наибольшего положительного и наименьшего отрицательного
+type marginSet struct {
+	positive, negative float64
+}
+
+// add добавляет отступ в набор
+func (m marginSet) add(v float64) marginSet {
+	m.positive = max(m.positive, v)
+	m.negative = min(m.negative, v)
+	return m
+}
+
+// join объединяет два набора отступов
+func (m marginSet) join(o marginSet) marginSet {
+	return marginSet{positive: max(m.positive, o.positive), negative: min(m.negative, o.negative)}
+}
+
+// value возвращает итоговый отступ
+func (m marginSet) value() float64 {
+	return m.positive + m.negative
+}
+
+// blockResult — внешние отступы разложенного блока, которые схлопываются
+// через его верхний и нижний край с отступами соседей и родителя
+type blockResult struct {
+	top, bottom marginSet
+	// collapsesThrough — блок пустой, и его верхний и нижний отступы
+	// схлопываются друг с другом
+	collapsesThrough bool
+}
+
+// flow — результат раскладки содержимого блока
+type flow struct {
+	height float64
+	// top — отступы, примыкающие к верхнему краю содержимого; bottom —
+	// отступы после последнего непустого ребенка
+	top, bottom marginSet
+	// empty — в содержимом нет ни строк, ни непустых блоков
+	empty bool
+}
+
+// layoutBlock раскладывает блок уровня блока в содержащем блоке шириной
+// cbWidth и высотой cbHeight (отрицательная высота зависит от содержимого).
+// Горизонтальные координаты отсчитываются от области содержимого
+// содержащего блока, а вертикальное положение задает родитель через place
+func layoutBlock(box *Box, cbWidth, cbHeight float64) blockResult {
+	if box.Type == AnonymousBox {
+		box.Content.Width = cbWidth
+	} else {
+		resolveEdges(box, cbWidth)
+		resolveBlockWidth(box, cbWidth, cbHeight)
+	}
+	height, definite := specifiedHeight(box, cbHeight)
+	inner := -1.0
+	if definite {
+		inner = height
+	}
+
+	bfc := establishesBFC(box)
+	topAdjoins := !bfc && box.Border.Top == 0 && box.Padding.Top == 0
+	bottomAdjoins := !bfc && !definite && box.Border.Bottom == 0 && box.Padding.Bottom == 0
+	f := layoutContents(box, inner, topAdjoins)
+	result := blockResult{top: marginSet{}.add(box.Margin.Top), bottom: marginSet{}.add(box.Margin.Bottom)}
+	if !definite {
+		height = f.height
+	}
+	minHeight, _ := contentSize(box, "min-height", cbHeight, false)
+	if f.empty && topAdjoins && bottomAdjoins && minHeight == 0 {
+		// Пустой блок: его отступы и отступы детей схлопываются вместе
+		all := result.top.join(f.bottom).join(result.bottom)
+		result = blockResult{top: all, bottom: all, collapsesThrough: true}
+	} else {
+		trailing := f.bottom
+		if topAdjoins {
+			result.top = result.top.join(f.top)
+			if f.empty {
+				trailing = marginSet{}
+			}
+		}
+		if bottomAdjoins {
+			result.bottom = result.bottom.join(trailing)
+		} else if !definite {
+			height += trailing.value()
+		}
+	}
+
+	box.Content.Height = clampHeight(box, height, cbHeight)
+	box.Content.X = box.Margin.Left + box.Border.Left + box.Padding.Left
+	box.offsetX, box.offsetY = relativeOffset(box.Style, cbWidth, cbHeight)
+	return result
+}
+
+// layoutAtomic раскладывает атомарный строчный блок в строке шириной
+// cbWidth. Координаты области содержимого отсчитываются от левого верхнего
+// угла прямоугольника отступов; положение в строке задает раскладка строк
+func layoutAtomic(box *Box, cbWidth float64) {
+	resolveEdges(box, cbWidth)
+	width, ok := contentSize(box, "width", cbWidth, true)
+	switch {
+	case box.replaced:
+		width = replacedWidth(box, cbWidth, -1)
+	case !ok:
+		// Ширина по содержимому (shrink-to-fit)
+		available := max(0, cbWidth-box.Margin.Horizontal()-box.Padding.Horizontal()-box.Border.Horizontal())
+		minContent, maxContent := intrinsicWidths(box)
+		width = min(max(minContent, available), maxContent)
+	}
+	box.Content.Width = clampWidth(box, width, cbWidth)
+
+	height, definite := specifiedHeight(box, -1)
+	inner := -1.0
+	if definite {
+		inner = height
+	}
+	// Атомарный блок создает свой контекст форматирования: отступы детей
+	// остаются внутри него
+	f := layoutContents(box, inner, false)
+	if !definite {
+		height = f.height + f.bottom.value()
+	}
+	box.Content.Height = clampHeight(box, height, -1)
+	box.Content.X = box.Margin.Left + box.Border.Left + box.Padding.Left
+	box.Content.Y = box.Margin.Top + box.Border.Top + box.Padding.Top
+	box.offsetX, box.offsetY = relativeOffset(box.Style, cbWidth, -1)
+}
+
+// layoutContents раскладывает содержимое блока: детей уровня блока друг под
+// другом или строки со строчным содержимым
+func layoutContents(box *Box, cbHeight float64, topAdjoins bool) flow {
+	switch {
+	case box.replaced:
+		return flow{height: replacedHeight(box)}
+	case len(box.Children) > 0 && box.Children[0].IsBlockLevel():
+		return layoutBlockChildren(box, cbHeight, topAdjoins)
+	}
+	height, lines := layoutInline(box)
+	return flow{height: height, empty: lines == 0}
+}
+
+// layoutBlockChildren раскладывает детей уровня блока друг под другом,
+// схлопывая отступы соседей. Если верхний край блока примыкает к первому
+// ребенку (нет рамки и полей), отступ ребенка уходит наружу через flow.top
+func layoutBlockChildren(box *Box, cbHeight float64, topAdjoins bool) flow {
+	var f flow
+	var pending marginSet
+	y := 0.0
+	empty := true
+	for _, child := range box.Children {
+		result := layoutBlock(child, box.Content.Width, cbHeight)
+		pending = pending.join(result.top)
+		if result.collapsesThrough {
+			// Пустой блок стоит там, где оказался бы верхний край следующего
+			if empty && topAdjoins {
+				place(child, y)
+			} else {
+				place(child, y+pending.value())
+			}
+			pending = pending.join(result.bottom)
+			continue
+		}
+		if empty && topAdjoins {
+			f.top = pending
+		} else {
+			y += pending.value()
+		}
+		place(child, y)
+		y += child.BorderRect().Height
+		pending = result.bottom
+		empty = false
+	}
+	if empty {
+		f.top = pending
+	}
+	f.height, f.bottom, f.empty = y, pending, empty
+	return f
+}
+
+// place помещает верхний край рамки блока на высоту y
+func place(box *Box, y float64) {
+	box.Content.Y = y + box.Border.Top + box.Padding.Top
+}
+
+// resolveEdges вычисляет поля, рамку и отступы блока. Проценты в полях и
+// отступах, в том числе вертикальных, берутся от ширины содержащего блока;
+// auto в отступах пока считается нулем
+func resolveEdges(box *Box, cbWidth float64) {
+	s := box.Style
+	box.Padding = Edges{
+		Top:    max(0, s.Length("padding-top").Resolve(cbWidth)),
+		Right:  max(0, s.Length("padding-right").Resolve(cbWidth)),
+		Bottom: max(0, s.Length("padding-bottom").Resolve(cbWidth)),
+		Left:   max(0, s.Length("padding-left").Resolve(cbWidth)),
+	}
+	box.Border = Edges{
+		Top:    s.Length("border-top-width").Resolve(0),
+		Right:  s.Length("border-right-width").Resolve(0),
+		Bottom: s.Length("border-bottom-width").Resolve(0),
+		Left:   s.Length("border-left-width").Resolve(0),
+	}
+	box.Margin = Edges{
+		Top:    s.Length("margin-top").Resolve(cbWidth),
+		Right:  s.Length("margin-right").Resolve(cbWidth),
+		Bottom: s.Length("margin-bottom").Resolve(cbWidth),
+		Left:   s.Length("margin-left").Resolve(cbWidth),
+	}
+}
+
+// resolveBlockWidth вычисляет ширину и горизонтальные отступы блока в
+// обычном потоке (CSS 2.1, 10.3.3 и 10.4): сначала по width, затем, если
+// результат нарушает max-width или min-width, по ним. Каждый раз уравнение
+// решается заново от отступов из стиля
+func resolveBlockWidth(box *Box, cbWidth, cbHeight float64) {
+	width, ok := contentSize(box, "width", cbWidth, true)
+	if box.replaced {
+		width, ok = replacedWidth(box, cbWidth, cbHeight), true
+	}
+	margin := box.Margin
+	solveWidth(box, cbWidth, width, !ok)
+	if maxWidth, ok := contentSize(box, "max-width", cbWidth, true); ok && box.Content.Width > maxWidth {
+		box.Margin = margin
+		solveWidth(box, cbWidth, maxWidth, false)
+	}
+	if minWidth, ok := contentSize(box, "min-width", cbWidth, true); ok && box.Content.Width < minWidth {
+		box.Margin = margin
+		solveWidth(box, cbWidth, minWidth, false)
+	}
+}
+
+// solveWidth решает уравнение ширины блока: сумма отступов, рамки, полей и
+// ширины равна ширине содержащего блока. auto в width забирает все
+// свободное место, два auto в отступах делят его поровну, а при переполнении
+// меняется отступ в конце строки (правый при direction: ltr)
+func solveWidth(box *Box, cbWidth, width float64, auto bool) {
+	s := box.Style
+	leftAuto, rightAuto := s.Length("margin-left").IsAuto(), s.Length("margin-right").IsAuto()
+	left, right := box.Margin.Left, box.Margin.Right
+	edges := box.Padding.Horizontal() + box.Border.Horizontal()
+	if auto {
+		width = max(0, cbWidth-left-right-edges)
+	}
+	free := cbWidth - width - edges - left - right
+	if free < 0 {
+		leftAuto, rightAuto = false, false
+	}
+	switch {
+	case auto:
+	case leftAuto && rightAuto:
+		left, right = left+free/2, right+free/2
+	case leftAuto:
+		left += free
+	case rightAuto:
+		right += free
+	case s.Keyword("direction") == "rtl":
+		left += free
+	default:
+		right += free
+	}
+	box.Margin.Left, box.Margin.Right = left, right
+	box.Content.Width = width
+}
+
+// contentSize переводит значение width, height или их ограничений в размер
+// области содержимого с учетом box-sizing. ok == false, если значение —
+// ключевое слово или проценты от размера, зависящего от содержимого
+func contentSize(box *Box, name string, base float64, horizontal bool) (float64, bool) {
+	l := box.Style.Length(name)
+	if !l.IsLength() || l.HasPercent() && base < 0 {
+		return 0, false
+	}
+	v := l.Resolve(base)
+	if box.Style.Keyword("box-sizing") == "border-box" {
+		if horizontal {
+			v -= box.Padding.Horizontal() + box.Border.Horizontal()
+		} else {
+			v -= box.Padding.Vertical() + box.Border.Vertical()
+		}
+	}
+	return max(0, v), true
+}
+
+// specifiedHeight возвращает высоту области содержимого, заданную в стиле
+func specifiedHeight(box *Box, cbHeight float64) (float64, bool) {
+	if box.Type == AnonymousBox {
+		return 0, false
+	}
+	return contentSize(box, "height", cbHeight, false)
+}
+
+// clampWidth ограничивает ширину min-width и max-width
+func clampWidth(box *Box, width, cbWidth float64) float64 {
+	if maxWidth, ok := contentSize(box, "max-width", cbWidth, true); ok {
+		width = min(width, maxWidth)
+	}
+	if minWidth, ok := contentSize(box, "min-width", cbWidth, true); ok {
+		width = max(width, minWidth)
+	}
+	return width
+}
+
+// clampHeight ограничивает высоту min-height и max-height
+func clampHeight(box *Box, height, cbHeight float64) float64 {
+	if box.Type == AnonymousBox {
+		return height
+	}
+	if maxHeight, ok := contentSize(box, "max-height", cbHeight, false); ok {
+		height = min(height, maxHeight)
+	}
+	if minHeight, ok := contentSize(box, "min-height", cbHeight, false); ok {
+		height = max(height, minHeight)
+	}
+	return height
+}
+
+// replacedWidth возвращает ширину замещаемого элемента: из стиля, по
+// пропорциям из заданной высоты или собственную
+func replacedWidth(box *Box, cbWidth, cbHeight float64) float64 {
+	if width, ok := contentSize(box, "width", cbWidth, true); ok {
+		return width
+	}
+	w, h := intrinsicSize(box.Node)
+	if height, ok := contentSize(box, "height", cbHeight, false); ok && w > 0 && h > 0 {
+		return height * w / h
+	}
+	return w
+}
+
+// replacedHeight возвращает высоту замещаемого элемента при height: auto:
+// по пропорциям из ширины, заданной в стиле, или собственную
+func replacedHeight(box *Box) float64 {
+	w, h := intrinsicSize(box.Node)
+	if w > 0 && h > 0 && box.Style.Length("width").IsLength() {
+		return box.Content.Width * h / w
+	}
+	return h
+}
+
+// establishesBFC проверяет, создает ли блок новый блочный контекст
+// форматирования. Отступы такого блока не схлопываются с отступами детей
+func establishesBFC(box *Box) bool {
+	if box.root || box.Type == InlineBlockBox {
+		return true
+	}
+	if box.Type == AnonymousBox {
+		return false
+	}
+	s := box.Style
+	switch s.Display() {
+	case "flow-root", "flex", "grid", "table", "table-cell", "table-caption":
+		return true
+	}
+	if float := s.Keyword("float"); float != "" && float != "none" {
+		return true
+	}
+	if position := s.Keyword("position"); position == "absolute" || position == "fixed" {
+		return true
+	}
+	for _, name := range []string{"overflow-x", "overflow-y"} {
+		if overflow := s.Keyword(name); overflow != "" && overflow != "visible" && overflow != "clip" {
+			return true
+		}
+	}
+	return false
+}
+
+// relativeOffset возвращает сдвиг блока с position: relative. left важнее
+// right, а top важнее bottom
+func relativeOffset(s *style.ComputedStyle, cbWidth, cbHeight float64) (dx, dy float64) {
+	if s.Keyword("position") != "relative" {
+		return 0, 0
+	}
+	if left := s.Length("left"); left.IsLength() {
+		dx = left.Resolve(cbWidth)
+	} else if right := s.Length("right"); right.IsLength() {
+		dx = -right.Resolve(cbWidth)
+	}
+	if top := s.Length("top"); top.IsLength() && !(top.HasPercent() && cbHeight < 0) {
+		dy = top.Resolve(cbHeight)
+	} else if bottom := s.Length("bottom"); bottom.IsLength() && !(bottom.HasPercent() && cbHeight < 0) {
+		dy = -bottom.Resolve(cbHeight)
+	}
+	return dx, dy
+}
+
+// translate переводит координаты блока и его потомков в координаты
+// документа. Координаты блока отсчитываются от (x, y) — области содержимого
+// родителя; у строчного содержимого это область содержимого блока, в
+// котором лежат строки
+func translate(box *Box, x, y float64) {
+	x, y = x+box.offsetX, y+box.offsetY
+	box.Content.X += x
+	box.Content.Y += y
+	for i := range box.Fragments {
+		box.Fragments[i].X += x
+		box.Fragments[i].Y += y
+	}
+	if box.Type != InlineBox {
+		x, y = box.Content.X, box.Content.Y
+	}
+	for _, child := range box.Children {
+		translate(child, x, y)
+	}
+}
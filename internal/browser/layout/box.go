@@ -0,0 +1,144 @@
+// Package layout раскладывает документ по блочной модели CSS: строит по DOM
+// и вычисленным стилям дерево блоков и рассчитывает для каждого блока
+// прямоугольники содержимого, внутренних полей, рамки и внешних отступов
+package layout
+
+import (
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/style"
+)
+
+// Rect — прямоугольник в пикселях CSS в координатах документа
+type Rect struct {
+	X, Y          float64
+	Width, Height float64
+}
+
+// Right возвращает координату правого края прямоугольника
+func (r Rect) Right() float64 {
+	return r.X + r.Width
+}
+
+// Bottom возвращает координату нижнего края прямоугольника
+func (r Rect) Bottom() float64 {
+	return r.Y + r.Height
+}
+
+// Expand возвращает прямоугольник, расширенный на e с каждой стороны
+func (r Rect) Expand(e Edges) Rect {
+	return Rect{
+		X:      r.X - e.Left,
+		Y:      r.Y - e.Top,
+		Width:  r.Width + e.Horizontal(),
+		Height: r.Height + e.Vertical(),
+	}
+}
+
+// Union возвращает наименьший прямоугольник, содержащий r и o
+func (r Rect) Union(o Rect) Rect {
+	x, y := min(r.X, o.X), min(r.Y, o.Y)
+	return Rect{X: x, Y: y, Width: max(r.Right(), o.Right()) - x, Height: max(r.Bottom(), o.Bottom()) - y}
+}
+
+// Edges — толщина полей, рамки или отступов с каждой стороны блока
+type Edges struct {
+	Top, Right, Bottom, Left float64
+}
+
+// Horizontal возвращает сумму левой и правой сторон
+func (e Edges) Horizontal() float64 {
+	return e.Left + e.Right
+}
+
+// Vertical возвращает сумму верхней и нижней сторон
+func (e Edges) Vertical() float64 {
+	return e.Top + e.Bottom
+}
+
+// BoxType — вид блока
+type BoxType int
+
+const (
+	// BlockBox — блок уровня блока: block, list-item, table, flex...
+	BlockBox BoxType = iota
+	// InlineBox — строчный блок (display: inline), который разбивается на
+	// фрагменты по строкам
+	InlineBox
+	// InlineBlockBox — атомарный строчный блок: inline-block или
+	// замещаемый элемент (img, video, input...)
+	InlineBlockBox
+	// AnonymousBox — анонимный блок, в который заключено строчное
+	// содержимое, стоящее рядом с блоками
+	AnonymousBox
+	// TextBox — текст
+	TextBox
+)
+
+// String возвращает название вида блока
+func (t BoxType) String() string {
+	switch t {
+	case BlockBox:
+		return "block"
+	case InlineBox:
+		return "inline"
+	case InlineBlockBox:
+		return "inline-block"
+	case AnonymousBox:
+		return "anonymous"
+	case TextBox:
+		return "text"
+	}
+	return "unknown"
+}
+
+// Box — блок дерева раскладки
+type Box struct {
+	Type BoxType
+	// Node — элемент или текстовый узел, породивший блок; nil у анонимных
+	// блоков
+	Node *html.Node
+	// Style — вычисленный стиль элемента. У текста и анонимных блоков это
+	// стиль родительского элемента
+	Style *style.ComputedStyle
+	// Text — текст блока TextBox
+	Text     string
+	Children []*Box
+
+	// Content — область содержимого. Padding, Border и Margin — толщина
+	// внутренних полей, рамки и внешних отступов вокруг нее
+	Content Rect
+	Padding Edges
+	Border  Edges
+	Margin  Edges
+	// Fragments — части строчного блока или текста на каждой из строк
+	Fragments []Rect
+
+	// replaced — замещаемый элемент: его содержимое не раскладывается, а
+	// размер берется из атрибутов
+	replaced bool
+	// root — блок корневого элемента документа
+	root bool
+	// offset — сдвиг при position: relative
+	offsetX, offsetY float64
+}
+
+// PaddingRect возвращает прямоугольник по внешнему краю внутренних полей
+func (b *Box) PaddingRect() Rect {
+	return b.Content.Expand(b.Padding)
+}
+
+// BorderRect возвращает прямоугольник по внешнему краю рамки
+func (b *Box) BorderRect() Rect {
+	return b.PaddingRect().Expand(b.Border)
+}
+
+// MarginRect возвращает прямоугольник по внешнему краю внешних отступов
+func (b *Box) MarginRect() Rect {
+	return b.BorderRect().Expand(b.Margin)
+}
+
+// IsBlockLevel проверяет, участвует ли блок в блочном контексте
+// форматирования
+func (b *Box) IsBlockLevel() bool {
+	return b.Type == BlockBox || b.Type == AnonymousBox
+}
@@ -0,0 +1,412 @@
+package layout
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/baneronetwo/gluglu/internal/browser/style"
+)
+
+// itemKind — вид элемента строки
+type itemKind int
+
+const (
+	wordItem itemKind = iota
+	spaceItem
+	// breakItem — принудительный перенос: <br> или перевод строки в pre
+	breakItem
+	// atomicItem — атомарный строчный блок
+	atomicItem
+	// openItem и closeItem — начало и конец строчного блока: его поля,
+	// рамка и отступ слева и справа
+	openItem
+	closeItem
+)
+
+// inlineItem — неделимый элемент строчного содержимого
+type inlineItem struct {
+	kind  itemKind
+	width float64
+	// height — высота строки текста или высота атомарного блока вместе с
+	// отступами
+	height float64
+	// owners — блоки, фрагменты которых включают элемент: текст и
+	// строчные блоки, в которые он вложен. Фрагменты считаются по области
+	// содержимого, без полей и рамки
+	owners []*Box
+	// atomic — блок элемента atomicItem
+	atomic *Box
+	// collapsible — пробел схлопывается; wrap — после пробела можно
+	// перенести строку
+	collapsible, wrap bool
+}
+
+// inlineFormatter раскладывает строчное содержимое блока по строкам
+type inlineFormatter struct {
+	container *Box
+	width     float64
+	items     []inlineItem
+	// y — верхний край следующей строки, lines — число строк
+	y     float64
+	lines int
+	// lineOf — номер строки последнего фрагмента блока
+	lineOf map[*Box]int
+	owners []*Box
+}
+
+// layoutInline раскладывает строчное содержимое блока и возвращает высоту
+// строк и их число. Шрифты не загружаются, поэтому ширина текста
+// оценивается по размеру шрифта (см. charWidth)
+func layoutInline(box *Box) (float64, int) {
+	f := &inlineFormatter{container: box, width: box.Content.Width, lineOf: make(map[*Box]int)}
+	f.collect(box.Children, nil)
+	f.breakLines()
+	for _, owner := range f.owners {
+		if len(owner.Fragments) == 0 {
+			continue
+		}
+		owner.Content = owner.Fragments[0]
+		for _, fragment := range owner.Fragments[1:] {
+			owner.Content = owner.Content.Union(fragment)
+		}
+	}
+	return f.y, f.lines
+}
+
+// collect разбирает строчных детей на элементы строки
+func (f *inlineFormatter) collect(children []*Box, owners []*Box) {
+	for _, child := range children {
+		switch child.Type {
+		case TextBox:
+			f.addOwner(child)
+			f.collectText(child, append(owners[:len(owners):len(owners)], child))
+		case InlineBlockBox:
+			layoutAtomic(child, f.width)
+			m := child.MarginRect()
+			f.items = append(f.items, inlineItem{kind: atomicItem, width: m.Width, height: m.Height, owners: owners, atomic: child})
+		case InlineBox:
+			f.addOwner(child)
+			inner := append(owners[:len(owners):len(owners)], child)
+			if child.Node != nil && child.Node.IsHTML() && child.Node.TagName == "br" {
+				f.items = append(f.items, inlineItem{kind: breakItem, owners: inner})
+				continue
+			}
+			// Поля, рамка и отступы строчного блока сдвигают соседей только по
+			// горизонтали и в его фрагменты не входят
+			resolveEdges(child, f.width)
+			child.offsetX, child.offsetY = relativeOffset(child.Style, f.width, -1)
+			f.items = append(f.items, inlineItem{kind: openItem, width: child.Margin.Left + child.Border.Left + child.Padding.Left, owners: owners})
+			f.collect(child.Children, inner)
+			f.items = append(f.items, inlineItem{kind: closeItem, width: child.Margin.Right + child.Border.Right + child.Padding.Right, owners: owners})
+		}
+	}
+}
+
+// addOwner запоминает блок, которому нужно рассчитать фрагменты
+func (f *inlineFormatter) addOwner(box *Box) {
+	box.Fragments = nil
+	f.owners = append(f.owners, box)
+}
+
+// collectText разбивает текст на слова и пробелы с учетом white-space
+func (f *inlineFormatter) collectText(box *Box, owners []*Box) {
+	s := box.Style
+	cw := charWidth(s)
+	height := s.LineHeight()
+	preserve, newlines, wrap := preservesSpaces(s), preservesNewlines(s), wraps(s)
+	wordSpacing := s.Length("word-spacing").Resolve(s.FontSize())
+
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			width := float64(utf8.RuneCountInString(word.String())) * cw
+			f.items = append(f.items, inlineItem{kind: wordItem, width: width, height: height, owners: owners})
+			word.Reset()
+		}
+	}
+	for _, r := range box.Text {
+		switch {
+		case r == '\n' && newlines:
+			flush()
+			f.items = append(f.items, inlineItem{kind: breakItem, height: height, owners: owners})
+		case isSpace(r) && preserve:
+			flush()
+			width := cw + wordSpacing
+			if r == '\t' {
+				width = 8 * cw
+			}
+			f.items = append(f.items, inlineItem{kind: spaceItem, width: width, owners: owners, wrap: wrap})
+		case isSpace(r):
+			flush()
+			// Пробелы подряд, в том числе на границе элементов, схлопываются
+			if n := len(f.items); n > 0 && f.items[n-1].kind == spaceItem && f.items[n-1].collapsible {
+				continue
+			}
+			f.items = append(f.items, inlineItem{kind: spaceItem, width: cw + wordSpacing, owners: owners, collapsible: true, wrap: wrap})
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+}
+
+// breakLines разбивает элементы на строки. Строка переносится в последнем
+// месте, где это разрешено, — после пробела или у атомарного блока; слово,
+// которое не помещается даже в пустой строке, выходит за ее край
+func (f *inlineFormatter) breakLines() {
+	var line []inlineItem
+	x := 0.0
+	// breakAt — число элементов строки до последней возможности переноса
+	breakAt := 0
+	wrap := wraps(f.container.Style)
+	for _, item := range f.items {
+		switch item.kind {
+		case spaceItem:
+			if item.collapsible && !hasContent(line) {
+				// Пробелы в начале строки удаляются
+				continue
+			}
+			line = append(line, item)
+			x += item.width
+			if item.wrap {
+				breakAt = len(line)
+			}
+			continue
+		case breakItem:
+			line = append(line, item)
+			f.finishLine(line, true)
+			line, x, breakAt = nil, 0, 0
+			continue
+		case atomicItem:
+			if wrap && hasContent(line) {
+				breakAt = len(line)
+			}
+		}
+		if (item.kind == wordItem || item.kind == atomicItem) && breakAt > 0 && x+item.width > f.width && hasContent(line[:breakAt]) {
+			rest := append([]inlineItem(nil), line[breakAt:]...)
+			f.finishLine(line[:breakAt], false)
+			line, x, breakAt = rest, 0, 0
+			for _, it := range rest {
+				x += it.width
+			}
+		}
+		line = append(line, item)
+		x += item.width
+		if item.kind == atomicItem && wrap {
+			breakAt = len(line)
+		}
+	}
+	if len(line) > 0 {
+		f.finishLine(line, false)
+	}
+}
+
+// hasContent проверяет, есть ли в строке слова или атомарные блоки
+func hasContent(line []inlineItem) bool {
+	for _, item := range line {
+		if item.kind == wordItem || item.kind == atomicItem {
+			return true
+		}
+	}
+	return false
+}
+
+// finishLine размещает элементы готовой строки. Строка без слов и
+// атомарных блоков не создается, если она не закончена принудительным
+// переносом
+func (f *inlineFormatter) finishLine(line []inlineItem, forced bool) {
+	// Схлопывающиеся пробелы в конце строки не занимают места
+	trimmed := make([]inlineItem, 0, len(line))
+	end := len(line)
+	for end > 0 && (line[end-1].kind == closeItem || line[end-1].kind == breakItem || line[end-1].kind == spaceItem && line[end-1].collapsible) {
+		end--
+	}
+	trimmed = append(trimmed, line[:end]...)
+	for _, item := range line[end:] {
+		if item.kind != spaceItem {
+			trimmed = append(trimmed, item)
+		}
+	}
+	if !forced && !hasContent(trimmed) {
+		return
+	}
+
+	height := f.container.Style.LineHeight()
+	width := 0.0
+	for _, item := range trimmed {
+		width += item.width
+		if item.kind == wordItem || item.kind == atomicItem {
+			height = max(height, item.height)
+		}
+	}
+	x := alignOffset(f.container.Style, f.width-width)
+	for _, item := range trimmed {
+		if item.kind == atomicItem {
+			// Нижний край атомарного блока стоит на нижнем краю строки
+			item.atomic.Content.X += x
+			item.atomic.Content.Y += f.y + height - item.height
+		}
+		rect := Rect{X: x, Y: f.y, Width: item.width, Height: height}
+		for _, owner := range item.owners {
+			if n := len(owner.Fragments); n > 0 && f.lineOf[owner] == f.lines {
+				owner.Fragments[n-1] = owner.Fragments[n-1].Union(rect)
+			} else {
+				owner.Fragments = append(owner.Fragments, rect)
+				f.lineOf[owner] = f.lines
+			}
+		}
+		x += item.width
+	}
+	f.y += height
+	f.lines++
+}
+
+// alignOffset возвращает сдвиг строки по text-align при свободном месте free
+func alignOffset(s *style.ComputedStyle, free float64) float64 {
+	if free <= 0 {
+		return 0
+	}
+	rtl := s.Keyword("direction") == "rtl"
+	switch s.Keyword("text-align") {
+	case "right":
+		return free
+	case "center":
+		return free / 2
+	case "end":
+		if !rtl {
+			return free
+		}
+	case "left", "justify":
+	default:
+		if rtl {
+			return free
+		}
+	}
+	return 0
+}
+
+// intrinsicWidths возвращает минимальную и максимальную ширину содержимого
+// блока (min-content и max-content) без его полей, рамки и отступов
+func intrinsicWidths(box *Box) (minContent, maxContent float64) {
+	if box.replaced {
+		w := replacedWidth(box, -1, -1)
+		return w, w
+	}
+	if len(box.Children) > 0 && box.Children[0].IsBlockLevel() {
+		for _, child := range box.Children {
+			childMin, childMax := outerWidths(child)
+			minContent, maxContent = max(minContent, childMin), max(maxContent, childMax)
+		}
+		return minContent, maxContent
+	}
+	line := 0.0
+	inlineWidths(box.Children, &minContent, &maxContent, &line)
+	return minContent, max(maxContent, line)
+}
+
+// inlineWidths накапливает ширину строчного содержимого: самое длинное
+// слово в minContent, ширину текущей строки без переносов в line и самую
+// длинную строку в maxContent
+func inlineWidths(children []*Box, minContent, maxContent, line *float64) {
+	for _, child := range children {
+		switch child.Type {
+		case TextBox:
+			cw := charWidth(child.Style)
+			for i, part := range strings.Split(child.Text, "\n") {
+				if i > 0 && preservesNewlines(child.Style) {
+					*maxContent = max(*maxContent, *line)
+					*line = 0
+				}
+				words := strings.Fields(part)
+				if preservesSpaces(child.Style) {
+					*line += float64(utf8.RuneCountInString(part)) * cw
+				} else if len(words) > 0 {
+					*line += float64(utf8.RuneCountInString(strings.Join(words, " "))) * cw
+				}
+				for _, word := range words {
+					*minContent = max(*minContent, float64(utf8.RuneCountInString(word))*cw)
+				}
+			}
+		case InlineBlockBox:
+			childMin, childMax := outerWidths(child)
+			*minContent = max(*minContent, childMin)
+			*line += childMax
+		case InlineBox:
+			if child.Node != nil && child.Node.IsHTML() && child.Node.TagName == "br" {
+				*maxContent = max(*maxContent, *line)
+				*line = 0
+				continue
+			}
+			*line += fixedEdges(child)
+			inlineWidths(child.Children, minContent, maxContent, line)
+		}
+	}
+}
+
+// outerWidths возвращает минимальную и максимальную ширину блока вместе с
+// полями, рамкой и отступами
+func outerWidths(box *Box) (minContent, maxContent float64) {
+	if box.Type == AnonymousBox {
+		return intrinsicWidths(box)
+	}
+	edges := fixedEdges(box)
+	if width := box.Style.Length("width"); width.IsLength() && !width.HasPercent() {
+		w := width.Resolve(0)
+		if box.Style.Keyword("box-sizing") == "border-box" {
+			s := box.Style
+			w -= min(w, s.Length("padding-left").Resolve(0)+s.Length("padding-right").Resolve(0)+
+				s.Length("border-left-width").Resolve(0)+s.Length("border-right-width").Resolve(0))
+		}
+		return w + edges, w + edges
+	}
+	minContent, maxContent = intrinsicWidths(box)
+	return minContent + edges, maxContent + edges
+}
+
+// fixedEdges возвращает сумму горизонтальных полей, рамки и отступов блока
+// без процентов, которые при вычислении ширины по содержимому неизвестны
+func fixedEdges(box *Box) float64 {
+	sum := 0.0
+	for _, name := range []string{"margin-left", "margin-right", "padding-left", "padding-right", "border-left-width", "border-right-width"} {
+		sum += box.Style.Length(name).Resolve(0)
+	}
+	return sum
+}
+
+// charWidth возвращает среднюю ширину символа: половину размера шрифта, а у
+// моноширинного шрифта 0.6 размера, с учетом letter-spacing
+func charWidth(s *style.ComputedStyle) float64 {
+	w := 0.5 * s.FontSize()
+	if strings.Contains(strings.ToLower(s.Get("font-family")), "monospace") {
+		w = 0.6 * s.FontSize()
+	}
+	return w + s.Length("letter-spacing").Resolve(s.FontSize())
+}
+
+// isSpace проверяет, является ли символ пробельным в смысле CSS
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+}
+
+// preservesSpaces проверяет, сохраняет ли white-space пробелы
+func preservesSpaces(s *style.ComputedStyle) bool {
+	switch s.Keyword("white-space") {
+	case "pre", "pre-wrap", "break-spaces":
+		return true
+	}
+	return false
+}
+
+// preservesNewlines проверяет, сохраняет ли white-space переводы строк
+func preservesNewlines(s *style.ComputedStyle) bool {
+	return preservesSpaces(s) || s.Keyword("white-space") == "pre-line"
+}
+
+// wraps проверяет, разрешает ли white-space переносить строки
+func wraps(s *style.ComputedStyle) bool {
+	switch s.Keyword("white-space") {
+	case "pre", "nowrap":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package layout
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/baneronetwo/gluglu/internal/browser/css"
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/style"
+)
+
+// layoutFixture раскладывает документ src с таблицей стилей sheet в области
+// просмотра 800×600 и возвращает блоки элементов с атрибутом id. Отступы
+// body убраны, чтобы координаты отсчитывались от края документа
+func layoutFixture(t *testing.T, sheet, src string) map[string]*Box {
+	t.Helper()
+	doc, err := html.NewParser().Parse(src)
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	device := style.DefaultDevice()
+	cascade := style.NewCascade([]*css.Stylesheet{css.ParseStylesheet("body{margin:0} " + sheet)}, device)
+	root := Layout(doc, cascade.Compute(doc), device.Width, device.Height)
+	if root == nil {
+		t.Fatalf("%s: дерево блоков не построено", src)
+	}
+	boxes := make(map[string]*Box)
+	var walk func(box *Box)
+	walk = func(box *Box) {
+		if box.Node != nil && box.Type != TextBox {
+			if id := box.Node.GetAttribute("id"); id != "" {
+				boxes[id] = box
+			}
+		}
+		for _, child := range box.Children {
+			walk(child)
+		}
+	}
+	walk(root)
+	return boxes
+}
+
+// rectString записывает прямоугольник как «x y ширина×высота»
+func rectString(r Rect) string {
+	return fmt.Sprintf("%g %g %g×%g", r.X, r.Y, r.Width, r.Height)
+}
+
+// layoutTest — проверка прямоугольника рамки блока id
+type layoutTest struct {
+	sheet, src string
+	id         string
+	want       string
+}
+
+func runLayoutTests(t *testing.T, tests []layoutTest) {
+	t.Helper()
+	for _, test := range tests {
+		box := layoutFixture(t, test.sheet, test.src)[test.id]
+		if box == nil {
+			t.Errorf("%s: нет блока #%s", test.src, test.id)
+			continue
+		}
+		if got := rectString(box.BorderRect()); got != test.want {
+			t.Errorf("%s %s: #%s: получено %s, ожидалось %s", test.sheet, test.src, test.id, got, test.want)
+		}
+	}
+}
+
+func TestMarginCollapsing(t *testing.T) {
+	const siblings = "<div id=a></div><div id=b></div>"
+	const nested = "<div id=p><div id=c></div></div><div id=n></div>"
+	runLayoutTests(t, []layoutTest{
+		// Соседние блоки: наибольший положительный плюс наименьший отрицательный
+		{"#a{height:10px;margin-bottom:20px} #b{height:10px;margin-top:30px}", siblings, "b", "0 40 800×10"},
+		{"#a{height:10px;margin-bottom:20px} #b{height:10px;margin-top:-5px}", siblings, "b", "0 25 800×10"},
+		{"#a{height:10px;margin-bottom:-10px} #b{height:10px;margin-top:-5px}", siblings, "b", "0 0 800×10"},
+
+		// Отступ первого ребенка выходит через верхний край родителя
+		{"#p{margin-top:10px} #c{margin-top:20px;height:5px}", nested, "p", "0 20 800×5"},
+		{"#p{margin-top:10px} #c{margin-top:20px;height:5px}", nested, "c", "0 20 800×5"},
+		// Поля, рамка и новый контекст форматирования разделяют отступы
+		{"#p{margin-top:10px;padding-top:1px} #c{margin-top:20px;height:5px}", nested, "c", "0 31 800×5"},
+		{"#p{margin-top:10px;border-top:2px solid} #c{margin-top:20px;height:5px}", nested, "c", "0 32 800×5"},
+		{"#p{margin-top:10px;display:flow-root} #c{margin-top:20px;height:5px}", nested, "c", "0 30 800×5"},
+		{"#p{margin-top:10px;overflow:hidden} #c{margin-top:20px;height:5px}", nested, "p", "0 10 800×25"},
+
+		// Отступ последнего ребенка выходит через нижний край родителя
+		// с высотой auto, но не с заданной высотой
+		{"#c{height:10px;margin-bottom:20px} #n{height:5px}", nested, "p", "0 0 800×10"},
+		{"#c{height:10px;margin-bottom:20px} #n{height:5px}", nested, "n", "0 30 800×5"},
+		{"#p{height:50px} #c{height:10px;margin-bottom:20px} #n{height:5px}", nested, "n", "0 50 800×5"},
+		{"#p{padding-bottom:1px} #c{height:10px;margin-bottom:20px} #n{height:5px}", nested, "p", "0 0 800×31"},
+
+		// Отступы пустого блока схлопываются друг с другом и с соседями
+		{"#a{height:10px;margin-bottom:10px} #e{margin-top:20px;margin-bottom:30px} #b{height:10px}",
+			"<div id=a></div><div id=e></div><div id=b></div>", "b", "0 40 800×10"},
+		{"#e{margin-top:20px;margin-bottom:30px;min-height:1px} #b{height:10px}",
+			"<div id=e></div><div id=b></div>", "b", "0 51 800×10"},
+		{"#c{margin-top:15px;margin-bottom:25px} #n{height:5px}", nested, "n", "0 25 800×5"},
+	})
+}
+
+func TestMinMaxSizes(t *testing.T) {
+	const single = "<div id=c></div>"
+	const nested = "<div id=p><div id=c></div></div>"
+	runLayoutTests(t, []layoutTest{
+		{"#c{width:1000px;max-width:300px;height:1px}", single, "c", "0 0 300×1"},
+		{"#c{max-width:50%;height:1px}", single, "c", "0 0 400×1"},
+		// min-width сильнее max-width
+		{"#c{width:100px;min-width:200px;max-width:150px;height:1px}", single, "c", "0 0 200×1"},
+		{"#c{min-width:900px;height:1px}", single, "c", "0 0 900×1"},
+		// Ограничение ширины с auto в отступах центрирует блок
+		{"#c{max-width:300px;margin:0 auto;height:1px}", single, "c", "250 0 300×1"},
+		{"#c{width:100px;min-width:200px;margin:0 auto;height:1px}", single, "c", "300 0 200×1"},
+		{"#c{width:600px;max-width:200px;margin:0 100px 0 auto;height:1px}", single, "c", "500 0 200×1"},
+
+		{"#c{height:10px;min-height:30px}", single, "c", "0 0 800×30"},
+		{"#c{height:100px;max-height:20px}", single, "c", "0 0 800×20"},
+		{"#c{height:100px;max-height:20px;min-height:40px}", single, "c", "0 0 800×40"},
+		// Проценты от высоты, зависящей от содержимого, не учитываются
+		{"#c{height:100px;max-height:50%}", nested, "c", "0 0 800×100"},
+		{"#p{height:100px} #c{height:100px;max-height:50%}", nested, "c", "0 0 800×50"},
+		{"#p{min-height:40px} #c{height:10px}", nested, "p", "0 0 800×40"},
+		{"#p{max-height:5px} #c{height:10px}", nested, "p", "0 0 800×5"},
+	})
+}
+
+func TestBoxSizing(t *testing.T) {
+	const single = "<div id=c></div>"
+	runLayoutTests(t, []layoutTest{
+		{"#c{width:200px;height:100px;padding:10px;border:5px solid}", single, "c", "0 0 230×130"},
+		{"#c{box-sizing:border-box;width:200px;height:100px;padding:10px;border:5px solid}", single, "c", "0 0 200×100"},
+		{"#c{box-sizing:border-box;width:50%;height:1px;padding:0 10px}", single, "c", "0 0 400×1"},
+		// Поля и рамка больше заданной ширины: область содержимого нулевая
+		{"#c{box-sizing:border-box;width:10px;height:10px;padding:20px}", single, "c", "0 0 40×40"},
+		// Ограничения тоже относятся к рамке
+		{"#c{box-sizing:border-box;width:50px;min-width:100px;height:1px;padding:0 20px}", single, "c", "0 0 100×1"},
+		{"#c{box-sizing:border-box;max-width:100px;height:1px;padding:0 10px}", single, "c", "0 0 100×1"},
+		{"#c{box-sizing:border-box;height:10px;min-height:50px;padding:10px}", single, "c", "0 0 800×50"},
+		// Поля и рамка при width: auto вычитаются из ширины содержащего блока
+		{"#c{height:1px;padding:0 10px;border:0 solid;border-left-width:5px;margin:0 20px}", single, "c", "20 0 760×1"},
+	})
+}
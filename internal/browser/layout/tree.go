@@ -0,0 +1,210 @@
+package layout
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/style"
+)
+
+// replacedSizes — размер по умолчанию замещаемых элементов, у которых он не
+// задан атрибутами width и height
+var replacedSizes = map[string][2]float64{
+	"img":      {0, 0},
+	"video":    {300, 150},
+	"canvas":   {300, 150},
+	"iframe":   {300, 150},
+	"embed":    {300, 150},
+	"object":   {300, 150},
+	"input":    {150, 20},
+	"select":   {80, 20},
+	"textarea": {180, 36},
+}
+
+// BuildTree строит дерево блоков документа. Возвращает nil, если у документа
+// нет корневого элемента или он не отображается
+func BuildTree(doc *html.Document, styles map[*html.Node]*style.ComputedStyle) *Box {
+	root := doc.DocumentElement()
+	if root == nil {
+		return nil
+	}
+	b := &treeBuilder{styles: styles}
+	s := b.styleOf(root)
+	if s.Display() == "none" {
+		return nil
+	}
+	// Корневой элемент всегда создает блок уровня блока
+	box := &Box{Type: BlockBox, Node: root, Style: s, root: true}
+	if !b.makeReplaced(box) {
+		b.addChildren(box, root)
+		normalize(box)
+	}
+	return box
+}
+
+type treeBuilder struct {
+	styles map[*html.Node]*style.ComputedStyle
+}
+
+// styleOf возвращает вычисленный стиль элемента
+func (b *treeBuilder) styleOf(n *html.Node) *style.ComputedStyle {
+	if s := b.styles[n]; s != nil {
+		return s
+	}
+	return style.Initial()
+}
+
+// addChildren добавляет в parent блоки детей узла n. Узел n и блок parent
+// различаются, если у элементов между ними display: contents
+func (b *treeBuilder) addChildren(parent *Box, n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.TextNode:
+			parent.Children = append(parent.Children, &Box{Type: TextBox, Node: c, Style: b.styleOf(n), Text: c.Data})
+		case html.ElementNode:
+			b.addElement(parent, c)
+		}
+	}
+}
+
+// addElement добавляет в parent блок элемента
+func (b *treeBuilder) addElement(parent *Box, el *html.Node) {
+	s := b.styleOf(el)
+	display := s.Display()
+	switch display {
+	case "none":
+		return
+	case "contents":
+		// Сам элемент блока не создает, его дети достаются родителю
+		b.addChildren(parent, el)
+		return
+	}
+	box := &Box{Type: BlockBox, Node: el, Style: s}
+	if isInlineLevel(display) {
+		box.Type = InlineBlockBox
+		if display == "inline" || display == "ruby" || display == "ruby-text" {
+			box.Type = InlineBox
+		}
+	}
+	if b.makeReplaced(box) {
+		if box.Type == InlineBox {
+			box.Type = InlineBlockBox
+		}
+		parent.Children = append(parent.Children, box)
+		return
+	}
+	b.addChildren(box, el)
+	// Блок внутри строчного элемента не разбивает его на части, как того
+	// требует CSS, а делает весь элемент блочным
+	if box.Type == InlineBox {
+		for _, child := range box.Children {
+			if child.IsBlockLevel() {
+				box.Type = BlockBox
+				break
+			}
+		}
+	}
+	if box.Type != InlineBox {
+		normalize(box)
+	}
+	parent.Children = append(parent.Children, box)
+}
+
+// makeReplaced помечает блок замещаемого элемента. Элементы SVG и MathML
+// тоже не раскладываются по правилам HTML: такой элемент занимает один блок
+func (b *treeBuilder) makeReplaced(box *Box) bool {
+	el := box.Node
+	_, ok := replacedSizes[el.TagName]
+	box.replaced = !el.IsHTML() || ok
+	return box.replaced
+}
+
+// isInlineLevel проверяет, создает ли значение display блок строчного уровня
+func isInlineLevel(display string) bool {
+	switch display {
+	case "inline", "inline-block", "inline-table", "inline-flex", "inline-grid", "ruby", "ruby-text":
+		return true
+	}
+	return false
+}
+
+// normalize заключает строчное содержимое блока, стоящее рядом с блоками,
+// в анонимные блоки. Последовательности из одних пробелов между блоками
+// отбрасываются
+func normalize(box *Box) {
+	hasBlock := false
+	for _, child := range box.Children {
+		if child.IsBlockLevel() {
+			hasBlock = true
+			break
+		}
+	}
+	if !hasBlock {
+		return
+	}
+	children := make([]*Box, 0, len(box.Children))
+	var run []*Box
+	flush := func() {
+		if len(run) > 0 && !isCollapsibleSpace(run) {
+			children = append(children, &Box{Type: AnonymousBox, Style: box.Style, Children: run})
+		}
+		run = nil
+	}
+	for _, child := range box.Children {
+		if child.IsBlockLevel() {
+			flush()
+			children = append(children, child)
+		} else {
+			run = append(run, child)
+		}
+	}
+	flush()
+	box.Children = children
+}
+
+// isCollapsibleSpace проверяет, состоят ли блоки только из текста с
+// пробелами, которые схлопываются
+func isCollapsibleSpace(boxes []*Box) bool {
+	for _, b := range boxes {
+		if b.Type != TextBox || preservesSpaces(b.Style) || strings.Trim(b.Text, " \t\n\f\r") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// intrinsicSize возвращает собственный размер замещаемого элемента: из
+// атрибутов width и height или размер по умолчанию
+func intrinsicSize(el *html.Node) (width, height float64) {
+	size := replacedSizes[el.TagName]
+	if !el.IsHTML() {
+		size = [2]float64{}
+		if el.TagName == "svg" {
+			size = [2]float64{300, 150}
+		}
+	}
+	width, height = size[0], size[1]
+	if v, ok := dimensionAttribute(el, "width"); ok {
+		width = v
+	}
+	if v, ok := dimensionAttribute(el, "height"); ok {
+		height = v
+	}
+	return width, height
+}
+
+// dimensionAttribute разбирает атрибут размера в пикселях: число, возможно
+// с единицей px. Проценты не поддерживаются
+func dimensionAttribute(el *html.Node, name string) (float64, bool) {
+	value, ok := el.LookupAttribute(name)
+	if !ok {
+		return 0, false
+	}
+	value = strings.TrimSuffix(strings.TrimSpace(value), "px")
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
@@ -0,0 +1,267 @@
+package metadata
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+)
+
+// Item — элемент микроданных или RDFa Lite
+type Item struct {
+	// Type — типы элемента (itemtype или typeof), для RDFa — полные адреса
+	Type []string
+	// ID — глобальный идентификатор элемента (itemid или resource)
+	ID string
+	// Properties — значения свойств по именам в порядке документа. Значение —
+	// строка или вложенный *Item. Имена свойств RDFa, как и типы, раскрываются
+	// в полные адреса через vocab и prefix
+	Properties map[string][]interface{}
+}
+
+// newItem создает пустой элемент
+func newItem() *Item {
+	return &Item{Properties: make(map[string][]interface{})}
+}
+
+// add добавляет значение свойства
+func (it *Item) add(name string, value interface{}) {
+	it.Properties[name] = append(it.Properties[name], value)
+}
+
+// Get возвращает первое строковое значение свойства
+func (it *Item) Get(name string) string {
+	for _, v := range it.Properties[name] {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// Микроданные
+
+// extractMicrodata возвращает элементы микроданных верхнего уровня: элементы
+// с itemscope, которые не являются значением свойства другого элемента
+func (m *Metadata) extractMicrodata(doc *html.Document) []*Item {
+	var items []*Item
+	scopes, _ := doc.QuerySelectorAll("[itemscope]:not([itemprop])")
+	for _, scope := range scopes {
+		items = append(items, m.microdataItem(doc, scope, map[*html.Node]bool{}))
+	}
+	return items
+}
+
+// microdataItem строит элемент микроданных по алгоритму из спецификации HTML.
+// building — элементы, которые строятся сейчас: ссылки itemref могут
+// образовывать циклы
+func (m *Metadata) microdataItem(doc *html.Document, root *html.Node, building map[*html.Node]bool) *Item {
+	building[root] = true
+	defer delete(building, root)
+
+	item := newItem()
+	item.Type = strings.Fields(root.GetAttribute("itemtype"))
+	if len(item.Type) > 0 {
+		item.ID = m.resolve(root.GetAttribute("itemid"))
+	}
+
+	for _, prop := range m.microdataProperties(doc, root) {
+		var value interface{}
+		if prop.HasAttribute("itemscope") {
+			if building[prop] {
+				continue
+			}
+			value = m.microdataItem(doc, prop, building)
+		} else {
+			value = m.microdataValue(prop)
+		}
+		for _, name := range uniqueFields(prop.GetAttribute("itemprop")) {
+			item.add(name, value)
+		}
+	}
+	return item
+}
+
+// microdataProperties находит элементы со свойствами элемента root: его
+// потомков (не заходя внутрь вложенных элементов) и элементы из itemref
+func (m *Metadata) microdataProperties(doc *html.Document, root *html.Node) []*html.Node {
+	visited := map[*html.Node]bool{root: true}
+	pending := root.Children()
+	for _, id := range strings.Fields(root.GetAttribute("itemref")) {
+		if ref := doc.FindElementsByID(id); ref != nil {
+			pending = append(pending, ref)
+		}
+	}
+
+	var result []*html.Node
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		if !current.HasAttribute("itemscope") {
+			pending = append(pending, current.Children()...)
+		}
+		if strings.TrimSpace(current.GetAttribute("itemprop")) != "" {
+			result = append(result, current)
+		}
+	}
+	m.sortTreeOrder(doc, result)
+	return result
+}
+
+// microdataValue возвращает значение свойства микроданных
+func (m *Metadata) microdataValue(n *html.Node) string {
+	switch n.TagName {
+	case "meta":
+		return n.GetAttribute("content")
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		return m.resolve(n.GetAttribute("src"))
+	case "a", "area", "link":
+		return m.resolve(n.GetAttribute("href"))
+	case "object":
+		return m.resolve(n.GetAttribute("data"))
+	case "data", "meter":
+		return n.GetAttribute("value")
+	case "time":
+		if datetime, ok := n.LookupAttribute("datetime"); ok {
+			return datetime
+		}
+	}
+	return n.TextContent()
+}
+
+// RDFa Lite
+
+// rdfaContext — словарь и префиксы, действующие внутри элемента
+type rdfaContext struct {
+	vocab    string
+	prefixes map[string]string
+}
+
+// extractRDFa возвращает элементы RDFa Lite верхнего уровня
+func (m *Metadata) extractRDFa(doc *html.Document) []*Item {
+	var items []*Item
+	root := doc.DocumentElement()
+	if root == nil {
+		return nil
+	}
+	m.walkRDFa(root, rdfaContext{}, nil, &items)
+	return items
+}
+
+// walkRDFa обходит элемент n: typeof создает новый элемент, property
+// добавляет значение свойства текущему элементу current
+func (m *Metadata) walkRDFa(n *html.Node, ctx rdfaContext, current *Item, items *[]*Item) {
+	if vocab, ok := n.LookupAttribute("vocab"); ok {
+		ctx.vocab = strings.TrimSpace(vocab)
+	}
+	if prefix, ok := n.LookupAttribute("prefix"); ok {
+		ctx.prefixes = parseRDFaPrefixes(prefix, ctx.prefixes)
+	}
+
+	var properties []string
+	for _, name := range strings.Fields(n.GetAttribute("property")) {
+		properties = append(properties, ctx.expand(name))
+	}
+	if typeof, ok := n.LookupAttribute("typeof"); ok {
+		item := newItem()
+		for _, t := range strings.Fields(typeof) {
+			item.Type = append(item.Type, ctx.expand(t))
+		}
+		item.ID = m.resolve(n.GetAttribute("resource"))
+		if current != nil && len(properties) > 0 {
+			for _, name := range properties {
+				current.add(name, item)
+			}
+		} else {
+			*items = append(*items, item)
+		}
+		current = item
+	} else if current != nil {
+		for _, name := range properties {
+			current.add(name, m.rdfaValue(n))
+		}
+	}
+
+	for _, child := range n.Children() {
+		m.walkRDFa(child, ctx, current, items)
+	}
+}
+
+// rdfaValue возвращает значение свойства RDFa Lite
+func (m *Metadata) rdfaValue(n *html.Node) string {
+	if content, ok := n.LookupAttribute("content"); ok {
+		return content
+	}
+	for _, attr := range []string{"resource", "href", "src"} {
+		if ref, ok := n.LookupAttribute(attr); ok {
+			return m.resolve(ref)
+		}
+	}
+	if datetime, ok := n.LookupAttribute("datetime"); ok && n.TagName == "time" {
+		return datetime
+	}
+	return n.TextContent()
+}
+
+// parseRDFaPrefixes добавляет к префиксам родителя объявления из атрибута
+// prefix вида «dc: http://purl.org/dc/terms/ og: http://ogp.me/ns#»
+func parseRDFaPrefixes(attr string, inherited map[string]string) map[string]string {
+	prefixes := make(map[string]string, len(inherited))
+	for k, v := range inherited {
+		prefixes[k] = v
+	}
+	fields := strings.Fields(attr)
+	for i := 0; i+1 < len(fields); i += 2 {
+		if name := strings.TrimSuffix(fields[i], ":"); name != fields[i] {
+			prefixes[strings.ToLower(name)] = fields[i+1]
+		}
+	}
+	return prefixes
+}
+
+// expand превращает термин или CURIE в полный адрес
+func (ctx rdfaContext) expand(term string) string {
+	if prefix, rest, ok := strings.Cut(term, ":"); ok {
+		if ns, ok := ctx.prefixes[strings.ToLower(prefix)]; ok {
+			return ns + rest
+		}
+		return term
+	}
+	return ctx.vocab + term
+}
+
+// uniqueFields разбивает строку на слова без повторов
+func uniqueFields(s string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, f := range strings.Fields(s) {
+		if !seen[f] {
+			seen[f] = true
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// sortTreeOrder сортирует элементы в порядке документа. Номера узлов
+// вычисляются один раз на документ
+func (m *Metadata) sortTreeOrder(doc *html.Document, nodes []*html.Node) {
+	if m.treeOrder == nil {
+		m.treeOrder = make(map[*html.Node]int)
+		var number func(n *html.Node)
+		number = func(n *html.Node) {
+			m.treeOrder[n] = len(m.treeOrder)
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				number(c)
+			}
+		}
+		number(&doc.Node)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return m.treeOrder[nodes[i]] < m.treeOrder[nodes[j]]
+	})
+}
@@ -0,0 +1,297 @@
+// Package metadata извлекает из HTML документа структурированные метаданные:
+// теги <meta>, OpenGraph и Twitter Cards, канонический адрес, иконки,
+// JSON-LD, микроданные и RDFa Lite
+package metadata
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/weburl"
+)
+
+// Metadata — метаданные документа
+type Metadata struct {
+	Title       string
+	Description string
+	// Language — язык документа из атрибута lang корневого элемента
+	Language string
+
+	// Meta — все теги <meta> с именем (name, property или http-equiv) в
+	// порядке появления в документе
+	Meta []MetaTag
+
+	OpenGraph OpenGraph
+	Twitter   TwitterCard
+
+	// Canonical — канонический адрес страницы из <link rel="canonical">
+	Canonical string
+	// Icons — иконки страницы из <link rel="icon">, apple-touch-icon и т.п.
+	Icons []Icon
+	// Manifest — адрес манифеста веб-приложения
+	Manifest string
+
+	// JSONLD — разобранные блоки <script type="application/ld+json">.
+	// Блоки с некорректным JSON пропускаются
+	JSONLD []interface{}
+	// Microdata и RDFa — элементы верхнего уровня, описанные атрибутами
+	// itemscope/itemprop и vocab/typeof/property соответственно
+	Microdata []*Item
+	RDFa      []*Item
+
+	base      *weburl.URL
+	treeOrder map[*html.Node]int
+}
+
+// MetaTag — пара «имя — значение» из тега <meta>. Name приводится к нижнему регистру
+type MetaTag struct {
+	Name    string
+	Content string
+}
+
+// OpenGraph — свойства протокола OpenGraph (og:*)
+type OpenGraph struct {
+	Title       string
+	Type        string
+	URL         string
+	Description string
+	SiteName    string
+	Locale      string
+	Images      []string
+	// Properties — все свойства og:* без префикса, включая
+	// структурированные (например, image:width), в порядке появления
+	Properties map[string][]string
+}
+
+// TwitterCard — свойства Twitter Cards (twitter:*)
+type TwitterCard struct {
+	Card        string
+	Site        string
+	Creator     string
+	Title       string
+	Description string
+	Image       string
+	// Properties — все свойства twitter:* без префикса
+	Properties map[string]string
+}
+
+// Icon — иконка страницы
+type Icon struct {
+	URL string
+	// Rel — тип ссылки: icon, apple-touch-icon, mask-icon и т.п.
+	Rel   string
+	Sizes string
+	Type  string
+}
+
+// Extract извлекает метаданные документа. Относительные адреса разрешаются
+// относительно базового адреса документа (если его нет, они остаются как есть)
+func Extract(doc *html.Document) *Metadata {
+	m := &Metadata{
+		Title: doc.Title(),
+		OpenGraph: OpenGraph{
+			Properties: make(map[string][]string),
+		},
+		Twitter: TwitterCard{
+			Properties: make(map[string]string),
+		},
+		base: doc.BaseURL(),
+	}
+	if root := doc.DocumentElement(); root != nil {
+		m.Language = root.GetAttribute("lang")
+	}
+
+	m.extractMeta(doc)
+	m.extractLinks(doc)
+	m.extractJSONLD(doc)
+	m.Microdata = m.extractMicrodata(doc)
+	m.RDFa = m.extractRDFa(doc)
+	return m
+}
+
+// Get возвращает значение первого тега <meta> с указанным именем
+func (m *Metadata) Get(name string) string {
+	name = strings.ToLower(name)
+	for _, tag := range m.Meta {
+		if tag.Name == name {
+			return tag.Content
+		}
+	}
+	return ""
+}
+
+// Favicon возвращает адрес иконки страницы: первую объявленную иконку rel="icon"
+// или, если таких нет, /favicon.ico относительно адреса документа
+func (m *Metadata) Favicon() string {
+	for _, icon := range m.Icons {
+		if icon.Rel == "icon" {
+			return icon.URL
+		}
+	}
+	if len(m.Icons) > 0 {
+		return m.Icons[0].URL
+	}
+	return m.resolve("/favicon.ico")
+}
+
+// resolve разрешает адрес относительно базового адреса документа
+func (m *Metadata) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ref
+	}
+	u, err := weburl.Parse(ref, m.base)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// extractMeta собирает теги <meta> и заполняет OpenGraph и Twitter Cards
+func (m *Metadata) extractMeta(doc *html.Document) {
+	tags, _ := doc.QuerySelectorAll("meta[content]")
+	for _, tag := range tags {
+		name := tag.GetAttribute("property")
+		if name == "" {
+			name = tag.GetAttribute("name")
+		}
+		if name == "" {
+			name = tag.GetAttribute("http-equiv")
+		}
+		if name == "" {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		content := tag.GetAttribute("content")
+		m.Meta = append(m.Meta, MetaTag{Name: name, Content: content})
+
+		switch {
+		case name == "description":
+			if m.Description == "" {
+				m.Description = content
+			}
+		case strings.HasPrefix(name, "og:"):
+			m.addOpenGraph(strings.TrimPrefix(name, "og:"), content)
+		case strings.HasPrefix(name, "twitter:"):
+			m.addTwitter(strings.TrimPrefix(name, "twitter:"), content)
+		}
+	}
+}
+
+// addOpenGraph добавляет свойство OpenGraph. Первое значение свойства
+// побеждает, кроме изображений, которых может быть несколько
+func (m *Metadata) addOpenGraph(property, content string) {
+	og := &m.OpenGraph
+	og.Properties[property] = append(og.Properties[property], content)
+
+	set := func(field *string) {
+		if *field == "" {
+			*field = content
+		}
+	}
+	switch property {
+	case "title":
+		set(&og.Title)
+	case "type":
+		set(&og.Type)
+	case "url":
+		if og.URL == "" {
+			og.URL = m.resolve(content)
+		}
+	case "description":
+		set(&og.Description)
+	case "site_name":
+		set(&og.SiteName)
+	case "locale":
+		set(&og.Locale)
+	case "image":
+		og.Images = append(og.Images, m.resolve(content))
+	case "image:url":
+		// og:image:url — синоним og:image, уточняющий последнее изображение
+		if len(og.Images) > 0 {
+			og.Images[len(og.Images)-1] = m.resolve(content)
+		} else {
+			og.Images = append(og.Images, m.resolve(content))
+		}
+	}
+}
+
+// addTwitter добавляет свойство Twitter Cards. Первое значение побеждает
+func (m *Metadata) addTwitter(property, content string) {
+	tc := &m.Twitter
+	if _, ok := tc.Properties[property]; ok {
+		return
+	}
+	tc.Properties[property] = content
+
+	switch property {
+	case "card":
+		tc.Card = content
+	case "site":
+		tc.Site = content
+	case "creator":
+		tc.Creator = content
+	case "title":
+		tc.Title = content
+	case "description":
+		tc.Description = content
+	case "image", "image:src":
+		if tc.Image == "" {
+			tc.Image = m.resolve(content)
+		}
+	}
+}
+
+// iconRels — типы ссылок, которые объявляют иконки страницы
+var iconRels = map[string]bool{
+	"icon":                         true,
+	"apple-touch-icon":             true,
+	"apple-touch-icon-precomposed": true,
+	"mask-icon":                    true,
+}
+
+// extractLinks находит канонический адрес, иконки и манифест
+func (m *Metadata) extractLinks(doc *html.Document) {
+	links, _ := doc.QuerySelectorAll("link[rel][href]")
+	for _, link := range links {
+		href := m.resolve(link.GetAttribute("href"))
+		for _, rel := range strings.Fields(strings.ToLower(link.GetAttribute("rel"))) {
+			switch {
+			case rel == "canonical":
+				if m.Canonical == "" {
+					m.Canonical = href
+				}
+			case rel == "manifest":
+				if m.Manifest == "" {
+					m.Manifest = href
+				}
+			case iconRels[rel]:
+				m.Icons = append(m.Icons, Icon{
+					URL:   href,
+					Rel:   rel,
+					Sizes: link.GetAttribute("sizes"),
+					Type:  link.GetAttribute("type"),
+				})
+			}
+		}
+	}
+}
+
+// extractJSONLD разбирает блоки JSON-LD
+func (m *Metadata) extractJSONLD(doc *html.Document) {
+	scripts, _ := doc.QuerySelectorAll("script[type]")
+	for _, script := range scripts {
+		mime, _, _ := strings.Cut(script.GetAttribute("type"), ";")
+		if !strings.EqualFold(strings.TrimSpace(mime), "application/ld+json") {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(script.TextContent()), &value); err != nil {
+			log.Printf("Некорректный блок JSON-LD: %v", err)
+			continue
+		}
+		m.JSONLD = append(m.JSONLD, value)
+	}
+}
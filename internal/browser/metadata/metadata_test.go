@@ -0,0 +1,236 @@
+package metadata
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/weburl"
+)
+
+// extractFixture разбирает документ с адресом https://example.com/news/page.html
+// и извлекает его метаданные
+func extractFixture(t *testing.T, src string) *Metadata {
+	t.Helper()
+	doc, err := html.NewParser().Parse(src)
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	doc.URL, err = weburl.Parse("https://example.com/news/page.html", nil)
+	if err != nil {
+		t.Fatalf("ошибка разбора адреса: %v", err)
+	}
+	return Extract(doc)
+}
+
+func TestOpenGraph(t *testing.T) {
+	m := extractFixture(t, `<html lang=ru><head>
+<meta property="og:title" content="Первый">
+<meta property="OG:Title" content="Второй">
+<meta property="og:image" content="/a.png">
+<meta property="og:image:width" content="640">
+<meta property="og:image" content="b.png">
+<meta property="og:image:url" content="https://cdn.example.com/b.png">
+<meta property="og:url" content="?id=1">
+<meta name="twitter:card" content="summary">
+<meta name="twitter:card" content="player">
+<meta name="twitter:image:src" content="/t.png">
+<meta name="Description" content="Описание">
+<meta property="og:site_name">
+</head></html>`)
+
+	og := m.OpenGraph
+	if og.Title != "Первый" {
+		t.Errorf("og:title: получено %q, ожидалось %q", og.Title, "Первый")
+	}
+	if want := []string{"https://example.com/a.png", "https://cdn.example.com/b.png"}; !reflect.DeepEqual(og.Images, want) {
+		t.Errorf("og:image: получено %q, ожидалось %q", og.Images, want)
+	}
+	if og.URL != "https://example.com/news/page.html?id=1" {
+		t.Errorf("og:url: получено %q", og.URL)
+	}
+	if got := og.Properties["image:width"]; !reflect.DeepEqual(got, []string{"640"}) {
+		t.Errorf("og:image:width: получено %q", got)
+	}
+	if got := og.Properties["title"]; len(got) != 2 {
+		t.Errorf("og:title: в Properties %d значений, ожидалось 2", len(got))
+	}
+	if _, ok := og.Properties["site_name"]; ok {
+		t.Errorf("og:site_name без content не должен учитываться")
+	}
+	if m.Twitter.Card != "summary" || m.Twitter.Image != "https://example.com/t.png" {
+		t.Errorf("twitter: получено %q, %q", m.Twitter.Card, m.Twitter.Image)
+	}
+	if m.Description != "Описание" || m.Get("DESCRIPTION") != "Описание" {
+		t.Errorf("description: получено %q", m.Description)
+	}
+	if m.Language != "ru" {
+		t.Errorf("язык: получено %q", m.Language)
+	}
+}
+
+func TestJSONLD(t *testing.T) {
+	m := extractFixture(t, `<head>
+<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+  {"@type": "WebSite", "name": "Сайт"},
+  {"@type": "NewsArticle", "headline": "Заголовок", "author": {"@type": "Person", "name": "Автор"}}
+]}</script>
+<script type="application/ld+json">{"@type": "Broken",</script>
+<script type="Application/LD+JSON; charset=utf-8">[{"@type": "Thing"}]</script>
+<script type="application/json">{"@type": "NotLD"}</script>
+</head>`)
+
+	if len(m.JSONLD) != 2 {
+		t.Fatalf("получено %d блоков JSON-LD, ожидалось 2", len(m.JSONLD))
+	}
+	block, _ := m.JSONLD[0].(map[string]interface{})
+	graph, _ := block["@graph"].([]interface{})
+	if len(graph) != 2 {
+		t.Fatalf("@graph: получено %d объектов, ожидалось 2", len(graph))
+	}
+	article, _ := graph[1].(map[string]interface{})
+	author, _ := article["author"].(map[string]interface{})
+	if article["headline"] != "Заголовок" || author["name"] != "Автор" {
+		t.Errorf("@graph: получено %v", article)
+	}
+	if list, ok := m.JSONLD[1].([]interface{}); !ok || len(list) != 1 {
+		t.Errorf("блок-список: получено %v", m.JSONLD[1])
+	}
+}
+
+// itemString описывает элемент в виде «тип{имя=значение ...}» с именами
+// свойств по алфавиту, чтобы сравнивать элементы одной строкой
+func itemString(it *Item) string {
+	var names []string
+	for name := range it.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	sb.WriteString(strings.Join(it.Type, ","))
+	if it.ID != "" {
+		sb.WriteString("#" + it.ID)
+	}
+	sb.WriteString("{")
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(name + "=")
+		for j, v := range it.Properties[name] {
+			if j > 0 {
+				sb.WriteString("|")
+			}
+			switch v := v.(type) {
+			case string:
+				sb.WriteString(v)
+			case *Item:
+				sb.WriteString(itemString(v))
+			}
+		}
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func TestMicrodata(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			"значения по типам элементов",
+			`<div itemscope itemtype="https://schema.org/Article" itemid="/a/1">
+<meta itemprop="inLanguage" content="ru"><a itemprop="url" href="../x">x</a>
+<img itemprop="image" src="i.png"><time itemprop="datePublished" datetime="2024-01-02">2 января</time>
+<data itemprop="wordCount" value="300">триста</data><span itemprop="headline alternativeHeadline headline"> Текст </span>
+</div>`,
+			[]string{"https://schema.org/Article#https://example.com/a/1{alternativeHeadline= Текст  datePublished=2024-01-02 headline= Текст  image=https://example.com/news/i.png inLanguage=ru url=https://example.com/x wordCount=300}"},
+		},
+		{
+			"вложенный элемент и itemref",
+			`<div itemscope itemtype="https://schema.org/Book" itemref="extra author">
+<span itemprop="name">Книга</span>
+<div itemprop="publisher" itemscope><span itemprop="name">Издательство</span></div>
+</div>
+<p id="author" itemprop="author">Автор</p>
+<div id="extra"><span itemprop="isbn">123</span></div>`,
+			[]string{"https://schema.org/Book{author=Автор isbn=123 name=Книга publisher={name=Издательство}}"},
+		},
+		{
+			// Потомки элемента с itemscope из itemref не обходятся
+			"цикл itemref не зацикливает разбор",
+			`<div itemscope id="a" itemref="b"><span itemprop="x">1</span></div>
+<div id="b" itemprop="self" itemscope itemref="a"><span itemprop="y">2</span></div>`,
+			[]string{"{self={y=2} x=1}"},
+		},
+		{
+			"несколько элементов верхнего уровня",
+			`<p itemscope><b itemprop="n">1</b></p><p itemscope><b itemprop="n">2</b></p>`,
+			[]string{"{n=1}", "{n=2}"},
+		},
+	}
+	for _, test := range tests {
+		m := extractFixture(t, test.src)
+		var got []string
+		for _, item := range m.Microdata {
+			got = append(got, itemString(item))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s:\nполучено  %q\nожидалось %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRDFa(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			"vocab раскрывает типы и свойства",
+			`<div vocab="https://schema.org/" typeof="Person" resource="/p/1">
+<span property="name">Иван</span><a property="url sameAs" href="/ivan">сайт</a>
+<meta property="jobTitle" content="Редактор">
+</div>`,
+			[]string{"https://schema.org/Person#https://example.com/p/1{https://schema.org/jobTitle=Редактор https://schema.org/name=Иван https://schema.org/sameAs=https://example.com/ivan https://schema.org/url=https://example.com/ivan}"},
+		},
+		{
+			"prefix и полные адреса",
+			`<div prefix="dc: http://purl.org/dc/terms/ OG: http://ogp.me/ns#" vocab="https://schema.org/" typeof="og:article">
+<span property="dc:title">Заголовок</span><span property="og:title">OG</span>
+<span property="http://example.org/vocab#rating">5</span><span property="ex:unknown">?</span>
+<time property="dateCreated" datetime="2024-05-06">6 мая</time>
+</div>`,
+			[]string{"http://ogp.me/ns#article{ex:unknown=? http://example.org/vocab#rating=5 http://ogp.me/ns#title=OG http://purl.org/dc/terms/title=Заголовок https://schema.org/dateCreated=2024-05-06}"},
+		},
+		{
+			"вложенный typeof со свойством и смена vocab",
+			`<div vocab="https://schema.org/" typeof="Book">
+<span property="name">Книга</span>
+<div property="author" typeof="Person"><span property="name">Автор</span></div>
+<div vocab="http://xmlns.com/foaf/0.1/" property="maker" typeof="Agent"><span property="name">Агент</span></div>
+</div>`,
+			[]string{"https://schema.org/Book{http://xmlns.com/foaf/0.1/maker=http://xmlns.com/foaf/0.1/Agent{http://xmlns.com/foaf/0.1/name=Агент} https://schema.org/author=https://schema.org/Person{https://schema.org/name=Автор} https://schema.org/name=Книга}"},
+		},
+		{
+			"property вне typeof игнорируется",
+			`<p vocab="https://schema.org/" property="name">x</p><div typeof="Thing"></div>`,
+			[]string{"Thing{}"},
+		},
+	}
+	for _, test := range tests {
+		m := extractFixture(t, test.src)
+		var got []string
+		for _, item := range m.RDFa {
+			got = append(got, itemString(item))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s:\nполучено  %q\nожидалось %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/language"
+	"golang.org/x/text/transform"
+)
+
+// prescanLimit — сколько байт документа просматривается в поисках <meta charset>
+const prescanLimit = 1024
+
+// FallbackLanguage определяет кодировку по умолчанию для документов, в которых
+// кодировка не указана и которые не являются корректным UTF-8
+var FallbackLanguage = language.Russian
+
+// DetectEncoding определяет кодировку HTML документа по алгоритму WHATWG:
+// метка порядка байтов, параметр charset заголовка Content-Type, <meta> в
+// начале документа и, наконец, кодировка по умолчанию.
+// Возвращает каноническое имя кодировки (например, "windows-1251") и длину BOM
+func DetectEncoding(body []byte, contentType string) (string, int) {
+	if name, n := declaredEncoding(body, contentType); name != "" {
+		return name, n
+	}
+
+	// 4. Документ без объявленной кодировки: если он целиком является
+	// корректным UTF-8, считаем его UTF-8, иначе берем кодировку по умолчанию
+	if utf8.Valid(body) {
+		return "utf-8", 0
+	}
+	return htmlindex.LanguageDefault(FallbackLanguage), 0
+}
+
+// declaredEncoding возвращает кодировку, объявленную меткой порядка байтов,
+// заголовком Content-Type или <meta> в начале документа, и длину BOM.
+// Если кодировка не объявлена, возвращает пустую строку
+func declaredEncoding(body []byte, contentType string) (string, int) {
+	// 1. Метка порядка байтов имеет наивысший приоритет
+	if name, n := sniffBOM(body); name != "" {
+		return name, n
+	}
+
+	// 2. Кодировка из заголовка Content-Type
+	if name := charsetFromContentType(contentType); name != "" {
+		return name, 0
+	}
+
+	// 3. Предварительный просмотр начала документа
+	return prescanMeta(body), 0
+}
+
+// DecodeBody перекодирует тело документа в UTF-8 и возвращает его вместе с
+// именем определенной кодировки
+func DecodeBody(body []byte, contentType string) (string, string, error) {
+	name, bomLength := DetectEncoding(body, contentType)
+
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return "", "", fmt.Errorf("неизвестная кодировка %s: %w", name, err)
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(body[bomLength:])
+	if err != nil {
+		return "", "", fmt.Errorf("ошибка декодирования из %s: %w", name, err)
+	}
+
+	return string(decoded), name, nil
+}
+
+// NewDecodingReader возвращает reader, перекодирующий документ из r в UTF-8
+// по мере чтения, и имя определенной кодировки. Объявленная кодировка
+// ищется в первых prescanLimit байтах, а если ее нет, документ
+// просматривается дальше, до первого байта вне ASCII (см. sniffEncoding)
+func NewDecodingReader(r io.Reader, contentType string) (io.Reader, string, error) {
+	br := bufio.NewReaderSize(r, sniffLimit)
+	prefix, err := br.Peek(prescanLimit)
+	if err != nil && err != io.EOF {
+		return nil, "", fmt.Errorf("ошибка чтения начала документа: %w", err)
+	}
+
+	name, bomLength := declaredEncoding(prefix, contentType)
+	if name == "" {
+		if name, err = sniffEncoding(br); err != nil {
+			return nil, "", err
+		}
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, "", fmt.Errorf("неизвестная кодировка %s: %w", name, err)
+	}
+	br.Discard(bomLength)
+
+	return transform.NewReader(br, enc.NewDecoder()), name, nil
+}
+
+// sniffLimit — сколько байт документа без объявленной кодировки
+// просматривается в поисках первого байта вне ASCII
+const sniffLimit = 64 << 10
+
+// sniffEncoding определяет кодировку документа, в котором она не объявлена,
+// не читая его из br. Текст из одних символов ASCII одинаково читается в
+// UTF-8 и в кодировке по умолчанию, поэтому решение откладывается до первого
+// байта вне ASCII: документ считается UTF-8, если просмотренное начало
+// вместе с этим байтом — корректный UTF-8. Документ, целиком состоящий из
+// ASCII, считается UTF-8, как и в DetectEncoding, а документ, в первых
+// sniffLimit байтах которого нет других символов, читается в кодировке по
+// умолчанию
+func sniffEncoding(br *bufio.Reader) (string, error) {
+	for n := prescanLimit; ; n = min(2*n, sniffLimit) {
+		prefix, err := br.Peek(n)
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("ошибка чтения начала документа: %w", err)
+		}
+		if err == nil {
+			prefix = trimPartialRune(prefix)
+		}
+		if err == io.EOF || !isASCII(prefix) {
+			if utf8.Valid(prefix) {
+				return "utf-8", nil
+			}
+			break
+		}
+		if n == sniffLimit {
+			break
+		}
+	}
+	return htmlindex.LanguageDefault(FallbackLanguage), nil
+}
+
+// isASCII проверяет, что b состоит только из символов ASCII
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// trimPartialRune отбрасывает символ UTF-8, обрезанный в конце буфера
+func trimPartialRune(b []byte) []byte {
+	for i := 1; i <= utf8.UTFMax && i <= len(b); i++ {
+		if utf8.RuneStart(b[len(b)-i]) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return b[:len(b)-i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// sniffBOM проверяет наличие метки порядка байтов
+func sniffBOM(body []byte) (string, int) {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8", 3
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return "utf-16be", 2
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return "utf-16le", 2
+	}
+	return "", 0
+}
+
+// charsetFromContentType извлекает кодировку из заголовка Content-Type
+func charsetFromContentType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+
+	var charset string
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		charset = params["charset"]
+	} else {
+		// Заголовок с ошибками: пытаемся найти charset так же, как в <meta content>
+		charset = extractCharsetFromContent(contentType)
+	}
+
+	return encodingName(charset)
+}
+
+// encodingName возвращает каноническое имя кодировки по ее метке или пустую
+// строку, если метка неизвестна
+func encodingName(label string) string {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return ""
+	}
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return ""
+	}
+	name, err := htmlindex.Name(enc)
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// prescanMeta ищет объявление кодировки в первых байтах документа
+func prescanMeta(body []byte) string {
+	if len(body) > prescanLimit {
+		body = body[:prescanLimit]
+	}
+
+	for pos := 0; pos < len(body); {
+		rest := body[pos:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			// Пропускаем комментарий до "-->" (тире из "<!--" тоже учитываются)
+			end := bytes.Index(body[pos+2:], []byte("-->"))
+			if end < 0 {
+				return ""
+			}
+			pos += 2 + end + 3
+
+		case hasPrefixFold(rest, "<meta") && len(rest) > 5 && (isSpaceByte(rest[5]) || rest[5] == '/'):
+			name, next := prescanMetaElement(body, pos+5)
+			if name != "" {
+				return name
+			}
+			pos = next
+
+		case len(rest) > 1 && rest[0] == '<' && isASCIILetter(rest[1]),
+			len(rest) > 2 && rest[0] == '<' && rest[1] == '/' && isASCIILetter(rest[2]):
+			// Пропускаем имя тега и все его атрибуты
+			for pos < len(body) && !isSpaceByte(body[pos]) && body[pos] != '>' {
+				pos++
+			}
+			for {
+				var ok bool
+				if _, _, pos, ok = prescanAttribute(body, pos); !ok {
+					break
+				}
+			}
+
+		case bytes.HasPrefix(rest, []byte("<!")), bytes.HasPrefix(rest, []byte("</")), bytes.HasPrefix(rest, []byte("<?")):
+			end := bytes.IndexByte(rest, '>')
+			if end < 0 {
+				return ""
+			}
+			pos += end + 1
+
+		default:
+			pos++
+		}
+	}
+
+	return ""
+}
+
+// prescanMetaElement разбирает атрибуты элемента <meta> и возвращает
+// объявленную в нем кодировку, а также позицию после элемента
+func prescanMetaElement(body []byte, pos int) (string, int) {
+	seen := make(map[string]bool)
+	gotPragma := false
+	needPragma := 0 // 0 — не определено, 1 — нужен http-equiv, -1 — не нужен
+	charset := ""
+
+	for {
+		name, value, next, ok := prescanAttribute(body, pos)
+		pos = next
+		if !ok {
+			break
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		switch name {
+		case "http-equiv":
+			if strings.EqualFold(value, "content-type") {
+				gotPragma = true
+			}
+		case "content":
+			if charset == "" {
+				if label := extractCharsetFromContent(value); label != "" {
+					charset = encodingName(label)
+					needPragma = 1
+				}
+			}
+		case "charset":
+			charset = encodingName(value)
+			needPragma = -1
+		}
+	}
+
+	if needPragma == 0 || (needPragma == 1 && !gotPragma) || charset == "" {
+		return "", pos
+	}
+
+	// Объявление UTF-16 внутри документа не может быть верным: документ,
+	// в котором его удалось прочитать, совместим с ASCII
+	switch charset {
+	case "utf-16be", "utf-16le":
+		charset = "utf-8"
+	case "x-user-defined":
+		charset = "windows-1252"
+	}
+
+	return charset, pos
+}
+
+// prescanAttribute читает очередной атрибут тега по алгоритму "get an attribute".
+// Возвращает имя и значение в нижнем регистре, новую позицию и false, если
+// атрибутов больше нет
+func prescanAttribute(body []byte, pos int) (string, string, int, bool) {
+	for pos < len(body) && (isSpaceByte(body[pos]) || body[pos] == '/') {
+		pos++
+	}
+	if pos >= len(body) || body[pos] == '>' {
+		return "", "", pos, false
+	}
+
+	// Имя атрибута
+	var name []byte
+	for ; pos < len(body); pos++ {
+		c := body[pos]
+		if c == '=' && len(name) > 0 {
+			break
+		}
+		if isSpaceByte(c) {
+			for pos < len(body) && isSpaceByte(body[pos]) {
+				pos++
+			}
+			if pos >= len(body) || body[pos] != '=' {
+				return string(name), "", pos, true
+			}
+			break
+		}
+		if c == '/' || c == '>' {
+			return string(name), "", pos, true
+		}
+		name = append(name, toLowerByte(c))
+	}
+	if pos >= len(body) {
+		return "", "", pos, false
+	}
+
+	// Пропускаем "=" и пробелы после него
+	pos++
+	for pos < len(body) && isSpaceByte(body[pos]) {
+		pos++
+	}
+	if pos >= len(body) {
+		return "", "", pos, false
+	}
+
+	// Значение атрибута
+	var value []byte
+	if quote := body[pos]; quote == '"' || quote == '\'' {
+		pos++
+		for ; pos < len(body); pos++ {
+			if body[pos] == quote {
+				return string(name), string(value), pos + 1, true
+			}
+			value = append(value, toLowerByte(body[pos]))
+		}
+		return "", "", pos, false
+	}
+	if body[pos] == '>' {
+		return string(name), "", pos, true
+	}
+	for ; pos < len(body); pos++ {
+		c := body[pos]
+		if isSpaceByte(c) || c == '>' {
+			return string(name), string(value), pos, true
+		}
+		value = append(value, toLowerByte(c))
+	}
+	return "", "", pos, false
+}
+
+// extractCharsetFromContent извлекает кодировку из значения атрибута content
+// элемента <meta> (например, "text/html; charset=windows-1251")
+func extractCharsetFromContent(content string) string {
+	lower := strings.ToLower(content)
+	for pos := 0; ; {
+		i := strings.Index(lower[pos:], "charset")
+		if i < 0 {
+			return ""
+		}
+		pos += i + len("charset")
+
+		rest := strings.TrimLeft(lower[pos:], "\t\n\f\r ")
+		if !strings.HasPrefix(rest, "=") {
+			continue
+		}
+		rest = strings.TrimLeft(rest[1:], "\t\n\f\r ")
+		if rest == "" {
+			return ""
+		}
+
+		if quote := rest[0]; quote == '"' || quote == '\'' {
+			end := strings.IndexByte(rest[1:], quote)
+			if end < 0 {
+				return ""
+			}
+			return rest[1 : 1+end]
+		}
+
+		end := strings.IndexAny(rest, "\t\n\f\r ;")
+		if end < 0 {
+			return rest
+		}
+		return rest[:end]
+	}
+}
+
+// hasPrefixFold проверяет префикс без учета регистра ASCII
+func hasPrefixFold(b []byte, prefix string) bool {
+	return len(b) >= len(prefix) && strings.EqualFold(string(b[:len(prefix)]), prefix)
+}
+
+func isSpaceByte(c byte) bool {
+	return c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func toLowerByte(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
@@ -0,0 +1,117 @@
+package network
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		want        string
+		bom         int
+	}{
+		{"BOM UTF-8 важнее заголовка и meta", "\xEF\xBB\xBF<meta charset=koi8-r>", "text/html; charset=windows-1251", "utf-8", 3},
+		{"BOM UTF-16LE", "\xFF\xFE<\x00h\x00", "", "utf-16le", 2},
+		{"BOM UTF-16BE", "\xFE\xFF\x00<\x00h", "text/html; charset=utf-8", "utf-16be", 2},
+		{"charset из Content-Type важнее meta", `<meta charset="koi8-r">`, "text/html; charset=Windows-1251", "windows-1251", 0},
+		{"charset в кавычках", "", `text/html; charset="koi8-r"`, "koi8-r", 0},
+		{"метка-синоним", "", "text/html; charset=cp1251", "windows-1251", 0},
+		{"неизвестная метка в заголовке", `<meta charset="koi8-r">`, "text/html; charset=bogus", "koi8-r", 0},
+		{"meta charset", `<!DOCTYPE html><html><head><meta charset="windows-1251">`, "text/html", "windows-1251", 0},
+		{"meta charset без кавычек", `<META CHARSET=KOI8-R>`, "", "koi8-r", 0},
+		{"meta http-equiv", `<meta http-equiv="Content-Type" content="text/html; charset=windows-1251">`, "", "windows-1251", 0},
+		{"http-equiv после content", `<meta content="text/html; charset=koi8-r" http-equiv=content-type>`, "", "koi8-r", 0},
+		{"content без http-equiv не учитывается", `<meta content="text/html; charset=koi8-r">`, "", "utf-8", 0},
+		{"meta в комментарии пропускается", `<!-- <meta charset="koi8-r"> --><meta charset="windows-1251">`, "", "windows-1251", 0},
+		{"<!--> закрывает комментарий", `<!--><meta charset="koi8-r">`, "", "koi8-r", 0},
+		{"meta в значении атрибута пропускается", `<div title="<meta charset=koi8-r>"><meta charset=iso-8859-2>`, "", "iso-8859-2", 0},
+		{"повторный атрибут игнорируется", `<meta charset=koi8-r charset=windows-1251>`, "", "koi8-r", 0},
+		{"UTF-16 в meta означает UTF-8", `<meta charset="utf-16le">`, "", "utf-8", 0},
+		{"x-user-defined в meta", `<meta charset="x-user-defined">`, "", "windows-1252", 0},
+		{"meta после 1024 байт не видна", strings.Repeat(" ", prescanLimit) + `<meta charset="koi8-r">`, "", "utf-8", 0},
+		{"meta на границе 1024 байт", strings.Repeat(" ", prescanLimit-len(`<meta charset="koi8-r">`)) + `<meta charset="koi8-r">`, "", "koi8-r", 0},
+		{"корректный UTF-8 без объявления", "<p>Привет</p>", "", "utf-8", 0},
+		{"кодировка по умолчанию", "<p>\xcf\xf0\xe8\xe2\xe5\xf2</p>", "", "windows-1251", 0},
+	}
+	for _, test := range tests {
+		name, bom := DetectEncoding([]byte(test.body), test.contentType)
+		if name != test.want || bom != test.bom {
+			t.Errorf("%s: получено %s (BOM %d), ожидалось %s (BOM %d)", test.name, name, bom, test.want, test.bom)
+		}
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	body := []byte("<meta charset=windows-1251><p>\xcf\xf0\xe8\xe2\xe5\xf2</p>")
+	text, name, err := DecodeBody(body, "")
+	if err != nil {
+		t.Fatalf("ошибка декодирования: %v", err)
+	}
+	if name != "windows-1251" || !strings.Contains(text, "Привет") {
+		t.Errorf("получено %q в кодировке %s", text, name)
+	}
+
+	text, name, err = DecodeBody([]byte("\xEF\xBB\xBFтекст"), "")
+	if err != nil || name != "utf-8" || text != "текст" {
+		t.Errorf("BOM не отброшен: %q, %s, %v", text, name, err)
+	}
+}
+
+func TestNewDecodingReader(t *testing.T) {
+	// Символ UTF-8, разрезанный границей просматриваемого начала, не
+	// делает документ некорректным UTF-8
+	body := strings.Repeat("a", prescanLimit-1) + "я"
+	r, name, err := NewDecodingReader(strings.NewReader(body), "")
+	if err != nil {
+		t.Fatalf("ошибка: %v", err)
+	}
+	if name != "utf-8" {
+		t.Errorf("кодировка %s, ожидалась utf-8", name)
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil || string(decoded) != body {
+		t.Errorf("текст изменился при декодировании")
+	}
+}
+
+func TestNewDecodingReaderMatchesDecodeBody(t *testing.T) {
+	head := "<!DOCTYPE html><html><head><title>t</title></head><body>" + strings.Repeat("<p>ascii</p>", 2*prescanLimit/12)
+	tests := []struct {
+		name string
+		body string
+	}{
+		// Начало из одних символов ASCII не доказывает, что документ в UTF-8
+		{"windows-1251 после длинного ASCII", head + "<p>\xcf\xf0\xe8\xe2\xe5\xf2</p>"},
+		{"UTF-8 после длинного ASCII", head + "<p>Привет</p>"},
+		{"только ASCII", head},
+		{"байт вне ASCII на границе просмотра", strings.Repeat("a", prescanLimit-1) + "\xcf\xf0\xe8"},
+	}
+	for _, test := range tests {
+		want, wantName, err := DecodeBody([]byte(test.body), "")
+		if err != nil {
+			t.Fatalf("%s: ошибка DecodeBody: %v", test.name, err)
+		}
+		r, name, err := NewDecodingReader(strings.NewReader(test.body), "")
+		if err != nil {
+			t.Fatalf("%s: ошибка: %v", test.name, err)
+		}
+		decoded, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: ошибка чтения: %v", test.name, err)
+		}
+		if name != wantName || string(decoded) != want {
+			t.Errorf("%s: кодировка %s, ожидалась %s, текст совпадает: %v", test.name, name, wantName, string(decoded) == want)
+		}
+	}
+
+	// В документе, где за sniffLimit байт не встретилось символов вне ASCII,
+	// берется кодировка по умолчанию
+	body := strings.Repeat("a", sniffLimit+10) + "\xcf\xf0\xe8"
+	if _, name, err := NewDecodingReader(strings.NewReader(body), ""); err != nil || name != "windows-1251" {
+		t.Errorf("длинный ASCII: кодировка %s, %v, ожидалась windows-1251", name, err)
+	}
+}
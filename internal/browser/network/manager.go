@@ -2,12 +2,18 @@ package network
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
+// streamCacheLimit — наибольший размер документа, который сохраняется в кэш
+// при потоковом чтении. Более крупные документы не копируются, чтобы во время
+// разбора в памяти не лежала вторая полная копия тела
+const streamCacheLimit = 1 << 20
+
 // Manager управляет сетевыми запросами
 type Manager struct {
 	client *http.Client
@@ -16,11 +22,31 @@ type Manager struct {
 
 // CacheEntry представляет кэшированный ответ
 type CacheEntry struct {
+	// URL — адрес документа после перенаправлений
+	URL       string
 	Content   string
+	Encoding  string
 	Timestamp time.Time
 	Headers   http.Header
 }
 
+// Response представляет загруженный документ, перекодированный в UTF-8
+type Response struct {
+	URL      string
+	Content  string
+	Encoding string
+	Headers  http.Header
+}
+
+// StreamResponse представляет документ, тело которого читается по мере загрузки
+type StreamResponse struct {
+	URL      string
+	Encoding string
+	Headers  http.Header
+	// Body — тело документа в UTF-8
+	Body io.ReadCloser
+}
+
 // NewManager создает новый менеджер сетевых запросов
 func NewManager() *Manager {
 	client := &http.Client{
@@ -40,6 +66,39 @@ func NewManager() *Manager {
 
 // Fetch загружает содержимое по указанному URL
 func (m *Manager) Fetch(url string) (string, error) {
+	resp, err := m.FetchResponse(url)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+// FetchResponse загружает документ по указанному URL и перекодирует его в UTF-8
+func (m *Manager) FetchResponse(url string) (*Response, error) {
+	stream, err := m.FetchStream(url)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Body.Close()
+	
+	content, err := io.ReadAll(stream.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+	
+	return &Response{
+		URL:      stream.URL,
+		Content:  string(content),
+		Encoding: stream.Encoding,
+		Headers:  stream.Headers,
+	}, nil
+}
+
+// FetchStream начинает загрузку документа по указанному URL. Тело ответа
+// перекодируется в UTF-8 по мере чтения, так что его можно разбирать, не
+// дожидаясь конца загрузки. Документ, прочитанный до конца, попадает в кэш,
+// если он не больше streamCacheLimit. Вызывающий должен закрыть Body
+func (m *Manager) FetchStream(url string) (*StreamResponse, error) {
 	log.Printf("Сетевой запрос: %s", url)
 	
 	// Проверка кэша
@@ -47,14 +106,19 @@ func (m *Manager) Fetch(url string) (string, error) {
 		// Проверяем, не устарел ли кэш (простая реализация - 5 минут)
 		if time.Since(entry.Timestamp) < 5*time.Minute {
 			log.Printf("Использование кэшированного ответа для %s", url)
-			return entry.Content, nil
+			return &StreamResponse{
+				URL:      entry.URL,
+				Encoding: entry.Encoding,
+				Headers:  entry.Headers,
+				Body:     io.NopCloser(strings.NewReader(entry.Content)),
+			}, nil
 		}
 	}
 	
 	// Создание запроса
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", fmt.Errorf("ошибка создания запроса: %w", err)
+		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
 	}
 	
 	// Установка заголовков
@@ -65,31 +129,75 @@ func (m *Manager) Fetch(url string) (string, error) {
 	// Выполнение запроса
 	resp, err := m.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("ошибка выполнения запроса: %w", err)
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
 	}
-	defer resp.Body.Close()
 	
 	// Проверка статуса ответа
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("неверный статус ответа: %d %s", resp.StatusCode, resp.Status)
+		resp.Body.Close()
+		return nil, fmt.Errorf("неверный статус ответа: %d %s", resp.StatusCode, resp.Status)
 	}
 	
-	// Чтение тела ответа
-	body, err := ioutil.ReadAll(resp.Body)
+	// Перекодирование в UTF-8 по мере чтения
+	decoded, encoding, err := NewDecodingReader(resp.Body, resp.Header.Get("Content-Type"))
 	if err != nil {
-		return "", fmt.Errorf("ошибка чтения ответа: %w", err)
+		resp.Body.Close()
+		return nil, fmt.Errorf("ошибка декодирования ответа: %w", err)
 	}
+	log.Printf("Кодировка документа %s: %s", url, encoding)
 	
-	content := string(body)
+	// После перенаправлений адрес документа может отличаться от запрошенного
+	finalURL := resp.Request.URL.String()
 	
-	// Кэширование ответа
-	m.cache[url] = CacheEntry{
-		Content:   content,
-		Timestamp: time.Now(),
-		Headers:   resp.Header,
+	body := &cachingBody{reader: decoded, body: resp.Body}
+	body.store = func(content string) {
+		m.cache[url] = CacheEntry{
+			URL:       finalURL,
+			Content:   content,
+			Encoding:  encoding,
+			Timestamp: time.Now(),
+			Headers:   resp.Header,
+		}
 	}
 	
-	return content, nil
+	return &StreamResponse{
+		URL:      finalURL,
+		Encoding: encoding,
+		Headers:  resp.Header,
+		Body:     body,
+	}, nil
+}
+
+// cachingBody — тело ответа, которое по мере чтения накапливается и после
+// чтения до конца сохраняется в кэш. Как только тело превышает
+// streamCacheLimit, накопленное отбрасывается и документ не кэшируется
+type cachingBody struct {
+	reader  io.Reader
+	body    io.Closer
+	content strings.Builder
+	store   func(content string)
+}
+
+// Read читает очередную часть перекодированного тела
+func (c *cachingBody) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	if c.store != nil && c.content.Len()+n > streamCacheLimit {
+		c.store = nil
+		c.content = strings.Builder{}
+	}
+	if c.store != nil {
+		c.content.Write(p[:n])
+	}
+	if err == io.EOF && c.store != nil {
+		c.store(c.content.String())
+		c.store = nil
+	}
+	return n, err
+}
+
+// Close закрывает соединение
+func (c *cachingBody) Close() error {
+	return c.body.Close()
 }
 
 // ClearCache очищает кэш
@@ -0,0 +1,38 @@
+package network
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCachingBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		cached bool
+	}{
+		{"небольшой документ кэшируется", 1000, true},
+		{"документ ровно на пределе кэшируется", streamCacheLimit, true},
+		{"крупный документ не кэшируется", streamCacheLimit + 1, false},
+	}
+	for _, test := range tests {
+		body := strings.Repeat("a", test.size)
+		stored := ""
+		called := false
+		c := &cachingBody{reader: strings.NewReader(body), body: io.NopCloser(nil)}
+		c.store = func(content string) {
+			stored, called = content, true
+		}
+		read, err := io.ReadAll(c)
+		if err != nil || string(read) != body {
+			t.Errorf("%s: тело прочитано неверно (%v)", test.name, err)
+		}
+		if called != test.cached || called && stored != body {
+			t.Errorf("%s: сохранено в кэш %v (%d байт), ожидалось %v", test.name, called, len(stored), test.cached)
+		}
+		if !test.cached && c.content.Len() != 0 {
+			t.Errorf("%s: накоплено %d байт после превышения предела", test.name, c.content.Len())
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Package reader реализует режим чтения: извлекает из страницы основной
+// текст статьи без навигации, рекламы и комментариев, а также ее заголовок,
+// автора, дату публикации и главное изображение
+package reader
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/metadata"
+)
+
+// ErrNoArticle возвращается, если на странице не найден текст статьи
+var ErrNoArticle = errors.New("на странице не найдена статья")
+
+// Article — статья, извлеченная в режиме чтения
+type Article struct {
+	Title string
+	// Byline — автор статьи
+	Byline   string
+	SiteName string
+	Language string
+	// Published — дата публикации; нулевая, если она не указана
+	Published time.Time
+	// LeadImage — адрес главного изображения статьи
+	LeadImage string
+	// Excerpt — краткое описание или первый абзац статьи
+	Excerpt string
+
+	// Content — очищенная разметка статьи
+	Content string
+	// Text — текст статьи, абзацы разделены пустой строкой
+	Text string
+	// Root — корневой элемент очищенной статьи. Это копия, не связанная с
+	// документом страницы
+	Root *html.Node
+}
+
+// Extract извлекает статью из документа. Сам документ не изменяется.
+// Относительные адреса ссылок и изображений разрешаются относительно
+// базового адреса документа
+func Extract(doc *html.Document) (*Article, error) {
+	body := doc.Body()
+	if body == nil {
+		return nil, ErrNoArticle
+	}
+	meta := metadata.Extract(doc)
+	base := doc.BaseURL()
+
+	a := &Article{
+		Title:    articleTitle(doc, meta),
+		SiteName: meta.OpenGraph.SiteName,
+		Language: meta.Language,
+	}
+
+	s := &scorer{scores: make(map[*html.Node]float64)}
+	root := body.CloneNode(true)
+	s.prepare(root)
+	article := s.gather(s.topCandidate(root))
+	s.clean(article, a.Title)
+	cleanAttributes(article, base)
+
+	a.Root = article
+	a.Text = plainText(article)
+	if a.Text == "" {
+		return nil, ErrNoArticle
+	}
+	a.Content = article.InnerHTML()
+
+	a.Byline = byline(meta)
+	if a.Byline == "" {
+		a.Byline = s.byline
+	}
+	a.Published = published(doc, meta)
+	a.LeadImage = resolveURL(base, leadImage(article, meta))
+	a.Excerpt = meta.Description
+	if a.Excerpt == "" {
+		a.Excerpt = meta.OpenGraph.Description
+	}
+	if a.Excerpt == "" {
+		a.Excerpt, _, _ = strings.Cut(a.Text, "\n\n")
+	}
+	return a, nil
+}
+
+// titleSeparators — разделители названия статьи и сайта в <title>
+var titleSeparators = []string{" | ", " - ", " – ", " — ", " :: ", " / ", " » ", " « "}
+
+// articleTitle возвращает заголовок статьи: og:title или <title> без
+// названия сайта
+func articleTitle(doc *html.Document, meta *metadata.Metadata) string {
+	if title := collapse(meta.OpenGraph.Title); title != "" {
+		return title
+	}
+	title := doc.Title()
+	for _, sep := range titleSeparators {
+		i := strings.LastIndex(title, sep)
+		if i < 0 {
+			continue
+		}
+		// «Статья | Сайт» или «Сайт | Статья»: берется более длинная часть,
+		// если в ней хотя бы три слова
+		first, last := title[:i], title[i+len(sep):]
+		part := first
+		if len(strings.Fields(last)) > len(strings.Fields(first)) {
+			part = last
+		}
+		if len(strings.Fields(part)) >= 3 {
+			return part
+		}
+		break
+	}
+	if title == "" {
+		if h1 := doc.FindElementsByTagName("h1"); len(h1) == 1 {
+			return collapse(h1[0].TextContent())
+		}
+	}
+	return title
+}
+
+// byline возвращает автора из тегов <meta>, JSON-LD или микроданных
+func byline(meta *metadata.Metadata) string {
+	for _, name := range []string{"author", "article:author", "dc.creator", "parsely-author", "sailthru.author"} {
+		// article:author часто содержит адрес профиля, а не имя
+		if value := collapse(meta.Get(name)); value != "" && !strings.Contains(value, "://") {
+			return value
+		}
+	}
+	for _, value := range jsonLDValues(meta, "author") {
+		if name := personName(value); name != "" {
+			return name
+		}
+	}
+	for _, item := range meta.Microdata {
+		for _, value := range item.Properties["author"] {
+			switch v := value.(type) {
+			case string:
+				return collapse(v)
+			case *metadata.Item:
+				if name := collapse(v.Get("name")); name != "" {
+					return name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// personName возвращает имя автора из значения JSON-LD: строки, объекта
+// Person или списка авторов
+func personName(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return collapse(v)
+	case map[string]interface{}:
+		name, _ := v["name"].(string)
+		return collapse(name)
+	case []interface{}:
+		var names []string
+		for _, item := range v {
+			if name := personName(item); name != "" {
+				names = append(names, name)
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+// jsonLDValues возвращает значения свойства из всех объектов JSON-LD,
+// включая вложенные в @graph и списки
+func jsonLDValues(meta *metadata.Metadata, property string) []interface{} {
+	var values []interface{}
+	var visit func(v interface{})
+	visit = func(v interface{}) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, item := range v {
+				visit(item)
+			}
+		case map[string]interface{}:
+			if value, ok := v[property]; ok {
+				values = append(values, value)
+			}
+			visit(v["@graph"])
+		}
+	}
+	for _, block := range meta.JSONLD {
+		visit(block)
+	}
+	return values
+}
+
+// dateLayouts — форматы дат, которые встречаются в метаданных статей
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// parseDate разбирает дату в одном из известных форматов
+func parseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// published ищет дату публикации в тегах <meta>, JSON-LD, микроданных и,
+// в последнюю очередь, в первом элементе <time datetime>
+func published(doc *html.Document, meta *metadata.Metadata) time.Time {
+	var candidates []string
+	for _, name := range []string{"article:published_time", "og:published_time", "datepublished", "date", "pubdate", "publishdate", "dc.date", "dc.date.issued"} {
+		candidates = append(candidates, meta.Get(name))
+	}
+	for _, value := range jsonLDValues(meta, "datePublished") {
+		if s, ok := value.(string); ok {
+			candidates = append(candidates, s)
+		}
+	}
+	for _, item := range meta.Microdata {
+		candidates = append(candidates, item.Get("datePublished"))
+	}
+	if t, _ := doc.QuerySelector("time[datetime]"); t != nil {
+		candidates = append(candidates, t.GetAttribute("datetime"))
+	}
+
+	for _, candidate := range candidates {
+		if t, ok := parseDate(candidate); ok {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// leadImage возвращает главное изображение: из OpenGraph, Twitter Cards,
+// JSON-LD или первое изображение статьи
+func leadImage(article *html.Node, meta *metadata.Metadata) string {
+	if len(meta.OpenGraph.Images) > 0 {
+		return meta.OpenGraph.Images[0]
+	}
+	if meta.Twitter.Image != "" {
+		return meta.Twitter.Image
+	}
+	for _, value := range jsonLDValues(meta, "image") {
+		if image := imageURL(value); image != "" {
+			return image
+		}
+	}
+	if img, _ := article.QuerySelector("img[src]"); img != nil {
+		return img.GetAttribute("src")
+	}
+	return ""
+}
+
+// imageURL возвращает адрес изображения из значения JSON-LD: строки,
+// объекта ImageObject или списка
+func imageURL(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		image, _ := v["url"].(string)
+		return image
+	case []interface{}:
+		if len(v) > 0 {
+			return imageURL(v[0])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,202 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/metadata"
+	"github.com/baneronetwo/gluglu/internal/browser/weburl"
+)
+
+// loadFixture разбирает документ из testdata с адресом
+// https://news.example.com/2024/03/tram.html
+func loadFixture(t *testing.T, name string) *html.Document {
+	t.Helper()
+	src, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("ошибка чтения %s: %v", name, err)
+	}
+	doc, err := html.NewParser().Parse(string(src))
+	if err != nil {
+		t.Fatalf("ошибка разбора %s: %v", name, err)
+	}
+	doc.URL, err = weburl.Parse("https://news.example.com/2024/03/tram.html", nil)
+	if err != nil {
+		t.Fatalf("ошибка разбора адреса: %v", err)
+	}
+	return doc
+}
+
+func TestExtractFixtures(t *testing.T) {
+	tests := []struct {
+		file      string
+		title     string
+		byline    string
+		published string
+		leadImage string
+		excerpt   string
+		// paragraphs — абзацы текста статьи, которые должны быть в нем целиком
+		paragraphs []string
+		// absent — фрагменты меню, рекламы, комментариев и служебной
+		// разметки, которых не должно быть ни в тексте, ни в разметке
+		absent []string
+	}{
+		{
+			file:      "news.html",
+			title:     "Город открыл новую линию трамвая",
+			byline:    "Анна Петрова, Олег Смирнов",
+			published: "2024-03-15T09:30:00+03:00",
+			leadImage: "https://news.example.com/img/tram.jpg",
+			excerpt:   "Новая линия соединила вокзал и университет.",
+			paragraphs: []string{
+				"В субботу в городе открылась новая линия трамвая, которая соединила железнодорожный вокзал, центральный рынок и университетский кампус.",
+				"Первые вагоны вышли на маршрут в шесть утра, а к полудню ими воспользовались более десяти тысяч пассажиров, сообщает транспортное управление.",
+				"Поделиться новостью можно в любой соцсети, а обсудить ее — на форуме сайта.",
+			},
+			absent: []string{
+				"Политика", "Купите слона", "Популярное", "Комментарии", "Наконец-то",
+				"ВКонтакте", "все права защищены", "Скрытый абзац", "Автор: редакция",
+				"trackView", "javascript:", "class=", "style=", "<h1>", "<p></p>",
+			},
+		},
+		{
+			file:      "blog.html",
+			title:     "Заметки о хлебе",
+			byline:    "Мария Иванова",
+			published: "2023-11-02T00:00:00Z",
+			leadImage: "https://blog.example.com/posts/bread.jpg",
+			excerpt:   "Закваску я веду уже третий год, и за это время она пережила два переезда, отпуск в холодильнике и одну неудачную попытку перевести ее на рожь.",
+			paragraphs: []string{
+				// Соседний блок с тем же классом собирается вместе с лучшим
+				"Про муку: мне нравится смешивать цельнозерновую и обычную пшеничную, примерно один к трем, иначе мякиш получается слишком плотным и тяжелым.",
+				// Таблица данных сохраняется, ячейки разделены пробелом
+				"Мука Вода",
+				"500 г 350 г",
+				"  мука   500\n  вода   350",
+			},
+			absent: []string{
+				"Главная", "Обо мне", "Ржаной хлеб", "Фокачча", "Сделано на коленке",
+				"<h2>", "форме обратной связи",
+			},
+		},
+	}
+	for _, test := range tests {
+		doc := loadFixture(t, test.file)
+		before := doc.Serialize()
+		a, err := Extract(doc)
+		if err != nil {
+			t.Errorf("%s: ошибка %v", test.file, err)
+			continue
+		}
+		if doc.Serialize() != before {
+			t.Errorf("%s: Extract изменил документ", test.file)
+		}
+
+		check := func(field, got, want string) {
+			if got != want {
+				t.Errorf("%s: %s: получено %q, ожидалось %q", test.file, field, got, want)
+			}
+		}
+		check("заголовок", a.Title, test.title)
+		check("автор", a.Byline, test.byline)
+		check("дата", a.Published.Format(time.RFC3339), test.published)
+		check("изображение", a.LeadImage, test.leadImage)
+		check("описание", a.Excerpt, test.excerpt)
+
+		paragraphs := strings.Split(a.Text, "\n\n")
+		for _, want := range test.paragraphs {
+			found := false
+			for _, p := range paragraphs {
+				found = found || p == want
+			}
+			if !found {
+				t.Errorf("%s: нет абзаца %q в тексте:\n%s", test.file, want, a.Text)
+			}
+		}
+		for _, fragment := range test.absent {
+			if strings.Contains(a.Text, fragment) || strings.Contains(a.Content, fragment) {
+				t.Errorf("%s: в статье остался фрагмент %q", test.file, fragment)
+			}
+		}
+	}
+}
+
+func TestExtractCleansAttributes(t *testing.T) {
+	a, err := Extract(loadFixture(t, "news.html"))
+	if err != nil {
+		t.Fatalf("ошибка: %v", err)
+	}
+	img, _ := a.Root.QuerySelector("img")
+	if img == nil {
+		t.Fatalf("изображение статьи удалено:\n%s", a.Content)
+	}
+	// Адрес ленивого изображения берется из data-src и разрешается
+	// относительно адреса документа
+	if got := img.GetAttribute("src"); got != "https://news.example.com/2024/03/photo.jpg" {
+		t.Errorf("src: получено %q", got)
+	}
+	if img.HasAttribute("data-src") || img.HasAttribute("class") || img.GetAttribute("alt") != "Новый трамвай" {
+		t.Errorf("атрибуты изображения: %v", img.Attributes)
+	}
+	link, _ := a.Root.QuerySelector("a")
+	if link == nil || link.GetAttribute("href") != "https://news.example.com/stats" {
+		t.Errorf("ссылка в статье: %v", link)
+	}
+}
+
+func TestExtractNoArticle(t *testing.T) {
+	if _, err := Extract(loadFixture(t, "empty.html")); err != ErrNoArticle {
+		t.Errorf("получено %v, ожидалось ErrNoArticle", err)
+	}
+}
+
+func TestArticleTitle(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"<title>Как испечь хлеб дома | Кулинария</title>", "Как испечь хлеб дома"},
+		{"<title>Кулинария — Как испечь хлеб дома</title>", "Как испечь хлеб дома"},
+		// Слишком короткая часть не считается заголовком статьи
+		{"<title>Хлеб | Кулинария</title>", "Хлеб | Кулинария"},
+		{`<meta property="og:title" content="  Заголовок   из OG "><title>Другой</title>`, "Заголовок из OG"},
+		{"<body><h1> Единственный  заголовок </h1>", "Единственный заголовок"},
+		{"<body><h1>Первый</h1><h1>Второй</h1>", ""},
+	}
+	for _, test := range tests {
+		doc, err := html.NewParser().Parse(test.src)
+		if err != nil {
+			t.Fatalf("ошибка разбора: %v", err)
+		}
+		if got := articleTitle(doc, metadata.Extract(doc)); got != test.want {
+			t.Errorf("%s: получено %q, ожидалось %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"<p>один\n  два</p><p>три</p>", "один два\n\nтри"},
+		{"<p>строка<br>вторая</p>", "строка\nвторая"},
+		{"<ul><li>раз</li><li>два</li></ul>", "• раз\n\n• два"},
+		{"<pre>  a\n   b</pre>", "  a\n   b"},
+		{"<div>текст <b>жирный</b> и <i>курсив</i></div>", "текст жирный и курсив"},
+		{"<table><tr><td>1</td><td>2</td></tr></table>", "1 2"},
+	}
+	for _, test := range tests {
+		doc, err := html.NewParser().Parse(test.src)
+		if err != nil {
+			t.Fatalf("ошибка разбора: %v", err)
+		}
+		if got := plainText(doc.Body()); got != test.want {
+			t.Errorf("%s: получено %q, ожидалось %q", test.src, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package reader
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+)
+
+// Поиск основного содержимого по идеям Readability: каждый абзац начисляет
+// очки своим предкам, а побеждает предок с наибольшим счетом
+
+// Регулярные выражения ниже и роли unlikelyRoles взяты из Mozilla
+// Readability (https://github.com/mozilla/readability, Readability.js),
+// Copyright (c) 2010 Arc90 Inc, распространяется по лицензии Apache 2.0
+// (http://www.apache.org/licenses/LICENSE-2.0)
+var (
+	// unlikelyCandidates — классы и id блоков, которые почти никогда не
+	// содержат статью: меню, подвалы, комментарии, реклама
+	unlikelyCandidates = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote`)
+	// maybeCandidates отменяет unlikelyCandidates: «article-comments» все же
+	// может оказаться статьей
+	maybeCandidates = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveNames   = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeNames   = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|footer|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|widget`)
+	bylineNames     = regexp.MustCompile(`(?i)byline|author|dateline|writtenby|p-author`)
+	// sentenceEnd — конец предложения, по которому короткий абзац без ссылок
+	// признается частью статьи
+	sentenceEnd = regexp.MustCompile(`[.!?…]( |$)`)
+)
+
+// removedTags — элементы, которые удаляются из статьи вместе с содержимым
+var removedTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"link": true, "meta": true, "iframe": true, "object": true, "embed": true,
+	"form": true, "button": true, "input": true, "select": true, "textarea": true,
+	"nav": true, "aside": true, "footer": true, "dialog": true, "canvas": true,
+	"svg": true, "math": true,
+}
+
+// unlikelyRoles — роли ARIA, которые не бывают у статьи (из Mozilla
+// Readability, см. выше)
+var unlikelyRoles = map[string]bool{
+	"menu": true, "menubar": true, "complementary": true, "navigation": true,
+	"alert": true, "alertdialog": true, "dialog": true, "banner": true,
+	"contentinfo": true, "search": true,
+}
+
+// paragraphBlocks — элементы, наличие которых внутри <div> означает, что
+// сам <div> не является абзацем
+var paragraphBlocks = map[string]bool{
+	"blockquote": true, "dl": true, "div": true, "img": true, "ol": true,
+	"p": true, "pre": true, "table": true, "ul": true, "section": true,
+	"article": true, "figure": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true,
+}
+
+// scorer хранит очки кандидатов
+type scorer struct {
+	scores map[*html.Node]float64
+	// byline — подпись автора, найденная при подготовке дерева
+	byline string
+}
+
+// prepare удаляет из дерева скрипты, скрытые элементы и блоки, которые по
+// именам классов и ролям не могут быть статьей. Подпись автора запоминается
+// и тоже удаляется
+func (s *scorer) prepare(root *html.Node) {
+	var removed []*html.Node
+	walk(root, func(n *html.Node) bool {
+		if n == root {
+			return true
+		}
+		if n.Type == html.CommentNode {
+			removed = append(removed, n)
+			return false
+		}
+		if n.Type != html.ElementNode {
+			return false
+		}
+		if removedTags[n.TagName] || !n.IsHTML() || isHidden(n) ||
+			unlikelyRoles[strings.ToLower(n.GetAttribute("role"))] {
+			removed = append(removed, n)
+			return false
+		}
+		if s.byline == "" && isByline(n) {
+			s.byline = collapse(n.TextContent())
+			removed = append(removed, n)
+			return false
+		}
+		names := n.GetAttribute("class") + " " + n.ID()
+		if n.TagName != "a" && n.TagName != "body" && unlikelyCandidates.MatchString(names) &&
+			!maybeCandidates.MatchString(names) {
+			removed = append(removed, n)
+			return false
+		}
+		return true
+	})
+	for _, n := range removed {
+		n.Parent.RemoveChild(n)
+	}
+}
+
+// isHidden проверяет, скрыт ли элемент атрибутами
+func isHidden(n *html.Node) bool {
+	if n.HasAttribute("hidden") || n.GetAttribute("aria-hidden") == "true" {
+		return true
+	}
+	style := strings.ToLower(strings.Join(strings.Fields(n.GetAttribute("style")), ""))
+	return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+// isByline проверяет, похож ли элемент на подпись автора
+func isByline(n *html.Node) bool {
+	rel := n.GetAttribute("rel")
+	itemprop := n.GetAttribute("itemprop")
+	if rel != "author" && !strings.Contains(itemprop, "author") &&
+		!bylineNames.MatchString(n.GetAttribute("class")+" "+n.ID()) {
+		return false
+	}
+	length := textLength(n)
+	return length > 0 && length < 100
+}
+
+// topCandidate начисляет очки предкам абзацев и возвращает элемент с
+// наибольшим счетом. Если абзацев нет, возвращается root
+func (s *scorer) topCandidate(root *html.Node) *html.Node {
+	var candidates []*html.Node
+	walk(root, func(n *html.Node) bool {
+		if n.Type != html.ElementNode || !isParagraph(n) {
+			return true
+		}
+		length := textLength(n)
+		if length < 25 {
+			return true
+		}
+		text := n.TextContent()
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")) +
+			math.Min(float64(length)/100, 3)
+
+		level := 0
+		for ancestor := n.Parent; ancestor != nil && ancestor.Type == html.ElementNode && level < 5; ancestor = ancestor.Parent {
+			if _, ok := s.scores[ancestor]; !ok {
+				s.scores[ancestor] = initialScore(ancestor)
+				candidates = append(candidates, ancestor)
+			}
+			divider := 1.0
+			switch {
+			case level == 1:
+				divider = 2
+			case level > 1:
+				divider = float64(level * 3)
+			}
+			s.scores[ancestor] += score / divider
+			level++
+			if ancestor == root {
+				break
+			}
+		}
+		return true
+	})
+
+	var top *html.Node
+	for _, c := range candidates {
+		s.scores[c] *= 1 - linkDensity(c)
+		if top == nil || s.scores[c] > s.scores[top] {
+			top = c
+		}
+	}
+	if top == nil {
+		return root
+	}
+	// Единственный ребенок обертки не дает собрать соседние блоки статьи
+	for top != root && top.Parent != nil && top.Parent != root && len(top.Parent.Children()) == 1 {
+		top = top.Parent
+	}
+	return top
+}
+
+// isParagraph проверяет, начисляет ли элемент очки предкам
+func isParagraph(n *html.Node) bool {
+	switch n.TagName {
+	case "p", "pre", "td":
+		return true
+	case "div", "section":
+		for _, child := range n.Children() {
+			if paragraphBlocks[child.TagName] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// initialScore — начальный счет кандидата по тегу и именам классов
+func initialScore(n *html.Node) float64 {
+	score := classWeight(n)
+	switch n.TagName {
+	case "div", "article", "main":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+	return score
+}
+
+// classWeight оценивает элемент по классу и id: «content» и «article» —
+// хорошо, «sidebar» и «comment» — плохо
+func classWeight(n *html.Node) float64 {
+	weight := 0.0
+	for _, name := range []string{n.GetAttribute("class"), n.ID()} {
+		if name == "" {
+			continue
+		}
+		if negativeNames.MatchString(name) {
+			weight -= 25
+		}
+		if positiveNames.MatchString(name) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// gather собирает статью из лучшего кандидата и тех его соседей, которые
+// тоже похожи на содержимое (например, абзацы вне общей обертки)
+func (s *scorer) gather(top *html.Node) *html.Node {
+	article := html.NewElement("div")
+	if top.Parent == nil || top.Parent.Type != html.ElementNode {
+		moveTo(top, article)
+		return article
+	}
+
+	topScore := s.scores[top]
+	threshold := math.Max(10, topScore*0.2)
+	topClass := top.GetAttribute("class")
+
+	var selected []*html.Node
+	for _, sibling := range top.Parent.Children() {
+		if sibling == top {
+			selected = append(selected, sibling)
+			continue
+		}
+		bonus := 0.0
+		if topClass != "" && sibling.GetAttribute("class") == topClass {
+			bonus = topScore * 0.2
+		}
+		if score, ok := s.scores[sibling]; ok && score+bonus >= threshold {
+			selected = append(selected, sibling)
+			continue
+		}
+		if sibling.TagName == "p" {
+			length := textLength(sibling)
+			density := linkDensity(sibling)
+			if length > 80 && density < 0.25 ||
+				length > 0 && density == 0 && sentenceEnd.MatchString(collapse(sibling.TextContent())) {
+				selected = append(selected, sibling)
+			}
+		}
+	}
+	for _, n := range selected {
+		n.Parent.RemoveChild(n)
+		article.AppendChild(n)
+	}
+	return article
+}
+
+// moveTo переносит детей узла в dst. Используется, когда статьей оказался
+// весь корень
+func moveTo(n, dst *html.Node) {
+	for n.FirstChild != nil {
+		child := n.FirstChild
+		n.RemoveChild(child)
+		dst.AppendChild(child)
+	}
+}
+
+// clean удаляет из собранной статьи блоки, которые похожи на списки ссылок,
+// галереи и остатки форм, а также пустые абзацы
+func (s *scorer) clean(article *html.Node, title string) {
+	var removed []*html.Node
+	walk(article, func(n *html.Node) bool {
+		if n == article || n.Type != html.ElementNode {
+			return true
+		}
+		switch n.TagName {
+		case "h1", "h2":
+			if classWeight(n) < 0 || title != "" && collapse(n.TextContent()) == title {
+				removed = append(removed, n)
+				return false
+			}
+		case "table", "ul", "ol", "div", "section", "fieldset":
+			if s.isFishy(n) {
+				removed = append(removed, n)
+				return false
+			}
+		case "p":
+			if textLength(n) == 0 && !hasMedia(n) {
+				removed = append(removed, n)
+				return false
+			}
+		}
+		return true
+	})
+	for _, n := range removed {
+		n.Parent.RemoveChild(n)
+	}
+}
+
+// isFishy проверяет, похож ли блок внутри статьи на мусор: в нем мало текста,
+// много ссылок, картинок или пунктов списка
+func (s *scorer) isFishy(n *html.Node) bool {
+	if n.TagName == "table" && isDataTable(n) {
+		return false
+	}
+	weight := classWeight(n)
+	if weight+s.scores[n] < 0 {
+		return true
+	}
+	text := n.TextContent()
+	if strings.Count(text, ",") >= 10 {
+		return false
+	}
+
+	count := func(tag string) int {
+		return len(n.FindElementsByTagName(tag))
+	}
+	paragraphs, images, items := count("p"), count("img"), count("li")-100
+	embeds := count("video") + count("audio")
+	length := textLength(n)
+	density := linkDensity(n)
+	isList := n.TagName == "ul" || n.TagName == "ol"
+
+	switch {
+	case images > 1 && float64(paragraphs)/float64(images) < 0.5:
+		return true
+	case !isList && items > paragraphs:
+		return true
+	case length < 25 && (images == 0 || images > 2) && embeds == 0:
+		return true
+	case weight < 25 && density > 0.2:
+		return true
+	case weight >= 25 && density > 0.5:
+		return true
+	case embeds > 1 && length < 75:
+		return true
+	}
+	return false
+}
+
+// isDataTable проверяет, содержит ли таблица данные, а не верстку
+func isDataTable(n *html.Node) bool {
+	return n.HasAttribute("summary") || len(n.FindElementsByTagName("caption")) > 0 ||
+		len(n.FindElementsByTagName("th")) > 0
+}
+
+// hasMedia проверяет, есть ли внутри элемента изображения или видео
+func hasMedia(n *html.Node) bool {
+	for _, tag := range []string{"img", "picture", "video", "audio", "figure"} {
+		if len(n.FindElementsByTagName(tag)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// linkDensity — доля текста элемента, находящегося внутри ссылок. Ссылки на
+// якоря той же страницы (сноски, оглавление) учитываются с малым весом
+func linkDensity(n *html.Node) float64 {
+	length := textLength(n)
+	if length == 0 {
+		return 0
+	}
+	links := 0.0
+	for _, a := range n.FindElementsByTagName("a") {
+		weight := 1.0
+		if strings.HasPrefix(a.GetAttribute("href"), "#") {
+			weight = 0.3
+		}
+		links += float64(textLength(a)) * weight
+	}
+	return links / float64(length)
+}
+
+// textLength — длина текста элемента в символах без учета лишних пробелов
+func textLength(n *html.Node) int {
+	return utf8.RuneCountInString(collapse(n.TextContent()))
+}
+
+// collapse удаляет пробелы по краям и схлопывает пробелы внутри строки
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// walk обходит элементы и текст в порядке документа. Если fn возвращает
+// false, потомки узла не посещаются
+func walk(n *html.Node, fn func(n *html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		walk(c, fn)
+		c = next
+	}
+}
@@ -0,0 +1,205 @@
+package reader
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/weburl"
+)
+
+// keptAttributes — атрибуты, которые остаются в очищенной статье. Классы,
+// стили и обработчики событий удаляются
+var keptAttributes = map[string]bool{
+	"href": true, "src": true, "alt": true, "title": true, "colspan": true,
+	"rowspan": true, "datetime": true, "cite": true, "lang": true, "dir": true,
+}
+
+// urlAttributes — атрибуты с адресами, которые разрешаются относительно базы
+var urlAttributes = map[string]bool{"href": true, "src": true, "cite": true}
+
+// cleanAttributes удаляет лишние атрибуты, разрешает относительные адреса и
+// заменяет ссылки javascript: их содержимым
+func cleanAttributes(root *html.Node, base *weburl.URL) {
+	var unwrapped []*html.Node
+	walk(root, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return true
+		}
+		// Ленивые изображения хранят настоящий адрес в data-src
+		if n.TagName == "img" && !n.HasAttribute("src") && n.HasAttribute("data-src") {
+			n.SetAttribute("src", n.GetAttribute("data-src"))
+		}
+		attrs := n.Attributes[:0]
+		for _, attr := range n.Attributes {
+			if !keptAttributes[attr.Name] {
+				continue
+			}
+			if urlAttributes[attr.Name] {
+				attr.Value = resolveURL(base, attr.Value)
+			}
+			attrs = append(attrs, attr)
+		}
+		n.Attributes = attrs
+		if n.TagName == "a" && strings.HasPrefix(strings.ToLower(n.GetAttribute("href")), "javascript:") {
+			unwrapped = append(unwrapped, n)
+		}
+		return true
+	})
+	for _, n := range unwrapped {
+		for n.FirstChild != nil {
+			child := n.FirstChild
+			n.RemoveChild(child)
+			n.Parent.InsertBefore(child, n)
+		}
+		n.Parent.RemoveChild(n)
+	}
+}
+
+// resolveURL разрешает адрес относительно base
+func resolveURL(base *weburl.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ref
+	}
+	u, err := weburl.Parse(ref, base)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// textBlocks — элементы, которые в тексте статьи начинают новый абзац
+var textBlocks = map[string]bool{
+	"address": true, "article": true, "blockquote": true, "dd": true, "div": true,
+	"dl": true, "dt": true, "figcaption": true, "figure": true, "h1": true,
+	"h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+	"hr": true, "li": true, "main": true, "ol": true, "p": true, "section": true,
+	"table": true, "tr": true, "ul": true, "details": true, "summary": true,
+}
+
+// htmlSpace — последовательность пробельных символов HTML
+var htmlSpace = regexp.MustCompile(`[ \t\n\f\r]+`)
+
+// plainText переводит статью в текст: блоки разделяются пустой строкой,
+// пробелы внутри абзацев схлопываются, <br> становится переводом строки,
+// а содержимое <pre> сохраняется как есть
+func plainText(root *html.Node) string {
+	var paragraphs []string
+	var current strings.Builder
+
+	flush := func() {
+		var lines []string
+		for _, line := range strings.Split(current.String(), "\n") {
+			if line = collapse(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			paragraphs = append(paragraphs, strings.Join(lines, "\n"))
+		}
+		current.Reset()
+	}
+
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			// Переводы строк в исходном тексте — обычные пробелы
+			current.WriteString(htmlSpace.ReplaceAllString(n.Data, " "))
+			return
+		case html.ElementNode:
+		default:
+			return
+		}
+		switch {
+		case n.TagName == "br":
+			current.WriteString("\n")
+			return
+		case n.TagName == "pre":
+			flush()
+			if text := strings.Trim(n.TextContent(), "\n"); strings.TrimSpace(text) != "" {
+				paragraphs = append(paragraphs, text)
+			}
+			return
+		case n.TagName == "td" || n.TagName == "th":
+			// Ячейки строки таблицы разделяются пробелом
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				visit(c)
+			}
+			current.WriteString(" ")
+			return
+		case textBlocks[n.TagName]:
+			flush()
+			if n.TagName == "li" {
+				current.WriteString("• ")
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				visit(c)
+			}
+			flush()
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(root)
+	flush()
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// HTML возвращает статью в виде отдельного HTML документа: с заголовком,
+// автором и датой публикации, но без оформления исходного сайта
+func (a *Article) HTML() string {
+	doc := html.NewDocument()
+	doc.AppendChild(&html.Node{Type: html.DocumentTypeNode, Data: "html"})
+	root := html.NewElement("html")
+	if a.Language != "" {
+		root.SetAttribute("lang", a.Language)
+	}
+	doc.AppendChild(root)
+
+	head := html.NewElement("head")
+	root.AppendChild(head)
+	charset := html.NewElement("meta")
+	charset.SetAttribute("charset", "utf-8")
+	head.AppendChild(charset)
+	title := html.NewElement("title")
+	title.SetTextContent(a.Title)
+	head.AppendChild(title)
+	if a.Byline != "" {
+		author := html.NewElement("meta")
+		author.SetAttribute("name", "author")
+		author.SetAttribute("content", a.Byline)
+		head.AppendChild(author)
+	}
+
+	body := html.NewElement("body")
+	root.AppendChild(body)
+	article := html.NewElement("article")
+	body.AppendChild(article)
+
+	header := html.NewElement("header")
+	h1 := html.NewElement("h1")
+	h1.SetTextContent(a.Title)
+	header.AppendChild(h1)
+	if a.Byline != "" {
+		byline := html.NewElement("p")
+		byline.SetAttribute("class", "byline")
+		byline.SetTextContent(a.Byline)
+		header.AppendChild(byline)
+	}
+	if !a.Published.IsZero() {
+		published := html.NewElement("time")
+		published.SetAttribute("datetime", a.Published.Format(time.RFC3339))
+		published.SetTextContent(a.Published.Format("02.01.2006"))
+		header.AppendChild(published)
+	}
+	article.AppendChild(header)
+	if a.Root != nil {
+		article.AppendChild(a.Root.CloneNode(true))
+	}
+	return doc.Serialize()
+}
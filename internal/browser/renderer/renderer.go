@@ -3,13 +3,23 @@ package renderer
 import (
 	"fmt"
 	"log"
+	"math"
 	"strings"
 
+	"github.com/baneronetwo/gluglu/internal/browser/css"
 	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/layout"
+	"github.com/baneronetwo/gluglu/internal/browser/style"
 )
 
 // Renderer представляет движок рендеринга
-type Renderer struct {}
+type Renderer struct {
+	// loadStylesheet загружает внешнюю таблицу стилей по абсолютному адресу
+	loadStylesheet func(url string) (string, error)
+	// device — устройство, для которого выполняется рендеринг: размер
+	// области просмотра и характеристики для медиазапросов
+	device style.Device
+}
 
 // Document представляет отрендеренный документ
 type Document struct {
@@ -17,25 +27,46 @@ type Document struct {
 	Elements []RenderedElement
 	Width    int
 	Height   int
+	// Stylesheets — таблицы стилей документа в порядке подключения
+	Stylesheets []*Stylesheet
+	// Layout — дерево раскладки; nil, если корневой элемент не отображается
+	Layout *layout.Box
 }
 
 // RenderedElement представляет отрендеренный элемент
 type RenderedElement struct {
-	TagName    string
-	Text       string
-	X          int
-	Y          int
-	Width      int
-	Height     int
-	Color      string
-	Background string
-	Children   []RenderedElement
+	TagName string
+	// Namespace — URI пространства имен элемента (HTML, SVG или MathML)
+	Namespace string
+	Text      string
+	X         int
+	Y         int
+	Width     int
+	Height    int
+	// Color — цвет текста, Background — цвет фона элемента
+	Color      css.Color
+	Background css.Color
+	// Style — вычисленный стиль элемента
+	Style *style.ComputedStyle
+	// Box — блок элемента в дереве раскладки
+	Box      *layout.Box
+	Children []RenderedElement
 }
 
 // NewRenderer создает новый движок рендеринга
 func NewRenderer() *Renderer {
 	log.Println("Инициализация движка рендеринга...")
-	return &Renderer{}
+	return &Renderer{device: style.DefaultDevice()}
+}
+
+// SetDevice задает устройство, для которого выполняется рендеринг
+func (r *Renderer) SetDevice(device style.Device) {
+	r.device = device
+}
+
+// Device возвращает устройство, для которого выполняется рендеринг
+func (r *Renderer) Device() style.Device {
+	return r.device
 }
 
 // Render выполняет рендеринг HTML документа
@@ -44,99 +75,89 @@ func (r *Renderer) Render(doc *html.Document) *Document {
 	
 	// Создаем отрендеренный документ
 	renderedDoc := &Document{
-		Title:    doc.Title,
+		Title:    doc.Title(),
 		Elements: make([]RenderedElement, 0),
-		Width:    800, // Стандартная ширина
-		Height:   600, // Стандартная высота
+		Width:    int(math.Round(r.device.Width)),
+		Height:   int(math.Round(r.device.Height)),
 	}
+	renderedDoc.Stylesheets = r.stylesheets(doc)
 	
-	// Рендерим все элементы, начиная с корневого
-	for _, element := range doc.Elements {
-		renderedElement := r.renderElement(&element, 0, 0)
-		renderedDoc.Elements = append(renderedDoc.Elements, renderedElement)
+	// Каскад: встроенная таблица стилей, таблицы страницы и атрибуты style.
+	// Таблицы, атрибут media которых не подходит устройству, не применяются
+	device := r.device
+	sheets := make([]*css.Stylesheet, 0, len(renderedDoc.Stylesheets))
+	for _, sheet := range renderedDoc.Stylesheets {
+		if style.MatchMedia(sheet.Media, device) {
+			sheets = append(sheets, sheet.Sheet)
+		}
+	}
+	styles := style.NewCascade(sheets, device).Compute(doc)
+	
+	// Раскладываем дерево блоков в области просмотра и рендерим элементы,
+	// начиная с корневого
+	renderedDoc.Layout = layout.Layout(doc, styles, device.Width, device.Height)
+	if renderedDoc.Layout != nil {
+		renderedDoc.Elements = append(renderedDoc.Elements, r.renderBox(renderedDoc.Layout))
 	}
 	
 	return renderedDoc
 }
 
-// renderElement рендерит отдельный HTML элемент
-func (r *Renderer) renderElement(element *html.Element, x, y int) RenderedElement {
-	// Создаем отрендеренный элемент
+// renderBox рендерит элемент по его блоку в дереве раскладки. Положение и
+// размер элемента — прямоугольник его рамки
+func (r *Renderer) renderBox(box *layout.Box) RenderedElement {
+	element := box.Node
+	border := box.BorderRect()
 	renderedElement := RenderedElement{
-		TagName:    element.TagName,
-		Text:       element.Text,
-		X:          x,
-		Y:          y,
-		Width:      100, // Стандартная ширина
-		Height:     20,  // Стандартная высота
-		Color:      "#000000", // Черный цвет текста по умолчанию
-		Background: "#FFFFFF", // Белый фон по умолчанию
-		Children:   make([]RenderedElement, 0),
+		TagName:   element.TagName,
+		Namespace: element.NamespaceURI(),
+		Text:      directText(element),
+		X:         int(math.Round(border.X)),
+		Y:         int(math.Round(border.Y)),
+		Width:     int(math.Round(border.Width)),
+		Height:    int(math.Round(border.Height)),
+		Style:     box.Style,
+		Box:       box,
+		Children:  r.renderChildren(box),
 	}
 	
-	// Применяем стили из атрибутов
-	if style, ok := element.Attributes["style"]; ok {
-		r.applyStyles(&renderedElement, style)
-	}
-	
-	// Рендерим дочерние элементы
-	currentY := y
-	for _, child := range element.Children {
-		childElement := r.renderElement(&child, x+10, currentY+renderedElement.Height)
-		renderedElement.Children = append(renderedElement.Children, childElement)
-		currentY += childElement.Height
-	}
-	
-	// Обновляем высоту элемента с учетом дочерних элементов
-	if len(renderedElement.Children) > 0 {
-		lastChild := renderedElement.Children[len(renderedElement.Children)-1]
-		renderedElement.Height = (lastChild.Y - y) + lastChild.Height
-	}
+	// Применяем вычисленный стиль
+	r.applyStyles(&renderedElement, box.Style)
 	
 	return renderedElement
 }
 
-// applyStyles применяет CSS стили к элементу
-func (r *Renderer) applyStyles(element *RenderedElement, styleStr string) {
-	// Разбиваем строку стилей на отдельные правила
-	styles := strings.Split(styleStr, ";")
-	
-	for _, style := range styles {
-		// Разбиваем правило на имя и значение
-		parts := strings.Split(style, ":")
-		if len(parts) != 2 {
-			continue
+// renderChildren рендерит элементы, блоки которых вложены в box. Текст и
+// анонимные блоки отдельных элементов не создают
+func (r *Renderer) renderChildren(box *layout.Box) []RenderedElement {
+	children := make([]RenderedElement, 0)
+	for _, child := range box.Children {
+		switch child.Type {
+		case layout.TextBox:
+		case layout.AnonymousBox:
+			children = append(children, r.renderChildren(child)...)
+		default:
+			children = append(children, r.renderBox(child))
 		}
-		
-		name := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		
-		// Применяем стиль в зависимости от его имени
-		switch name {
-		case "color":
-			element.Color = value
-		case "background-color":
-			element.Background = value
-		case "width":
-			// В реальном браузере здесь должен быть парсинг размеров (px, em, % и т.д.)
-			if strings.HasSuffix(value, "px") {
-				widthStr := strings.TrimSuffix(value, "px")
-				var width int
-				if _, err := fmt.Sscanf(widthStr, "%d", &width); err == nil {
-					element.Width = width
-				}
-			}
-		case "height":
-			// В реальном браузере здесь должен быть парсинг размеров (px, em, % и т.д.)
-			if strings.HasSuffix(value, "px") {
-				heightStr := strings.TrimSuffix(value, "px")
-				var height int
-				if _, err := fmt.Sscanf(heightStr, "%d", &height); err == nil {
-					element.Height = height
-				}
-			}
+	}
+	return children
+}
+
+// directText возвращает текст непосредственных текстовых детей элемента
+func directText(element *html.Node) string {
+	var sb strings.Builder
+	for c := element.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
 		}
 	}
+	return strings.TrimSpace(sb.String())
+}
+
+// applyStyles применяет вычисленный стиль к элементу
+func (r *Renderer) applyStyles(element *RenderedElement, computed *style.ComputedStyle) {
+	element.Color = computed.Color("color")
+	element.Background = computed.Color("background-color")
 }
 
 // GetTextRepresentation возвращает текстовое представление отрендеренного документа
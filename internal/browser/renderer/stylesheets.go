@@ -0,0 +1,165 @@
+package renderer
+
+import (
+	"log"
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/css"
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/weburl"
+)
+
+// maxImportDepth ограничивает вложенность @import
+const maxImportDepth = 8
+
+// Stylesheet — таблица стилей документа
+type Stylesheet struct {
+	// Href — адрес внешней таблицы стилей; пустая строка у <style>
+	Href string
+	// Node — элемент <style> (HTML или SVG) или <link>, которым подключена таблица
+	Node *html.Node
+	// Media — список медиазапросов из атрибута media; пустая строка
+	// означает, что таблица применяется на любом устройстве
+	Media string
+	Sheet *css.Stylesheet
+}
+
+// SetStylesheetLoader задает функцию загрузки внешних таблиц стилей. Без нее
+// <link rel="stylesheet"> и @import пропускаются
+func (r *Renderer) SetStylesheetLoader(load func(url string) (string, error)) {
+	r.loadStylesheet = load
+}
+
+// stylesheets собирает таблицы стилей документа в порядке документа: блоки
+// <style>, в том числе внутри <svg>, и внешние таблицы из <link
+// rel="stylesheet">. Правила @layer упорядочиваются уже в каскаде
+func (r *Renderer) stylesheets(doc *html.Document) []*Stylesheet {
+	var sheets []*Stylesheet
+	for _, el := range doc.FindElementsByTagName("*") {
+		if !isCSSType(el.GetAttribute("type")) {
+			continue
+		}
+		switch {
+		case isStyleElement(el):
+			sheet := css.ParseStylesheet(el.TextContent())
+			r.resolveImports(sheet, doc.BaseURL(), 0)
+			sheets = append(sheets, &Stylesheet{Node: el, Media: el.GetAttribute("media"), Sheet: sheet})
+		case el.IsHTML() && el.TagName == "link":
+			if !isStylesheetLink(el) {
+				continue
+			}
+			u, err := doc.ResolveURL(el.GetAttribute("href"))
+			if err != nil {
+				log.Printf("Некорректный адрес таблицы стилей: %v", err)
+				continue
+			}
+			sheet := r.fetchStylesheet(u, 0)
+			if sheet == nil {
+				continue
+			}
+			sheets = append(sheets, &Stylesheet{Href: u.String(), Node: el, Media: el.GetAttribute("media"), Sheet: sheet})
+		}
+	}
+	return sheets
+}
+
+// isStyleElement проверяет, является ли элемент блоком <style> HTML или SVG
+func isStyleElement(el *html.Node) bool {
+	return el.TagName == "style" && (el.IsHTML() || el.Namespace == html.SVGNamespace)
+}
+
+// isCSSType проверяет атрибут type у <style> и <link>: таблица стилей
+// применяется, только если он пуст или равен text/css
+func isCSSType(t string) bool {
+	t = strings.TrimSpace(t)
+	return t == "" || strings.EqualFold(t, "text/css")
+}
+
+// isStylesheetLink проверяет, подключает ли <link> основную таблицу стилей.
+// Альтернативные таблицы (rel="alternate stylesheet") не применяются
+func isStylesheetLink(el *html.Node) bool {
+	if strings.TrimSpace(el.GetAttribute("href")) == "" {
+		return false
+	}
+	stylesheet, alternate := false, false
+	for _, rel := range strings.Fields(strings.ToLower(el.GetAttribute("rel"))) {
+		switch rel {
+		case "stylesheet":
+			stylesheet = true
+		case "alternate":
+			alternate = true
+		}
+	}
+	return stylesheet && !alternate
+}
+
+// fetchStylesheet загружает и разбирает внешнюю таблицу стилей
+func (r *Renderer) fetchStylesheet(u *weburl.URL, depth int) *css.Stylesheet {
+	if r.loadStylesheet == nil {
+		log.Printf("Таблица стилей не загружена: %s", u)
+		return nil
+	}
+	text, err := r.loadStylesheet(u.StringWithoutFragment())
+	if err != nil {
+		log.Printf("Ошибка загрузки таблицы стилей %s: %v", u, err)
+		return nil
+	}
+	sheet := css.ParseStylesheet(text)
+	r.resolveImports(sheet, u, depth)
+	return sheet
+}
+
+// resolveImports загружает таблицы из правил @import. Правила
+// импортированной таблицы помещаются в Rules правила @import. Как и в
+// браузерах, @import учитывается только в начале таблицы, до других правил
+func (r *Renderer) resolveImports(sheet *css.Stylesheet, base *weburl.URL, depth int) {
+	for _, rule := range sheet.Rules {
+		at, ok := rule.(*css.AtRule)
+		if !ok {
+			return
+		}
+		switch at.Name {
+		case "charset", "layer":
+			continue
+		case "import":
+		default:
+			return
+		}
+		ref, ok := importURL(at)
+		if !ok {
+			continue
+		}
+		if depth >= maxImportDepth {
+			log.Printf("Слишком глубокая вложенность @import: %s", ref)
+			continue
+		}
+		u, err := weburl.Parse(ref, base)
+		if err != nil {
+			log.Printf("Некорректный адрес @import: %v", err)
+			continue
+		}
+		if imported := r.fetchStylesheet(u, depth+1); imported != nil {
+			at.Rules = imported.Rules
+		}
+	}
+}
+
+// importURL возвращает адрес из прелюдии @import: строку, url(адрес) или
+// url("адрес")
+func importURL(rule *css.AtRule) (string, bool) {
+	if len(rule.Prelude) == 0 {
+		return "", false
+	}
+	v := rule.Prelude[0]
+	switch {
+	case v.Type == css.StringToken || v.Type == css.URLToken:
+		return v.Value, true
+	case v.IsFunction("url") || v.IsFunction("src"):
+		for _, arg := range v.Children {
+			if arg.Type == css.StringToken {
+				return arg.Value, true
+			}
+		}
+	}
+	return "", false
+}
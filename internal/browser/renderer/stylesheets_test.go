@@ -0,0 +1,154 @@
+package renderer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/style"
+	"github.com/baneronetwo/gluglu/internal/browser/weburl"
+)
+
+// renderFixture разбирает документ с адресом https://example.com/page.html и
+// рендерит его; внешние таблицы стилей берутся из files по абсолютному адресу
+func renderFixture(t *testing.T, r *Renderer, src string, files map[string]string) *Document {
+	t.Helper()
+	doc, err := html.NewParser().Parse(src)
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	if doc.URL, err = weburl.Parse("https://example.com/page.html", nil); err != nil {
+		t.Fatalf("ошибка разбора адреса: %v", err)
+	}
+	r.SetStylesheetLoader(func(url string) (string, error) {
+		text, ok := files[url]
+		if !ok {
+			return "", fmt.Errorf("нет файла %s", url)
+		}
+		return text, nil
+	})
+	return r.Render(doc)
+}
+
+// findElement возвращает отрендеренный элемент с указанным id
+func findElement(elements []RenderedElement, id string) *RenderedElement {
+	for i := range elements {
+		if elements[i].Box.Node.GetAttribute("id") == id {
+			return &elements[i]
+		}
+		if found := findElement(elements[i].Children, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// colorOf возвращает вычисленный цвет текста элемента с указанным id
+func colorOf(t *testing.T, doc *Document, id string) string {
+	t.Helper()
+	el := findElement(doc.Elements, id)
+	if el == nil {
+		t.Fatalf("элемент #%s не отрендерен", id)
+	}
+	return el.Style.Get("color")
+}
+
+func TestStylesheetsDocumentOrder(t *testing.T) {
+	files := map[string]string{
+		"https://example.com/css/a.css":     `@import "sub/b.css"; p{color:green} .b{color:green}`,
+		"https://example.com/css/sub/b.css": `.c{color:green}`,
+	}
+	doc := renderFixture(t, NewRenderer(), `<!DOCTYPE html><head>
+<style>p{color:red} .b{color:red} .c{color:red}</style>
+<link rel=stylesheet href=css/a.css>
+<link rel="alternate stylesheet" href=css/a.css>
+<style type=text/plain>p{color:red}</style>
+</head><body>
+<style>.b{color:blue}</style>
+<p id=a>a</p><p id=b class=b>b</p><p id=c class=c>c</p>`, files)
+
+	var got []string
+	for _, sheet := range doc.Stylesheets {
+		got = append(got, sheet.Node.TagName+" "+sheet.Href)
+	}
+	want := []string{"style ", "link https://example.com/css/a.css", "style "}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("таблицы стилей %q, ожидалось %q", got, want)
+	}
+
+	// При равной специфичности побеждает таблица, которая позже в документе,
+	// будь она внешней или встроенной. Правила @import стоят на месте
+	// импортирующей таблицы, а адрес разрешается относительно нее
+	for id, want := range map[string]string{"a": "rgb(0, 128, 0)", "b": "rgb(0, 0, 255)", "c": "rgb(0, 128, 0)"} {
+		if got := colorOf(t, doc, id); got != want {
+			t.Errorf("#%s: color = %q, ожидалось %q", id, got, want)
+		}
+	}
+}
+
+func TestStylesheetMediaAttribute(t *testing.T) {
+	files := map[string]string{"https://example.com/print.css": `#a{color:red}`}
+	src := `<!DOCTYPE html><head>
+<link rel=stylesheet href=print.css media=print>
+<style media="(min-width: 2000px)">#b{color:red}</style>
+<style media="screen and (min-width: 500px)">#c{color:green}</style>
+</head><body><p id=a>a</p><p id=b>b</p><p id=c>c</p>`
+
+	// Таблицы с неподходящим media собираются, но не применяются
+	doc := renderFixture(t, NewRenderer(), src, files)
+	if len(doc.Stylesheets) != 3 || doc.Stylesheets[0].Media != "print" {
+		t.Fatalf("собрано %d таблиц стилей, ожидалось 3", len(doc.Stylesheets))
+	}
+	for id, want := range map[string]string{"a": "rgb(0, 0, 0)", "b": "rgb(0, 0, 0)", "c": "rgb(0, 128, 0)"} {
+		if got := colorOf(t, doc, id); got != want {
+			t.Errorf("экран: #%s: color = %q, ожидалось %q", id, got, want)
+		}
+	}
+
+	// На другом устройстве применяются другие таблицы
+	r := NewRenderer()
+	r.SetDevice(style.Device{Width: 2400, Height: 1200, Type: "print"})
+	doc = renderFixture(t, r, src, files)
+	for id, want := range map[string]string{"a": "rgb(255, 0, 0)", "b": "rgb(255, 0, 0)", "c": "rgb(0, 0, 0)"} {
+		if got := colorOf(t, doc, id); got != want {
+			t.Errorf("печать: #%s: color = %q, ожидалось %q", id, got, want)
+		}
+	}
+}
+
+func TestStylesheetsInSVG(t *testing.T) {
+	doc := renderFixture(t, NewRenderer(), `<!DOCTYPE html><body>
+<svg><style>#a{color:green}</style><style type=text/plain>#b{color:red}</style></svg>
+<math><style>#b{color:red}</style></math>
+<p id=a>a</p><p id=b>b</p>`, nil)
+
+	// <style> внутри <svg> применяется ко всему документу, а внутри <math> — нет
+	if len(doc.Stylesheets) != 1 || doc.Stylesheets[0].Node.Namespace != html.SVGNamespace {
+		t.Fatalf("собрано %d таблиц стилей, ожидалась одна из <svg>", len(doc.Stylesheets))
+	}
+	for id, want := range map[string]string{"a": "rgb(0, 128, 0)", "b": "rgb(0, 0, 0)"} {
+		if got := colorOf(t, doc, id); got != want {
+			t.Errorf("#%s: color = %q, ожидалось %q", id, got, want)
+		}
+	}
+}
+
+func TestStylesheetLayers(t *testing.T) {
+	files := map[string]string{
+		"https://example.com/reset.css": `p{color:blue} #b{color:red}`,
+		"https://example.com/theme.css": `#c{color:red}`,
+	}
+	doc := renderFixture(t, NewRenderer(), `<!DOCTYPE html><head>
+<style>@layer theme, reset; @import "reset.css" layer(reset); @layer theme{#a{color:red}}</style>
+<style>@import "theme.css" layer; p:not(#a){color:green}</style>
+</head><body><p id=a>a</p><p id=b>b</p><p id=c>c</p>`, files)
+
+	// Порядок слоев общий для всех таблиц документа: слой reset сильнее
+	// объявленного раньше слоя theme, слой без имени из второй таблицы —
+	// слоя reset, а правила вне слоев сильнее любого слоя
+	for id, want := range map[string]string{"a": "rgb(0, 0, 255)", "b": "rgb(0, 128, 0)", "c": "rgb(0, 128, 0)"} {
+		if got := colorOf(t, doc, id); got != want {
+			t.Errorf("#%s: color = %q, ожидалось %q", id, got, want)
+		}
+	}
+}
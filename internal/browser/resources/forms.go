@@ -0,0 +1,200 @@
+package resources
+
+import (
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+)
+
+// Form — форма документа с полями
+type Form struct {
+	// Action — абсолютный адрес отправки. Без атрибута action форма
+	// отправляется на адрес документа
+	Action string
+	// Method — get, post или dialog
+	Method  string
+	Enctype string
+	Name    string
+	ID      string
+	Fields  []Field
+	Node    *html.Node
+}
+
+// Field — поле формы: <input>, <select>, <textarea> или <button>
+type Field struct {
+	// Tag — тег элемента
+	Tag string
+	// Type — тип поля: для <input> и <button> это атрибут type в нижнем
+	// регистре, для <select> — select-one или select-multiple, для
+	// <textarea> — textarea
+	Type string
+	Name string
+	// Value — значение, которое будет отправлено: value у <input> и
+	// <button>, текст <textarea>, значение выбранного пункта <select>
+	Value    string
+	Checked  bool
+	Required bool
+	Disabled bool
+	// Options — пункты <select>
+	Options []Option
+	Node    *html.Node
+}
+
+// Option — пункт списка <select>
+type Option struct {
+	Value    string
+	Label    string
+	Selected bool
+}
+
+// formMethods — допустимые значения атрибута method
+var formMethods = map[string]bool{"get": true, "post": true, "dialog": true}
+
+// newForm создает описание формы без полей
+func (c *collector) newForm(el *html.Node) Form {
+	method := strings.ToLower(strings.TrimSpace(el.GetAttribute("method")))
+	if !formMethods[method] {
+		method = "get"
+	}
+	enctype := strings.ToLower(strings.TrimSpace(el.GetAttribute("enctype")))
+	switch enctype {
+	case "multipart/form-data", "text/plain":
+	default:
+		enctype = "application/x-www-form-urlencoded"
+	}
+	action := ""
+	if c.documentURL != nil {
+		action = c.documentURL.String()
+	}
+	if ref := strings.TrimSpace(el.GetAttribute("action")); ref != "" {
+		action = c.resolve(ref)
+	}
+	return Form{
+		Action:  action,
+		Method:  method,
+		Enctype: enctype,
+		Name:    el.GetAttribute("name"),
+		ID:      el.ID(),
+		Node:    el,
+	}
+}
+
+// assignFields распределяет поля по формам-владельцам. Поле принадлежит
+// форме из атрибута form, а без него — ближайшей форме-предку
+func (c *collector) assignFields(doc *html.Document) {
+	for _, el := range c.fields {
+		owner := el.Parent
+		if id, ok := el.LookupAttribute("form"); ok {
+			owner = doc.FindElementsByID(id)
+			if owner != nil && owner.TagName != "form" {
+				owner = nil
+			}
+		} else {
+			for owner != nil && owner.TagName != "form" {
+				owner = owner.Parent
+			}
+		}
+		i, ok := c.forms[owner]
+		if !ok {
+			continue
+		}
+		c.result.Forms[i].Fields = append(c.result.Forms[i].Fields, c.field(el))
+	}
+}
+
+// field описывает поле формы
+func (c *collector) field(el *html.Node) Field {
+	f := Field{
+		Tag:      el.TagName,
+		Name:     el.GetAttribute("name"),
+		Value:    el.GetAttribute("value"),
+		Checked:  el.HasAttribute("checked"),
+		Required: el.HasAttribute("required"),
+		Disabled: el.HasAttribute("disabled") || inDisabledFieldset(el),
+		Node:     el,
+	}
+	switch el.TagName {
+	case "input":
+		f.Type = strings.ToLower(strings.TrimSpace(el.GetAttribute("type")))
+		if f.Type == "" {
+			f.Type = "text"
+		}
+		if (f.Type == "checkbox" || f.Type == "radio") && !el.HasAttribute("value") {
+			f.Value = "on"
+		}
+	case "button":
+		f.Type = strings.ToLower(strings.TrimSpace(el.GetAttribute("type")))
+		if f.Type != "reset" && f.Type != "button" {
+			f.Type = "submit"
+		}
+	case "textarea":
+		f.Type = "textarea"
+		f.Value = el.TextContent()
+	case "select":
+		f.Type = "select-one"
+		if el.HasAttribute("multiple") {
+			f.Type = "select-multiple"
+		}
+		f.Options, f.Value = selectOptions(el, f.Type == "select-multiple")
+	}
+	return f
+}
+
+// selectOptions возвращает пункты <select> и значение выбранного пункта.
+// В списке с одним выбором без selected выбран первый доступный пункт
+func selectOptions(el *html.Node, multiple bool) ([]Option, string) {
+	var options []Option
+	selected := -1
+	for _, opt := range el.FindElementsByTagName("option") {
+		label := strings.Join(strings.Fields(opt.TextContent()), " ")
+		if l, ok := opt.LookupAttribute("label"); ok {
+			label = l
+		}
+		value, ok := opt.LookupAttribute("value")
+		if !ok {
+			value = strings.Join(strings.Fields(opt.TextContent()), " ")
+		}
+		options = append(options, Option{Value: value, Label: label, Selected: opt.HasAttribute("selected")})
+		if opt.HasAttribute("selected") && (selected < 0 || !multiple) {
+			selected = len(options) - 1
+		}
+	}
+	if !multiple && len(options) > 0 {
+		// В списке с одним выбором выбран только последний пункт с selected
+		if selected < 0 {
+			selected = 0
+		}
+		for i := range options {
+			options[i].Selected = i == selected
+		}
+	}
+	if selected < 0 {
+		return options, ""
+	}
+	return options, options[selected].Value
+}
+
+// inDisabledFieldset проверяет, отключено ли поле через <fieldset disabled>.
+// Поля в первой <legend> такого fieldset остаются доступными
+func inDisabledFieldset(el *html.Node) bool {
+	for child, p := el, el.Parent; p != nil; child, p = p, p.Parent {
+		if p.TagName != "fieldset" || !p.HasAttribute("disabled") {
+			continue
+		}
+		if child.TagName == "legend" && firstLegend(p) == child {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// firstLegend возвращает первую дочернюю <legend> элемента
+func firstLegend(fieldset *html.Node) *html.Node {
+	for _, child := range fieldset.Children() {
+		if child.TagName == "legend" {
+			return child
+		}
+	}
+	return nil
+}
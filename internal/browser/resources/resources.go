@@ -0,0 +1,298 @@
+// Package resources перечисляет то, на что ссылается загруженный документ:
+// исходящие ссылки, подресурсы (скрипты, стили, изображения, фреймы, медиа)
+// и формы с их полями. Все адреса разрешаются в абсолютные относительно
+// базового адреса документа
+package resources
+
+import (
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/weburl"
+)
+
+// Resources — ссылки, подресурсы и формы документа в порядке их появления
+type Resources struct {
+	Links     []Link
+	Resources []Resource
+	Forms     []Form
+}
+
+// Link — исходящая ссылка <a href> или <area href>
+type Link struct {
+	URL string
+	// Text — текст ссылки со схлопнутыми пробелами; у <area> и ссылок-картинок
+	// без текста — значение alt
+	Text string
+	// Rel — типы ссылки из атрибута rel в нижнем регистре (nofollow, noopener...)
+	Rel    []string
+	Target string
+	Node   *html.Node
+}
+
+// Kind — вид подресурса
+type Kind string
+
+// Виды подресурсов
+const (
+	Script     Kind = "script"
+	Stylesheet Kind = "stylesheet"
+	Image      Kind = "image"
+	Frame      Kind = "iframe"
+	Media      Kind = "media"
+	Track      Kind = "track"
+	Object     Kind = "object"
+	Icon       Kind = "icon"
+	Manifest   Kind = "manifest"
+	// Preload — ресурсы из <link rel="preload">, modulepreload и prefetch
+	Preload Kind = "preload"
+)
+
+// Resource — подресурс, который браузер загружает для показа документа
+type Resource struct {
+	Kind Kind
+	// URL — адрес ресурса. У изображений, заданных только через srcset,
+	// это адрес первого варианта
+	URL string
+	// Type — MIME тип из атрибута type, если он указан
+	Type string
+	// Candidates — варианты изображения из srcset
+	Candidates []Candidate
+	Node       *html.Node
+}
+
+// Candidate — вариант изображения из srcset с дескриптором ширины (480w)
+// или плотности пикселей (2x)
+type Candidate struct {
+	URL        string
+	Descriptor string
+}
+
+// Extract перечисляет ссылки, подресурсы и формы документа. Относительные
+// адреса разрешаются относительно базового адреса документа (если у документа
+// нет адреса и <base>, они остаются как есть)
+func Extract(doc *html.Document) *Resources {
+	c := &collector{
+		documentURL: doc.URL,
+		base:        doc.BaseURL(),
+		forms:       make(map[*html.Node]int),
+	}
+	for _, el := range doc.FindElementsByTagName("*") {
+		if el.IsHTML() {
+			c.element(el)
+		}
+	}
+	c.assignFields(doc)
+	return &c.result
+}
+
+// collector собирает результат за один обход документа
+type collector struct {
+	documentURL *weburl.URL
+	base        *weburl.URL
+	result      Resources
+	// forms — индексы форм в result.Forms
+	forms map[*html.Node]int
+	// fields — элементы форм в порядке документа
+	fields []*html.Node
+}
+
+// resolve разрешает адрес относительно базового адреса документа
+func (c *collector) resolve(ref string) string {
+	u, err := weburl.Parse(ref, c.base)
+	if err != nil {
+		return strings.TrimSpace(ref)
+	}
+	return u.String()
+}
+
+// element добавляет в результат ссылки, ресурсы и формы элемента
+func (c *collector) element(el *html.Node) {
+	switch el.TagName {
+	case "a", "area":
+		if href, ok := el.LookupAttribute("href"); ok {
+			c.addLink(el, href)
+		}
+	case "script":
+		c.addAttribute(el, Script, "src")
+	case "link":
+		c.addLinkElement(el)
+	case "img":
+		c.addImage(el, el.GetAttribute("src"))
+	case "source":
+		// <source> внутри <picture> задает варианты изображения, внутри
+		// <video> и <audio> — файлы медиа
+		if el.Parent != nil && el.Parent.TagName == "picture" {
+			c.addImage(el, "")
+		} else {
+			c.addAttribute(el, Media, "src")
+		}
+	case "iframe", "frame":
+		c.addAttribute(el, Frame, "src")
+	case "video":
+		c.addAttribute(el, Media, "src")
+		c.addAttribute(el, Image, "poster")
+	case "audio":
+		c.addAttribute(el, Media, "src")
+	case "track":
+		c.addAttribute(el, Track, "src")
+	case "embed":
+		c.addAttribute(el, Object, "src")
+	case "object":
+		c.addAttribute(el, Object, "data")
+	case "input":
+		if strings.EqualFold(el.GetAttribute("type"), "image") {
+			c.addAttribute(el, Image, "src")
+		}
+		c.fields = append(c.fields, el)
+	case "button", "select", "textarea":
+		c.fields = append(c.fields, el)
+	case "form":
+		c.forms[el] = len(c.result.Forms)
+		c.result.Forms = append(c.result.Forms, c.newForm(el))
+	}
+}
+
+// addLink добавляет исходящую ссылку
+func (c *collector) addLink(el *html.Node, href string) {
+	text := strings.Join(strings.Fields(el.TextContent()), " ")
+	if text == "" {
+		text = el.GetAttribute("alt")
+		for _, img := range el.FindElementsByTagName("img") {
+			if text != "" {
+				break
+			}
+			text = img.GetAttribute("alt")
+		}
+	}
+	c.result.Links = append(c.result.Links, Link{
+		URL:    c.resolve(href),
+		Text:   text,
+		Rel:    strings.Fields(strings.ToLower(el.GetAttribute("rel"))),
+		Target: el.GetAttribute("target"),
+		Node:   el,
+	})
+}
+
+// addAttribute добавляет ресурс, адрес которого указан в атрибуте attr
+func (c *collector) addAttribute(el *html.Node, kind Kind, attr string) {
+	ref, ok := el.LookupAttribute(attr)
+	if !ok || strings.TrimSpace(ref) == "" {
+		return
+	}
+	c.result.Resources = append(c.result.Resources, Resource{
+		Kind: kind,
+		URL:  c.resolve(ref),
+		Type: mimeType(el),
+		Node: el,
+	})
+}
+
+// mimeType возвращает MIME тип ресурса из атрибута type. У <input> этот
+// атрибут задает тип поля, а не ресурса
+func mimeType(el *html.Node) string {
+	if el.TagName == "input" {
+		return ""
+	}
+	return el.GetAttribute("type")
+}
+
+// addLinkElement добавляет ресурс из <link> по типам в атрибуте rel
+func (c *collector) addLinkElement(el *html.Node) {
+	href, ok := el.LookupAttribute("href")
+	if !ok || strings.TrimSpace(href) == "" {
+		return
+	}
+	var kind Kind
+	for _, rel := range strings.Fields(strings.ToLower(el.GetAttribute("rel"))) {
+		switch rel {
+		case "stylesheet":
+			kind = Stylesheet
+		case "icon", "apple-touch-icon", "apple-touch-icon-precomposed", "mask-icon":
+			kind = Icon
+		case "manifest":
+			kind = Manifest
+		case "preload", "modulepreload", "prefetch":
+			kind = Preload
+		default:
+			continue
+		}
+		break
+	}
+	if kind == "" {
+		return
+	}
+	c.result.Resources = append(c.result.Resources, Resource{
+		Kind: kind,
+		URL:  c.resolve(href),
+		Type: el.GetAttribute("type"),
+		Node: el,
+	})
+}
+
+// addImage добавляет изображение с вариантами из srcset
+func (c *collector) addImage(el *html.Node, src string) {
+	candidates := parseSrcset(el.GetAttribute("srcset"))
+	for i := range candidates {
+		candidates[i].URL = c.resolve(candidates[i].URL)
+	}
+	if strings.TrimSpace(src) != "" {
+		src = c.resolve(src)
+	} else if len(candidates) > 0 {
+		src = candidates[0].URL
+	} else {
+		return
+	}
+	c.result.Resources = append(c.result.Resources, Resource{
+		Kind:       Image,
+		URL:        src,
+		Type:       el.GetAttribute("type"),
+		Candidates: candidates,
+		Node:       el,
+	})
+}
+
+// parseSrcset разбирает атрибут srcset по алгоритму спецификации HTML:
+// адреса разделяются запятыми, но запятые внутри адреса допустимы
+func parseSrcset(srcset string) []Candidate {
+	var candidates []Candidate
+	isSpace := func(c byte) bool {
+		return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+	}
+	s := srcset
+	for {
+		s = strings.TrimLeft(s, " \t\n\f\r,")
+		if s == "" {
+			return candidates
+		}
+		end := 0
+		for end < len(s) && !isSpace(s[end]) {
+			end++
+		}
+		u := s[:end]
+		s = s[end:]
+
+		var descriptor string
+		if strings.HasSuffix(u, ",") {
+			// Запятая в конце адреса завершает вариант без дескриптора
+			u = strings.TrimRight(u, ",")
+		} else {
+			// Дескриптор продолжается до запятой вне скобок
+			depth, i := 0, 0
+			for ; i < len(s); i++ {
+				if s[i] == '(' {
+					depth++
+				} else if s[i] == ')' && depth > 0 {
+					depth--
+				} else if s[i] == ',' && depth == 0 {
+					break
+				}
+			}
+			descriptor = strings.Join(strings.Fields(s[:i]), " ")
+			s = s[i:]
+		}
+		if u != "" {
+			candidates = append(candidates, Candidate{URL: u, Descriptor: descriptor})
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package resources
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+	"github.com/baneronetwo/gluglu/internal/browser/weburl"
+)
+
+// extractFixture извлекает ресурсы из testdata/page.html с адресом
+// https://example.com/dir/page.html
+func extractFixture(t *testing.T) *Resources {
+	t.Helper()
+	src, err := os.ReadFile(filepath.Join("testdata", "page.html"))
+	if err != nil {
+		t.Fatalf("ошибка чтения: %v", err)
+	}
+	doc, err := html.NewParser().Parse(string(src))
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	doc.URL, err = weburl.Parse("https://example.com/dir/page.html", nil)
+	if err != nil {
+		t.Fatalf("ошибка разбора адреса: %v", err)
+	}
+	return Extract(doc)
+}
+
+func TestLinks(t *testing.T) {
+	var got []string
+	for _, l := range extractFixture(t).Links {
+		got = append(got, fmt.Sprintf("%s %q %v %s", l.URL, l.Text, l.Rel, l.Target))
+	}
+	// Адреса разрешаются относительно первого <base href>; <a> без href и
+	// ссылки внутри SVG не учитываются
+	want := []string{
+		`https://cdn.example.com/about "О нас" [nofollow noopener] _top`,
+		`https://cdn.example.com/static/#top "Наверх" [] `,
+		`https://other.example.org/x?y=1 "Внешняя" [] `,
+		`https://cdn.example.com/img "Миниатюра" [] `,
+		`https://cdn.example.com/static/region "Область" [] `,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ссылки:\nполучено  %q\nожидалось %q", got, want)
+	}
+}
+
+func TestResources(t *testing.T) {
+	var got []string
+	for _, r := range extractFixture(t).Resources {
+		line := fmt.Sprintf("%s %s", r.Kind, r.URL)
+		if r.Type != "" {
+			line += " " + r.Type
+		}
+		for _, c := range r.Candidates {
+			line += fmt.Sprintf(" [%s %s]", c.URL, c.Descriptor)
+		}
+		got = append(got, line)
+	}
+	want := []string{
+		"stylesheet https://cdn.example.com/static/css/site.css text/css",
+		"icon https://cdn.example.com/favicon.png image/png",
+		"manifest https://cdn.example.com/static/app.webmanifest",
+		"preload https://cdn.example.com/static/js/chunk.js",
+		"script https://cdn.example.com/static/js/app.js module",
+		"image https://cdn.example.com/static/thumb.png",
+		"image https://cdn.example.com/static/fallback.jpg [https://cdn.example.com/static/small.jpg 480w] [https://cdn.example.com/static/large.jpg 1080w]",
+		// Без src адресом изображения становится первый вариант из srcset
+		"image https://cdn.example.com/static/a.jpg [https://cdn.example.com/static/a.jpg ] [https://cdn.example.com/static/b.jpg 2x]",
+		"image https://cdn.example.com/static/p.webp image/webp [https://cdn.example.com/static/p.webp 1x] [https://cdn.example.com/static/p@2x.webp 2x]",
+		"image https://cdn.example.com/static/p.png",
+		"media https://cdn.example.com/static/movie.mp4",
+		"image https://cdn.example.com/static/poster.jpg",
+		"media https://cdn.example.com/static/movie.webm video/webm",
+		"track https://cdn.example.com/static/subs.vtt",
+		"iframe https://frames.example.com/embed",
+		"object https://cdn.example.com/static/doc.pdf application/pdf",
+		// У <input type="image"> атрибут type — тип поля, а не MIME тип
+		"image https://cdn.example.com/static/go.png",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ресурсы:\nполучено  %q\nожидалось %q", got, want)
+	}
+}
+
+// fieldString описывает поле формы одной строкой
+func fieldString(f Field) string {
+	s := fmt.Sprintf("%s %s %s=%q", f.Tag, f.Type, f.Name, f.Value)
+	var flags []string
+	if f.Checked {
+		flags = append(flags, "checked")
+	}
+	if f.Required {
+		flags = append(flags, "required")
+	}
+	if f.Disabled {
+		flags = append(flags, "disabled")
+	}
+	if len(flags) > 0 {
+		s += " " + strings.Join(flags, " ")
+	}
+	return s
+}
+
+func TestForms(t *testing.T) {
+	forms := extractFixture(t).Forms
+	if len(forms) != 3 {
+		t.Fatalf("получено %d форм, ожидалось 3", len(forms))
+	}
+	tests := []struct {
+		action, method, enctype string
+		fields                  []string
+	}{
+		{
+			"https://cdn.example.com/static/search", "get", "application/x-www-form-urlencoded",
+			[]string{
+				`input text q="хлеб"`,
+				`input checkbox fresh="on" checked`,
+				`input image go=""`,
+				// В списке с одним выбором выбран последний пункт с selected
+				`select select-one sort="rating"`,
+				`button submit =""`,
+			},
+		},
+		{
+			// Без action форма отправляется на адрес документа, а не <base>
+			"https://example.com/dir/page.html", "post", "multipart/form-data",
+			[]string{
+				// Поле в первой <legend> отключенного fieldset доступно
+				`input text in-legend=""`,
+				`textarea textarea comment="  Текст " disabled`,
+				`select select-multiple extras="a"`,
+				`button reset =""`,
+				// Поле вне формы с атрибутом form принадлежит указанной форме
+				`input text outside="" required`,
+			},
+		},
+		{"https://example.com/dir/page.html", "get", "application/x-www-form-urlencoded", nil},
+	}
+	for i, test := range tests {
+		f := forms[i]
+		if f.Action != test.action || f.Method != test.method || f.Enctype != test.enctype {
+			t.Errorf("форма %d: получено %s %s %s, ожидалось %s %s %s", i, f.Action, f.Method, f.Enctype, test.action, test.method, test.enctype)
+		}
+		var fields []string
+		for _, field := range f.Fields {
+			fields = append(fields, fieldString(field))
+		}
+		if !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("поля формы %d:\nполучено  %q\nожидалось %q", i, fields, test.fields)
+		}
+	}
+	var options []string
+	for _, opt := range forms[0].Fields[3].Options {
+		options = append(options, fmt.Sprintf("%s/%s/%v", opt.Value, opt.Label, opt.Selected))
+	}
+	want := []string{"date/По дате/false", "По цене/По цене/false", "rating/Рейтинг/true"}
+	if !reflect.DeepEqual(options, want) {
+		t.Errorf("пункты select:\nполучено  %q\nожидалось %q", options, want)
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	tests := []struct {
+		srcset string
+		want   []Candidate
+	}{
+		{"a.jpg", []Candidate{{"a.jpg", ""}}},
+		{" a.jpg 1x ,b.jpg  2x", []Candidate{{"a.jpg", "1x"}, {"b.jpg", "2x"}}},
+		// Запятая внутри адреса не разделяет варианты
+		{"data:image/png;base64,AAA 1x, b.png 2x", []Candidate{{"data:image/png;base64,AAA", "1x"}, {"b.png", "2x"}}},
+		{"a.jpg, b.jpg", []Candidate{{"a.jpg", ""}, {"b.jpg", ""}}},
+		{"a.jpg (max-width: 10px, 20px) 100w, b.jpg", []Candidate{{"a.jpg", "(max-width: 10px, 20px) 100w"}, {"b.jpg", ""}}},
+		{",,,", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		if got := parseSrcset(test.srcset); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: получено %v, ожидалось %v", test.srcset, got, test.want)
+		}
+	}
+}
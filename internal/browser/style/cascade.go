@@ -0,0 +1,480 @@
+// Package style — система стилей: сопоставляет правила таблиц стилей с
+// элементами документа, упорядочивает объявления по каскаду (источник,
+// важность, специфичность, порядок) и вычисляет для каждого элемента
+// значения свойств с учетом наследования и начальных значений
+package style
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/css"
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+)
+
+// Origin — источник таблицы стилей
+type Origin int
+
+const (
+	// UserAgentOrigin — встроенная таблица стилей браузера
+	UserAgentOrigin Origin = iota
+	// AuthorOrigin — таблицы стилей страницы и атрибут style
+	AuthorOrigin
+)
+
+// Cascade — правила всех таблиц стилей документа, подготовленные к
+// сопоставлению с элементами
+type Cascade struct {
+	rules []cascadeRule
+	// layers — корень дерева каскадных слоев
+	layers *cascadeLayer
+	// device — устройство, относительно области просмотра которого
+	// вычисляются vw, vh и подобные единицы
+	device Device
+}
+
+// cascadeRule — правило со скомпилированным селектором
+type cascadeRule struct {
+	selector     *html.Selector
+	declarations []declaration
+	origin       Origin
+	layer        *cascadeLayer
+	// order — номер правила во всех таблицах: при равной специфичности
+	// побеждает более позднее
+	order int
+}
+
+// declaration — объявление свойства после разворачивания сокращений
+type declaration struct {
+	name      string
+	value     []css.ComponentValue
+	important bool
+	// shorthand — сокращение, значение которого содержит var(). Такое
+	// сокращение разворачивается только после подстановки переменных,
+	// а value хранит его исходное значение
+	shorthand string
+}
+
+// NewCascade готовит каскад из встроенной таблицы стилей и таблиц автора
+// в порядке их подключения для отображения на устройстве device. Слои
+// @layer всех таблиц автора упорядочиваются вместе
+func NewCascade(author []*css.Stylesheet, device Device) *Cascade {
+	c := &Cascade{device: device, layers: &cascadeLayer{}}
+	c.addRules(userAgentStylesheet().Rules, UserAgentOrigin, "", c.layers)
+	for _, sheet := range author {
+		c.addRules(sheet.Rules, AuthorOrigin, "", c.layers)
+	}
+	c.layers.assignRanks(0)
+	return c
+}
+
+// addRules добавляет правила слоя layer. parent — селектор правила, в
+// которое вложены правила (CSS Nesting)
+func (c *Cascade) addRules(rules []css.Rule, origin Origin, parent string, layer *cascadeLayer) {
+	for _, rule := range rules {
+		switch rule := rule.(type) {
+		case *css.StyleRule:
+			text := rule.Selector
+			if parent != "" {
+				text = nestSelector(parent, rule.Prelude)
+			}
+			sel, err := html.CompileSelector(text)
+			if err != nil {
+				// Правило с некорректным селектором отбрасывается целиком
+				log.Printf("Правило CSS пропущено: %v", err)
+				continue
+			}
+			c.rules = append(c.rules, cascadeRule{
+				selector:     sel,
+				declarations: expandDeclarations(rule.Declarations),
+				origin:       origin,
+				layer:        layer,
+				order:        len(c.rules),
+			})
+			c.addRules(rule.Rules, origin, text, layer)
+		case *css.AtRule:
+			switch rule.Name {
+			case "media":
+				if mediaMatches(rule.Prelude, c.device) {
+					c.addGroup(rule, origin, parent, layer)
+				}
+			case "import":
+				if importMatches(rule.Prelude, c.device) {
+					c.addRules(rule.Rules, origin, parent, importLayer(rule.Prelude, layer))
+				}
+			case "supports":
+				if supportsMatches(rule.Prelude) {
+					c.addGroup(rule, origin, parent, layer)
+				}
+			case "layer":
+				c.addLayer(rule, origin, parent, layer)
+			}
+		}
+	}
+}
+
+// addGroup добавляет правила условного @-правила. Объявления прямо в теле
+// @-правила, вложенного в правило стиля, относятся к родительскому селектору
+func (c *Cascade) addGroup(rule *css.AtRule, origin Origin, parent string, layer *cascadeLayer) {
+	if parent != "" && len(rule.Declarations) > 0 {
+		if sel, err := html.CompileSelector(parent); err == nil {
+			c.rules = append(c.rules, cascadeRule{
+				selector:     sel,
+				declarations: expandDeclarations(rule.Declarations),
+				origin:       origin,
+				layer:        layer,
+				order:        len(c.rules),
+			})
+		}
+	}
+	c.addRules(rule.Rules, origin, parent, layer)
+}
+
+// addLayer обрабатывает @layer. Правило без блока только объявляет слои и
+// тем задает их порядок; правило с блоком помещает его правила во вложенный
+// слой с указанным именем или в новый слой без имени
+func (c *Cascade) addLayer(rule *css.AtRule, origin Origin, parent string, layer *cascadeLayer) {
+	names, ok := layerNames(rule.Prelude)
+	if !ok {
+		return
+	}
+	if rule.Block == nil {
+		for _, name := range names {
+			layer.declare(name)
+		}
+		return
+	}
+	switch len(names) {
+	case 0:
+		c.addGroup(rule, origin, parent, layer.anonymous())
+	case 1:
+		c.addGroup(rule, origin, parent, layer.declare(names[0]))
+	}
+}
+
+// importMatches проверяет условия @import. Прелюдия: адрес, затем
+// необязательные layer или layer(), supports() и список медиазапросов
+func importMatches(prelude []css.ComponentValue, device Device) bool {
+	parts := fields(prelude)
+	if len(parts) == 0 {
+		return false
+	}
+	rest := restAfter(prelude, parts, 1)
+	parts = parts[1:]
+	if len(parts) > 0 && (parts[0].IsIdent("layer") || parts[0].IsFunction("layer")) {
+		rest = restAfter(rest, parts, 1)
+		parts = parts[1:]
+	}
+	if len(parts) > 0 && parts[0].IsFunction("supports") {
+		// Условие supports() записывается так же, как условие в скобках @supports
+		condition := css.ComponentValue{Token: css.Token{Type: css.OpenParenToken}, Children: parts[0].Children}
+		if !supportsInParens(condition) {
+			return false
+		}
+		rest = restAfter(rest, parts, 1)
+	}
+	return mediaMatches(rest, device)
+}
+
+// nestSelector строит селектор вложенного правила: «&» заменяется
+// селектором родителя, а селектор без «&» считается потомком родителя
+func nestSelector(parent string, prelude []css.ComponentValue) string {
+	var selectors []string
+	for _, part := range splitCommas(prelude) {
+		part = trimSpaces(part)
+		var sb strings.Builder
+		nested := false
+		for _, v := range part {
+			if v.IsDelim("&") {
+				sb.WriteString(":is(" + parent + ")")
+				nested = true
+				continue
+			}
+			sb.WriteString(v.String())
+		}
+		if nested {
+			selectors = append(selectors, sb.String())
+		} else {
+			selectors = append(selectors, ":is("+parent+") "+sb.String())
+		}
+	}
+	return strings.Join(selectors, ", ")
+}
+
+// trimSpaces удаляет пробелы по краям значения
+func trimSpaces(value []css.ComponentValue) []css.ComponentValue {
+	for len(value) > 0 && value[0].Type == css.WhitespaceToken {
+		value = value[1:]
+	}
+	for len(value) > 0 && value[len(value)-1].Type == css.WhitespaceToken {
+		value = value[:len(value)-1]
+	}
+	return value
+}
+
+// expandDeclarations разворачивает сокращенные записи и отбрасывает
+// объявления неизвестных свойств и объявления с некорректным значением.
+// Значения с var() проверяются только после подстановки. Пользовательские
+// свойства сохраняются как есть
+func expandDeclarations(decls []css.Declaration) []declaration {
+	var result []declaration
+	for _, d := range decls {
+		if d.IsCustomProperty() {
+			result = append(result, declaration{name: d.Name, value: d.Value, important: d.Important})
+			continue
+		}
+		if _, ok := properties[d.Name]; ok {
+			if hasVar(d.Value) || validValue(d.Name, d.Value) {
+				result = append(result, declaration{name: d.Name, value: d.Value, important: d.Important})
+			}
+			continue
+		}
+		expand := shorthands[d.Name]
+		if expand == nil {
+			continue
+		}
+		if isWideKeyword(d.Value) {
+			// inherit, initial и подобные у сокращения относятся ко всем его свойствам
+			for _, name := range longhandsOf(d.Name) {
+				result = append(result, declaration{name: name, value: d.Value, important: d.Important})
+			}
+			continue
+		}
+		if hasVar(d.Value) {
+			for _, name := range longhandsOf(d.Name) {
+				result = append(result, declaration{name: name, value: d.Value, important: d.Important, shorthand: d.Name})
+			}
+			continue
+		}
+		longhands, ok := expand(d.Value)
+		if !ok {
+			continue
+		}
+		// Сокращение с некорректным значением любого свойства отбрасывается целиком
+		names := make([]string, 0, len(longhands))
+		for name, value := range longhands {
+			if !validValue(name, value) {
+				ok = false
+			}
+			names = append(names, name)
+		}
+		if !ok {
+			continue
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			result = append(result, declaration{name: name, value: longhands[name], important: d.Important})
+		}
+	}
+	return result
+}
+
+// shorthandSamples — значения, на которых разворачиваются сокращения, не
+// принимающие initial, чтобы узнать их свойства
+var shorthandSamples = map[string]string{
+	"font":          "medium serif",
+	"flex-flow":     "row",
+	"border":        "none",
+	"border-top":    "none",
+	"border-right":  "none",
+	"border-bottom": "none",
+	"border-left":   "none",
+	"outline":       "none",
+	"background":    "none",
+}
+
+// longhandsOf возвращает свойства, которые задает сокращенная запись
+func longhandsOf(shorthand string) []string {
+	sample := keyword("initial")
+	if text, ok := shorthandSamples[shorthand]; ok {
+		sample = css.ParseComponentValues(text)
+	}
+	longhands, _ := shorthands[shorthand](sample)
+	names := make([]string, 0, len(longhands))
+	for name := range longhands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isWideKeyword проверяет, является ли значение общим ключевым словом CSS
+// (inherit, initial, unset, revert, revert-layer)
+func isWideKeyword(value []css.ComponentValue) bool {
+	return wideKeyword(value) != ""
+}
+
+// wideKeyword возвращает общее ключевое слово CSS в нижнем регистре или
+// пустую строку
+func wideKeyword(value []css.ComponentValue) string {
+	if len(value) != 1 || value[0].Type != css.IdentToken {
+		return ""
+	}
+	switch name := strings.ToLower(value[0].Value); name {
+	case "inherit", "initial", "unset", "revert", "revert-layer":
+		return name
+	}
+	return ""
+}
+
+// candidate — объявление, которое участвует в каскаде для элемента
+type candidate struct {
+	value     []css.ComponentValue
+	shorthand string
+	origin    Origin
+	important bool
+	inline    bool
+	// layer — номер каскадного слоя; у атрибута style — номер корня
+	layer       int
+	specificity html.Specificity
+	order       int
+	// index — номер объявления среди объявлений свойства для элемента: из
+	// двух объявлений в одном правиле побеждает более позднее
+	index int
+}
+
+// level возвращает уровень источника и важности: важные объявления
+// браузера сильнее важных объявлений автора, а те — обычных
+func (c *candidate) level() int {
+	if !c.important {
+		return int(c.origin)
+	}
+	return 3 - int(c.origin)
+}
+
+// less проверяет, проигрывает ли объявление c объявлению other
+func (c *candidate) less(other *candidate) bool {
+	if c.level() != other.level() {
+		return c.level() < other.level()
+	}
+	// Атрибут style сильнее любого селектора того же источника
+	if c.inline != other.inline {
+		return other.inline
+	}
+	// Обычные объявления более позднего слоя сильнее, а важные — слабее
+	if c.layer != other.layer {
+		if c.important {
+			return c.layer > other.layer
+		}
+		return c.layer < other.layer
+	}
+	if c.specificity != other.specificity {
+		return c.specificity.Less(other.specificity)
+	}
+	if c.order != other.order {
+		return c.order < other.order
+	}
+	return c.index < other.index
+}
+
+// Compute вычисляет стили всех элементов документа
+func (c *Cascade) Compute(doc *html.Document) map[*html.Node]*ComputedStyle {
+	styles := make(map[*html.Node]*ComputedStyle)
+	var walk func(n *html.Node, parent *ComputedStyle)
+	walk = func(n *html.Node, parent *ComputedStyle) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			computed := c.ComputeElement(child, parent)
+			styles[child] = computed
+			walk(child, computed)
+		}
+	}
+	walk(&doc.Node, nil)
+	return styles
+}
+
+// ComputeElement вычисляет стиль элемента по стилю его родителя (nil у
+// корневого элемента)
+func (c *Cascade) ComputeElement(el *html.Node, parent *ComputedStyle) *ComputedStyle {
+	cascaded := make(map[string][]*candidate)
+	add := func(d declaration, cand candidate) {
+		cand.value, cand.important, cand.shorthand = d.value, d.important, d.shorthand
+		cand.index = len(cascaded[d.name])
+		cascaded[d.name] = append(cascaded[d.name], &cand)
+	}
+	for i := range c.rules {
+		rule := &c.rules[i]
+		specificity, ok := rule.selector.MatchSpecificity(el)
+		if !ok {
+			continue
+		}
+		for _, d := range rule.declarations {
+			add(d, candidate{origin: rule.origin, layer: rule.layer.rank, specificity: specificity, order: rule.order})
+		}
+	}
+	if inline, ok := el.LookupAttribute("style"); ok {
+		for _, d := range expandDeclarations(css.ParseDeclarations(inline)) {
+			add(d, candidate{origin: AuthorOrigin, inline: true, layer: c.layers.rank})
+		}
+	}
+
+	computed := &ComputedStyle{
+		values: make(map[string][]css.ComponentValue, len(properties)),
+		custom: resolveCustomProperties(cascaded, parent),
+	}
+	for name, prop := range properties {
+		computed.values[name] = resolveValue(name, prop, cascaded[name], parent, computed.custom)
+	}
+	computed.computeLengths(parent, c.device)
+	computed.computeColors(parent)
+	return computed
+}
+
+// cascadeWinner упорядочивает объявления и возвращает победителя каскада.
+// Если победило общее ключевое слово (inherit, initial, unset), вместо
+// объявления возвращается оно; revert откатывает к объявлениям более
+// слабого источника, а revert-layer — к объявлениям более слабого слоя
+// того же источника. Без объявлений результат — unset
+func cascadeWinner(candidates []*candidate) (*candidate, string) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[j].less(candidates[i])
+	})
+	for i := 0; i < len(candidates); i++ {
+		cand := candidates[i]
+		switch keyword := wideKeyword(cand.value); keyword {
+		case "":
+			return cand, ""
+		case "revert":
+			for i+1 < len(candidates) && candidates[i+1].origin >= cand.origin {
+				i++
+			}
+		case "revert-layer":
+			for i+1 < len(candidates) && candidates[i+1].level() == cand.level() && candidates[i+1].layer == cand.layer {
+				i++
+			}
+		default:
+			return nil, keyword
+		}
+	}
+	return nil, "unset"
+}
+
+// resolveValue выбирает значение свойства: победителя каскада с учетом
+// ключевых слов inherit, initial, unset и revert, а если объявлений нет —
+// значение родителя для наследуемых свойств или начальное значение.
+// var() в значении заменяются пользовательскими свойствами custom; если
+// подстановка не удалась или дала некорректное значение, оно
+// недействительно во время вычисления и свойство ведет себя как при unset
+func resolveValue(name string, prop property, candidates []*candidate, parent *ComputedStyle, custom map[string][]css.ComponentValue) []css.ComponentValue {
+	cand, keywordValue := cascadeWinner(candidates)
+	if cand != nil {
+		if cand.shorthand == "" && !hasVar(cand.value) {
+			return cand.value
+		}
+		if value, ok := substituteDeclaration(name, cand, custom); ok && validValue(name, value) {
+			return value
+		}
+		keywordValue = "unset"
+	}
+
+	switch {
+	case keywordValue == "initial":
+		return initialValue(name)
+	case parent != nil && (keywordValue == "inherit" || prop.inherited):
+		return parent.values[name]
+	}
+	return initialValue(name)
+}
@@ -0,0 +1,254 @@
+package style
+
+import (
+	"testing"
+
+	"github.com/baneronetwo/gluglu/internal/browser/css"
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+)
+
+// computeStyle вычисляет стили документа src с таблицей стилей sheet и
+// возвращает стиль элемента с id="c"
+func computeStyle(t *testing.T, sheet, src string) *ComputedStyle {
+	t.Helper()
+	doc, err := html.NewParser().Parse(src)
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	el, _ := doc.QuerySelector("#c")
+	if el == nil {
+		t.Fatalf("%s: нет элемента #c", src)
+	}
+	cascade := NewCascade([]*css.Stylesheet{css.ParseStylesheet(sheet)}, DefaultDevice())
+	return cascade.Compute(doc)[el]
+}
+
+func TestCascadeOrder(t *testing.T) {
+	tests := []struct {
+		sheet, src string
+		property   string
+		want       string
+	}{
+		// Специфичность и порядок
+		{"#c{color:red} p{color:blue}", "<p id=c>", "color", "rgb(255, 0, 0)"},
+		{"p.x{color:red} p{color:blue}", "<p id=c class=x>", "color", "rgb(255, 0, 0)"},
+		{"p{color:red} p{color:blue}", "<p id=c>", "color", "rgb(0, 0, 255)"},
+		{"p{color:red;color:blue}", "<p id=c>", "color", "rgb(0, 0, 255)"},
+		{"p{margin:1px;margin-left:2px;margin:3px}", "<p id=c>", "margin-left", "3px"},
+		{"", "<p id=c style='width:1px;width:2px'>", "width", "2px"},
+		{"p{color:red!important;color:blue}", "<p id=c>", "color", "rgb(255, 0, 0)"},
+		{":is(#c, p){color:red} #c{color:blue}", "<p id=c>", "color", "rgb(0, 0, 255)"},
+		{":where(#c){color:red} p{color:blue}", "<p id=c>", "color", "rgb(0, 0, 255)"},
+
+		// Важность и атрибут style
+		{"p{color:blue!important} #c{color:red}", "<p id=c>", "color", "rgb(0, 0, 255)"},
+		{"#c{color:red}", "<p id=c style='color:blue'>", "color", "rgb(0, 0, 255)"},
+		{"p{color:red!important}", "<p id=c style='color:blue'>", "color", "rgb(255, 0, 0)"},
+		{"#c{color:red!important}", "<p id=c style='color:blue!important'>", "color", "rgb(0, 0, 255)"},
+		{"p{color:red!important} p{color:blue!important}", "<p id=c>", "color", "rgb(0, 0, 255)"},
+
+		// Источники: автор сильнее браузера, revert откатывает к браузеру
+		{"p{display:inline}", "<p id=c>", "display", "inline"},
+		{"p{display:inline} #c{display:revert}", "<p id=c>", "display", "block"},
+		{"#c{display:initial}", "<p id=c>", "display", "inline"},
+
+		// Наследование
+		{"div{color:red}", "<div><p id=c>", "color", "rgb(255, 0, 0)"},
+		{"div{width:10px}", "<div><p id=c>", "width", "auto"},
+		{"div{width:10px} #c{width:inherit}", "<div><p id=c>", "width", "10px"},
+		{"div{color:red} #c{color:initial}", "<div><p id=c>", "color", "rgb(0, 0, 0)"},
+		{"div{color:red} #c{color:blue} #c{color:unset}", "<div><p id=c>", "color", "rgb(255, 0, 0)"},
+		{"div{font-size:20px} #c{width:2em}", "<div><p id=c>", "width", "40px"},
+		{"div{margin:1px 2px} #c{margin:inherit}", "<div><p id=c>", "margin-right", "2px"},
+
+		// Вложенные правила и условные правила внутри них
+		{"div{ #c{color:red} }", "<div><p id=c>", "color", "rgb(255, 0, 0)"},
+		{"div{ &.x{color:red} }", "<div id=c class=x>", "color", "rgb(255, 0, 0)"},
+		{"div{ @media screen{ color:red } }", "<div id=c>", "color", "rgb(255, 0, 0)"},
+		{"div{ @media print{ color:red } }", "<div id=c>", "color", "rgb(0, 0, 0)"},
+		{"div{ @supports (display:grid){ p{color:red} } }", "<div><p id=c>", "color", "rgb(255, 0, 0)"},
+	}
+	for _, test := range tests {
+		s := computeStyle(t, test.sheet, test.src)
+		if got := s.Get(test.property); got != test.want {
+			t.Errorf("%s %s: %s = %q, ожидалось %q", test.sheet, test.src, test.property, got, test.want)
+		}
+	}
+}
+
+func TestCascadeLayers(t *testing.T) {
+	tests := []struct {
+		sheet, src string
+		want       string
+	}{
+		// Правила вне слоев сильнее правил в слоях, а более поздний слой
+		// сильнее раннего независимо от специфичности
+		{"#c{color:red} @layer a{p{color:blue}}", "<p id=c>", "rgb(255, 0, 0)"},
+		{"@layer a{#c{color:red}} p{color:blue}", "<p id=c>", "rgb(0, 0, 255)"},
+		{"@layer a{#c{color:red}} @layer b{p{color:blue}}", "<p id=c>", "rgb(0, 0, 255)"},
+
+		// Порядок задается первым упоминанием имени, в том числе правилом без блока
+		{"@layer b, a; @layer a{p{color:red}} @layer b{#c{color:blue}}", "<p id=c>", "rgb(255, 0, 0)"},
+		{"@layer a{p{color:red}} @layer b{p{color:blue}} @layer a{p{color:green}}", "<p id=c>", "rgb(0, 0, 255)"},
+
+		// Вложенные слои слабее правил самого слоя; имя a.b равно вложенному b
+		{"@layer a{p{color:red} @layer b{#c{color:blue}}}", "<p id=c>", "rgb(255, 0, 0)"},
+		{"@layer a{@layer b{p{color:red}}} @layer a.b{p{color:blue}}", "<p id=c>", "rgb(0, 0, 255)"},
+		{"@layer a.c{p{color:red}} @layer b{#c{color:blue}}", "<p id=c>", "rgb(0, 0, 255)"},
+
+		// Каждый слой без имени отдельный
+		{"@layer{p{color:red}} @layer{p{color:blue}}", "<p id=c>", "rgb(0, 0, 255)"},
+
+		// У важных объявлений порядок обратный
+		{"@layer a{p{color:red!important}} @layer b{p{color:blue!important}}", "<p id=c>", "rgb(255, 0, 0)"},
+		{"@layer a{p{color:red!important}} #c{color:blue!important}", "<p id=c>", "rgb(255, 0, 0)"},
+		{"@layer a{p{color:red}}", "<p id=c style='color:blue'>", "rgb(0, 0, 255)"},
+
+		// revert-layer откатывает к предыдущему слою, а не к браузеру
+		{"@layer a{p{color:red}} @layer b{#c{color:revert-layer}}", "<p id=c>", "rgb(255, 0, 0)"},
+		{"@layer a{p{color:red}} #c{color:revert-layer}", "<p id=c>", "rgb(255, 0, 0)"},
+
+		// Некорректное имя отбрасывает правило
+		{"@layer a b{p{color:red}}", "<p id=c>", "rgb(0, 0, 0)"},
+		{"@layer a, b{p{color:red}}", "<p id=c>", "rgb(0, 0, 0)"},
+	}
+	for _, test := range tests {
+		s := computeStyle(t, test.sheet, test.src)
+		if got := s.Get("color"); got != test.want {
+			t.Errorf("%s %s: color = %q, ожидалось %q", test.sheet, test.src, got, test.want)
+		}
+	}
+}
+
+func TestCascadeDropsInvalidValues(t *testing.T) {
+	tests := []struct {
+		sheet    string
+		property string
+		want     string
+	}{
+		// Некорректное значение не перекрывает предыдущее объявление
+		{"#c{width:200px;color:red;display:block} #c{width:foo;color:notacolor;display:bogus}", "width", "200px"},
+		{"#c{width:200px;color:red;display:block} #c{width:foo;color:notacolor;display:bogus}", "color", "rgb(255, 0, 0)"},
+		{"#c{width:200px;color:red;display:block} #c{width:foo;color:notacolor;display:bogus}", "display", "block"},
+		{"#c{position:absolute} #c{position:middle}", "position", "absolute"},
+		{"#c{font-weight:bold} #c{font-weight:1001}", "font-weight", "bold"},
+		{"#c{z-index:2} #c{z-index:1.5}", "z-index", "2"},
+		{"#c{border-top:2px solid} #c{border-top-width:10%}", "border-top-width", "2px"},
+		{"#c{color:red} #c{color:}", "color", "rgb(255, 0, 0)"},
+
+		// Сокращение с некорректной частью отбрасывается целиком
+		{"#c{margin:1px} #c{margin:2px foo}", "margin-top", "1px"},
+		{"#c{border-top:1px solid red} #c{border-top:1px bogus red}", "border-top-color", "rgb(255, 0, 0)"},
+
+		// Значение с var() проверяется после подстановки: некорректный
+		// результат ведет себя как unset
+		{"#c{--w:foo;width:200px} #c{width:var(--w)}", "width", "auto"},
+		{"#c{--w:50px;width:200px} #c{width:var(--w)}", "width", "50px"},
+		{"#c{--m:foo;margin-top:3px} #c{margin:var(--m)}", "margin-top", "0px"},
+	}
+	for _, test := range tests {
+		s := computeStyle(t, test.sheet, "<div id=c>")
+		if got := s.Get(test.property); got != test.want {
+			t.Errorf("%s: %s = %q, ожидалось %q", test.sheet, test.property, got, test.want)
+		}
+	}
+}
+
+func TestValidValue(t *testing.T) {
+	tests := []struct {
+		property, value string
+		want            bool
+	}{
+		{"width", "10px", true},
+		{"width", "50%", true},
+		{"width", "calc(100% - 2em)", true},
+		{"width", "calc(2 * 10px + 5%)", true},
+		{"width", "calc(10px + 1)", false},
+		{"width", "calc(1 + 2)", false},
+		{"width", "calc(0)", false},
+		{"width", "min(10px, 5)", false},
+		{"width", "AUTO", true},
+		{"width", "10", false},
+		{"width", "none", false},
+		{"max-width", "none", true},
+		{"margin-left", "auto", true},
+		{"padding-left", "auto", false},
+		{"color", "#abc", true},
+		{"color", "currentColor", true},
+		{"color", "rgb(1 2 3 / 50%)", true},
+		{"color", "#abcde", false},
+		{"display", "flex", true},
+		{"display", "block flow", false},
+		{"display", "inherit", true},
+		{"font-style", "oblique 10deg", true},
+		{"font-style", "oblique 10px", false},
+		{"line-height", "1.5", true},
+		{"line-height", "normal", true},
+		{"background-repeat", "repeat no-repeat", true},
+		{"background-repeat", "repeat-x repeat", false},
+		{"opacity", "50%", true},
+		{"flex-grow", "-1", false},
+		{"order", "-1", true},
+		{"cursor", "pointer", true},
+		{"cursor", "", false},
+	}
+	for _, test := range tests {
+		if got := validValue(test.property, css.ParseComponentValues(test.value)); got != test.want {
+			t.Errorf("%s: %q: получено %v, ожидалось %v", test.property, test.value, got, test.want)
+		}
+	}
+}
+
+func TestNegativeLengths(t *testing.T) {
+	const example = "#c{width:200px} #c{width:-10px;height:-5px;padding-top:-3px;min-width:-1px;line-height:-2}"
+	tests := []struct {
+		sheet    string
+		property string
+		want     string
+	}{
+		// Отрицательные числа отвергаются при разборе
+		{example, "width", "200px"},
+		{example, "height", "auto"},
+		{example, "padding-top", "0px"},
+		{example, "min-width", "auto"},
+		{example, "line-height", "normal"},
+		{"#c{max-height:-1%}", "max-height", "none"},
+		{"#c{flex-basis:-1px}", "flex-basis", "auto"},
+		{"#c{margin-top:-5px;text-indent:-1em;left:-2px}", "margin-top", "-5px"},
+		{"#c{margin-top:-5px;text-indent:-1em;left:-2px}", "text-indent", "-16px"},
+
+		// Отрицательный результат calc() заменяется нулем при вычислении
+		{"#c{width:calc(10px - 20px)}", "width", "0px"},
+		{"#c{width:max(-10px, -5px)}", "width", "0px"},
+		{"#c{padding-left:calc(1em - 20px)}", "padding-left", "0px"},
+		{"#c{padding-left:calc(50% - 100px)}", "padding-left", "max(0px, 50% - 100px)"},
+		{"#c{width:calc(-10%)}", "width", "max(0px, -10%)"},
+		{"#c{padding-left:calc(-10%)}", "padding-left", "max(0px, -10%)"},
+		{"#c{width:calc(10%)}", "width", "10%"},
+		{"#c{line-height:calc(1px - 5px)}", "line-height", "0px"},
+		{"#c{margin-top:calc(10px - 20px)}", "margin-top", "-10px"},
+
+		// Бесконечный результат заменяется наибольшей длиной того же знака
+		{"#c{width:calc(1px / 0)}", "width", "33554432px"},
+		{"#c{margin-top:calc(-1px / 0)}", "margin-top", "-33554432px"},
+	}
+	for _, test := range tests {
+		s := computeStyle(t, test.sheet, "<div id=c>")
+		if got := s.Get(test.property); got != test.want {
+			t.Errorf("%s: %s = %q, ожидалось %q", test.sheet, test.property, got, test.want)
+		}
+	}
+
+	// Выражение с процентами ограничивается при раскладке
+	padding := computeStyle(t, "#c{padding-left:calc(50% - 100px)}", "<div id=c>").Length("padding-left")
+	if got := padding.Resolve(100); got != 0 {
+		t.Errorf("padding-left от 100px: получено %g, ожидалось 0", got)
+	}
+	if got := padding.Resolve(400); got != 100 {
+		t.Errorf("padding-left от 400px: получено %g, ожидалось 100", got)
+	}
+	width := computeStyle(t, "#c{width:calc(-10%)}", "<div id=c>").Length("width")
+	if got := width.Resolve(400); got != 0 {
+		t.Errorf("width от 400px: получено %g, ожидалось 0", got)
+	}
+}
@@ -0,0 +1,350 @@
+package style
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/css"
+)
+
+// ComputedStyle — значения всех поддерживаемых свойств элемента после
+// каскада и наследования
+type ComputedStyle struct {
+	values map[string][]css.ComponentValue
+	// custom — действительные пользовательские свойства (--имя) после
+	// подстановки var()
+	custom map[string][]css.ComponentValue
+	// lengths — разобранные значения свойств-длин
+	lengths map[string]Length
+	// colors — значения свойств-цветов; currentcolor уже заменен цветом текста
+	colors map[string]css.Color
+	// fontSize и rootFontSize — размер шрифта элемента и корневого
+	// элемента в пикселях
+	fontSize     float64
+	rootFontSize float64
+}
+
+// Initial возвращает стиль, в котором все свойства имеют начальные значения
+func Initial() *ComputedStyle {
+	s := &ComputedStyle{values: make(map[string][]css.ComponentValue, len(properties))}
+	for name := range properties {
+		s.values[name] = initialValue(name)
+	}
+	s.computeLengths(nil, Device{})
+	s.computeColors(nil)
+	return s
+}
+
+// Value возвращает значение свойства в виде компонентных значений. name
+// может быть и именем пользовательского свойства (--имя)
+func (s *ComputedStyle) Value(name string) []css.ComponentValue {
+	if isCustomPropertyName(name) {
+		return s.custom[name]
+	}
+	return s.values[name]
+}
+
+// Get возвращает значение свойства в виде текста CSS
+func (s *ComputedStyle) Get(name string) string {
+	return css.Serialize(s.Value(name))
+}
+
+// CustomProperties возвращает имена действительных пользовательских
+// свойств элемента в алфавитном порядке
+func (s *ComputedStyle) CustomProperties() []string {
+	names := make([]string, 0, len(s.custom))
+	for name := range s.custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Keyword возвращает значение свойства-ключевого слова в нижнем регистре
+// или пустую строку, если значение не является одним идентификатором
+func (s *ComputedStyle) Keyword(name string) string {
+	value := s.values[name]
+	if len(value) != 1 || value[0].Type != css.IdentToken {
+		return ""
+	}
+	return strings.ToLower(value[0].Value)
+}
+
+// Display возвращает значение display
+func (s *ComputedStyle) Display() string {
+	return s.Keyword("display")
+}
+
+// Length возвращает значение свойства-длины (width, margin-left...). У
+// свойств, которые не являются длинами, результат — пустая Length
+func (s *ComputedStyle) Length(name string) Length {
+	return s.lengths[name]
+}
+
+// Color возвращает значение свойства-цвета (color, background-color,
+// border-top-color...). У других свойств результат — Transparent
+func (s *ComputedStyle) Color(name string) css.Color {
+	return s.colors[name]
+}
+
+// FontSize возвращает размер шрифта в пикселях
+func (s *ComputedStyle) FontSize() float64 {
+	return s.fontSize
+}
+
+// LineHeight возвращает высоту строки в пикселях. Для normal она
+// принимается равной 1.2 размера шрифта
+func (s *ComputedStyle) LineHeight() float64 {
+	value := fields(s.values["line-height"])
+	if len(value) == 1 && value[0].Type == css.NumberToken {
+		return value[0].Number * s.fontSize
+	}
+	if l := s.lengths["line-height"]; l.IsLength() {
+		return l.Resolve(s.fontSize)
+	}
+	return 1.2 * s.fontSize
+}
+
+// lengthProperty описывает свойство-длину: ключевые слова, которые оно
+// принимает вместо длины, и запрет отрицательных значений
+type lengthProperty struct {
+	keywords    []string
+	nonNegative bool
+}
+
+// lengthProperties — свойства-длины. line-height, который принимает и
+// число, разбирается отдельно и тоже не может быть отрицательным
+var lengthProperties = map[string]lengthProperty{
+	"width":          {[]string{"auto"}, true},
+	"height":         {[]string{"auto"}, true},
+	"min-width":      {[]string{"auto"}, true},
+	"min-height":     {[]string{"auto"}, true},
+	"max-width":      {[]string{"none"}, true},
+	"max-height":     {[]string{"none"}, true},
+	"margin-top":     {[]string{"auto"}, false},
+	"margin-right":   {[]string{"auto"}, false},
+	"margin-bottom":  {[]string{"auto"}, false},
+	"margin-left":    {[]string{"auto"}, false},
+	"padding-top":    {nil, true},
+	"padding-right":  {nil, true},
+	"padding-bottom": {nil, true},
+	"padding-left":   {nil, true},
+	"top":            {[]string{"auto"}, false},
+	"right":          {[]string{"auto"}, false},
+	"bottom":         {[]string{"auto"}, false},
+	"left":           {[]string{"auto"}, false},
+	"text-indent":    {nil, false},
+	"letter-spacing": {[]string{"normal"}, false},
+	"word-spacing":   {[]string{"normal"}, false},
+	"flex-basis":     {[]string{"auto", "content"}, true},
+	"gap":            {[]string{"normal"}, true},
+}
+
+// borderWidths — свойства толщины рамки и свойства ее стиля
+var borderWidths = map[string]string{
+	"border-top-width":    "border-top-style",
+	"border-right-width":  "border-right-style",
+	"border-bottom-width": "border-bottom-style",
+	"border-left-width":   "border-left-style",
+	"outline-width":       "outline-style",
+}
+
+// lineWidthKeywords — толщина рамки, заданная ключевым словом
+var lineWidthKeywords = map[string]float64{"thin": 1, "medium": 3, "thick": 5}
+
+// fontSizeKeywords — абсолютные размеры шрифта
+var fontSizeKeywords = map[string]float64{
+	"xx-small":  9,
+	"x-small":   10,
+	"small":     13,
+	"medium":    16,
+	"large":     18,
+	"x-large":   24,
+	"xx-large":  32,
+	"xxx-large": 48,
+}
+
+// computeLengths переводит длины в вычисленные значения: размер шрифта —
+// в пиксели относительно шрифта родителя, остальные длины — в пиксели
+// относительно шрифта элемента и области просмотра. Проценты сохраняются
+// до раскладки. Значение, которое не удалось разобрать, заменяется
+// унаследованным или начальным. Отрицательный результат calc() у
+// свойств, которые не принимают отрицательных значений, заменяется нулем
+func (s *ComputedStyle) computeLengths(parent *ComputedStyle, device Device) {
+	ctx := &unitContext{fontSize: 16, rootFontSize: 16, device: device}
+	if parent != nil {
+		ctx.fontSize, ctx.rootFontSize = parent.fontSize, parent.rootFontSize
+	}
+	s.fontSize = computeFontSize(s.values["font-size"], ctx)
+	if parent == nil {
+		s.rootFontSize = s.fontSize
+	} else {
+		s.rootFontSize = parent.rootFontSize
+	}
+	s.values["font-size"] = Px(s.fontSize).values()
+
+	ctx.fontSize = s.fontSize
+	if parent == nil {
+		ctx.rootFontSize = s.fontSize
+	}
+	s.lengths = make(map[string]Length, len(lengthProperties)+len(borderWidths)+1)
+	for name, prop := range lengthProperties {
+		l, ok := parseLength(s.values[name], ctx, prop.keywords...)
+		if !ok {
+			if parent != nil && properties[name].inherited {
+				l = parent.lengths[name]
+			} else {
+				l, _ = parseLength(initialValue(name), ctx, prop.keywords...)
+			}
+		}
+		if prop.nonNegative {
+			l = clampNonNegative(l)
+		}
+		s.lengths[name] = l
+		s.values[name] = l.values()
+	}
+	for name, styleName := range borderWidths {
+		l, ok := parseLength(s.values[name], ctx, "thin", "medium", "thick")
+		if !ok || l.HasPercent() {
+			l = Length{keyword: "medium"}
+		}
+		if w, ok := lineWidthKeywords[l.keyword]; ok {
+			l = Px(w)
+		}
+		// Рамка без стиля не имеет толщины
+		if borderStyle := s.Keyword(styleName); borderStyle == "none" || borderStyle == "hidden" {
+			l = Px(0)
+		}
+		s.lengths[name] = l
+		s.values[name] = l.values()
+	}
+
+	// line-height: число наследуется как есть, а длина и проценты
+	// переводятся в пиксели относительно шрифта элемента
+	value := fields(s.values["line-height"])
+	if len(value) == 1 && value[0].Type == css.NumberToken {
+		return
+	}
+	if l, ok := parseLength(value, ctx, "normal"); ok {
+		if l.IsLength() {
+			l = Px(math.Max(l.Resolve(s.fontSize), 0))
+		}
+		s.lengths["line-height"] = l
+		s.values["line-height"] = l.values()
+	} else if parent != nil {
+		s.lengths["line-height"] = parent.lengths["line-height"]
+		s.values["line-height"] = parent.values["line-height"]
+	} else {
+		s.lengths["line-height"] = Length{keyword: "normal"}
+		s.values["line-height"] = initialValue("line-height")
+	}
+}
+
+// colorProperties — свойства, значения которых являются цветами
+var colorProperties = []string{
+	"background-color",
+	"border-top-color",
+	"border-right-color",
+	"border-bottom-color",
+	"border-left-color",
+	"outline-color",
+}
+
+// computeColors разбирает цвета. currentcolor в color означает цвет
+// родителя, а в остальных свойствах — цвет текста элемента; в вычисленном
+// значении остальных свойств он сохраняется ключевым словом и потому
+// наследуется как ключевое слово. Значение, которое не удалось разобрать,
+// заменяется унаследованным или начальным
+func (s *ComputedStyle) computeColors(parent *ComputedStyle) {
+	s.colors = make(map[string]css.Color, len(colorProperties)+1)
+	inherited := css.Black
+	if parent != nil {
+		inherited = parent.colors["color"]
+	}
+	color, ok := css.ParseColor(s.values["color"], inherited)
+	if !ok {
+		color = inherited
+	}
+	s.colors["color"] = color
+	s.values["color"] = css.ParseComponentValues(color.String())
+
+	for _, name := range colorProperties {
+		value := s.values[name]
+		c, ok := css.ParseColor(value, color)
+		if !ok {
+			value = initialValue(name)
+			c, _ = css.ParseColor(value, color)
+		}
+		s.colors[name] = c
+		if !isCurrentColor(value) {
+			value = css.ParseComponentValues(c.String())
+		}
+		s.values[name] = value
+	}
+}
+
+// clampNonNegative заменяет отрицательную длину нулем. Выражение с
+// процентами вычисляется только при раскладке, поэтому оборачивается в max();
+// так же оборачивается и отрицательный процент, полученный из calc()
+func clampNonNegative(l Length) Length {
+	switch {
+	case !l.IsLength():
+		return l
+	case l.HasPercent():
+		if l.expr.op == leafOp && l.expr.value >= 0 {
+			return l
+		}
+		zero := Px(0).expr
+		return Length{expr: &calcNode{op: maxOp, typ: lengthPercentType, args: []*calcNode{zero, l.expr}}}
+	case l.Resolve(0) < 0:
+		return Px(0)
+	}
+	return l
+}
+
+// isCurrentColor проверяет, является ли значение ключевым словом currentcolor
+func isCurrentColor(value []css.ComponentValue) bool {
+	parts := fields(value)
+	return len(parts) == 1 && parts[0].IsIdent("currentcolor")
+}
+
+// computeFontSize вычисляет размер шрифта в пикселях. ctx содержит размер
+// шрифта родителя, от которого считаются em, проценты, larger и smaller
+func computeFontSize(value []css.ComponentValue, ctx *unitContext) float64 {
+	parts := fields(value)
+	if len(parts) == 1 && parts[0].Type == css.IdentToken {
+		name := strings.ToLower(parts[0].Value)
+		if size, ok := fontSizeKeywords[name]; ok {
+			return size
+		}
+		switch name {
+		case "larger":
+			return ctx.fontSize * 1.2
+		case "smaller":
+			return ctx.fontSize / 1.2
+		}
+	}
+	if l, ok := parseLength(value, ctx); ok {
+		if size := l.Resolve(ctx.fontSize); size >= 0 {
+			return size
+		}
+	}
+	return ctx.fontSize
+}
+
+// initialValues — разобранные начальные значения свойств
+var initialValues = parseInitialValues()
+
+func parseInitialValues() map[string][]css.ComponentValue {
+	values := make(map[string][]css.ComponentValue, len(properties))
+	for name, prop := range properties {
+		values[name] = css.ParseComponentValues(prop.initial)
+	}
+	return values
+}
+
+// initialValue возвращает начальное значение свойства
+func initialValue(name string) []css.ComponentValue {
+	return initialValues[name]
+}
@@ -0,0 +1,58 @@
+package style
+
+import (
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/css"
+	"github.com/baneronetwo/gluglu/internal/browser/html"
+)
+
+// supportsMatches вычисляет условие @supports: объявления в скобках,
+// selector(), not, and и or
+func supportsMatches(prelude []css.ComponentValue) bool {
+	parts := fields(prelude)
+	if len(parts) == 0 {
+		return false
+	}
+	if parts[0].IsIdent("not") {
+		return len(parts) == 2 && !supportsInParens(parts[1])
+	}
+	result := supportsInParens(parts[0])
+	for i := 1; i < len(parts); i += 2 {
+		if i+1 >= len(parts) {
+			return false
+		}
+		switch {
+		case parts[i].IsIdent("and"):
+			result = supportsInParens(parts[i+1]) && result
+		case parts[i].IsIdent("or"):
+			result = supportsInParens(parts[i+1]) || result
+		default:
+			return false
+		}
+	}
+	return result
+}
+
+// supportsInParens вычисляет условие в скобках или функцию selector()
+func supportsInParens(v css.ComponentValue) bool {
+	if v.IsFunction("selector") {
+		_, err := html.CompileSelector(css.Serialize(trimSpaces(v.Children)))
+		return err == nil
+	}
+	if v.Type != css.OpenParenToken {
+		return false
+	}
+	inner := trimSpaces(v.Children)
+	if len(inner) > 0 && inner[0].Type == css.IdentToken {
+		rest := trimSpaces(inner[1:])
+		if len(rest) > 0 && rest[0].Type == css.ColonToken {
+			name := inner[0].Value
+			if !strings.HasPrefix(name, "--") {
+				name = strings.ToLower(name)
+			}
+			return IsKnownProperty(name) && len(trimSpaces(rest[1:])) > 0
+		}
+	}
+	return supportsMatches(inner)
+}
@@ -0,0 +1,106 @@
+package style
+
+import "github.com/baneronetwo/gluglu/internal/browser/css"
+
+// cascadeLayer — каскадный слой (@layer). Слои образуют дерево: корень
+// содержит правила вне слоев, а вложенные слои идут в порядке первого
+// упоминания их имен. Правила самого слоя сильнее правил его вложенных слоев
+type cascadeLayer struct {
+	children []*cascadeLayer
+	named    map[string]*cascadeLayer
+	// rank — место слоя в каскаде: у обычных объявлений более поздний слой
+	// сильнее, у важных — слабее
+	rank int
+}
+
+// declare возвращает вложенный слой с именем path (a.b — путь [a b]),
+// создавая недостающие слои
+func (l *cascadeLayer) declare(path []string) *cascadeLayer {
+	for _, name := range path {
+		child, ok := l.named[name]
+		if !ok {
+			child = l.anonymous()
+			if l.named == nil {
+				l.named = make(map[string]*cascadeLayer)
+			}
+			l.named[name] = child
+		}
+		l = child
+	}
+	return l
+}
+
+// anonymous создает вложенный слой без имени: каждый @layer без имени
+// задает отдельный слой
+func (l *cascadeLayer) anonymous() *cascadeLayer {
+	child := &cascadeLayer{}
+	l.children = append(l.children, child)
+	return child
+}
+
+// assignRanks нумерует слои начиная с next: вложенные слои получают
+// меньшие номера, чем родитель. Возвращает следующий свободный номер
+func (l *cascadeLayer) assignRanks(next int) int {
+	for _, child := range l.children {
+		next = child.assignRanks(next)
+	}
+	l.rank = next
+	return next + 1
+}
+
+// layerNames разбирает список имен слоев из прелюдии @layer. Имя состоит из
+// идентификаторов, разделенных точками без пробелов
+func layerNames(prelude []css.ComponentValue) ([][]string, bool) {
+	if len(fields(prelude)) == 0 {
+		return nil, true
+	}
+	var names [][]string
+	for _, part := range splitCommas(prelude) {
+		name, ok := layerName(trimSpaces(part))
+		if !ok {
+			return nil, false
+		}
+		names = append(names, name)
+	}
+	return names, true
+}
+
+// layerName разбирает одно имя слоя
+func layerName(value []css.ComponentValue) ([]string, bool) {
+	var path []string
+	for i, v := range value {
+		if i%2 == 1 {
+			if !v.IsDelim(".") {
+				return nil, false
+			}
+			continue
+		}
+		if v.Type != css.IdentToken || isWideKeyword(value[i:i+1]) {
+			return nil, false
+		}
+		path = append(path, v.Value)
+	}
+	if len(value)%2 == 0 {
+		return nil, false
+	}
+	return path, true
+}
+
+// importLayer возвращает слой, в который @import помещает правила
+// импортированной таблицы: layer задает слой без имени, layer(имя) —
+// именованный слой, а без них правила остаются в слое l
+func importLayer(prelude []css.ComponentValue, l *cascadeLayer) *cascadeLayer {
+	parts := fields(prelude)
+	if len(parts) < 2 {
+		return l
+	}
+	switch v := parts[1]; {
+	case v.IsIdent("layer"):
+		return l.anonymous()
+	case v.IsFunction("layer"):
+		if name, ok := layerName(trimSpaces(v.Children)); ok {
+			return l.declare(name)
+		}
+	}
+	return l
+}
@@ -0,0 +1,480 @@
+package style
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/css"
+)
+
+// Device — характеристики устройства, на котором отображается документ.
+// По ним вычисляются единицы vw и vh и медиазапросы
+type Device struct {
+	// Width и Height — размеры области просмотра в пикселях CSS
+	Width  float64
+	Height float64
+	// Type — тип носителя: screen (по умолчанию) или print
+	Type string
+	// ColorScheme — предпочитаемая цветовая схема: light (по умолчанию) или dark
+	ColorScheme string
+	// ReducedMotion — пользователь просит сократить анимацию
+	ReducedMotion bool
+	// Resolution — число физических пикселей в пикселе CSS
+	// (devicePixelRatio); 0 означает 1
+	Resolution float64
+}
+
+// Length — вычисленное значение длины. Единицы, зависящие от шрифта и
+// области просмотра (em, rem, vw...), уже переведены в пиксели, а проценты
+// и выражения с ними вычисляются при раскладке относительно размера
+// содержащего блока
+type Length struct {
+	// keyword — auto, none, normal и т. п.; пустая строка у длины
+	keyword string
+	expr    *calcNode
+}
+
+// Keyword возвращает ключевое слово значения (auto, none...) или пустую строку
+func (l Length) Keyword() string {
+	return l.keyword
+}
+
+// IsAuto проверяет, равно ли значение auto
+func (l Length) IsAuto() bool {
+	return l.keyword == "auto"
+}
+
+// IsLength проверяет, задано ли значение длиной, а не ключевым словом
+func (l Length) IsLength() bool {
+	return l.expr != nil
+}
+
+// HasPercent проверяет, зависит ли длина от размера содержащего блока
+func (l Length) HasPercent() bool {
+	return l.expr != nil && l.expr.hasPercent()
+}
+
+// Resolve возвращает длину в пикселях. Проценты берутся от base. У
+// ключевых слов результат 0
+func (l Length) Resolve(base float64) float64 {
+	if l.expr == nil {
+		return 0
+	}
+	return finite(l.expr.eval(base))
+}
+
+// String возвращает вычисленное значение в виде текста CSS
+func (l Length) String() string {
+	if l.expr == nil {
+		return l.keyword
+	}
+	if l.expr.op == sumOp || l.expr.op == productOp {
+		return "calc(" + l.expr.String() + ")"
+	}
+	return l.expr.String()
+}
+
+// values возвращает вычисленное значение в виде компонентных значений
+func (l Length) values() []css.ComponentValue {
+	return css.ParseComponentValues(l.String())
+}
+
+// Px создает длину в пикселях
+func Px(v float64) Length {
+	return Length{expr: &calcNode{op: leafOp, value: v, unit: pxUnit, typ: lengthType}}
+}
+
+// maxCalcLength — наибольшая по модулю длина, которой заменяется
+// бесконечный результат выражения
+const maxCalcLength = 1 << 25
+
+// finite приводит результат выражения к конечному числу, как требует
+// css-values-4: NaN становится нулем, а бесконечность — наибольшей длиной
+// того же знака
+func finite(v float64) float64 {
+	switch {
+	case math.IsNaN(v):
+		return 0
+	case math.IsInf(v, 1):
+		return maxCalcLength
+	case math.IsInf(v, -1):
+		return -maxCalcLength
+	}
+	return v
+}
+
+// calcOp — операция узла выражения
+type calcOp int
+
+const (
+	leafOp calcOp = iota
+	sumOp
+	productOp
+	minOp
+	maxOp
+	clampOp
+)
+
+// calcUnit — единица листа выражения после перевода в пиксели
+type calcUnit int
+
+const (
+	numberUnit calcUnit = iota
+	pxUnit
+	percentUnit
+)
+
+// calcType — тип значения выражения: число, длина, процент или длина с
+// процентами (сумма или сравнение длины и процента)
+type calcType int
+
+const (
+	numberType calcType = iota
+	lengthType
+	percentType
+	lengthPercentType
+)
+
+// addTypes возвращает тип суммы, min(), max() или clamp() от значений
+// типов a и b. Число сочетается только с числом
+func addTypes(a, b calcType) (calcType, bool) {
+	switch {
+	case a == b:
+		return a, true
+	case a == numberType || b == numberType:
+		return 0, false
+	}
+	return lengthPercentType, true
+}
+
+// calcNode — узел выражения calc(). У суммы и произведения слагаемые и
+// множители хранятся в args, а знак (вычитание, деление) — в inverted.
+// typ — тип значения узла, проверенный при разборе
+type calcNode struct {
+	op       calcOp
+	value    float64
+	unit     calcUnit
+	typ      calcType
+	args     []*calcNode
+	inverted []bool
+}
+
+// eval вычисляет выражение, беря проценты от base
+func (n *calcNode) eval(base float64) float64 {
+	switch n.op {
+	case leafOp:
+		if n.unit == percentUnit {
+			return n.value * base / 100
+		}
+		return n.value
+	case sumOp:
+		sum := 0.0
+		for i, arg := range n.args {
+			if n.inverted[i] {
+				sum -= arg.eval(base)
+			} else {
+				sum += arg.eval(base)
+			}
+		}
+		return sum
+	case productOp:
+		product := 1.0
+		for i, arg := range n.args {
+			if n.inverted[i] {
+				product /= arg.eval(base)
+			} else {
+				product *= arg.eval(base)
+			}
+		}
+		return product
+	case minOp, maxOp:
+		result := n.args[0].eval(base)
+		for _, arg := range n.args[1:] {
+			if v := arg.eval(base); n.op == minOp && v < result || n.op == maxOp && v > result {
+				result = v
+			}
+		}
+		return result
+	case clampOp:
+		low, value, high := n.args[0].eval(base), n.args[1].eval(base), n.args[2].eval(base)
+		return math.Max(low, math.Min(value, high))
+	}
+	return 0
+}
+
+// hasPercent проверяет, есть ли в выражении проценты
+func (n *calcNode) hasPercent() bool {
+	if n.op == leafOp {
+		return n.unit == percentUnit
+	}
+	for _, arg := range n.args {
+		if arg.hasPercent() {
+			return true
+		}
+	}
+	return false
+}
+
+// String записывает выражение в виде текста CSS
+func (n *calcNode) String() string {
+	switch n.op {
+	case leafOp:
+		v := strconv.FormatFloat(n.value, 'f', -1, 64)
+		switch n.unit {
+		case pxUnit:
+			return v + "px"
+		case percentUnit:
+			return v + "%"
+		}
+		return v
+	case sumOp, productOp:
+		var sb strings.Builder
+		for i, arg := range n.args {
+			if i > 0 {
+				switch {
+				case n.op == sumOp && n.inverted[i]:
+					sb.WriteString(" - ")
+				case n.op == sumOp:
+					sb.WriteString(" + ")
+				case n.inverted[i]:
+					sb.WriteString(" / ")
+				default:
+					sb.WriteString(" * ")
+				}
+			}
+			if arg.op == sumOp || n.op == productOp && arg.op == productOp {
+				sb.WriteString("(" + arg.String() + ")")
+			} else {
+				sb.WriteString(arg.String())
+			}
+		}
+		return sb.String()
+	}
+	names := map[calcOp]string{minOp: "min", maxOp: "max", clampOp: "clamp"}
+	args := make([]string, len(n.args))
+	for i, arg := range n.args {
+		args[i] = arg.String()
+	}
+	return names[n.op] + "(" + strings.Join(args, ", ") + ")"
+}
+
+// unitContext — размеры, относительно которых переводятся единицы длины
+type unitContext struct {
+	// fontSize — размер шрифта для em, ex и ch; rootFontSize — для rem
+	fontSize     float64
+	rootFontSize float64
+	device       Device
+}
+
+// absoluteUnits — множители абсолютных единиц длины относительно пикселя
+var absoluteUnits = map[string]float64{
+	"px": 1,
+	"in": 96,
+	"cm": 96 / 2.54,
+	"mm": 96 / 25.4,
+	"q":  96 / 101.6,
+	"pt": 96.0 / 72,
+	"pc": 16,
+}
+
+// toPixels переводит число с единицей в пиксели
+func (ctx *unitContext) toPixels(value float64, unit string) (float64, bool) {
+	unit = strings.ToLower(unit)
+	if k, ok := absoluteUnits[unit]; ok {
+		return value * k, true
+	}
+	switch unit {
+	case "em":
+		return value * ctx.fontSize, true
+	case "rem":
+		return value * ctx.rootFontSize, true
+	case "ex", "ch":
+		// Без метрик шрифта и «x», и «0» считаются шириной в половину кегля
+		return value * ctx.fontSize / 2, true
+	case "vw", "svw", "lvw", "dvw":
+		return value * ctx.device.Width / 100, true
+	case "vh", "svh", "lvh", "dvh":
+		return value * ctx.device.Height / 100, true
+	case "vmin":
+		return value * math.Min(ctx.device.Width, ctx.device.Height) / 100, true
+	case "vmax":
+		return value * math.Max(ctx.device.Width, ctx.device.Height) / 100, true
+	}
+	return 0, false
+}
+
+// parseLength разбирает значение длины: число с единицей, процент, 0,
+// calc(), min(), max(), clamp() или ключевое слово из keywords
+func parseLength(value []css.ComponentValue, ctx *unitContext, keywords ...string) (Length, bool) {
+	parts := fields(value)
+	if len(parts) != 1 {
+		return Length{}, false
+	}
+	v := parts[0]
+	if v.Type == css.IdentToken {
+		name := strings.ToLower(v.Value)
+		for _, k := range keywords {
+			if name == k {
+				return Length{keyword: name}, true
+			}
+		}
+		return Length{}, false
+	}
+	if v.Type == css.NumberToken {
+		// Число без единицы — длина только если это 0
+		if v.Number != 0 {
+			return Length{}, false
+		}
+		return Px(0), true
+	}
+	node, ok := parseCalcValue(v, ctx)
+	if !ok || node.typ == numberType {
+		// Выражение, результат которого — число, длиной не является
+		return Length{}, false
+	}
+	return Length{expr: simplify(node)}, true
+}
+
+// parseCalcValue разбирает одно значение выражения: число, размерность,
+// процент или математическую функцию
+func parseCalcValue(v css.ComponentValue, ctx *unitContext) (*calcNode, bool) {
+	switch v.Type {
+	case css.NumberToken:
+		return &calcNode{op: leafOp, value: v.Number, unit: numberUnit, typ: numberType}, true
+	case css.PercentageToken:
+		return &calcNode{op: leafOp, value: v.Number, unit: percentUnit, typ: percentType}, true
+	case css.DimensionToken:
+		px, ok := ctx.toPixels(v.Number, v.Unit)
+		if !ok {
+			return nil, false
+		}
+		return &calcNode{op: leafOp, value: px, unit: pxUnit, typ: lengthType}, true
+	case css.OpenParenToken:
+		return parseCalcSum(v.Children, ctx)
+	case css.FunctionToken:
+		args := splitCommas(v.Children)
+		switch strings.ToLower(v.Value) {
+		case "calc":
+			return parseCalcSum(v.Children, ctx)
+		case "min", "max":
+			op := minOp
+			if strings.EqualFold(v.Value, "max") {
+				op = maxOp
+			}
+			return parseCalcArgs(op, args, ctx)
+		case "clamp":
+			if len(args) != 3 {
+				return nil, false
+			}
+			return parseCalcArgs(clampOp, args, ctx)
+		}
+	}
+	return nil, false
+}
+
+// parseCalcArgs разбирает аргументы min(), max() или clamp(). Аргументы
+// должны быть совместимых типов
+func parseCalcArgs(op calcOp, args [][]css.ComponentValue, ctx *unitContext) (*calcNode, bool) {
+	node := &calcNode{op: op}
+	for i, arg := range args {
+		child, ok := parseCalcSum(arg, ctx)
+		if !ok {
+			return nil, false
+		}
+		if i == 0 {
+			node.typ = child.typ
+		} else if node.typ, ok = addTypes(node.typ, child.typ); !ok {
+			return nil, false
+		}
+		node.args = append(node.args, child)
+	}
+	return node, len(node.args) > 0
+}
+
+// parseCalcSum разбирает сумму: «a + b - c». Знаки + и - обязательно
+// окружены пробелами
+func parseCalcSum(values []css.ComponentValue, ctx *unitContext) (*calcNode, bool) {
+	values = trimSpaces(values)
+	sum := &calcNode{op: sumOp}
+	start, inverted := 0, false
+	for i := 0; i <= len(values); i++ {
+		if i < len(values) && !(values[i].IsDelim("+") || values[i].IsDelim("-")) {
+			continue
+		}
+		if i < len(values) && (i == 0 || values[i-1].Type != css.WhitespaceToken ||
+			i+1 >= len(values) || values[i+1].Type != css.WhitespaceToken) {
+			return nil, false
+		}
+		term, ok := parseCalcProduct(values[start:i], ctx)
+		if !ok {
+			return nil, false
+		}
+		// Складывать можно только значения совместимых типов
+		if len(sum.args) == 0 {
+			sum.typ = term.typ
+		} else if sum.typ, ok = addTypes(sum.typ, term.typ); !ok {
+			return nil, false
+		}
+		sum.args = append(sum.args, term)
+		sum.inverted = append(sum.inverted, inverted)
+		if i < len(values) {
+			inverted = values[i].IsDelim("-")
+			start = i + 1
+		}
+	}
+	if len(sum.args) == 1 {
+		return sum.args[0], true
+	}
+	return sum, true
+}
+
+// parseCalcProduct разбирает произведение: «a * b / c»
+func parseCalcProduct(values []css.ComponentValue, ctx *unitContext) (*calcNode, bool) {
+	parts := fields(values)
+	if len(parts) == 0 || len(parts)%2 == 0 {
+		return nil, false
+	}
+	product := &calcNode{op: productOp, typ: numberType}
+	lengths := 0
+	for i := 0; i < len(parts); i += 2 {
+		inverted := false
+		if i > 0 {
+			switch {
+			case parts[i-1].IsDelim("*"):
+			case parts[i-1].IsDelim("/"):
+				inverted = true
+			default:
+				return nil, false
+			}
+		}
+		factor, ok := parseCalcValue(parts[i], ctx)
+		if !ok {
+			return nil, false
+		}
+		if factor.typ != numberType {
+			// Длину можно умножать только на число, а делить — только на число
+			lengths++
+			if inverted || lengths > 1 {
+				return nil, false
+			}
+			product.typ = factor.typ
+		}
+		product.args = append(product.args, factor)
+		product.inverted = append(product.inverted, inverted)
+	}
+	if len(product.args) == 1 {
+		return product.args[0], true
+	}
+	return product, true
+}
+
+// simplify сворачивает выражение без процентов в одно значение в пикселях.
+// Бесконечный результат и NaN заменяются конечной длиной сразу, чтобы
+// записанное значение совпадало с вычисленным
+func simplify(n *calcNode) *calcNode {
+	if n.op == leafOp || n.hasPercent() {
+		return n
+	}
+	return &calcNode{op: leafOp, value: finite(n.eval(0)), unit: pxUnit, typ: lengthType}
+}
@@ -0,0 +1,491 @@
+package style
+
+import (
+	"math"
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/css"
+)
+
+// DefaultDevice возвращает устройство по умолчанию: экран 800×600 в
+// светлой схеме с плотностью пикселей 1
+func DefaultDevice() Device {
+	return Device{Width: 800, Height: 600, Type: "screen", ColorScheme: "light", Resolution: 1}
+}
+
+// mediaType возвращает тип носителя; по умолчанию screen
+func (d Device) mediaType() string {
+	if d.Type == "" {
+		return "screen"
+	}
+	return strings.ToLower(d.Type)
+}
+
+// colorScheme возвращает цветовую схему; по умолчанию light
+func (d Device) colorScheme() string {
+	if d.ColorScheme == "" {
+		return "light"
+	}
+	return strings.ToLower(d.ColorScheme)
+}
+
+// resolution возвращает плотность пикселей; по умолчанию 1
+func (d Device) resolution() float64 {
+	if d.Resolution <= 0 {
+		return 1
+	}
+	return d.Resolution
+}
+
+// MatchMedia проверяет, подходит ли устройство под список медиазапросов
+// query, как window.matchMedia. Пустой список подходит любому устройству
+func MatchMedia(query string, device Device) bool {
+	return mediaMatches(css.ParseComponentValues(query), device)
+}
+
+// mediaMatches вычисляет список медиазапросов (Media Queries Level 4).
+// Список выполняется, если выполняется хотя бы один запрос. Некорректный
+// запрос считается равным «not all»
+func mediaMatches(prelude []css.ComponentValue, device Device) bool {
+	prelude = trimSpaces(prelude)
+	if len(prelude) == 0 {
+		return true
+	}
+	for _, query := range splitCommas(prelude) {
+		if evalMediaQuery(fields(query), device) == kTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// kleene — значение трехзначной логики медиазапросов: условие с
+// неизвестной характеристикой не истинно и не ложно
+type kleene int
+
+const (
+	kFalse kleene = iota
+	kTrue
+	kUnknown
+)
+
+func toKleene(b bool) kleene {
+	if b {
+		return kTrue
+	}
+	return kFalse
+}
+
+func (k kleene) not() kleene {
+	switch k {
+	case kTrue:
+		return kFalse
+	case kFalse:
+		return kTrue
+	}
+	return kUnknown
+}
+
+// evalMediaQuery вычисляет один запрос: «[not|only] тип [and условие]»
+// или условие без типа. Неизвестное значение в запросе считается ложным
+func evalMediaQuery(parts []css.ComponentValue, device Device) kleene {
+	if len(parts) == 0 {
+		return kFalse
+	}
+	// Запрос без типа носителя начинается со скобки или с «not (...)»
+	if parts[0].Type != css.IdentToken || parts[0].IsIdent("not") && len(parts) > 1 && parts[1].Type != css.IdentToken {
+		return evalMediaCondition(parts, device, true)
+	}
+	negate := false
+	switch {
+	case parts[0].IsIdent("not"):
+		negate = true
+		parts = parts[1:]
+	case parts[0].IsIdent("only"):
+		parts = parts[1:]
+	}
+	if len(parts) == 0 || parts[0].Type != css.IdentToken || isMediaKeyword(parts[0]) {
+		return kFalse
+	}
+	result := toKleene(mediaTypeMatches(parts[0].Value, device))
+	if len(parts) > 1 {
+		// После типа носителя допускаются только условия через and
+		if len(parts) < 3 || !parts[1].IsIdent("and") {
+			return kFalse
+		}
+		result = kleeneAnd(result, evalMediaCondition(parts[2:], device, false))
+	}
+	if result == kUnknown {
+		return kFalse
+	}
+	if negate {
+		return result.not()
+	}
+	return result
+}
+
+// isMediaKeyword проверяет, является ли идентификатор зарезервированным
+// словом медиазапроса, которое не может быть типом носителя
+func isMediaKeyword(v css.ComponentValue) bool {
+	for _, word := range []string{"not", "only", "and", "or", "layer"} {
+		if v.IsIdent(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaTypeMatches проверяет тип носителя. Устаревшие типы (tv, handheld...)
+// не подходят никакому устройству
+func mediaTypeMatches(name string, device Device) bool {
+	switch strings.ToLower(name) {
+	case "all":
+		return true
+	case "screen", "print":
+		return strings.EqualFold(name, device.mediaType())
+	}
+	return false
+}
+
+// evalMediaCondition вычисляет условие: «not A», «A and B and C» или
+// «A or B or C». allowOr — можно ли использовать or и not на верхнем
+// уровне (после типа носителя нельзя)
+func evalMediaCondition(parts []css.ComponentValue, device Device, allowOr bool) kleene {
+	if len(parts) == 0 {
+		return kFalse
+	}
+	if parts[0].IsIdent("not") {
+		if !allowOr || len(parts) != 2 {
+			return kFalse
+		}
+		return evalMediaInParens(parts[1], device).not()
+	}
+	result := evalMediaInParens(parts[0], device)
+	var combinator string
+	for i := 1; i < len(parts); i += 2 {
+		if i+1 >= len(parts) || parts[i].Type != css.IdentToken {
+			return kFalse
+		}
+		op := strings.ToLower(parts[i].Value)
+		if op != "and" && (op != "or" || !allowOr) || combinator != "" && combinator != op {
+			// and и or нельзя смешивать на одном уровне без скобок
+			return kFalse
+		}
+		combinator = op
+		next := evalMediaInParens(parts[i+1], device)
+		if op == "and" {
+			result = kleeneAnd(result, next)
+		} else {
+			result = kleeneOr(result, next)
+		}
+	}
+	return result
+}
+
+func kleeneAnd(a, b kleene) kleene {
+	switch {
+	case a == kFalse || b == kFalse:
+		return kFalse
+	case a == kUnknown || b == kUnknown:
+		return kUnknown
+	}
+	return kTrue
+}
+
+func kleeneOr(a, b kleene) kleene {
+	switch {
+	case a == kTrue || b == kTrue:
+		return kTrue
+	case a == kUnknown || b == kUnknown:
+		return kUnknown
+	}
+	return kFalse
+}
+
+// evalMediaInParens вычисляет условие или характеристику в скобках.
+// Функции и прочие конструкции, которые не удалось разобрать, дают
+// неизвестное значение
+func evalMediaInParens(v css.ComponentValue, device Device) kleene {
+	if v.Type != css.OpenParenToken {
+		return kUnknown
+	}
+	inner := trimSpaces(v.Children)
+	parts := fields(inner)
+	if len(parts) > 0 && (parts[0].Type == css.OpenParenToken || parts[0].IsIdent("not")) {
+		return evalMediaCondition(parts, device, true)
+	}
+	return evalMediaFeature(inner, device)
+}
+
+// featureKind — тип значения характеристики устройства
+type featureKind int
+
+const (
+	// lengthFeature, ratioFeature, resolutionFeature и integerFeature —
+	// характеристики, которые сравниваются как числа и поддерживают
+	// префиксы min- и max- и запись диапазоном
+	lengthFeature featureKind = iota
+	ratioFeature
+	resolutionFeature
+	integerFeature
+	// discreteFeature — характеристика со значением-ключевым словом
+	discreteFeature
+)
+
+// mediaFeature — характеристика устройства, которую можно проверить в
+// медиазапросе
+type mediaFeature struct {
+	kind    featureKind
+	number  func(d Device) float64
+	keyword func(d Device) string
+}
+
+// mediaFeatures — поддерживаемые характеристики устройства. Область
+// просмотра совпадает с экраном устройства
+var mediaFeatures = map[string]mediaFeature{
+	"width":               {kind: lengthFeature, number: func(d Device) float64 { return d.Width }},
+	"height":              {kind: lengthFeature, number: func(d Device) float64 { return d.Height }},
+	"device-width":        {kind: lengthFeature, number: func(d Device) float64 { return d.Width }},
+	"device-height":       {kind: lengthFeature, number: func(d Device) float64 { return d.Height }},
+	"aspect-ratio":        {kind: ratioFeature, number: aspectRatio},
+	"device-aspect-ratio": {kind: ratioFeature, number: aspectRatio},
+	"resolution":          {kind: resolutionFeature, number: Device.resolution},
+	"color":               {kind: integerFeature, number: func(Device) float64 { return 8 }},
+	"color-index":         {kind: integerFeature, number: func(Device) float64 { return 0 }},
+	"monochrome":          {kind: integerFeature, number: func(Device) float64 { return 0 }},
+	"grid":                {kind: integerFeature, number: func(Device) float64 { return 0 }},
+	"orientation": {kind: discreteFeature, keyword: func(d Device) string {
+		if d.Height >= d.Width {
+			return "portrait"
+		}
+		return "landscape"
+	}},
+	"prefers-color-scheme": {kind: discreteFeature, keyword: Device.colorScheme},
+	"prefers-reduced-motion": {kind: discreteFeature, keyword: func(d Device) string {
+		if d.ReducedMotion {
+			return "reduce"
+		}
+		return "no-preference"
+	}},
+	"prefers-contrast":     {kind: discreteFeature, keyword: constant("no-preference")},
+	"prefers-reduced-data": {kind: discreteFeature, keyword: constant("no-preference")},
+	"forced-colors":        {kind: discreteFeature, keyword: constant("none")},
+	"inverted-colors":      {kind: discreteFeature, keyword: constant("none")},
+	"scripting":            {kind: discreteFeature, keyword: constant("enabled")},
+	"display-mode":         {kind: discreteFeature, keyword: constant("browser")},
+	"dynamic-range":        {kind: discreteFeature, keyword: constant("standard")},
+	"video-dynamic-range":  {kind: discreteFeature, keyword: constant("standard")},
+	"color-gamut":          {kind: discreteFeature, keyword: constant("srgb")},
+	"scan":                 {kind: discreteFeature, keyword: constant("progressive")},
+	"update":               {kind: discreteFeature, keyword: whenScreen("fast", "none")},
+	"hover":                {kind: discreteFeature, keyword: whenScreen("hover", "none")},
+	"any-hover":            {kind: discreteFeature, keyword: whenScreen("hover", "none")},
+	"pointer":              {kind: discreteFeature, keyword: whenScreen("fine", "none")},
+	"any-pointer":          {kind: discreteFeature, keyword: whenScreen("fine", "none")},
+	"overflow-block":       {kind: discreteFeature, keyword: whenScreen("scroll", "paged")},
+	"overflow-inline":      {kind: discreteFeature, keyword: whenScreen("scroll", "none")},
+}
+
+func aspectRatio(d Device) float64 {
+	if d.Height == 0 {
+		return math.Inf(1)
+	}
+	return d.Width / d.Height
+}
+
+func constant(value string) func(Device) string {
+	return func(Device) string { return value }
+}
+
+// whenScreen возвращает значение характеристики для экрана и для печати
+func whenScreen(screen, print string) func(Device) string {
+	return func(d Device) string {
+		if d.mediaType() == "print" {
+			return print
+		}
+		return screen
+	}
+}
+
+// evalMediaFeature вычисляет характеристику в скобках: «(имя)», «(имя:
+// значение)», «(min-имя: значение)» или диапазон «(400px <= width < 800px)»
+func evalMediaFeature(inner []css.ComponentValue, device Device) kleene {
+	parts := fields(inner)
+	if len(parts) == 0 {
+		return kUnknown
+	}
+	if parts[0].Type == css.IdentToken {
+		name := strings.ToLower(parts[0].Value)
+		if len(parts) == 1 {
+			return evalBooleanFeature(name, device)
+		}
+		if parts[1].Type == css.ColonToken {
+			return evalPlainFeature(name, trimSpaces(restAfter(inner, parts, 2)), device)
+		}
+	}
+	return evalRangeFeature(inner, device)
+}
+
+// evalBooleanFeature вычисляет «(имя)»: характеристика истинна, если ее
+// значение не равно 0, none или no-preference
+func evalBooleanFeature(name string, device Device) kleene {
+	feature, ok := mediaFeatures[name]
+	if !ok {
+		return kUnknown
+	}
+	if feature.kind == discreteFeature {
+		value := feature.keyword(device)
+		return toKleene(value != "none" && value != "no-preference")
+	}
+	return toKleene(feature.number(device) != 0)
+}
+
+// evalPlainFeature вычисляет «(имя: значение)» с префиксами min- и max-
+func evalPlainFeature(name string, value []css.ComponentValue, device Device) kleene {
+	cmp := "="
+	switch {
+	case strings.HasPrefix(name, "min-"):
+		cmp, name = ">=", name[4:]
+	case strings.HasPrefix(name, "max-"):
+		cmp, name = "<=", name[4:]
+	}
+	feature, ok := mediaFeatures[name]
+	if !ok || feature.kind == discreteFeature && cmp != "=" {
+		return kUnknown
+	}
+	if feature.kind == discreteFeature {
+		parts := fields(value)
+		if len(parts) != 1 || parts[0].Type != css.IdentToken {
+			return kUnknown
+		}
+		return toKleene(strings.EqualFold(parts[0].Value, feature.keyword(device)))
+	}
+	n, ok := featureValue(feature.kind, value, device)
+	if !ok {
+		return kUnknown
+	}
+	return toKleene(compare(feature.number(device), cmp, n))
+}
+
+// evalRangeFeature вычисляет запись диапазоном: «имя op значение»,
+// «значение op имя» или «значение op имя op значение»
+func evalRangeFeature(inner []css.ComponentValue, device Device) kleene {
+	// Делим на операнды и операторы <, >, =, <=, >=
+	var operands [][]css.ComponentValue
+	var operators []string
+	var current []css.ComponentValue
+	for i := 0; i < len(inner); i++ {
+		v := inner[i]
+		if !(v.IsDelim("<") || v.IsDelim(">") || v.IsDelim("=")) {
+			current = append(current, v)
+			continue
+		}
+		op := v.Value
+		if op != "=" && i+1 < len(inner) && inner[i+1].IsDelim("=") {
+			op += "="
+			i++
+		}
+		operands = append(operands, trimSpaces(current))
+		operators = append(operators, op)
+		current = nil
+	}
+	operands = append(operands, trimSpaces(current))
+
+	featureAt := func(i int) (mediaFeature, bool) {
+		parts := fields(operands[i])
+		if len(parts) != 1 || parts[0].Type != css.IdentToken {
+			return mediaFeature{}, false
+		}
+		feature, ok := mediaFeatures[strings.ToLower(parts[0].Value)]
+		return feature, ok && feature.kind != discreteFeature
+	}
+	switch len(operators) {
+	case 1:
+		if feature, ok := featureAt(0); ok {
+			n, ok := featureValue(feature.kind, operands[1], device)
+			if !ok {
+				return kUnknown
+			}
+			return toKleene(compare(feature.number(device), operators[0], n))
+		}
+		if feature, ok := featureAt(1); ok {
+			n, ok := featureValue(feature.kind, operands[0], device)
+			if !ok {
+				return kUnknown
+			}
+			return toKleene(compare(n, operators[0], feature.number(device)))
+		}
+	case 2:
+		feature, ok := featureAt(1)
+		// Оба оператора должны быть направлены в одну сторону
+		if !ok || operators[0][0] != operators[1][0] || operators[0][0] == '=' {
+			return kUnknown
+		}
+		low, ok1 := featureValue(feature.kind, operands[0], device)
+		high, ok2 := featureValue(feature.kind, operands[2], device)
+		if !ok1 || !ok2 {
+			return kUnknown
+		}
+		value := feature.number(device)
+		return toKleene(compare(low, operators[0], value) && compare(value, operators[1], high))
+	}
+	return kUnknown
+}
+
+// compare сравнивает числа оператором диапазона
+func compare(a float64, op string, b float64) bool {
+	const epsilon = 1e-9
+	switch op {
+	case "<":
+		return a < b-epsilon
+	case "<=":
+		return a <= b+epsilon
+	case ">":
+		return a > b+epsilon
+	case ">=":
+		return a >= b-epsilon
+	}
+	return math.Abs(a-b) <= epsilon
+}
+
+// featureValue разбирает значение числовой характеристики. em и rem в
+// медиазапросах считаются от начального размера шрифта
+func featureValue(kind featureKind, value []css.ComponentValue, device Device) (float64, bool) {
+	parts := fields(value)
+	switch kind {
+	case lengthFeature:
+		ctx := &unitContext{fontSize: 16, rootFontSize: 16, device: device}
+		l, ok := parseLength(value, ctx)
+		if !ok || l.HasPercent() {
+			return 0, false
+		}
+		return l.Resolve(0), true
+	case ratioFeature:
+		// «ширина / высота» или одно число
+		if len(parts) == 1 && parts[0].Type == css.NumberToken {
+			return parts[0].Number, true
+		}
+		if len(parts) == 3 && parts[0].Type == css.NumberToken && parts[1].IsDelim("/") && parts[2].Type == css.NumberToken {
+			if parts[2].Number == 0 {
+				return math.Inf(1), true
+			}
+			return parts[0].Number / parts[2].Number, true
+		}
+	case resolutionFeature:
+		if len(parts) == 1 && parts[0].Type == css.DimensionToken {
+			switch strings.ToLower(parts[0].Unit) {
+			case "dppx", "x":
+				return parts[0].Number, true
+			case "dpi":
+				return parts[0].Number / 96, true
+			case "dpcm":
+				return parts[0].Number * 2.54 / 96, true
+			}
+		}
+	case integerFeature:
+		if len(parts) == 1 && parts[0].Type == css.NumberToken && parts[0].Integer {
+			return parts[0].Number, true
+		}
+	}
+	return 0, false
+}
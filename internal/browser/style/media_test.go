@@ -0,0 +1,102 @@
+package style
+
+import "testing"
+
+func TestMatchMedia(t *testing.T) {
+	screen := DefaultDevice()
+	print := screen
+	print.Type = "print"
+	dense := screen
+	dense.Resolution = 2
+	dense.ColorScheme = "dark"
+	tests := []struct {
+		query  string
+		device Device
+		want   bool
+	}{
+		// Типы носителей и списки запросов
+		{"", screen, true},
+		{"all", screen, true},
+		{"screen", screen, true},
+		{"PRINT", print, true},
+		{"print", screen, false},
+		{"not print", screen, true},
+		{"only screen and (color)", screen, true},
+		{"not screen and (color)", screen, false},
+		{"print, (width: 800px)", screen, true},
+		{"print, garbage!", screen, false},
+		{"tv", screen, false},
+		{"not tv", screen, true},
+
+		// Некорректные запросы равны «not all»
+		{"and", screen, false},
+		{"screen and", screen, false},
+		{"screen or (color)", screen, false},
+		{"only", screen, false},
+		{"not", screen, false},
+		{"(color) and (width) or (height)", screen, false},
+		{"screen and (color) or (height)", screen, false},
+
+		// Характеристики с префиксами min- и max-
+		{"(min-width: 800px)", screen, true},
+		{"(min-width: 800.5px)", screen, false},
+		{"(max-width: 50em)", screen, true},
+		{"(max-width: 49em)", screen, false},
+		{"(width: 800px)", screen, true},
+		{"(min-aspect-ratio: 4/3)", screen, true},
+		{"(aspect-ratio: 4 / 3)", screen, true},
+		{"(min-resolution: 2dppx)", dense, true},
+		{"(min-resolution: 192dpi)", screen, false},
+		{"(min-orientation: portrait)", screen, false},
+
+		// Запись диапазоном
+		{"(width >= 800px)", screen, true},
+		{"(width > 800px)", screen, false},
+		{"(width < 800.5px)", screen, true},
+		{"(800px = width)", screen, true},
+		{"(50em > width)", screen, false},
+		{"(50em >= width)", screen, true},
+		{"(400px <= width < 801px)", screen, true},
+		{"(400px < width < 800px)", screen, false},
+		{"(1000px > width >= 500px)", screen, true},
+		{"(400px < width > 100px)", screen, false},
+		{"(400px = width = 800px)", screen, false},
+		{"(width >= 10%)", screen, false},
+		{"(height > 500px) and (height <= 600px)", screen, true},
+		{"(aspect-ratio > 1)", screen, true},
+		{"(resolution >= 2x)", dense, true},
+		{"(color > 4)", screen, true},
+
+		// Дискретные и логические характеристики
+		{"(color)", screen, true},
+		{"(monochrome)", screen, false},
+		{"(hover)", screen, true},
+		{"(hover)", print, false},
+		{"(orientation: landscape)", screen, true},
+		{"(prefers-color-scheme: dark)", dense, true},
+		{"(prefers-color-scheme: dark)", screen, false},
+		{"(prefers-reduced-motion)", screen, false},
+
+		// Неизвестные характеристики дают неизвестное значение: отрицание
+		// его не меняет, и запрос в целом ложен
+		{"(unknown)", screen, false},
+		{"not (unknown)", screen, false},
+		{"not (unknown: 1)", screen, false},
+		{"not (width: bogus)", screen, false},
+		{"not (width < 0px)", screen, true},
+		{"(unknown) or (width)", screen, true},
+		{"(unknown) and (width)", screen, false},
+		{"not ((unknown) or (width > 1000px))", screen, false},
+		{"not ((unknown) and (width > 1000px))", screen, true},
+		{"screen and (unknown)", screen, false},
+		{"not screen and (unknown)", screen, false},
+		{"(not (unknown)) or (color)", screen, true},
+		{"f(x)", screen, false},
+		{"not f(x)", screen, false},
+	}
+	for _, test := range tests {
+		if got := MatchMedia(test.query, test.device); got != test.want {
+			t.Errorf("%q на %s: получено %v, ожидалось %v", test.query, test.device.Type, got, test.want)
+		}
+	}
+}
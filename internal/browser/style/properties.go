@@ -0,0 +1,129 @@
+package style
+
+// property описывает свойство CSS: наследуется ли оно и его начальное значение
+type property struct {
+	inherited bool
+	initial   string
+}
+
+// properties — свойства, которые поддерживает система стилей. Объявления
+// других свойств при каскаде отбрасываются, как неизвестные браузеру
+var properties = map[string]property{
+	// Текст и шрифт
+	"color":           {true, "#000000"},
+	"font-family":     {true, "serif"},
+	"font-size":       {true, "medium"},
+	"font-style":      {true, "normal"},
+	"font-weight":     {true, "normal"},
+	"font-variant":    {true, "normal"},
+	"font-stretch":    {true, "normal"},
+	"line-height":     {true, "normal"},
+	"text-align":      {true, "start"},
+	"text-indent":     {true, "0"},
+	"text-transform":  {true, "none"},
+	"letter-spacing":  {true, "normal"},
+	"word-spacing":    {true, "normal"},
+	"white-space":     {true, "normal"},
+	"word-break":      {true, "normal"},
+	"overflow-wrap":   {true, "normal"},
+	"direction":       {true, "ltr"},
+	"visibility":      {true, "visible"},
+	"cursor":          {true, "auto"},
+	"quotes":          {true, "auto"},
+	"text-decoration": {false, "none"},
+	"vertical-align":  {false, "baseline"},
+	"unicode-bidi":    {false, "normal"},
+	"text-overflow":   {false, "clip"},
+
+	// Списки и таблицы
+	"list-style-type":     {true, "disc"},
+	"list-style-position": {true, "outside"},
+	"list-style-image":    {true, "none"},
+	"border-collapse":     {true, "separate"},
+	"border-spacing":      {true, "0"},
+	"caption-side":        {true, "top"},
+	"empty-cells":         {true, "show"},
+	"table-layout":        {false, "auto"},
+
+	// Блоковая модель
+	"display":        {false, "inline"},
+	"box-sizing":     {false, "content-box"},
+	"width":          {false, "auto"},
+	"height":         {false, "auto"},
+	"min-width":      {false, "auto"},
+	"min-height":     {false, "auto"},
+	"max-width":      {false, "none"},
+	"max-height":     {false, "none"},
+	"margin-top":     {false, "0"},
+	"margin-right":   {false, "0"},
+	"margin-bottom":  {false, "0"},
+	"margin-left":    {false, "0"},
+	"padding-top":    {false, "0"},
+	"padding-right":  {false, "0"},
+	"padding-bottom": {false, "0"},
+	"padding-left":   {false, "0"},
+
+	"border-top-width":    {false, "medium"},
+	"border-right-width":  {false, "medium"},
+	"border-bottom-width": {false, "medium"},
+	"border-left-width":   {false, "medium"},
+	"border-top-style":    {false, "none"},
+	"border-right-style":  {false, "none"},
+	"border-bottom-style": {false, "none"},
+	"border-left-style":   {false, "none"},
+	"border-top-color":    {false, "currentcolor"},
+	"border-right-color":  {false, "currentcolor"},
+	"border-bottom-color": {false, "currentcolor"},
+	"border-left-color":   {false, "currentcolor"},
+
+	"outline-width": {false, "medium"},
+	"outline-style": {false, "none"},
+	"outline-color": {false, "currentcolor"},
+
+	// Позиционирование
+	"position":   {false, "static"},
+	"top":        {false, "auto"},
+	"right":      {false, "auto"},
+	"bottom":     {false, "auto"},
+	"left":       {false, "auto"},
+	"float":      {false, "none"},
+	"clear":      {false, "none"},
+	"z-index":    {false, "auto"},
+	"overflow-x": {false, "visible"},
+	"overflow-y": {false, "visible"},
+
+	// Фон и эффекты
+	"background-color":    {false, "transparent"},
+	"background-image":    {false, "none"},
+	"background-repeat":   {false, "repeat"},
+	"background-position": {false, "0% 0%"},
+	"background-size":     {false, "auto"},
+	"opacity":             {false, "1"},
+	"box-shadow":          {false, "none"},
+	"transform":           {false, "none"},
+	"content":             {false, "normal"},
+
+	// Гибкая разметка
+	"flex-direction":  {false, "row"},
+	"flex-wrap":       {false, "nowrap"},
+	"flex-grow":       {false, "0"},
+	"flex-shrink":     {false, "1"},
+	"flex-basis":      {false, "auto"},
+	"justify-content": {false, "normal"},
+	"align-items":     {false, "normal"},
+	"align-self":      {false, "auto"},
+	"order":           {false, "0"},
+	"gap":             {false, "normal"},
+}
+
+// IsKnownProperty проверяет, поддерживает ли система стилей свойство,
+// сокращенную запись или пользовательское свойство name
+func IsKnownProperty(name string) bool {
+	_, ok := properties[name]
+	return ok || shorthands[name] != nil || isCustomPropertyName(name)
+}
+
+// IsInherited проверяет, наследуется ли свойство
+func IsInherited(name string) bool {
+	return properties[name].inherited
+}
@@ -0,0 +1,506 @@
+package style
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/css"
+)
+
+// expander разворачивает значение сокращенной записи в значения свойств.
+// ok == false означает некорректное значение
+type expander func(value []css.ComponentValue) (longhands map[string][]css.ComponentValue, ok bool)
+
+// shorthands — поддерживаемые сокращенные записи
+var shorthands map[string]expander
+
+func init() {
+	shorthands = map[string]expander{
+		"margin":               boxSides("margin-%s"),
+		"padding":              boxSides("padding-%s"),
+		"border-width":         boxSides("border-%s-width"),
+		"border-style":         boxSides("border-%s-style"),
+		"border-color":         boxSides("border-%s-color"),
+		"inset":                boxSides("%s"),
+		"border":               border("top", "right", "bottom", "left"),
+		"border-top":           border("top"),
+		"border-right":         border("right"),
+		"border-bottom":        border("bottom"),
+		"border-left":          border("left"),
+		"outline":              outline,
+		"overflow":             pair("overflow-x", "overflow-y"),
+		"font":                 font,
+		"background":           background,
+		"list-style":           listStyle,
+		"flex":                 flex,
+		"flex-flow":            flexFlow,
+		"text-decoration-line": alias("text-decoration"),
+
+		// Логические свойства при горизонтальном письме слева направо
+		"margin-block":         pair("margin-top", "margin-bottom"),
+		"margin-inline":        pair("margin-left", "margin-right"),
+		"padding-block":        pair("padding-top", "padding-bottom"),
+		"padding-inline":       pair("padding-left", "padding-right"),
+		"margin-block-start":   alias("margin-top"),
+		"margin-block-end":     alias("margin-bottom"),
+		"margin-inline-start":  alias("margin-left"),
+		"margin-inline-end":    alias("margin-right"),
+		"padding-block-start":  alias("padding-top"),
+		"padding-block-end":    alias("padding-bottom"),
+		"padding-inline-start": alias("padding-left"),
+		"padding-inline-end":   alias("padding-right"),
+		"inline-size":          alias("width"),
+		"block-size":           alias("height"),
+		"min-inline-size":      alias("min-width"),
+		"min-block-size":       alias("min-height"),
+		"max-inline-size":      alias("max-width"),
+		"max-block-size":       alias("max-height"),
+	}
+}
+
+// sides — стороны блока в порядке записи сокращений
+var sides = []string{"top", "right", "bottom", "left"}
+
+// fields разбивает значение на части, разделенные пробелами
+func fields(value []css.ComponentValue) []css.ComponentValue {
+	var parts []css.ComponentValue
+	for _, v := range value {
+		if v.Type != css.WhitespaceToken {
+			parts = append(parts, v)
+		}
+	}
+	return parts
+}
+
+// single возвращает значение из одной части
+func single(v css.ComponentValue) []css.ComponentValue {
+	return []css.ComponentValue{v}
+}
+
+// keyword создает значение-идентификатор
+func keyword(name string) []css.ComponentValue {
+	return single(css.ComponentValue{Token: css.Token{Type: css.IdentToken, Value: name}})
+}
+
+// boxSides разворачивает запись из 1–4 значений для сторон блока (margin,
+// padding, border-width...). pattern содержит %s на месте стороны
+func boxSides(pattern string) expander {
+	return func(value []css.ComponentValue) (map[string][]css.ComponentValue, bool) {
+		parts := fields(value)
+		if len(parts) == 0 || len(parts) > 4 {
+			return nil, false
+		}
+		// Недостающие стороны берутся с противоположной: right = top,
+		// bottom = top, left = right
+		index := [][]int{{0, 0, 0, 0}, {0, 1, 0, 1}, {0, 1, 2, 1}, {0, 1, 2, 3}}[len(parts)-1]
+		longhands := make(map[string][]css.ComponentValue, 4)
+		for i, side := range sides {
+			longhands[strings.Replace(pattern, "%s", side, 1)] = single(parts[index[i]])
+		}
+		return longhands, true
+	}
+}
+
+// pair разворачивает запись из 1–2 значений для начала и конца направления
+func pair(start, end string) expander {
+	return func(value []css.ComponentValue) (map[string][]css.ComponentValue, bool) {
+		parts := fields(value)
+		switch len(parts) {
+		case 1:
+			return map[string][]css.ComponentValue{start: single(parts[0]), end: single(parts[0])}, true
+		case 2:
+			return map[string][]css.ComponentValue{start: single(parts[0]), end: single(parts[1])}, true
+		}
+		return nil, false
+	}
+}
+
+// alias переносит значение в другое свойство
+func alias(name string) expander {
+	return func(value []css.ComponentValue) (map[string][]css.ComponentValue, bool) {
+		return map[string][]css.ComponentValue{name: value}, true
+	}
+}
+
+// borderStyles — ключевые слова border-style
+var borderStyles = map[string]bool{
+	"none": true, "hidden": true, "dotted": true, "dashed": true, "solid": true,
+	"double": true, "groove": true, "ridge": true, "inset": true, "outset": true,
+}
+
+// isLineWidth проверяет, является ли часть толщиной линии
+func isLineWidth(v css.ComponentValue) bool {
+	switch v.Type {
+	case css.DimensionToken:
+		return true
+	case css.NumberToken:
+		return v.Number == 0
+	case css.IdentToken:
+		switch strings.ToLower(v.Value) {
+		case "thin", "medium", "thick":
+			return true
+		}
+	case css.FunctionToken:
+		return isMathFunction(v)
+	}
+	return false
+}
+
+// isMathFunction проверяет, является ли часть математической функцией
+func isMathFunction(v css.ComponentValue) bool {
+	return v.IsFunction("calc") || v.IsFunction("min") || v.IsFunction("max") || v.IsFunction("clamp")
+}
+
+// splitLine разбирает значение вида «толщина || стиль || цвет» (border, outline)
+func splitLine(value []css.ComponentValue) (width, lineStyle, color []css.ComponentValue, ok bool) {
+	parts := fields(value)
+	if len(parts) == 0 || len(parts) > 3 {
+		return nil, nil, nil, false
+	}
+	for _, v := range parts {
+		switch {
+		case width == nil && isLineWidth(v):
+			width = single(v)
+		case lineStyle == nil && v.Type == css.IdentToken && borderStyles[strings.ToLower(v.Value)]:
+			lineStyle = single(v)
+		case color == nil && css.IsColor(single(v)):
+			color = single(v)
+		default:
+			return nil, nil, nil, false
+		}
+	}
+	return width, lineStyle, color, true
+}
+
+// border разворачивает border и border-<сторона>. Опущенные части получают
+// начальные значения
+func border(sides ...string) expander {
+	return func(value []css.ComponentValue) (map[string][]css.ComponentValue, bool) {
+		width, lineStyle, color, ok := splitLine(value)
+		if !ok {
+			return nil, false
+		}
+		longhands := make(map[string][]css.ComponentValue)
+		for _, side := range sides {
+			longhands["border-"+side+"-width"] = orInitial(width, "border-"+side+"-width")
+			longhands["border-"+side+"-style"] = orInitial(lineStyle, "border-"+side+"-style")
+			longhands["border-"+side+"-color"] = orInitial(color, "border-"+side+"-color")
+		}
+		return longhands, true
+	}
+}
+
+// outline разворачивает outline
+func outline(value []css.ComponentValue) (map[string][]css.ComponentValue, bool) {
+	width, lineStyle, color, ok := splitLine(value)
+	if !ok {
+		return nil, false
+	}
+	return map[string][]css.ComponentValue{
+		"outline-width": orInitial(width, "outline-width"),
+		"outline-style": orInitial(lineStyle, "outline-style"),
+		"outline-color": orInitial(color, "outline-color"),
+	}, true
+}
+
+// orInitial возвращает значение или начальное значение свойства, если оно опущено
+func orInitial(value []css.ComponentValue, name string) []css.ComponentValue {
+	if value != nil {
+		return value
+	}
+	return initialValue(name)
+}
+
+// font разворачивает font: [стиль || вариант || насыщенность || ширина]?
+// размер [/ интерлиньяж]? семейство
+func font(value []css.ComponentValue) (map[string][]css.ComponentValue, bool) {
+	longhands := map[string][]css.ComponentValue{
+		"font-style":   initialValue("font-style"),
+		"font-variant": initialValue("font-variant"),
+		"font-weight":  initialValue("font-weight"),
+		"font-stretch": initialValue("font-stretch"),
+		"line-height":  initialValue("line-height"),
+	}
+	parts := fields(value)
+	i := 0
+	for ; i < len(parts); i++ {
+		v := parts[i]
+		if isFontSize(v) {
+			break
+		}
+		if v.Type == css.NumberToken && v.Number >= 1 && v.Number <= 1000 {
+			longhands["font-weight"] = single(v)
+			continue
+		}
+		if v.Type != css.IdentToken {
+			return nil, false
+		}
+		switch name := strings.ToLower(v.Value); name {
+		case "normal":
+		case "italic", "oblique":
+			longhands["font-style"] = single(v)
+		case "small-caps":
+			longhands["font-variant"] = single(v)
+		case "bold", "bolder", "lighter":
+			longhands["font-weight"] = single(v)
+		case "ultra-condensed", "extra-condensed", "condensed", "semi-condensed",
+			"semi-expanded", "expanded", "extra-expanded", "ultra-expanded":
+			longhands["font-stretch"] = single(v)
+		default:
+			return nil, false
+		}
+	}
+	if i >= len(parts) {
+		return nil, false
+	}
+	longhands["font-size"] = single(parts[i])
+	i++
+	if i < len(parts) && parts[i].IsDelim("/") {
+		if i+1 >= len(parts) {
+			return nil, false
+		}
+		longhands["line-height"] = single(parts[i+1])
+		i += 2
+	}
+	// Семейство — весь остаток значения вместе с запятыми и пробелами
+	family := restAfter(value, parts, i)
+	if len(family) == 0 {
+		return nil, false
+	}
+	longhands["font-family"] = family
+	return longhands, true
+}
+
+// isFontSize проверяет, является ли часть размером шрифта
+func isFontSize(v css.ComponentValue) bool {
+	switch v.Type {
+	case css.DimensionToken, css.PercentageToken:
+		return true
+	case css.IdentToken:
+		name := strings.ToLower(v.Value)
+		_, ok := fontSizeKeywords[name]
+		return ok || name == "larger" || name == "smaller"
+	case css.FunctionToken:
+		return isMathFunction(v)
+	}
+	return false
+}
+
+// restAfter возвращает часть исходного значения, начиная с части parts[i]
+func restAfter(value, parts []css.ComponentValue, i int) []css.ComponentValue {
+	if i >= len(parts) {
+		return nil
+	}
+	seen := 0
+	for j, v := range value {
+		if v.Type == css.WhitespaceToken {
+			continue
+		}
+		if seen == i {
+			return value[j:]
+		}
+		seen++
+	}
+	return nil
+}
+
+// background разворачивает background в цвет и изображение фона. Цвет
+// фона можно указать только в последнем слое
+func background(value []css.ComponentValue) (map[string][]css.ComponentValue, bool) {
+	longhands := map[string][]css.ComponentValue{
+		"background-color":    initialValue("background-color"),
+		"background-image":    initialValue("background-image"),
+		"background-repeat":   initialValue("background-repeat"),
+		"background-position": initialValue("background-position"),
+		"background-size":     initialValue("background-size"),
+	}
+	layers := splitCommas(value)
+	last := fields(layers[len(layers)-1])
+	var position []css.ComponentValue
+	for i := 0; i < len(last); i++ {
+		v := last[i]
+		switch {
+		case v.Type == css.URLToken || v.IsFunction("url") || isGradient(v):
+			longhands["background-image"] = single(v)
+		case v.IsIdent("none"):
+			longhands["background-image"] = single(v)
+		case v.Type == css.IdentToken && backgroundRepeats[strings.ToLower(v.Value)]:
+			longhands["background-repeat"] = single(v)
+		case v.IsDelim("/"):
+			// Размер фона следует за позицией через «/»
+			if i+1 < len(last) {
+				longhands["background-size"] = single(last[i+1])
+				i++
+			}
+		case isPosition(v):
+			position = append(position, v)
+		case v.Type == css.IdentToken && backgroundKeywords[strings.ToLower(v.Value)]:
+		case css.IsColor(single(v)):
+			longhands["background-color"] = single(v)
+		default:
+			return nil, false
+		}
+	}
+	if position != nil {
+		longhands["background-position"] = joinSpaces(position)
+	}
+	return longhands, true
+}
+
+// backgroundRepeats — ключевые слова background-repeat
+var backgroundRepeats = map[string]bool{
+	"repeat": true, "repeat-x": true, "repeat-y": true, "no-repeat": true,
+	"space": true, "round": true,
+}
+
+// backgroundKeywords — прочие ключевые слова background, которые не являются цветом
+var backgroundKeywords = map[string]bool{
+	"scroll": true, "fixed": true, "local": true, "border-box": true,
+	"padding-box": true, "content-box": true, "text": true, "cover": true,
+	"contain": true, "auto": true,
+}
+
+// isGradient проверяет, является ли часть градиентом
+func isGradient(v css.ComponentValue) bool {
+	return v.Type == css.FunctionToken && strings.HasSuffix(strings.ToLower(v.Value), "gradient")
+}
+
+// isPosition проверяет, является ли часть координатой позиции фона
+func isPosition(v css.ComponentValue) bool {
+	switch v.Type {
+	case css.DimensionToken, css.PercentageToken:
+		return true
+	case css.NumberToken:
+		return v.Number == 0
+	case css.IdentToken:
+		switch strings.ToLower(v.Value) {
+		case "left", "right", "top", "bottom", "center":
+			return true
+		}
+	case css.FunctionToken:
+		return isMathFunction(v)
+	}
+	return false
+}
+
+// joinSpaces соединяет части значения пробелами
+func joinSpaces(parts []css.ComponentValue) []css.ComponentValue {
+	var value []css.ComponentValue
+	for i, v := range parts {
+		if i > 0 {
+			value = append(value, css.ComponentValue{Token: css.Token{Type: css.WhitespaceToken}})
+		}
+		value = append(value, v)
+	}
+	return value
+}
+
+// splitCommas разбивает значение по запятым верхнего уровня
+func splitCommas(value []css.ComponentValue) [][]css.ComponentValue {
+	var parts [][]css.ComponentValue
+	start := 0
+	for i, v := range value {
+		if v.Type == css.CommaToken {
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// listStylePositions — ключевые слова list-style-position
+var listStylePositions = map[string]bool{"inside": true, "outside": true}
+
+// listStyle разворачивает list-style
+func listStyle(value []css.ComponentValue) (map[string][]css.ComponentValue, bool) {
+	longhands := map[string][]css.ComponentValue{
+		"list-style-type":     initialValue("list-style-type"),
+		"list-style-position": initialValue("list-style-position"),
+		"list-style-image":    initialValue("list-style-image"),
+	}
+	parts := fields(value)
+	if len(parts) == 0 || len(parts) > 3 {
+		return nil, false
+	}
+	nones, typeSet := 0, false
+	for _, v := range parts {
+		switch {
+		case v.IsIdent("none"):
+			nones++
+		case v.Type == css.IdentToken && listStylePositions[strings.ToLower(v.Value)]:
+			longhands["list-style-position"] = single(v)
+		case v.Type == css.URLToken || v.IsFunction("url") || isGradient(v):
+			longhands["list-style-image"] = single(v)
+		default:
+			longhands["list-style-type"] = single(v)
+			typeSet = true
+		}
+	}
+	// «none» относится к типу маркера, если он не задан явно (изображение
+	// и так по умолчанию none)
+	if nones > 0 && !typeSet {
+		longhands["list-style-type"] = keyword("none")
+	}
+	return longhands, true
+}
+
+// flex разворачивает flex: none | [grow shrink? || basis]
+func flex(value []css.ComponentValue) (map[string][]css.ComponentValue, bool) {
+	parts := fields(value)
+	if len(parts) == 0 || len(parts) > 3 {
+		return nil, false
+	}
+	number := func(n float64) []css.ComponentValue {
+		return single(css.ComponentValue{Token: css.Token{
+			Type: css.NumberToken, Number: n, Repr: strconv.FormatFloat(n, 'f', -1, 64), Integer: true,
+		}})
+	}
+	if len(parts) == 1 && parts[0].IsIdent("none") {
+		return map[string][]css.ComponentValue{
+			"flex-grow": number(0), "flex-shrink": number(0), "flex-basis": keyword("auto"),
+		}, true
+	}
+	if len(parts) == 1 && parts[0].IsIdent("auto") {
+		return map[string][]css.ComponentValue{
+			"flex-grow": number(1), "flex-shrink": number(1), "flex-basis": keyword("auto"),
+		}, true
+	}
+	// Если basis опущен, он равен 0
+	longhands := map[string][]css.ComponentValue{
+		"flex-grow": number(1), "flex-shrink": number(1),
+		"flex-basis": single(css.ComponentValue{Token: css.Token{Type: css.PercentageToken, Repr: "0", Integer: true}}),
+	}
+	numbers := 0
+	for _, v := range parts {
+		if v.Type == css.NumberToken && numbers < 2 {
+			if numbers == 0 {
+				longhands["flex-grow"] = single(v)
+			} else {
+				longhands["flex-shrink"] = single(v)
+			}
+			numbers++
+			continue
+		}
+		longhands["flex-basis"] = single(v)
+	}
+	return longhands, true
+}
+
+// flexFlow разворачивает flex-flow
+func flexFlow(value []css.ComponentValue) (map[string][]css.ComponentValue, bool) {
+	longhands := map[string][]css.ComponentValue{
+		"flex-direction": initialValue("flex-direction"),
+		"flex-wrap":      initialValue("flex-wrap"),
+	}
+	for _, v := range fields(value) {
+		switch strings.ToLower(v.Value) {
+		case "row", "row-reverse", "column", "column-reverse":
+			longhands["flex-direction"] = single(v)
+		case "nowrap", "wrap", "wrap-reverse":
+			longhands["flex-wrap"] = single(v)
+		default:
+			return nil, false
+		}
+	}
+	return longhands, true
+}
@@ -0,0 +1,25 @@
+package style
+
+import (
+	_ "embed"
+	"sync"
+
+	"github.com/baneronetwo/gluglu/internal/browser/css"
+)
+
+//go:embed useragent.css
+var userAgentCSS string
+
+var (
+	userAgentOnce  sync.Once
+	userAgentSheet *css.Stylesheet
+)
+
+// userAgentStylesheet возвращает разобранную встроенную таблицу стилей.
+// Она разбирается один раз при первом обращении
+func userAgentStylesheet() *css.Stylesheet {
+	userAgentOnce.Do(func() {
+		userAgentSheet = css.ParseStylesheet(userAgentCSS)
+	})
+	return userAgentSheet
+}
@@ -0,0 +1,254 @@
+package style
+
+import (
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/css"
+)
+
+// validator проверяет значение свойства по его грамматике
+type validator func(value []css.ComponentValue) bool
+
+// validators — проверки значений свойств. Объявление с некорректным
+// значением отбрасывается при разборе, и в каскаде участвуют предыдущие
+// объявления того же свойства. Свойства без проверки принимают любое
+// непустое значение
+var validators map[string]validator
+
+func init() {
+	validators = map[string]validator{
+		"color":       isColor,
+		"font-size":   isFontSizeValue,
+		"font-style":  isFontStyle,
+		"font-weight": isFontWeight,
+		"line-height": isLineHeight,
+		"text-align":  oneOf("start", "end", "left", "right", "center", "justify", "match-parent"),
+		"white-space": oneOf("normal", "pre", "nowrap", "pre-wrap", "pre-line", "break-spaces"),
+		"word-break":  oneOf("normal", "break-all", "keep-all", "break-word"),
+		"direction":   oneOf("ltr", "rtl"),
+		"visibility":  oneOf("visible", "hidden", "collapse"),
+		"vertical-align": func(value []css.ComponentValue) bool {
+			return isLength(value, "baseline", "sub", "super", "text-top", "text-bottom", "middle", "top", "bottom")
+		},
+		"overflow-wrap": oneOf("normal", "break-word", "anywhere"),
+		"unicode-bidi":  oneOf("normal", "embed", "isolate", "bidi-override", "isolate-override", "plaintext"),
+
+		"list-style-position": inSet(listStylePositions),
+		"border-collapse":     oneOf("separate", "collapse"),
+		"caption-side":        oneOf("top", "bottom"),
+		"empty-cells":         oneOf("show", "hide"),
+		"table-layout":        oneOf("auto", "fixed"),
+
+		"display": oneOf(
+			"none", "contents", "block", "inline", "inline-block", "flow-root", "list-item",
+			"flex", "inline-flex", "grid", "inline-grid", "table", "inline-table",
+			"table-row-group", "table-header-group", "table-footer-group", "table-row",
+			"table-cell", "table-column-group", "table-column", "table-caption",
+			"ruby", "ruby-base", "ruby-text", "ruby-base-container", "ruby-text-container",
+		),
+		"box-sizing":    oneOf("content-box", "border-box"),
+		"outline-style": func(value []css.ComponentValue) bool { return inSet(borderStyles)(value) || isKeyword(value, "auto") },
+
+		"position":   oneOf("static", "relative", "absolute", "fixed", "sticky"),
+		"float":      oneOf("none", "left", "right", "inline-start", "inline-end"),
+		"clear":      oneOf("none", "left", "right", "both", "inline-start", "inline-end"),
+		"z-index":    func(value []css.ComponentValue) bool { return isKeyword(value, "auto") || isInteger(value) },
+		"overflow-x": oneOf("visible", "hidden", "clip", "scroll", "auto"),
+		"overflow-y": oneOf("visible", "hidden", "clip", "scroll", "auto"),
+
+		"background-repeat": isBackgroundRepeat,
+		"opacity":           isAlphaValue,
+
+		"flex-direction": oneOf("row", "row-reverse", "column", "column-reverse"),
+		"flex-wrap":      oneOf("nowrap", "wrap", "wrap-reverse"),
+		"flex-grow":      isNonNegativeNumber,
+		"flex-shrink":    isNonNegativeNumber,
+		"order":          isInteger,
+	}
+	for name, prop := range lengthProperties {
+		validators[name] = lengthOf(prop)
+	}
+	for name := range borderWidths {
+		validators[name] = isLineWidthValue
+	}
+	for _, name := range colorProperties {
+		validators[name] = isColor
+	}
+	for _, side := range sides {
+		validators["border-"+side+"-style"] = inSet(borderStyles)
+	}
+}
+
+// validValue проверяет значение свойства name. Общие ключевые слова
+// (inherit, initial...) допустимы у любого свойства
+func validValue(name string, value []css.ComponentValue) bool {
+	if len(fields(value)) == 0 {
+		return false
+	}
+	if isWideKeyword(value) {
+		return true
+	}
+	valid := validators[name]
+	return valid == nil || valid(value)
+}
+
+// isKeyword проверяет, является ли значение одним из ключевых слов
+func isKeyword(value []css.ComponentValue, keywords ...string) bool {
+	parts := fields(value)
+	if len(parts) != 1 {
+		return false
+	}
+	for _, k := range keywords {
+		if parts[0].IsIdent(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// oneOf создает проверку свойства, принимающего одно ключевое слово
+func oneOf(keywords ...string) validator {
+	return func(value []css.ComponentValue) bool {
+		return isKeyword(value, keywords...)
+	}
+}
+
+// inSet создает проверку свойства, принимающего одно ключевое слово из set
+func inSet(set map[string]bool) validator {
+	return func(value []css.ComponentValue) bool {
+		parts := fields(value)
+		return len(parts) == 1 && parts[0].Type == css.IdentToken && set[strings.ToLower(parts[0].Value)]
+	}
+}
+
+// isLength проверяет, является ли значение длиной, процентом или ключевым
+// словом из keywords
+func isLength(value []css.ComponentValue, keywords ...string) bool {
+	_, ok := parseLength(value, &unitContext{}, keywords...)
+	return ok
+}
+
+// lengthOf создает проверку свойства-длины. Отрицательные числа
+// отвергаются сразу, а результат calc() ограничивается при вычислении
+func lengthOf(prop lengthProperty) validator {
+	return func(value []css.ComponentValue) bool {
+		if prop.nonNegative && isNegative(value) {
+			return false
+		}
+		return isLength(value, prop.keywords...)
+	}
+}
+
+// isNegative проверяет, является ли значение отрицательным числом,
+// размерностью или процентом
+func isNegative(value []css.ComponentValue) bool {
+	parts := fields(value)
+	if len(parts) != 1 {
+		return false
+	}
+	switch parts[0].Type {
+	case css.NumberToken, css.DimensionToken, css.PercentageToken:
+		return parts[0].Number < 0
+	}
+	return false
+}
+
+// isLineWidthValue проверяет толщину рамки: длину без процентов или thin,
+// medium, thick
+func isLineWidthValue(value []css.ComponentValue) bool {
+	l, ok := parseLength(value, &unitContext{}, "thin", "medium", "thick")
+	return ok && !l.HasPercent()
+}
+
+// isColor проверяет, является ли значение цветом
+func isColor(value []css.ComponentValue) bool {
+	return css.IsColor(value)
+}
+
+// number возвращает число, если значение состоит из одного числа
+func number(value []css.ComponentValue) (css.ComponentValue, bool) {
+	parts := fields(value)
+	if len(parts) != 1 || parts[0].Type != css.NumberToken {
+		return css.ComponentValue{}, false
+	}
+	return parts[0], true
+}
+
+// isInteger проверяет, является ли значение целым числом
+func isInteger(value []css.ComponentValue) bool {
+	v, ok := number(value)
+	return ok && v.Integer
+}
+
+// isNonNegativeNumber проверяет, является ли значение неотрицательным числом
+func isNonNegativeNumber(value []css.ComponentValue) bool {
+	v, ok := number(value)
+	return ok && v.Number >= 0
+}
+
+// isAlphaValue проверяет значение прозрачности: число или процент
+func isAlphaValue(value []css.ComponentValue) bool {
+	parts := fields(value)
+	return len(parts) == 1 && (parts[0].Type == css.NumberToken || parts[0].Type == css.PercentageToken)
+}
+
+// isFontSizeValue проверяет размер шрифта: абсолютное или относительное
+// ключевое слово, длину или процент
+func isFontSizeValue(value []css.ComponentValue) bool {
+	parts := fields(value)
+	if len(parts) == 1 && parts[0].Type == css.IdentToken {
+		name := strings.ToLower(parts[0].Value)
+		_, ok := fontSizeKeywords[name]
+		return ok || name == "larger" || name == "smaller"
+	}
+	return isLength(value)
+}
+
+// isFontStyle проверяет font-style: normal, italic или oblique с
+// необязательным углом
+func isFontStyle(value []css.ComponentValue) bool {
+	parts := fields(value)
+	if len(parts) == 2 && parts[0].IsIdent("oblique") {
+		switch strings.ToLower(parts[1].Unit) {
+		case "deg", "grad", "rad", "turn":
+			return parts[1].Type == css.DimensionToken
+		}
+		return false
+	}
+	return isKeyword(value, "normal", "italic", "oblique")
+}
+
+// isFontWeight проверяет font-weight: ключевое слово или число от 1 до 1000
+func isFontWeight(value []css.ComponentValue) bool {
+	if v, ok := number(value); ok {
+		return v.Number >= 1 && v.Number <= 1000
+	}
+	return isKeyword(value, "normal", "bold", "bolder", "lighter")
+}
+
+// isLineHeight проверяет line-height: normal, неотрицательное число,
+// длину или процент
+func isLineHeight(value []css.ComponentValue) bool {
+	if isNegative(value) {
+		return false
+	}
+	if _, ok := number(value); ok {
+		return true
+	}
+	return isLength(value, "normal")
+}
+
+// isBackgroundRepeat проверяет background-repeat: repeat-x, repeat-y или
+// одно-два ключевых слова для горизонтали и вертикали
+func isBackgroundRepeat(value []css.ComponentValue) bool {
+	parts := fields(value)
+	if len(parts) == 1 {
+		return inSet(backgroundRepeats)(value)
+	}
+	for _, v := range parts {
+		if v.IsIdent("repeat-x") || v.IsIdent("repeat-y") || !inSet(backgroundRepeats)(single(v)) {
+			return false
+		}
+	}
+	return len(parts) == 2
+}
@@ -0,0 +1,187 @@
+package style
+
+import (
+	"strings"
+
+	"github.com/baneronetwo/gluglu/internal/browser/css"
+)
+
+// maxSubstitutionLength ограничивает число значений после подстановки
+// var(), чтобы экспоненциально растущие цепочки переменных не занимали
+// всю память
+const maxSubstitutionLength = 1 << 16
+
+// isCustomPropertyName проверяет, является ли имя пользовательским
+// свойством (--имя)
+func isCustomPropertyName(name string) bool {
+	return strings.HasPrefix(name, "--")
+}
+
+// hasVar проверяет, есть ли в значении функция var(), в том числе внутри
+// других функций и блоков
+func hasVar(value []css.ComponentValue) bool {
+	for _, v := range value {
+		if v.IsFunction("var") || hasVar(v.Children) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveCustomProperties вычисляет пользовательские свойства элемента.
+// Они всегда наследуются; initial делает свойство недействительным
+// (guaranteed-invalid), как и ссылка на недействительное свойство без
+// запасного значения. Все свойства, которые ссылаются друг на друга по
+// кругу, тоже недействительны
+func resolveCustomProperties(cascaded map[string][]*candidate, parent *ComputedStyle) map[string][]css.ComponentValue {
+	custom := make(map[string][]css.ComponentValue)
+	if parent != nil {
+		for name, value := range parent.custom {
+			custom[name] = value
+		}
+	}
+	specified := make(map[string][]css.ComponentValue)
+	for name, candidates := range cascaded {
+		if !isCustomPropertyName(name) {
+			continue
+		}
+		cand, keyword := cascadeWinner(candidates)
+		switch {
+		case cand != nil:
+			specified[name] = cand.value
+		case keyword == "initial":
+			delete(custom, name)
+		}
+	}
+
+	// Обход в глубину: stack — свойства, которые сейчас вычисляются. Если
+	// ссылка ведет на свойство из стека, все свойства от него до вершины
+	// стека образуют цикл
+	const (
+		visiting = iota + 1
+		done
+	)
+	state := make(map[string]int)
+	cyclic := make(map[string]bool)
+	var stack []string
+	var resolve func(name string) ([]css.ComponentValue, bool)
+	resolve = func(name string) ([]css.ComponentValue, bool) {
+		raw, ok := specified[name]
+		if !ok || state[name] == done {
+			value, ok := custom[name]
+			return value, ok
+		}
+		if state[name] == visiting {
+			for i := len(stack) - 1; i >= 0; i-- {
+				cyclic[stack[i]] = true
+				if stack[i] == name {
+					break
+				}
+			}
+			return nil, false
+		}
+		state[name] = visiting
+		stack = append(stack, name)
+		value, ok := substituteVars(raw, resolve)
+		stack = stack[:len(stack)-1]
+		state[name] = done
+		if !ok || cyclic[name] {
+			delete(custom, name)
+			return nil, false
+		}
+		custom[name] = value
+		return value, true
+	}
+	for name := range specified {
+		resolve(name)
+	}
+	return custom
+}
+
+// substituteVars заменяет функции var() значениями пользовательских
+// свойств, которые возвращает lookup. Если свойство недействительно,
+// используется запасное значение после запятой, а без него подстановка
+// не удается
+func substituteVars(value []css.ComponentValue, lookup func(name string) ([]css.ComponentValue, bool)) ([]css.ComponentValue, bool) {
+	budget := maxSubstitutionLength
+	return substitute(value, lookup, &budget)
+}
+
+func substitute(value []css.ComponentValue, lookup func(name string) ([]css.ComponentValue, bool), budget *int) ([]css.ComponentValue, bool) {
+	result := make([]css.ComponentValue, 0, len(value))
+	for _, v := range value {
+		var parts []css.ComponentValue
+		switch {
+		case v.IsFunction("var"):
+			name, fallback, hasFallback, ok := parseVar(v.Children)
+			if !ok {
+				return nil, false
+			}
+			if parts, ok = lookup(name); !ok {
+				if !hasFallback {
+					return nil, false
+				}
+				if parts, ok = substitute(fallback, lookup, budget); !ok {
+					return nil, false
+				}
+			}
+		case len(v.Children) > 0:
+			children, ok := substitute(v.Children, lookup, budget)
+			if !ok {
+				return nil, false
+			}
+			v.Children = children
+			parts = []css.ComponentValue{v}
+		default:
+			parts = []css.ComponentValue{v}
+		}
+		if *budget -= len(parts); *budget < 0 {
+			return nil, false
+		}
+		result = append(result, parts...)
+	}
+	return result, true
+}
+
+// parseVar разбирает аргументы var(): имя пользовательского свойства и
+// необязательное запасное значение после запятой (оно может быть пустым)
+func parseVar(args []css.ComponentValue) (name string, fallback []css.ComponentValue, hasFallback, ok bool) {
+	args = trimSpaces(args)
+	if len(args) == 0 || args[0].Type != css.IdentToken || !isCustomPropertyName(args[0].Value) {
+		return "", nil, false, false
+	}
+	rest := trimSpaces(args[1:])
+	if len(rest) == 0 {
+		return args[0].Value, nil, false, true
+	}
+	if rest[0].Type != css.CommaToken {
+		return "", nil, false, false
+	}
+	return args[0].Value, trimSpaces(rest[1:]), true, true
+}
+
+// substituteDeclaration подставляет переменные в значение объявления. У
+// объявления, полученного из сокращенной записи с var(), сокращение
+// разворачивается после подстановки. ok == false означает, что значение
+// недействительно во время вычисления
+func substituteDeclaration(name string, cand *candidate, custom map[string][]css.ComponentValue) ([]css.ComponentValue, bool) {
+	value, ok := substituteVars(cand.value, func(name string) ([]css.ComponentValue, bool) {
+		value, ok := custom[name]
+		return value, ok
+	})
+	if !ok {
+		return nil, false
+	}
+	value = trimSpaces(value)
+	if cand.shorthand == "" {
+		return value, len(value) > 0
+	}
+	longhands, ok := shorthands[cand.shorthand](value)
+	if !ok {
+		return nil, false
+	}
+	if longhand, ok := longhands[name]; ok {
+		return longhand, true
+	}
+	return initialValue(name), true
+}
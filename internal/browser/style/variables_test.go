@@ -0,0 +1,82 @@
+package style
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVariables(t *testing.T) {
+	tests := []struct {
+		sheet    string
+		property string
+		want     string
+	}{
+		// Подстановка и наследование
+		{"#c{--a:10px;width:var(--a)}", "width", "10px"},
+		{"#c{--a: 10px ;width:var( --a )}", "--a", "10px"},
+		{"div{--a:30px} #c{width:var(--a)}", "width", "30px"},
+		{"#c{--A:1px;--a:2px;width:var(--A)}", "width", "1px"},
+		{"#c{--m:1px 2px;margin:var(--m)}", "margin-right", "2px"},
+		{"#c{--w:calc(var(--x) * 2);--x:5px;width:var(--w)}", "width", "10px"},
+		// Переменная родителя вычисляется на родителе
+		{"div{--a:1px;--b:var(--a)} #c{--a:2px;width:var(--b)}", "width", "1px"},
+
+		// Запасные значения
+		{"#c{width:var(--missing, 20px)}", "width", "20px"},
+		{"#c{width:var(--missing, var(--also-missing, 5px))}", "width", "5px"},
+		{"#c{--f:a;font-family:var(--missing, x, y)}", "font-family", "x, y"},
+		{"#c{--a:1px;width:var(--a, foo)}", "width", "1px"},
+		{"#c{--e:var(--missing,);width:1px}", "--e", ""},
+		{"#c{width:var(--missing,)}", "width", "auto"},
+		{"div{--a:1px} #c{--a:initial;width:var(--a, 7px)}", "width", "7px"},
+
+		// Циклы делают недействительными все свойства цикла, даже с
+		// запасными значениями, но запасное значение у ссылки на них
+		// используется
+		{"#c{--a:var(--b);--b:var(--a);width:var(--a, 1px)}", "width", "1px"},
+		{"#c{--a:var(--a);width:var(--a, 2px)}", "width", "2px"},
+		{"#c{--a:var(--b, 1px);--b:var(--a, 2px);width:var(--b, 3px)}", "width", "3px"},
+		{"#c{--a:var(--b);--b:var(--c);--c:var(--b);width:var(--a, 4px)}", "width", "4px"},
+		{"#c{--b:var(--c);--c:var(--b);--x:var(--b, 5px);width:var(--x)}", "width", "5px"},
+		{"div{--a:1px} #c{--a:var(--b);--b:var(--a);width:var(--a, 6px)}", "width", "6px"},
+
+		// Недействительное значение ведет себя как unset
+		{"#c{--a:var(--missing);width:var(--a)}", "width", "auto"},
+		{"div{color:blue} #c{color:var(--missing)}", "color", "rgb(0, 0, 255)"},
+		{"#c{color:red;color:var(--missing)}", "color", "rgb(0, 0, 0)"},
+		{"#c{width:var(a)}", "width", "auto"},
+		{"#c{width:var(--a 1px)}", "width", "auto"},
+	}
+	for _, test := range tests {
+		s := computeStyle(t, test.sheet, "<div><p id=c>")
+		if got := s.Get(test.property); got != test.want {
+			t.Errorf("%s: %s = %q, ожидалось %q", test.sheet, test.property, got, test.want)
+		}
+	}
+}
+
+func TestVariableCycles(t *testing.T) {
+	s := computeStyle(t, "#c{--a:var(--b);--b:var(--a);--c:var(--a);--d:var(--a, ok);--e:1}", "<p id=c>")
+	if got := strings.Join(s.CustomProperties(), " "); got != "--d --e" {
+		t.Errorf("действительные свойства: получено %q, ожидалось %q", got, "--d --e")
+	}
+	if got := s.Get("--d"); got != "ok" {
+		t.Errorf("--d: получено %q, ожидалось %q", got, "ok")
+	}
+}
+
+func TestVariableLengthLimit(t *testing.T) {
+	// Каждое свойство в 8 раз длиннее предыдущего: --p4 из 65535 значений
+	// еще укладывается в ограничение, а --p5 уже нет
+	var sheet strings.Builder
+	sheet.WriteString("#c{--p0:x x x x x x x x;")
+	for i := 1; i <= 5; i++ {
+		ref := "var(--p" + string(rune('0'+i-1)) + ") "
+		sheet.WriteString("--p" + string(rune('0'+i)) + ":" + strings.Repeat(ref, 8) + ";")
+	}
+	sheet.WriteString("}")
+	s := computeStyle(t, sheet.String(), "<p id=c>")
+	if got := strings.Join(s.CustomProperties(), " "); got != "--p0 --p1 --p2 --p3 --p4" {
+		t.Errorf("действительные свойства: получено %q", got)
+	}
+}
@@ -0,0 +1,99 @@
+package weburl
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// encodeSet — множество байтов, которые заменяются последовательностями %XX.
+// Множества вложены друг в друга так же, как в спецификации URL
+type encodeSet func(b byte) bool
+
+// inC0ControlSet — управляющие символы C0 и все байты вне ASCII
+func inC0ControlSet(b byte) bool {
+	return b < 0x20 || b > 0x7E
+}
+
+// inFragmentSet — множество для фрагмента
+func inFragmentSet(b byte) bool {
+	return inC0ControlSet(b) || b == ' ' || b == '"' || b == '<' || b == '>' || b == '`'
+}
+
+// inQuerySet — множество для запроса в адресах с неспециальной схемой
+func inQuerySet(b byte) bool {
+	return inC0ControlSet(b) || b == ' ' || b == '"' || b == '#' || b == '<' || b == '>'
+}
+
+// inSpecialQuerySet — множество для запроса в адресах со специальной схемой
+func inSpecialQuerySet(b byte) bool {
+	return inQuerySet(b) || b == '\''
+}
+
+// inPathSet — множество для сегментов пути
+func inPathSet(b byte) bool {
+	return inQuerySet(b) || b == '?' || b == '`' || b == '{' || b == '}'
+}
+
+// inUserinfoSet — множество для имени пользователя и пароля
+func inUserinfoSet(b byte) bool {
+	return inPathSet(b) || b == '/' || b == ':' || b == ';' || b == '=' || b == '@' ||
+		b >= '[' && b <= '^' || b == '|'
+}
+
+// percentEncode кодирует байты строки из множества set
+func percentEncode(s string, set encodeSet) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if set(b) {
+			sb.WriteByte('%')
+			sb.WriteByte(upperHex[b>>4])
+			sb.WriteByte(upperHex[b&15])
+		} else {
+			sb.WriteByte(b)
+		}
+	}
+	return sb.String()
+}
+
+// percentEncodeRune кодирует символ в UTF-8 и байты из множества set
+func percentEncodeRune(c rune, set encodeSet) string {
+	if c < utf8.RuneSelf && !set(byte(c)) {
+		return string(c)
+	}
+	return percentEncode(string(c), set)
+}
+
+const upperHex = "0123456789ABCDEF"
+
+// percentDecode заменяет последовательности %XX байтами. Некорректные
+// последовательности остаются как есть
+func percentDecode(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			sb.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func isHex(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'f' || b >= 'A' && b <= 'F'
+}
+
+func unhex(b byte) byte {
+	switch {
+	case b >= '0' && b <= '9':
+		return b - '0'
+	case b >= 'a' && b <= 'f':
+		return b - 'a' + 10
+	}
+	return b - 'A' + 10
+}
@@ -0,0 +1,357 @@
+package weburl
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// idnaProfile переводит доменные имена в ASCII по правилам UTS #46 с
+// параметрами из спецификации URL: без переходной обработки, без правил
+// STD3, без проверки дефисов и длины имени, но с проверкой соединителей,
+// правил Bidi и меток с префиксом xn--
+var idnaProfile = idna.New(
+	idna.MapForLookup(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false),
+	idna.CheckHyphens(false),
+	idna.CheckJoiners(true),
+	idna.BidiRule(),
+)
+
+// isForbiddenHostCodePoint — символы, недопустимые в любом хосте
+func isForbiddenHostCodePoint(c rune) bool {
+	switch c {
+	case 0, '\t', '\n', '\r', ' ', '#', '/', ':', '<', '>', '?', '@', '[', '\\', ']', '^', '|':
+		return true
+	}
+	return false
+}
+
+// isForbiddenDomainCodePoint — символы, недопустимые в доменном имени
+func isForbiddenDomainCodePoint(c rune) bool {
+	return isForbiddenHostCodePoint(c) || c <= 0x1F || c == '%' || c == 0x7F
+}
+
+// parseHost разбирает хост. Хост адреса с неспециальной схемой непрозрачен:
+// он только кодируется, а не переводится в ASCII
+func parseHost(input string, opaque bool) (string, error) {
+	if strings.HasPrefix(input, "[") {
+		if !strings.HasSuffix(input, "]") {
+			return "", errors.New("незакрытая скобка в адресе IPv6")
+		}
+		address, err := parseIPv6(input[1 : len(input)-1])
+		if err != nil {
+			return "", err
+		}
+		return "[" + serializeIPv6(address) + "]", nil
+	}
+	if opaque {
+		for _, c := range input {
+			if isForbiddenHostCodePoint(c) {
+				return "", errors.New("недопустимый символ в хосте")
+			}
+		}
+		return percentEncode(input, inC0ControlSet), nil
+	}
+
+	domain := percentDecode(input)
+	if !utf8.ValidString(domain) {
+		domain = strings.ToValidUTF8(domain, string(utf8.RuneError))
+	}
+	ascii, err := domainToASCII(domain)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range ascii {
+		if isForbiddenDomainCodePoint(c) {
+			return "", errors.New("недопустимый символ в доменном имени")
+		}
+	}
+	if endsInNumber(ascii) {
+		address, err := parseIPv4(ascii)
+		if err != nil {
+			return "", err
+		}
+		return serializeIPv4(address), nil
+	}
+	return ascii, nil
+}
+
+// domainToASCII переводит доменное имя в ASCII (Punycode)
+func domainToASCII(domain string) (string, error) {
+	// Имя в ASCII без меток «xn--» достаточно привести к нижнему регистру
+	isASCII := true
+	for i := 0; i < len(domain); i++ {
+		if domain[i] >= utf8.RuneSelf {
+			isASCII = false
+			break
+		}
+	}
+	if isASCII {
+		lower := strings.ToLower(domain)
+		if !strings.HasPrefix(lower, "xn--") && !strings.Contains(lower, ".xn--") {
+			return lower, nil
+		}
+	}
+	ascii, err := idnaProfile.ToASCII(domain)
+	if err != nil {
+		return "", errors.New("некорректное доменное имя: " + err.Error())
+	}
+	if ascii == "" {
+		return "", errors.New("пустое доменное имя")
+	}
+	return ascii, nil
+}
+
+// endsInNumber проверяет, заканчивается ли имя числом: такой хост
+// разбирается как адрес IPv4
+func endsInNumber(host string) bool {
+	parts := strings.Split(host, ".")
+	if parts[len(parts)-1] == "" {
+		if len(parts) == 1 {
+			return false
+		}
+		parts = parts[:len(parts)-1]
+	}
+	last := parts[len(parts)-1]
+	if last != "" && strings.Trim(last, "0123456789") == "" {
+		return true
+	}
+	_, err := parseIPv4Number(last)
+	return err == nil
+}
+
+// parseIPv4Number разбирает часть адреса IPv4: десятичную, восьмеричную
+// (с ведущим нулем) или шестнадцатеричную (с 0x)
+func parseIPv4Number(s string) (uint64, error) {
+	if s == "" {
+		return 0, errors.New("пустая часть адреса IPv4")
+	}
+	base := 10
+	if len(s) >= 2 && (s[:2] == "0x" || s[:2] == "0X") {
+		s = s[2:]
+		base = 16
+	} else if len(s) >= 2 && s[0] == '0' {
+		s = s[1:]
+		base = 8
+	}
+	if s == "" {
+		return 0, nil
+	}
+	for i := 0; i < len(s); i++ {
+		if !isHex(s[i]) || base == 10 && !isASCIIDigit(rune(s[i])) || base == 8 && s[i] > '7' {
+			return 0, errors.New("некорректная часть адреса IPv4")
+		}
+	}
+	n, err := strconv.ParseUint(s, base, 64)
+	if err != nil {
+		// Слишком длинное число — все равно число, но больше любого допустимого
+		return 1 << 40, nil
+	}
+	return n, nil
+}
+
+// parseIPv4 разбирает адрес IPv4 в любой из записей, которые понимают
+// браузеры: «127.0.0.1», «0x7f.1», «2130706433»
+func parseIPv4(s string) (uint32, error) {
+	parts := strings.Split(s, ".")
+	if parts[len(parts)-1] == "" && len(parts) > 1 {
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) > 4 {
+		return 0, errors.New("слишком много частей в адресе IPv4")
+	}
+	numbers := make([]uint64, len(parts))
+	for i, part := range parts {
+		n, err := parseIPv4Number(part)
+		if err != nil {
+			return 0, err
+		}
+		numbers[i] = n
+	}
+	for _, n := range numbers[:len(numbers)-1] {
+		if n > 255 {
+			return 0, errors.New("часть адреса IPv4 больше 255")
+		}
+	}
+	last := numbers[len(numbers)-1]
+	if last >= 1<<(8*(5-len(numbers))) {
+		return 0, errors.New("адрес IPv4 вне допустимого диапазона")
+	}
+	ipv4 := last
+	for i, n := range numbers[:len(numbers)-1] {
+		ipv4 += n << (8 * (3 - i))
+	}
+	return uint32(ipv4), nil
+}
+
+// serializeIPv4 записывает адрес IPv4 в десятичной записи с точками
+func serializeIPv4(address uint32) string {
+	parts := make([]string, 4)
+	for i := 3; i >= 0; i-- {
+		parts[i] = strconv.Itoa(int(address % 256))
+		address /= 256
+	}
+	return strings.Join(parts, ".")
+}
+
+// parseIPv6 разбирает адрес IPv6 по алгоритму спецификации, включая
+// сокращение «::» и адреса IPv4 в последних двух частях
+func parseIPv6(s string) ([8]uint16, error) {
+	var address [8]uint16
+	fail := errors.New("некорректный адрес IPv6")
+	pieceIndex, compress := 0, -1
+	p := 0
+	c := func() rune {
+		if p >= len(s) {
+			return eof
+		}
+		return rune(s[p])
+	}
+
+	if c() == ':' {
+		if p+1 >= len(s) || s[p+1] != ':' {
+			return address, fail
+		}
+		p += 2
+		pieceIndex++
+		compress = pieceIndex
+	}
+	for c() != eof {
+		if pieceIndex == 8 {
+			return address, fail
+		}
+		if c() == ':' {
+			if compress != -1 {
+				return address, fail
+			}
+			p++
+			pieceIndex++
+			compress = pieceIndex
+			continue
+		}
+		value, length := 0, 0
+		for length < 4 && c() != eof && isHex(byte(c())) {
+			value = value*16 + int(unhex(byte(c())))
+			p++
+			length++
+		}
+		if c() == '.' {
+			// Последние 32 бита записаны как адрес IPv4
+			if length == 0 {
+				return address, fail
+			}
+			p -= length
+			if pieceIndex > 6 {
+				return address, fail
+			}
+			numbersSeen := 0
+			for c() != eof {
+				piece := -1
+				if numbersSeen > 0 {
+					if c() == '.' && numbersSeen < 4 {
+						p++
+					} else {
+						return address, fail
+					}
+				}
+				if !isASCIIDigit(c()) {
+					return address, fail
+				}
+				for isASCIIDigit(c()) {
+					n := int(c() - '0')
+					switch piece {
+					case -1:
+						piece = n
+					case 0:
+						return address, fail
+					default:
+						piece = piece*10 + n
+					}
+					if piece > 255 {
+						return address, fail
+					}
+					p++
+				}
+				address[pieceIndex] = address[pieceIndex]*0x100 + uint16(piece)
+				numbersSeen++
+				if numbersSeen == 2 || numbersSeen == 4 {
+					pieceIndex++
+				}
+			}
+			if numbersSeen != 4 {
+				return address, fail
+			}
+			break
+		} else if c() == ':' {
+			p++
+			if c() == eof {
+				return address, fail
+			}
+		} else if c() != eof {
+			return address, fail
+		}
+		address[pieceIndex] = uint16(value)
+		pieceIndex++
+	}
+
+	if compress != -1 {
+		swaps := pieceIndex - compress
+		pieceIndex = 7
+		for pieceIndex != 0 && swaps > 0 {
+			address[pieceIndex], address[compress+swaps-1] = address[compress+swaps-1], address[pieceIndex]
+			pieceIndex--
+			swaps--
+		}
+	} else if pieceIndex != 8 {
+		return address, fail
+	}
+	return address, nil
+}
+
+// serializeIPv6 записывает адрес IPv6, сокращая самую длинную
+// последовательность нулевых частей до «::»
+func serializeIPv6(address [8]uint16) string {
+	compress, longest := -1, 1
+	for i := 0; i < 8; {
+		if address[i] != 0 {
+			i++
+			continue
+		}
+		j := i
+		for j < 8 && address[j] == 0 {
+			j++
+		}
+		if j-i > longest {
+			compress, longest = i, j-i
+		}
+		i = j
+	}
+
+	var sb strings.Builder
+	ignore0 := false
+	for i := 0; i < 8; i++ {
+		if ignore0 && address[i] == 0 {
+			continue
+		}
+		ignore0 = false
+		if compress == i {
+			if i == 0 {
+				sb.WriteString("::")
+			} else {
+				sb.WriteString(":")
+			}
+			ignore0 = true
+			continue
+		}
+		sb.WriteString(strconv.FormatUint(uint64(address[i]), 16))
+		if i != 7 {
+			sb.WriteByte(':')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,587 @@
+package weburl
+
+import (
+	"strings"
+)
+
+// Состояния разборщика адресов из спецификации
+type parserState int
+
+const (
+	schemeStartState parserState = iota
+	schemeState
+	noSchemeState
+	specialRelativeOrAuthorityState
+	pathOrAuthorityState
+	relativeState
+	relativeSlashState
+	specialAuthoritySlashesState
+	specialAuthorityIgnoreSlashesState
+	authorityState
+	hostState
+	portState
+	fileState
+	fileSlashState
+	fileHostState
+	pathStartState
+	pathState
+	opaquePathState
+	queryState
+	fragmentState
+)
+
+// eof — символ, который возвращается за концом входной строки
+const eof = -1
+
+// parser — разборщик адресов (basic URL parser)
+type parser struct {
+	input string
+	base  *URL
+
+	url     *URL
+	runes   []rune
+	pointer int
+	buffer  strings.Builder
+
+	atSignSeen        bool
+	insideBrackets    bool
+	passwordTokenSeen bool
+}
+
+// c возвращает текущий символ или eof
+func (p *parser) c() rune {
+	return p.at(p.pointer)
+}
+
+// at возвращает символ в позиции i или eof
+func (p *parser) at(i int) rune {
+	if i < 0 || i >= len(p.runes) {
+		return eof
+	}
+	return p.runes[i]
+}
+
+// remainingStartsWith проверяет, начинается ли остаток строки после
+// текущего символа с s
+func (p *parser) remainingStartsWith(s string) bool {
+	i := p.pointer + 1
+	for _, c := range s {
+		if p.at(i) != c {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// fail возвращает ошибку разбора
+func (p *parser) fail(reason string) error {
+	return &Error{Input: p.input, Reason: reason}
+}
+
+// isSpecial проверяет, специальная ли схема у разбираемого адреса
+func (p *parser) isSpecial() bool {
+	return isSpecialScheme(p.url.Scheme)
+}
+
+// parse выполняет разбор
+func (p *parser) parse() (*URL, error) {
+	// Управляющие символы и пробелы по краям, а также табуляции и переводы
+	// строк внутри адреса игнорируются
+	input := strings.TrimFunc(p.input, func(c rune) bool { return c <= ' ' })
+	input = strings.Map(func(c rune) rune {
+		if c == '\t' || c == '\n' || c == '\r' {
+			return -1
+		}
+		return c
+	}, input)
+	p.runes = []rune(input)
+	p.url = &URL{Port: -1}
+
+	state := schemeStartState
+	for p.pointer = 0; p.pointer <= len(p.runes); p.pointer++ {
+		next, err := p.step(state, p.c())
+		if err != nil {
+			return nil, err
+		}
+		state = next
+	}
+	return p.url, nil
+}
+
+// step обрабатывает символ c в состоянии state и возвращает новое состояние
+func (p *parser) step(state parserState, c rune) (parserState, error) {
+	u := p.url
+	switch state {
+	case schemeStartState:
+		if isASCIIAlpha(c) {
+			p.buffer.WriteRune(toLower(c))
+			return schemeState, nil
+		}
+		p.pointer--
+		return noSchemeState, nil
+
+	case schemeState:
+		if isASCIIAlphanumeric(c) || c == '+' || c == '-' || c == '.' {
+			p.buffer.WriteRune(toLower(c))
+			return schemeState, nil
+		}
+		if c == ':' {
+			u.Scheme = p.buffer.String()
+			p.buffer.Reset()
+			switch {
+			case u.Scheme == "file":
+				return fileState, nil
+			case p.isSpecial() && p.base != nil && p.base.Scheme == u.Scheme:
+				return specialRelativeOrAuthorityState, nil
+			case p.isSpecial():
+				return specialAuthoritySlashesState, nil
+			case p.remainingStartsWith("/"):
+				p.pointer++
+				return pathOrAuthorityState, nil
+			}
+			u.OpaquePath = true
+			u.Path = []string{""}
+			return opaquePathState, nil
+		}
+		// Это была не схема: разбор начинается заново как относительного адреса
+		p.buffer.Reset()
+		p.pointer = -1
+		return noSchemeState, nil
+
+	case noSchemeState:
+		base := p.base
+		if base == nil || base.OpaquePath && c != '#' {
+			return 0, p.fail("относительный адрес без базового адреса")
+		}
+		if base.OpaquePath && c == '#' {
+			u.Scheme = base.Scheme
+			u.Path = append([]string(nil), base.Path...)
+			u.OpaquePath = true
+			u.Query, u.HasQuery = base.Query, base.HasQuery
+			u.HasFragment = true
+			return fragmentState, nil
+		}
+		p.pointer--
+		if base.Scheme != "file" {
+			return relativeState, nil
+		}
+		return fileState, nil
+
+	case specialRelativeOrAuthorityState:
+		if c == '/' && p.remainingStartsWith("/") {
+			p.pointer++
+			return specialAuthorityIgnoreSlashesState, nil
+		}
+		p.pointer--
+		return relativeState, nil
+
+	case pathOrAuthorityState:
+		if c == '/' {
+			return authorityState, nil
+		}
+		p.pointer--
+		return pathState, nil
+
+	case relativeState:
+		base := p.base
+		u.Scheme = base.Scheme
+		if c == '/' || p.isSpecial() && c == '\\' {
+			return relativeSlashState, nil
+		}
+		p.copyAuthority(base)
+		u.Path = append([]string(nil), base.Path...)
+		u.Query, u.HasQuery = base.Query, base.HasQuery
+		switch c {
+		case '?':
+			u.Query, u.HasQuery = "", true
+			return queryState, nil
+		case '#':
+			u.HasFragment = true
+			return fragmentState, nil
+		case eof:
+			return relativeState, nil
+		}
+		u.Query, u.HasQuery = "", false
+		p.shortenPath()
+		p.pointer--
+		return pathState, nil
+
+	case relativeSlashState:
+		if p.isSpecial() && (c == '/' || c == '\\') {
+			return specialAuthorityIgnoreSlashesState, nil
+		}
+		if c == '/' {
+			return authorityState, nil
+		}
+		p.copyAuthority(p.base)
+		p.pointer--
+		return pathState, nil
+
+	case specialAuthoritySlashesState:
+		if c == '/' && p.remainingStartsWith("/") {
+			p.pointer++
+		} else {
+			p.pointer--
+		}
+		return specialAuthorityIgnoreSlashesState, nil
+
+	case specialAuthorityIgnoreSlashesState:
+		if c != '/' && c != '\\' {
+			p.pointer--
+			return authorityState, nil
+		}
+		return specialAuthorityIgnoreSlashesState, nil
+
+	case authorityState:
+		if c == '@' {
+			buffer := p.buffer.String()
+			if p.atSignSeen {
+				buffer = "%40" + buffer
+			}
+			p.atSignSeen = true
+			for _, bc := range buffer {
+				if bc == ':' && !p.passwordTokenSeen {
+					p.passwordTokenSeen = true
+					continue
+				}
+				encoded := percentEncodeRune(bc, inUserinfoSet)
+				if p.passwordTokenSeen {
+					u.Password += encoded
+				} else {
+					u.Username += encoded
+				}
+			}
+			p.buffer.Reset()
+			return authorityState, nil
+		}
+		if c == eof || c == '/' || c == '?' || c == '#' || p.isSpecial() && c == '\\' {
+			if p.atSignSeen && p.buffer.Len() == 0 {
+				return 0, p.fail("нет хоста после данных пользователя")
+			}
+			p.pointer -= len([]rune(p.buffer.String())) + 1
+			p.buffer.Reset()
+			return hostState, nil
+		}
+		p.buffer.WriteRune(c)
+		return authorityState, nil
+
+	case hostState:
+		if c == ':' && !p.insideBrackets {
+			if p.buffer.Len() == 0 {
+				return 0, p.fail("пустой хост")
+			}
+			if err := p.setHost(p.buffer.String()); err != nil {
+				return 0, err
+			}
+			p.buffer.Reset()
+			return portState, nil
+		}
+		if c == eof || c == '/' || c == '?' || c == '#' || p.isSpecial() && c == '\\' {
+			p.pointer--
+			if p.isSpecial() && p.buffer.Len() == 0 {
+				return 0, p.fail("пустой хост")
+			}
+			if err := p.setHost(p.buffer.String()); err != nil {
+				return 0, err
+			}
+			p.buffer.Reset()
+			return pathStartState, nil
+		}
+		if c == '[' {
+			p.insideBrackets = true
+		} else if c == ']' {
+			p.insideBrackets = false
+		}
+		p.buffer.WriteRune(c)
+		return hostState, nil
+
+	case portState:
+		if isASCIIDigit(c) {
+			p.buffer.WriteRune(c)
+			return portState, nil
+		}
+		if c == eof || c == '/' || c == '?' || c == '#' || p.isSpecial() && c == '\\' {
+			if digits := strings.TrimLeft(p.buffer.String(), "0"); p.buffer.Len() > 0 {
+				if len(digits) > 5 {
+					return 0, p.fail("порт больше 65535")
+				}
+				port := 0
+				for _, d := range digits {
+					port = port*10 + int(d-'0')
+				}
+				if port > 65535 {
+					return 0, p.fail("порт больше 65535")
+				}
+				if port == defaultPort(u.Scheme) {
+					port = -1
+				}
+				u.Port = port
+				p.buffer.Reset()
+			}
+			p.pointer--
+			return pathStartState, nil
+		}
+		return 0, p.fail("некорректный порт")
+
+	case fileState:
+		u.Scheme = "file"
+		u.Host, u.HasHost = "", true
+		if c == '/' || c == '\\' {
+			return fileSlashState, nil
+		}
+		if base := p.base; base != nil && base.Scheme == "file" {
+			u.Host, u.HasHost = base.Host, base.HasHost
+			u.Path = append([]string(nil), base.Path...)
+			u.Query, u.HasQuery = base.Query, base.HasQuery
+			switch c {
+			case '?':
+				u.Query, u.HasQuery = "", true
+				return queryState, nil
+			case '#':
+				u.HasFragment = true
+				return fragmentState, nil
+			case eof:
+				return fileState, nil
+			}
+			u.Query, u.HasQuery = "", false
+			if !startsWithWindowsDriveLetter(p.runes[p.pointer:]) {
+				p.shortenPath()
+			} else {
+				u.Path = nil
+			}
+		}
+		p.pointer--
+		return pathState, nil
+
+	case fileSlashState:
+		if c == '/' || c == '\\' {
+			return fileHostState, nil
+		}
+		if base := p.base; base != nil && base.Scheme == "file" {
+			u.Host, u.HasHost = base.Host, base.HasHost
+			if !startsWithWindowsDriveLetter(p.runes[p.pointer:]) &&
+				len(base.Path) > 0 && isNormalizedWindowsDriveLetter(base.Path[0]) {
+				u.Path = append(u.Path, base.Path[0])
+			}
+		}
+		p.pointer--
+		return pathState, nil
+
+	case fileHostState:
+		if c == eof || c == '/' || c == '\\' || c == '?' || c == '#' {
+			p.pointer--
+			buffer := p.buffer.String()
+			if isWindowsDriveLetter(buffer) {
+				// «file://C:/» — это буква диска, а не хост; буфер станет
+				// первым сегментом пути
+				return pathState, nil
+			}
+			if buffer == "" {
+				u.Host, u.HasHost = "", true
+				return pathStartState, nil
+			}
+			if err := p.setHost(buffer); err != nil {
+				return 0, err
+			}
+			if u.Host == "localhost" {
+				u.Host = ""
+			}
+			p.buffer.Reset()
+			return pathStartState, nil
+		}
+		p.buffer.WriteRune(c)
+		return fileHostState, nil
+
+	case pathStartState:
+		if p.isSpecial() {
+			if c != '/' && c != '\\' {
+				p.pointer--
+			}
+			return pathState, nil
+		}
+		switch c {
+		case '?':
+			u.Query, u.HasQuery = "", true
+			return queryState, nil
+		case '#':
+			u.HasFragment = true
+			return fragmentState, nil
+		case eof:
+			return pathStartState, nil
+		}
+		if c != '/' {
+			p.pointer--
+		}
+		return pathState, nil
+
+	case pathState:
+		slash := c == '/' || p.isSpecial() && c == '\\'
+		if c == eof || slash || c == '?' || c == '#' {
+			buffer := p.buffer.String()
+			switch {
+			case isDoubleDotSegment(buffer):
+				p.shortenPath()
+				if !slash {
+					u.Path = append(u.Path, "")
+				}
+			case isSingleDotSegment(buffer):
+				if !slash {
+					u.Path = append(u.Path, "")
+				}
+			default:
+				if u.Scheme == "file" && len(u.Path) == 0 && isWindowsDriveLetter(buffer) {
+					buffer = buffer[:1] + ":"
+				}
+				u.Path = append(u.Path, buffer)
+			}
+			p.buffer.Reset()
+			switch c {
+			case '?':
+				u.Query, u.HasQuery = "", true
+				return queryState, nil
+			case '#':
+				u.HasFragment = true
+				return fragmentState, nil
+			}
+			return pathState, nil
+		}
+		p.buffer.WriteString(percentEncodeRune(c, inPathSet))
+		return pathState, nil
+
+	case opaquePathState:
+		switch c {
+		case '?':
+			u.Query, u.HasQuery = "", true
+			return queryState, nil
+		case '#':
+			u.HasFragment = true
+			return fragmentState, nil
+		case ' ':
+			// Пробел перед запросом или фрагментом кодируется, иначе при
+			// повторном разборе он был бы удален вместе с краевыми пробелами
+			if next := p.at(p.pointer + 1); next == '?' || next == '#' {
+				u.Path[0] += "%20"
+			} else {
+				u.Path[0] += " "
+			}
+		case eof:
+		default:
+			u.Path[0] += percentEncodeRune(c, inC0ControlSet)
+		}
+		return opaquePathState, nil
+
+	case queryState:
+		if c == '#' || c == eof {
+			set := inQuerySet
+			if p.isSpecial() {
+				set = inSpecialQuerySet
+			}
+			u.Query += percentEncode(p.buffer.String(), set)
+			p.buffer.Reset()
+			if c == '#' {
+				u.HasFragment = true
+				return fragmentState, nil
+			}
+			return queryState, nil
+		}
+		p.buffer.WriteRune(c)
+		return queryState, nil
+
+	case fragmentState:
+		if c != eof {
+			u.Fragment += percentEncodeRune(c, inFragmentSet)
+		}
+		return fragmentState, nil
+	}
+	return state, nil
+}
+
+// copyAuthority копирует из базового адреса данные пользователя, хост и порт
+func (p *parser) copyAuthority(base *URL) {
+	p.url.Username = base.Username
+	p.url.Password = base.Password
+	p.url.Host, p.url.HasHost = base.Host, base.HasHost
+	p.url.Port = base.Port
+}
+
+// setHost разбирает хост и записывает его в адрес
+func (p *parser) setHost(input string) error {
+	host, err := parseHost(input, !p.isSpecial())
+	if err != nil {
+		return &Error{Input: p.input, Reason: err.Error()}
+	}
+	p.url.Host, p.url.HasHost = host, true
+	return nil
+}
+
+// shortenPath удаляет последний сегмент пути. Буква диска в адресе file:
+// не удаляется
+func (p *parser) shortenPath() {
+	u := p.url
+	if u.Scheme == "file" && len(u.Path) == 1 && isNormalizedWindowsDriveLetter(u.Path[0]) {
+		return
+	}
+	if len(u.Path) > 0 {
+		u.Path = u.Path[:len(u.Path)-1]
+	}
+}
+
+// isSingleDotSegment проверяет, является ли сегмент пути «.»
+func isSingleDotSegment(s string) bool {
+	return s == "." || strings.EqualFold(s, "%2e")
+}
+
+// isDoubleDotSegment проверяет, является ли сегмент пути «..»
+func isDoubleDotSegment(s string) bool {
+	switch strings.ToLower(s) {
+	case "..", ".%2e", "%2e.", "%2e%2e":
+		return true
+	}
+	return false
+}
+
+// isWindowsDriveLetter проверяет, является ли строка буквой диска («C:» или «C|»)
+func isWindowsDriveLetter(s string) bool {
+	return len(s) == 2 && isASCIIAlpha(rune(s[0])) && (s[1] == ':' || s[1] == '|')
+}
+
+// isNormalizedWindowsDriveLetter проверяет, является ли строка буквой диска
+// с двоеточием
+func isNormalizedWindowsDriveLetter(s string) bool {
+	return isWindowsDriveLetter(s) && s[1] == ':'
+}
+
+// startsWithWindowsDriveLetter проверяет, начинаются ли символы с буквы диска
+func startsWithWindowsDriveLetter(runes []rune) bool {
+	if len(runes) < 2 || !isASCIIAlpha(runes[0]) || runes[1] != ':' && runes[1] != '|' {
+		return false
+	}
+	if len(runes) == 2 {
+		return true
+	}
+	switch runes[2] {
+	case '/', '\\', '?', '#':
+		return true
+	}
+	return false
+}
+
+func isASCIIAlpha(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isASCIIDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isASCIIAlphanumeric(c rune) bool {
+	return isASCIIAlpha(c) || isASCIIDigit(c)
+}
+
+func toLower(c rune) rune {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
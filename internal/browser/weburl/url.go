@@ -0,0 +1,215 @@
+// Package weburl разбирает и сериализует адреса по стандарту WHATWG URL —
+// так же, как это делают браузеры: с обратными косыми чертами в специальных
+// схемах, удалением табуляций и переводов строк, IDNA для доменных имен,
+// разбором IPv4 в десятичной, восьмеричной и шестнадцатеричной записи и
+// процентным кодированием по множествам из спецификации
+package weburl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// URL — разобранный адрес. Поля хранятся в сериализованном виде: хост уже
+// переведен в ASCII, а путь, запрос и фрагмент — закодированы
+type URL struct {
+	Scheme   string
+	Username string
+	Password string
+	// Host — доменное имя в ASCII, адрес IPv4 или адрес IPv6 в квадратных
+	// скобках. HasHost отличает пустой хост (file:///) от его отсутствия (mailto:)
+	Host    string
+	HasHost bool
+	// Port — порт или -1, если он не указан либо совпадает с портом схемы
+	// по умолчанию
+	Port int
+	// Path — сегменты пути. У адресов с непрозрачным путем (mailto:, data:)
+	// путь — одна строка в Path[0], а OpaquePath == true
+	Path       []string
+	OpaquePath bool
+	// Query и Fragment — запрос без «?» и фрагмент без «#». HasQuery и
+	// HasFragment отличают пустой запрос («?») от его отсутствия
+	Query       string
+	HasQuery    bool
+	Fragment    string
+	HasFragment bool
+}
+
+// Error — ошибка разбора адреса
+type Error struct {
+	Input  string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("некорректный URL %q: %s", e.Input, e.Reason)
+}
+
+// specialSchemes — специальные схемы и их порты по умолчанию (-1 — порта нет)
+var specialSchemes = map[string]int{
+	"ftp":   21,
+	"file":  -1,
+	"http":  80,
+	"https": 443,
+	"ws":    80,
+	"wss":   443,
+}
+
+// isSpecialScheme проверяет, является ли схема специальной
+func isSpecialScheme(scheme string) bool {
+	_, ok := specialSchemes[scheme]
+	return ok
+}
+
+// defaultPort возвращает порт схемы по умолчанию или -1
+func defaultPort(scheme string) int {
+	if port, ok := specialSchemes[scheme]; ok {
+		return port
+	}
+	return -1
+}
+
+// Parse разбирает адрес input. Относительный адрес разрешается относительно
+// base; если base == nil, адрес должен быть абсолютным
+func Parse(input string, base *URL) (*URL, error) {
+	p := &parser{input: input, base: base}
+	return p.parse()
+}
+
+// Parse разбирает адрес ref относительно u
+func (u *URL) Parse(ref string) (*URL, error) {
+	return Parse(ref, u)
+}
+
+// IsSpecial проверяет, относится ли адрес к специальной схеме (http, https,
+// ws, wss, ftp, file), для которой действуют особые правила разбора
+func (u *URL) IsSpecial() bool {
+	return isSpecialScheme(u.Scheme)
+}
+
+// String возвращает сериализованный адрес (свойство href)
+func (u *URL) String() string {
+	return u.serialize(false)
+}
+
+// StringWithoutFragment возвращает адрес без фрагмента
+func (u *URL) StringWithoutFragment() string {
+	return u.serialize(true)
+}
+
+// serialize сериализует адрес по алгоритму спецификации
+func (u *URL) serialize(excludeFragment bool) string {
+	var sb strings.Builder
+	sb.WriteString(u.Scheme)
+	sb.WriteByte(':')
+	if u.HasHost {
+		sb.WriteString("//")
+		if u.Username != "" || u.Password != "" {
+			sb.WriteString(u.Username)
+			if u.Password != "" {
+				sb.WriteByte(':')
+				sb.WriteString(u.Password)
+			}
+			sb.WriteByte('@')
+		}
+		sb.WriteString(u.Host)
+		if u.Port >= 0 {
+			sb.WriteByte(':')
+			sb.WriteString(strconv.Itoa(u.Port))
+		}
+	} else if !u.OpaquePath && len(u.Path) > 1 && u.Path[0] == "" {
+		// Без «/.» путь «//x» был бы прочитан как хост
+		sb.WriteString("/.")
+	}
+	sb.WriteString(u.Pathname())
+	if u.HasQuery {
+		sb.WriteByte('?')
+		sb.WriteString(u.Query)
+	}
+	if u.HasFragment && !excludeFragment {
+		sb.WriteByte('#')
+		sb.WriteString(u.Fragment)
+	}
+	return sb.String()
+}
+
+// Protocol возвращает схему с двоеточием («https:»)
+func (u *URL) Protocol() string {
+	return u.Scheme + ":"
+}
+
+// Hostname возвращает хост без порта
+func (u *URL) Hostname() string {
+	return u.Host
+}
+
+// HostPort возвращает хост вместе с портом, если он указан (свойство host)
+func (u *URL) HostPort() string {
+	if u.Port < 0 {
+		return u.Host
+	}
+	return u.Host + ":" + strconv.Itoa(u.Port)
+}
+
+// PortString возвращает порт строкой или пустую строку
+func (u *URL) PortString() string {
+	if u.Port < 0 {
+		return ""
+	}
+	return strconv.Itoa(u.Port)
+}
+
+// Pathname возвращает сериализованный путь
+func (u *URL) Pathname() string {
+	if u.OpaquePath {
+		if len(u.Path) == 0 {
+			return ""
+		}
+		return u.Path[0]
+	}
+	var sb strings.Builder
+	for _, segment := range u.Path {
+		sb.WriteByte('/')
+		sb.WriteString(segment)
+	}
+	return sb.String()
+}
+
+// Search возвращает запрос с «?» или пустую строку, если запрос пуст
+func (u *URL) Search() string {
+	if u.Query == "" {
+		return ""
+	}
+	return "?" + u.Query
+}
+
+// Hash возвращает фрагмент с «#» или пустую строку, если фрагмент пуст
+func (u *URL) Hash() string {
+	if u.Fragment == "" {
+		return ""
+	}
+	return "#" + u.Fragment
+}
+
+// Origin возвращает сериализованный источник адреса («https://example.com»).
+// У адресов без кортежного источника (file:, data:, about:) это «null»
+func (u *URL) Origin() string {
+	switch u.Scheme {
+	case "http", "https", "ws", "wss", "ftp":
+		return u.Scheme + "://" + u.HostPort()
+	case "blob":
+		if inner, err := Parse(u.Pathname(), nil); err == nil &&
+			(inner.Scheme == "http" || inner.Scheme == "https") {
+			return inner.Origin()
+		}
+	}
+	return "null"
+}
+
+// Clone возвращает копию адреса
+func (u *URL) Clone() *URL {
+	c := *u
+	c.Path = append([]string(nil), u.Path...)
+	return &c
+}
@@ -0,0 +1,119 @@
+package weburl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Тест прогоняет разбор адресов по urltestdata.json из web-platform-tests,
+// скопированному в testdata/wpt. Выводится число пройденных тестов, а тест
+// падает, если оно стало меньше зафиксированного минимума. После улучшения
+// разбора минимум нужно поднять
+
+const wptDir = "testdata/wpt"
+
+// urlTestMinimum — минимальное число пройденных тестов из urltestdata.json.
+// Сейчас проходят все тесты файла
+const urlTestMinimum = 820
+
+// urlTest — один тест из urltestdata.json. Base равен nil, если адрес
+// разбирается без базового; Failure означает, что разбор должен завершиться
+// ошибкой
+type urlTest struct {
+	Input    string  `json:"input"`
+	Base     *string `json:"base"`
+	Failure  bool    `json:"failure"`
+	Href     string  `json:"href"`
+	Origin   *string `json:"origin"`
+	Protocol string  `json:"protocol"`
+	Username string  `json:"username"`
+	Password string  `json:"password"`
+	Host     string  `json:"host"`
+	Hostname string  `json:"hostname"`
+	Port     string  `json:"port"`
+	Pathname string  `json:"pathname"`
+	Search   string  `json:"search"`
+	Hash     string  `json:"hash"`
+}
+
+func TestURLConformance(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(wptDir, "urltestdata.json"))
+	if err != nil {
+		t.Fatalf("не найдены тесты разбора адресов: %v", err)
+	}
+	// Кроме тестов файл содержит строки-комментарии
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("ошибка чтения urltestdata.json: %v", err)
+	}
+
+	passed, total := 0, 0
+	for _, entry := range entries {
+		var test urlTest
+		if json.Unmarshal(entry, &test) != nil {
+			continue
+		}
+		total++
+		if got, ok := runURLTest(test); ok {
+			passed++
+		} else if testing.Verbose() {
+			base := "null"
+			if test.Base != nil {
+				base = *test.Base
+			}
+			t.Logf("%q относительно %q: %s", test.Input, base, got)
+		}
+	}
+	t.Logf("urltestdata.json: пройдено %d из %d", passed, total)
+	if passed < urlTestMinimum {
+		t.Errorf("urltestdata.json: пройдено %d тестов, ожидалось не меньше %d", passed, urlTestMinimum)
+	}
+}
+
+// runURLTest выполняет тест и возвращает описание расхождения
+func runURLTest(test urlTest) (string, bool) {
+	var base *URL
+	if test.Base != nil {
+		var err error
+		if base, err = Parse(*test.Base, nil); err != nil {
+			return fmt.Sprintf("базовый адрес не разобран: %v", err), test.Failure
+		}
+	}
+	u, err := Parse(test.Input, base)
+	if test.Failure {
+		if err == nil {
+			return fmt.Sprintf("ожидалась ошибка, получено %q", u.String()), false
+		}
+		return "", true
+	}
+	if err != nil {
+		return fmt.Sprintf("ошибка %v, ожидалось %q", err, test.Href), false
+	}
+
+	fields := []struct {
+		name, got, want string
+	}{
+		{"href", u.String(), test.Href},
+		{"protocol", u.Protocol(), test.Protocol},
+		{"username", u.Username, test.Username},
+		{"password", u.Password, test.Password},
+		{"host", u.HostPort(), test.Host},
+		{"hostname", u.Hostname(), test.Hostname},
+		{"port", u.PortString(), test.Port},
+		{"pathname", u.Pathname(), test.Pathname},
+		{"search", u.Search(), test.Search},
+		{"hash", u.Hash(), test.Hash},
+	}
+	if test.Origin != nil {
+		fields = append(fields, struct{ name, got, want string }{"origin", u.Origin(), *test.Origin})
+	}
+	for _, f := range fields {
+		if f.got != f.want {
+			return fmt.Sprintf("%s: получено %q, ожидалось %q", f.name, f.got, f.want), false
+		}
+	}
+	return "", true
+}
@@ -1,9 +1,6 @@
 package ui
 
 import (
-	"fmt"
-	"net/url"
-
 	"github.com/zserge/lorca"
 )
 